@@ -255,11 +255,22 @@ func New(header Header, txs Txs, plan Plan, prevState State) Block {
 	}
 }
 
-// Hash returns the 256-bit SHA2 Hash of the Header and Data.
+// Hash returns the 256-bit SHA2 Hash of the Header and Data. It is computed
+// over a deterministic encoding of the contents of the Block, and is preserved
+// when the Block is marshaled and unmarshaled, so it can be used to compare
+// Blocks without comparing their contents.
 func (block Block) Hash() id.Hash {
 	return block.hash
 }
 
+// IsEmpty returns true if the Block is a valid Block without any Txs. Empty
+// Blocks are proposed when there are no pending transactions, so that the
+// chain stays live, and are committed like any other Block. They must not be
+// confused with the InvalidBlock, which is never committed.
+func (block Block) IsEmpty() bool {
+	return !block.hash.Equal(InvalidHash) && len(block.txs) == 0
+}
+
 // Header of the Block.
 func (block Block) Header() Header {
 	return block.header
@@ -302,6 +313,14 @@ type Height int64
 // Round in which a block was proposed.
 type Round int64
 
+// ConsensusThreshold returns the number of matching votes that are needed for
+// consensus among n signatories. It is 2f+1, where f = (n-1)/3 is the maximum
+// number of faulty signatories that can be tolerated by n signatories. Any
+// lower threshold cannot guarantee safety.
+func ConsensusThreshold(n int) int {
+	return 2*((n-1)/3) + 1
+}
+
 // Define some default invalid values.
 var (
 	InvalidHash      = id.Hash{}
@@ -368,6 +368,26 @@ var _ = Describe("Block", func() {
 	})
 
 	Context("Block", func() {
+		Context("when checking whether a block is empty", func() {
+			It("should only return true for valid blocks without txs", func() {
+				test := func() bool {
+					header := RandomBlockHeader(Standard)
+					emptyBlock := New(header, nil, RandomBytesSlice(), RandomBytesSlice())
+					Expect(emptyBlock.IsEmpty()).Should(BeTrue())
+					Expect(emptyBlock.Hash()).ShouldNot(Equal(InvalidHash))
+
+					txs := Txs(RandomBytesSlice())
+					if len(txs) == 0 {
+						txs = Txs{0}
+					}
+					Expect(New(header, txs, nil, nil).IsEmpty()).Should(BeFalse())
+					Expect(InvalidBlock.IsEmpty()).Should(BeFalse())
+					return true
+				}
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
 		Context("when stringifying random blocks", func() {
 			Context("when blocks are equal", func() {
 				It("should return equal strings", func() {
@@ -463,6 +483,31 @@ var _ = Describe("Block", func() {
 				})
 			})
 
+			Context("when marshaling and then unmarshaling", func() {
+				It("should return a block with a hash that is equal to the hash of its contents", func() {
+					test := func() bool {
+						block := RandomBlock(RandomBlockKind())
+
+						data, err := block.MarshalBinary()
+						Expect(err).NotTo(HaveOccurred())
+						binaryBlock := Block{}
+						Expect(binaryBlock.UnmarshalBinary(data)).Should(Succeed())
+
+						data, err = json.Marshal(block)
+						Expect(err).NotTo(HaveOccurred())
+						jsonBlock := Block{}
+						Expect(json.Unmarshal(data, &jsonBlock)).Should(Succeed())
+
+						for _, newBlock := range []Block{binaryBlock, jsonBlock} {
+							Expect(newBlock.Hash()).Should(Equal(block.Hash()))
+							Expect(newBlock.Hash()).Should(Equal(ComputeHash(newBlock.Header(), newBlock.Txs(), newBlock.Plan(), newBlock.PreviousState())))
+						}
+						return true
+					}
+					Expect(quick.Check(test, nil)).Should(Succeed())
+				})
+			})
+
 			Context("when the header, data, and previous state are unequal", func() {
 				It("should return a block with computed hashes that are unequal", func() {
 					test := func() bool {
@@ -481,4 +526,22 @@ var _ = Describe("Block", func() {
 			})
 		})
 	})
+
+	Context("Consensus threshold", func() {
+		It("should return 2f+1 for 3f+1 signatories", func() {
+			Expect(ConsensusThreshold(1)).Should(Equal(1))
+			Expect(ConsensusThreshold(4)).Should(Equal(3))
+			Expect(ConsensusThreshold(7)).Should(Equal(5))
+			Expect(ConsensusThreshold(10)).Should(Equal(7))
+		})
+
+		It("should be more than two thirds of the signatories", func() {
+			test := func(f uint8) bool {
+				n := 3*int(f) + 1
+				Expect(3 * ConsensusThreshold(n)).Should(BeNumerically(">", 2*n))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
 })
@@ -0,0 +1,189 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/renproject/id"
+)
+
+// The canonical encoding is a binary encoding with exactly one encoding for
+// every value, so that hashes and signatures computed over it are
+// byte-identical across implementations. Fields are written in the order in
+// which they are declared. Integers are fixed-width and little-endian, hashes
+// and signatures are written as fixed-length byte arrays, and variable-length
+// byte slices are prefixed with their length as a uint64. Empty and nil byte
+// slices have the same encoding. Decoding is strict: truncated data, and
+// trailing data, are rejected.
+
+// MarshalCanonical returns the canonical encoding of the Header. The
+// signatories are written in order, because their order defines the proposer
+// schedule.
+func (header Header) MarshalCanonical() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := header.writeCanonical(buf); err != nil {
+		return buf.Bytes(), err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a Header.
+func (header *Header) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := header.readCanonical(buf); err != nil {
+		return err
+	}
+	return CheckCanonicalEOF(buf)
+}
+
+func (header Header) writeCanonical(buf *bytes.Buffer) error {
+	fields := []interface{}{
+		header.kind,
+		header.parentHash,
+		header.baseHash,
+		header.txsRef,
+		header.planRef,
+		header.prevStateRef,
+		header.height,
+		header.round,
+		header.timestamp,
+		uint64(len(header.signatories)),
+	}
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot write header: %v", err)
+		}
+	}
+	for _, sig := range header.signatories {
+		if err := binary.Write(buf, binary.LittleEndian, sig); err != nil {
+			return fmt.Errorf("cannot write header.signatories data: %v", err)
+		}
+	}
+	return nil
+}
+
+func (header *Header) readCanonical(buf *bytes.Buffer) error {
+	fields := []interface{}{
+		&header.kind,
+		&header.parentHash,
+		&header.baseHash,
+		&header.txsRef,
+		&header.planRef,
+		&header.prevStateRef,
+		&header.height,
+		&header.round,
+		&header.timestamp,
+	}
+	for _, field := range fields {
+		if err := binary.Read(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot read header: %v", err)
+		}
+	}
+	var lenSignatories uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenSignatories); err != nil {
+		return fmt.Errorf("cannot read header.signatories len: %v", err)
+	}
+	if lenSignatories > uint64(buf.Len()/len(id.Signatory{})) {
+		return fmt.Errorf("cannot read header.signatories data: expected %v signatories, got %v bytes", lenSignatories, buf.Len())
+	}
+	header.signatories = nil
+	if lenSignatories > 0 {
+		header.signatories = make(id.Signatories, lenSignatories)
+		for i := range header.signatories {
+			if err := binary.Read(buf, binary.LittleEndian, &header.signatories[i]); err != nil {
+				return fmt.Errorf("cannot read header.signatories data: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalCanonical returns the canonical encoding of the Block: its hash,
+// followed by its Header, Txs, Plan, and previous State.
+func (block Block) MarshalCanonical() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, block.hash); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write block.hash: %v", err)
+	}
+	if err := block.header.writeCanonical(buf); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write block.header: %v", err)
+	}
+	if err := WriteCanonicalBytes(buf, block.txs); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write block.txs: %v", err)
+	}
+	if err := WriteCanonicalBytes(buf, block.plan); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write block.plan: %v", err)
+	}
+	if err := WriteCanonicalBytes(buf, block.prevState); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write block.prevState: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a Block. Like
+// UnmarshalBinary, it does not recompute the hash of the Block.
+func (block *Block) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := binary.Read(buf, binary.LittleEndian, &block.hash); err != nil {
+		return fmt.Errorf("cannot read block.hash: %v", err)
+	}
+	if err := block.header.readCanonical(buf); err != nil {
+		return fmt.Errorf("cannot read block.header: %v", err)
+	}
+	txs, err := ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read block.txs: %v", err)
+	}
+	plan, err := ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read block.plan: %v", err)
+	}
+	prevState, err := ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read block.prevState: %v", err)
+	}
+	block.txs, block.plan, block.prevState = txs, plan, prevState
+	return CheckCanonicalEOF(buf)
+}
+
+// WriteCanonicalBytes writes the canonical encoding of a variable-length byte
+// slice: its length as a uint64, followed by its bytes. It is used to build
+// the canonical encodings of types that embed variable-length data.
+func WriteCanonicalBytes(buf *bytes.Buffer, data []byte) error {
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(data))); err != nil {
+		return fmt.Errorf("cannot write len: %v", err)
+	}
+	if _, err := buf.Write(data); err != nil {
+		return fmt.Errorf("cannot write data: %v", err)
+	}
+	return nil
+}
+
+// ReadCanonicalBytes reads a variable-length byte slice written by
+// WriteCanonicalBytes. It returns nil for an empty byte slice, and an error if
+// the length is greater than the number of remaining bytes.
+func ReadCanonicalBytes(buf *bytes.Buffer) ([]byte, error) {
+	var length uint64
+	if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("cannot read len: %v", err)
+	}
+	if length > uint64(buf.Len()) {
+		return nil, fmt.Errorf("cannot read data: expected %v bytes, got %v bytes", length, buf.Len())
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	data := make([]byte, length)
+	copy(data, buf.Next(int(length)))
+	return data, nil
+}
+
+// CheckCanonicalEOF returns an error if there are bytes remaining after a
+// canonical encoding has been decoded.
+func CheckCanonicalEOF(buf *bytes.Buffer) error {
+	if buf.Len() != 0 {
+		return fmt.Errorf("unexpected %v trailing bytes", buf.Len())
+	}
+	return nil
+}
@@ -1 +1,73 @@
 package block_test
+
+import (
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/block"
+	. "github.com/renproject/hyperdrive/testutil"
+)
+
+var _ = Describe("Canonical encoding", func() {
+	Context("when canonically marshaling a random block", func() {
+		It("should equal itself after unmarshaling, and marshal to the same bytes", func() {
+			test := func() bool {
+				block := RandomBlock(RandomBlockKind())
+				data, err := block.MarshalCanonical()
+				Expect(err).NotTo(HaveOccurred())
+
+				var newBlock Block
+				Expect(newBlock.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newBlock.Hash()).Should(Equal(block.Hash()))
+				Expect(newBlock.Equal(block)).Should(BeTrue())
+
+				newData, err := newBlock.MarshalCanonical()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(newData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should equal itself after marshaling a header and then unmarshaling", func() {
+			test := func() bool {
+				header := RandomBlockHeader(RandomBlockKind())
+				data, err := header.MarshalCanonical()
+				Expect(err).NotTo(HaveOccurred())
+
+				var newHeader Header
+				Expect(newHeader.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newHeader.String()).Should(Equal(header.String()))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when canonically unmarshaling malformed data", func() {
+		It("should reject truncated data", func() {
+			test := func() bool {
+				data, err := RandomBlock(RandomBlockKind()).MarshalCanonical()
+				Expect(err).NotTo(HaveOccurred())
+
+				var newBlock Block
+				Expect(newBlock.UnmarshalCanonical(data[:len(data)-1])).ShouldNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should reject trailing data", func() {
+			test := func(trailing byte) bool {
+				data, err := RandomBlock(RandomBlockKind()).MarshalCanonical()
+				Expect(err).NotTo(HaveOccurred())
+
+				var newBlock Block
+				Expect(newBlock.UnmarshalCanonical(append(data, trailing))).ShouldNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package hyperdrive
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/replica"
+)
+
+// ProposerEquivocation is evidence that a proposer signed two different
+// proposals at the same height and round.
+type ProposerEquivocation = replica.ProposerEquivocation
+
+// VoteEquivocation is evidence that a signatory signed two different prevotes,
+// or two different precommits, at the same height and round.
+type VoteEquivocation = replica.VoteEquivocation
+
+// An EquivocationAggregator collects evidence of equivocation from the Replicas
+// of all Shards, keyed by the Signatory that equivocated. A Signatory that
+// equivocates on one Shard is likely to misbehave on others, so the evidence is
+// useful to every Shard of which the Signatory is a member. It is safe for
+// concurrent use.
+type EquivocationAggregator struct {
+	mu       *sync.RWMutex
+	evidence map[Signatory]map[Shard]map[equivocationKey]ProposerEquivocation
+}
+
+type equivocationKey struct {
+	height block.Height
+	round  block.Round
+}
+
+// NewEquivocationAggregator returns an EquivocationAggregator that has not
+// collected any evidence.
+func NewEquivocationAggregator() *EquivocationAggregator {
+	return &EquivocationAggregator{
+		mu:       new(sync.RWMutex),
+		evidence: map[Signatory]map[Shard]map[equivocationKey]ProposerEquivocation{},
+	}
+}
+
+// DidEquivocate collects evidence of equivocation from the Replica of a
+// Shard. Only the first evidence at each height and round of a Shard is kept,
+// so that reporting the same misbehaviour more than once does not count it
+// more than once.
+func (aggregator *EquivocationAggregator) DidEquivocate(shard Shard, equivocation ProposerEquivocation) {
+	aggregator.mu.Lock()
+	defer aggregator.mu.Unlock()
+
+	signatory := equivocation.First.Signatory()
+	if _, ok := aggregator.evidence[signatory]; !ok {
+		aggregator.evidence[signatory] = map[Shard]map[equivocationKey]ProposerEquivocation{}
+	}
+	if _, ok := aggregator.evidence[signatory][shard]; !ok {
+		aggregator.evidence[signatory][shard] = map[equivocationKey]ProposerEquivocation{}
+	}
+	key := equivocationKey{height: equivocation.First.Height(), round: equivocation.First.Round()}
+	if _, ok := aggregator.evidence[signatory][shard][key]; !ok {
+		aggregator.evidence[signatory][shard][key] = equivocation
+	}
+}
+
+// Evidence returns the evidence of equivocation by the Signatory that has been
+// collected from each Shard. Shards on which the Signatory has not equivocated
+// are omitted.
+func (aggregator *EquivocationAggregator) Evidence(signatory Signatory) map[Shard][]ProposerEquivocation {
+	aggregator.mu.RLock()
+	defer aggregator.mu.RUnlock()
+
+	evidence := make(map[Shard][]ProposerEquivocation, len(aggregator.evidence[signatory]))
+	for shard, equivocations := range aggregator.evidence[signatory] {
+		evidence[shard] = make([]ProposerEquivocation, 0, len(equivocations))
+		for _, equivocation := range equivocations {
+			evidence[shard] = append(evidence[shard], equivocation)
+		}
+	}
+	return evidence
+}
+
+// Misbehaviour returns the total number of times that the Signatory has been
+// seen equivocating, across all Shards.
+func (aggregator *EquivocationAggregator) Misbehaviour(signatory Signatory) int {
+	aggregator.mu.RLock()
+	defer aggregator.mu.RUnlock()
+
+	n := 0
+	for _, equivocations := range aggregator.evidence[signatory] {
+		n += len(equivocations)
+	}
+	return n
+}
@@ -0,0 +1,63 @@
+package hyperdrive_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/testutil"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive"
+	. "github.com/renproject/hyperdrive/testutil/replica"
+)
+
+var _ = Describe("EquivocationAggregator", func() {
+
+	newEquivocation := func(key *ecdsa.PrivateKey, height block.Height, round block.Round) ProposerEquivocation {
+		first := process.NewPropose(height, round, testutil.RandomBlock(block.Standard), block.InvalidRound)
+		Expect(process.Sign(first, *key)).Should(Succeed())
+		second := process.NewPropose(height, round, testutil.RandomBlock(block.Standard), block.InvalidRound)
+		Expect(process.Sign(second, *key)).Should(Succeed())
+		return ProposerEquivocation{First: first, Second: second}
+	}
+
+	Context("when a signatory equivocates on multiple shards", func() {
+		It("should aggregate the evidence from all shards", func() {
+			key, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			signatory := id.NewSignatory(key.PublicKey)
+			other, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			aggregator := NewEquivocationAggregator()
+			shard1, shard2 := RandomShard(), RandomShard()
+			aggregator.DidEquivocate(shard1, newEquivocation(key, 1, 0))
+			aggregator.DidEquivocate(shard1, newEquivocation(key, 2, 1))
+			aggregator.DidEquivocate(shard2, newEquivocation(key, 1, 0))
+			aggregator.DidEquivocate(shard2, newEquivocation(other, 1, 0))
+
+			// Reporting the same height and round again is not counted again
+			aggregator.DidEquivocate(shard2, newEquivocation(key, 1, 0))
+
+			Expect(aggregator.Misbehaviour(signatory)).Should(Equal(3))
+			Expect(aggregator.Misbehaviour(id.NewSignatory(other.PublicKey))).Should(Equal(1))
+			Expect(aggregator.Misbehaviour(testutil.RandomSignatory())).Should(Equal(0))
+
+			evidence := aggregator.Evidence(signatory)
+			Expect(evidence).Should(HaveLen(2))
+			Expect(evidence[shard1]).Should(HaveLen(2))
+			Expect(evidence[shard2]).Should(HaveLen(1))
+			for _, equivocations := range evidence {
+				for _, equivocation := range equivocations {
+					Expect(equivocation.First.Signatory()).Should(Equal(signatory))
+					Expect(equivocation.Second.Signatory()).Should(Equal(signatory))
+				}
+			}
+		})
+	})
+})
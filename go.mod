@@ -6,8 +6,9 @@ require (
 	github.com/ethereum/go-ethereum v1.9.5
 	github.com/onsi/ginkgo v1.10.1
 	github.com/onsi/gomega v1.7.0
+	github.com/prometheus/client_golang v1.2.1
 	github.com/renproject/id v0.1.1
 	github.com/renproject/phi v0.1.0
 	github.com/sirupsen/logrus v1.4.2
-	golang.org/x/crypto v0.0.0-20190911031432-227b76d455e7
+	go.uber.org/goleak v1.1.11
 )
@@ -5,12 +5,12 @@ package hyperdrive
 
 import (
 	"crypto/ecdsa"
+	"fmt"
 
 	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/hyperdrive/replica"
 	"github.com/renproject/id"
-	"github.com/renproject/phi"
 )
 
 type (
@@ -65,22 +65,37 @@ type (
 	Blockchain   = process.Blockchain
 	Process      = process.Process
 	ProcessState = process.State
+	// A Signer produces signatures on behalf of a Signatory. Implementations
+	// can keep the private key outside of the process, in an HSM or behind a
+	// remote signer.
+	Signer = process.Signer
+	// A VoteExtender returns an opaque extension that is signed with every
+	// Prevote and Precommit.
+	VoteExtender = process.VoteExtender
 )
 
 type (
-	Messages       = replica.Messages
-	Message        = replica.Message
-	Shards         = replica.Shards
-	Shard          = replica.Shard
-	Options        = replica.Options
-	Replicas       = replica.Replicas
-	Replica        = replica.Replica
-	ProcessStorage = replica.ProcessStorage
-	BlockStorage   = replica.BlockStorage
-	BlockIterator  = replica.BlockIterator
-	Validator      = replica.Validator
-	Observer       = replica.Observer
-	Broadcaster    = replica.Broadcaster
+	Messages         = replica.Messages
+	Message          = replica.Message
+	Shards           = replica.Shards
+	Shard            = replica.Shard
+	ValidatorSet     = replica.ValidatorSet
+	LightCommitProof = replica.LightCommitProof
+	Options          = replica.Options
+	Replicas         = replica.Replicas
+	ReplicaSet       = replica.ReplicaSet
+	Replica          = replica.Replica
+	Status           = replica.Status
+	ProcessStorage   = replica.ProcessStorage
+	Watermark        = replica.Watermark
+	WatermarkStorage = replica.WatermarkStorage
+	BlockStorage     = replica.BlockStorage
+	BlockIterator    = replica.BlockIterator
+	BlockBuilder     = replica.BlockBuilder
+	Validator        = replica.Validator
+	Observer         = replica.Observer
+	Broadcaster      = replica.Broadcaster
+	PeerBroadcaster  = replica.PeerBroadcaster
 )
 
 var (
@@ -89,6 +104,23 @@ var (
 	NewSignatory = id.NewSignatory
 )
 
+var (
+	// ErrWrongShard is returned when a Message is received for a Shard that is
+	// not maintained by any of the Replicas.
+	ErrWrongShard = replica.ErrWrongShard
+	// ErrInvalidSignatory is returned when a Message is received from a
+	// Signatory that is not a member of the Shard.
+	ErrInvalidSignatory = replica.ErrInvalidSignatory
+	// ErrInvalidSignature is returned when a Message is received with a
+	// Signature that cannot be verified against its Signatory.
+	ErrInvalidSignature = replica.ErrInvalidSignature
+	// ErrStaleHeight is returned when a Message is received for a Height that
+	// has already been committed.
+	ErrStaleHeight = replica.ErrStaleHeight
+	// ErrDuplicate is returned when a Message has already been received.
+	ErrDuplicate = replica.ErrDuplicate
+)
+
 var (
 	StandardBlockKind = block.Standard
 	RebaseBlockKind   = block.Rebase
@@ -102,11 +134,13 @@ var (
 type Hyperdrive interface {
 	Start()
 	Rebase(sigs Signatories)
-	HandleMessage(message Message)
+	HandleMessage(message Message) error
+	Equivocations() *EquivocationAggregator
 }
 
 type hyperdrive struct {
-	replicas map[Shard]Replica
+	replicas      *replica.ReplicaSet
+	equivocations *EquivocationAggregator
 }
 
 // New returns a new `Hyperdrive` instance that wraps multiple replica
@@ -114,9 +148,14 @@ type hyperdrive struct {
 // instances will use the same interfaces and private key. Replicas will not be
 // created for shards for which the replica is not a signatory. This means that
 // rebasing can shuffle Signatories, but it cannot introduce new ones or remove
-// existing ones (this will be supported in future updates).
+// existing ones (this will be supported in future updates). Evidence of
+// equivocation that is seen by any replica instance is collected by the
+// `EquivocationAggregator` returned by `Equivocations`, after it has been
+// passed to the `OnProposerEquivocation` callback of the `Options`. An error is
+// returned if a replica instance cannot be created, for example because the
+// `Options` are not valid for the signatories of the Shard.
 //
-//  hyper := hyperdrive.New(
+//  hyper, err := hyperdrive.New(
 //      hyperdrive.Options{},
 //      pStorage,
 //      bStorage,
@@ -127,6 +166,9 @@ type hyperdrive struct {
 //      shards,
 //      privKey,
 //  )
+//  if err != nil {
+//      log.Fatalf("cannot create hyperdrive: %v", err)
+//  }
 //  hyper.Start()
 //  for {
 //      select {
@@ -136,45 +178,65 @@ type hyperdrive struct {
 //          if !ok {
 //              break
 //          }
-//          hyper.HandleMessage(message)
+//          if err := hyper.HandleMessage(message); err != nil {
+//              log.Printf("bad message: %v", err)
+//          }
 //      }
 //  }
-func New(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shards Shards, privKey ecdsa.PrivateKey) Hyperdrive {
-	replicas := make(map[Shard]Replica, len(shards))
+func New(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shards Shards, privKey ecdsa.PrivateKey) (Hyperdrive, error) {
+	return NewWithSigner(options, pStorage, blockStorage, blockIterator, validator, observer, broadcaster, shards, process.NewECDSASigner(privKey))
+}
+
+// NewWithSigner returns a new `Hyperdrive` instance in the same way as New, but
+// all replica instances sign using the Signer instead of a private key.
+func NewWithSigner(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shards Shards, signer Signer) (Hyperdrive, error) {
+	replicas := make(Replicas, 0, len(shards))
+	equivocations := NewEquivocationAggregator()
 	for _, shard := range shards {
 		if observer.IsSignatory(shard) {
-			replicas[shard] = replica.New(options, pStorage, blockStorage, blockIterator, validator, observer, broadcaster, shard, privKey)
+			r, err := replica.NewWithSigner(optionsWithEquivocations(options, equivocations, shard), pStorage, blockStorage, blockIterator, validator, observer, broadcaster, shard, signer)
+			if err != nil {
+				return nil, fmt.Errorf("cannot create replica for shard=%v: %v", shard, err)
+			}
+			replicas = append(replicas, r)
 		}
 	}
 	return &hyperdrive{
-		replicas: replicas,
+		replicas:      replica.NewReplicaSet(replicas...),
+		equivocations: equivocations,
+	}, nil
+}
+
+// optionsWithEquivocations returns a copy of the `Options` that feeds evidence
+// of equivocation on the Shard into the `EquivocationAggregator`.
+func optionsWithEquivocations(options Options, equivocations *EquivocationAggregator, shard Shard) Options {
+	onProposerEquivocation := options.OnProposerEquivocation
+	options.OnProposerEquivocation = func(equivocation ProposerEquivocation) {
+		if onProposerEquivocation != nil {
+			onProposerEquivocation(equivocation)
+		}
+		equivocations.DidEquivocate(shard, equivocation)
 	}
+	return options
 }
 
 // Start all replicas in the `Hyperdrive` instance. All replicas will be started
 // in parallel. This must be done before shards can be rebased, and before
 // messages can be handled.
 func (hyper *hyperdrive) Start() {
-	phi.ParForAll(hyper.replicas, func(shard Shard) {
-		replica := hyper.replicas[shard]
-		replica.Start()
-	})
+	hyper.replicas.Start()
 }
 
 func (hyper *hyperdrive) Rebase(sigs Signatories) {
-	for shard, replica := range hyper.replicas {
-		replica.Rebase(sigs)
-		hyper.replicas[shard] = replica
-	}
+	hyper.replicas.Rebase(sigs)
 }
 
-func (hyper *hyperdrive) HandleMessage(message Message) {
-	replica, ok := hyper.replicas[message.Shard]
-	if !ok {
-		return
-	}
-	defer func() {
-		hyper.replicas[message.Shard] = replica
-	}()
-	replica.HandleMessage(message)
+// Equivocations returns the `EquivocationAggregator` that collects evidence of
+// equivocation from all replicas.
+func (hyper *hyperdrive) Equivocations() *EquivocationAggregator {
+	return hyper.equivocations
+}
+
+func (hyper *hyperdrive) HandleMessage(message Message) error {
+	return hyper.replicas.HandleMessage(message)
 }
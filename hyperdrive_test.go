@@ -562,7 +562,10 @@ func NewNode(logger logrus.FieldLogger, shards Shards, pk *ecdsa.PrivateKey, bro
 	iter := NewMockBlockIterator(store)
 	validator := NewMockValidator(store)
 	observer := NewMockObserver(store, isSignatory)
-	hd := New(option, store, store, iter, validator, observer, broadcaster, shards, *pk)
+	hd, err := New(option, store, store, iter, validator, observer, broadcaster, shards, *pk)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create hyperdrive: %v", err))
+	}
 
 	return &Node{
 		logger:     logger,
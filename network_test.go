@@ -0,0 +1,60 @@
+package hyperdrive_test
+
+import (
+	"github.com/renproject/hyperdrive/block"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil/replica"
+)
+
+var _ = Describe("ReplicaNetwork", func() {
+
+	Context("when 4 replicas (f=1) are stepped", func() {
+		It("should commit the same block at height 1 on every replica", func() {
+			network := NewReplicaNetwork(4, 1)
+			network.Start()
+			defer network.Close()
+
+			height, err := network.Step()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).Should(Equal(block.Height(1)))
+
+			blocks := network.CommittedBlocks(1)
+			Expect(blocks).Should(HaveLen(4))
+			for _, committedBlock := range blocks {
+				Expect(committedBlock.Hash()).ShouldNot(Equal(block.InvalidHash))
+				Expect(committedBlock.Hash()).Should(Equal(blocks[0].Hash()))
+			}
+		})
+	})
+
+	Context("when 1 of 4 replicas (f=1) equivocates", func() {
+		It("should commit on the honest replicas, and produce evidence of the equivocation", func() {
+			network := NewReplicaNetwork(4, 1)
+			network.SetBehavior(0, Equivocate)
+			network.Start()
+			defer network.Close()
+
+			height, err := network.Step()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(height).Should(Equal(block.Height(1)))
+
+			blocks := network.CommittedBlocks(1)
+			for _, committedBlock := range blocks[1:] {
+				Expect(committedBlock.Hash()).ShouldNot(Equal(block.InvalidHash))
+				Expect(committedBlock.Hash()).Should(Equal(blocks[1].Hash()))
+			}
+
+			for i := 1; i < 4; i++ {
+				evidence := network.VoteEquivocations(i)
+				Expect(evidence).ShouldNot(BeEmpty())
+				for _, equivocation := range evidence {
+					Expect(equivocation.First.Signatory()).Should(Equal(network.Signatory(0)))
+					Expect(equivocation.Second.Signatory()).Should(Equal(network.Signatory(0)))
+					Expect(equivocation.First.BlockHash()).ShouldNot(Equal(equivocation.Second.BlockHash()))
+				}
+			}
+		})
+	})
+})
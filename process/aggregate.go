@@ -0,0 +1,152 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// An Aggregator is a Verifier for a signature scheme in which the signatures of
+// many signatories can be combined into one signature. This lets a Polka or a
+// LatestCommit be stored as one compact proof, instead of with one signature
+// per signatory.
+type Aggregator interface {
+	Verifier
+
+	// Aggregate combines signatures into one signature.
+	Aggregate(sigs [][]byte) ([]byte, error)
+	// VerifyAggregate verifies that a signature is the aggregate of the
+	// signatures over the sighashes, where the i-th sighash was signed by the
+	// i-th signatory.
+	VerifyAggregate(hashes [][]byte, sig []byte, signatories []id.Signatory) error
+}
+
+// An AggregatedPolka is a Polka in which the signatures of the prevotes have
+// been aggregated into one signature. The prevotes are kept without their
+// signatures, because they are needed to compute the sighashes that were
+// signed.
+type AggregatedPolka struct {
+	Prevotes Polka
+	Sig      id.Signature
+}
+
+// Aggregate the signatures of the prevotes in the Polka using an Aggregator.
+// The Polka is not modified, and the AggregatedPolka must still be verified.
+func (polka Polka) Aggregate(aggregator Aggregator) (AggregatedPolka, error) {
+	if err := polka.check(1, polka.signatories()); err != nil {
+		return AggregatedPolka{}, err
+	}
+	prevotes := make(Polka, len(polka))
+	sigs := make([][]byte, len(polka))
+	for i, prevote := range polka {
+		sig := prevote.sig
+		sigs[i] = sig[:]
+		prevote.sig = id.Signature{}
+		prevotes[i] = prevote
+	}
+	sig, err := aggregateSignature(aggregator, sigs)
+	if err != nil {
+		return AggregatedPolka{}, err
+	}
+	return AggregatedPolka{Prevotes: prevotes, Sig: sig}, nil
+}
+
+// Verify that the AggregatedPolka contains at least a threshold number of
+// prevotes for the same block hash, at the same height and round, from
+// distinct signatories from the set of signatories, and that its signature is
+// the aggregate of their signatures.
+func (polka AggregatedPolka) Verify(threshold int, signatories id.Signatories, aggregator Aggregator) error {
+	if err := polka.Prevotes.check(threshold, signatories); err != nil {
+		return err
+	}
+	hashes := make([][]byte, len(polka.Prevotes))
+	for i := range polka.Prevotes {
+		hash := polka.Prevotes[i].SigHash()
+		hashes[i] = hash[:]
+	}
+	if err := aggregator.VerifyAggregate(hashes, polka.Sig[:], polka.Prevotes.signatories()); err != nil {
+		return fmt.Errorf("unverified polka: %v", err)
+	}
+	return nil
+}
+
+func (polka Polka) signatories() []id.Signatory {
+	signatories := make([]id.Signatory, len(polka))
+	for i := range polka {
+		signatories[i] = polka[i].signatory
+	}
+	return signatories
+}
+
+// An AggregatedCommit is a LatestCommit in which the signatures of the
+// precommits have been aggregated into one signature. The precommits are kept
+// without their signatures, because they are needed to compute the sighashes
+// that were signed.
+type AggregatedCommit struct {
+	Block      block.Block
+	Precommits []Precommit
+	Sig        id.Signature
+}
+
+// Aggregate the signatures of the precommits in the LatestCommit using an
+// Aggregator. The LatestCommit is not modified, and the AggregatedCommit must
+// still be verified.
+func (latestCommit LatestCommit) Aggregate(aggregator Aggregator) (AggregatedCommit, error) {
+	if err := latestCommit.check(1, latestCommit.signatories()); err != nil {
+		return AggregatedCommit{}, err
+	}
+	precommits := make([]Precommit, len(latestCommit.Precommits))
+	sigs := make([][]byte, len(latestCommit.Precommits))
+	for i, precommit := range latestCommit.Precommits {
+		sig := precommit.sig
+		sigs[i] = sig[:]
+		precommit.sig = id.Signature{}
+		precommits[i] = precommit
+	}
+	sig, err := aggregateSignature(aggregator, sigs)
+	if err != nil {
+		return AggregatedCommit{}, err
+	}
+	return AggregatedCommit{Block: latestCommit.Block, Precommits: precommits, Sig: sig}, nil
+}
+
+// Verify that the AggregatedCommit contains at least a threshold number of
+// precommits for its block, with the same rules as `LatestCommit.Verify`, and
+// that its signature is the aggregate of their signatures.
+func (commit AggregatedCommit) Verify(threshold int, signatories id.Signatories, aggregator Aggregator) error {
+	latestCommit := LatestCommit{Block: commit.Block, Precommits: commit.Precommits}
+	if err := latestCommit.check(threshold, signatories); err != nil {
+		return err
+	}
+	hashes := make([][]byte, len(commit.Precommits))
+	for i := range commit.Precommits {
+		hash := commit.Precommits[i].SigHash()
+		hashes[i] = hash[:]
+	}
+	if err := aggregator.VerifyAggregate(hashes, commit.Sig[:], latestCommit.signatories()); err != nil {
+		return fmt.Errorf("unverified commit: %v", err)
+	}
+	return nil
+}
+
+func (latestCommit LatestCommit) signatories() []id.Signatory {
+	signatories := make([]id.Signatory, len(latestCommit.Precommits))
+	for i := range latestCommit.Precommits {
+		signatories[i] = latestCommit.Precommits[i].signatory
+	}
+	return signatories
+}
+
+func aggregateSignature(aggregator Aggregator, sigs [][]byte) (id.Signature, error) {
+	aggregate, err := aggregator.Aggregate(sigs)
+	if err != nil {
+		return id.Signature{}, fmt.Errorf("error aggregating signatures: %v", err)
+	}
+	if len(aggregate) != id.SignatureLength {
+		return id.Signature{}, fmt.Errorf("invariant violation: invalid aggregate signature, expected = %v, got = %v", id.SignatureLength, len(aggregate))
+	}
+	sig := id.Signature{}
+	copy(sig[:], aggregate)
+	return sig, nil
+}
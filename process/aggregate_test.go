@@ -0,0 +1,161 @@
+package process_test
+
+import (
+	cRand "crypto/rand"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/process"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("Aggregation", func() {
+	// newBLSKeys returns n BLS private keys, the Aggregator for their public
+	// keys, and their signatories.
+	newBLSKeys := func(n int) ([]*BLSPrivateKey, Aggregator, id.Signatories) {
+		keys := make([]*BLSPrivateKey, n)
+		pubKeys := make([][]byte, n)
+		signatories := make(id.Signatories, n)
+		for i := range keys {
+			key, err := GenerateBLSKey(cRand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			keys[i] = key
+			pubKeys[i] = key.PublicKey()
+			signatories[i] = BLSSignatory(key.PublicKey())
+		}
+		aggregator, err := NewBLSAggregator(pubKeys)
+		Expect(err).NotTo(HaveOccurred())
+		return keys, aggregator, signatories
+	}
+
+	// newBLSPolka returns a polka with one prevote signed by each key.
+	newBLSPolka := func(keys []*BLSPrivateKey, blockHash id.Hash) Polka {
+		polka := make(Polka, len(keys))
+		for i, key := range keys {
+			prevote := NewPrevote(1, 0, blockHash, nil)
+			Expect(SignWith(prevote, NewBLSSigner(key))).Should(Succeed())
+			polka[i] = *prevote
+		}
+		return polka
+	}
+
+	Context("when signing with a BLS key", func() {
+		It("should verify against the public key of the signatory", func() {
+			keys, aggregator, signatories := newBLSKeys(2)
+			prevote := NewPrevote(1, 0, RandomHash(), nil)
+			Expect(SignWith(prevote, NewBLSSigner(keys[0]))).Should(Succeed())
+			Expect(prevote.Signatory()).Should(Equal(signatories[0]))
+			Expect(VerifyWith(prevote, aggregator)).Should(Succeed())
+
+			// Expect the signature to not verify for another signatory
+			hash, sig := prevote.SigHash(), prevote.Sig()
+			Expect(aggregator.Verify(hash[:], sig[:], signatories[1])).ShouldNot(Succeed())
+		})
+
+		It("should not verify against unknown public keys", func() {
+			keys, _, _ := newBLSKeys(1)
+			_, aggregator, _ := newBLSKeys(1)
+			prevote := NewPrevote(1, 0, RandomHash(), nil)
+			Expect(SignWith(prevote, NewBLSSigner(keys[0]))).Should(Succeed())
+			Expect(VerifyWith(prevote, aggregator)).ShouldNot(Succeed())
+		})
+
+		It("should reject malformed public keys", func() {
+			_, err := NewBLSAggregator([][]byte{make([]byte, BLSPublicKeyLength)})
+			Expect(err).To(HaveOccurred())
+			_, err = NewBLSAggregator([][]byte{make([]byte, BLSPublicKeyLength-1)})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when aggregating a polka", func() {
+		It("should verify against the public keys of the signatories", func() {
+			f := rand.Intn(3) + 1
+			keys, aggregator, signatories := newBLSKeys(3*f + 1)
+			polka := newBLSPolka(keys[:2*f+1], RandomHash())
+			Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+
+			aggregated, err := polka.Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(aggregated.Prevotes).Should(HaveLen(2*f + 1))
+			for _, prevote := range aggregated.Prevotes {
+				Expect(prevote.Sig()).Should(Equal(id.Signature{}))
+			}
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).Should(Succeed())
+
+			// Expect the polka to be unchanged
+			for _, prevote := range polka {
+				Expect(VerifyWith(&prevote, aggregator)).Should(Succeed())
+			}
+		})
+
+		It("should not verify under the threshold", func() {
+			f := rand.Intn(3) + 1
+			keys, aggregator, signatories := newBLSKeys(3*f + 1)
+			aggregated, err := newBLSPolka(keys[:2*f], RandomHash()).Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).ShouldNot(Succeed())
+		})
+
+		It("should not verify when a prevote is changed", func() {
+			f := rand.Intn(3) + 1
+			keys, aggregator, signatories := newBLSKeys(3*f + 1)
+			blockHash := RandomHash()
+			aggregated, err := newBLSPolka(keys[:2*f+1], blockHash).Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+
+			// Replace a prevote with a prevote that has a different sighash,
+			// but is still for the same block hash
+			changed := NewPrevoteWithExtension(1, 0, blockHash, nil, []byte("extension"))
+			Expect(SignWith(changed, NewBLSSigner(keys[0]))).Should(Succeed())
+			aggregated.Prevotes[0] = *changed
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).ShouldNot(Succeed())
+		})
+
+		It("should not verify when a signatory is missing from the aggregate", func() {
+			f := rand.Intn(3) + 1
+			keys, aggregator, signatories := newBLSKeys(3*f + 1)
+			polka := newBLSPolka(keys[:2*f+2], RandomHash())
+			aggregated, err := polka[:2*f+1].Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+
+			// Claim a prevote whose signature is not in the aggregate
+			aggregated.Prevotes = append(aggregated.Prevotes, polka[2*f+1])
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).ShouldNot(Succeed())
+		})
+
+		It("should not aggregate prevotes for different block hashes", func() {
+			keys, aggregator, _ := newBLSKeys(2)
+			polka := append(newBLSPolka(keys[:1], RandomHash()), newBLSPolka(keys[1:], RandomHash())...)
+			_, err := polka.Aggregate(aggregator)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when aggregating a commit", func() {
+		It("should verify against the public keys of the signatories", func() {
+			f := rand.Intn(3) + 1
+			keys, aggregator, signatories := newBLSKeys(3*f + 1)
+			committedBlock := RandomBlock(block.Standard)
+			precommits := make([]Precommit, 2*f+1)
+			for i := range precommits {
+				precommit := NewPrecommit(committedBlock.Header().Height(), committedBlock.Header().Round(), committedBlock.Hash())
+				Expect(SignWith(precommit, NewBLSSigner(keys[i]))).Should(Succeed())
+				precommits[i] = *precommit
+			}
+			latestCommit := LatestCommit{Block: committedBlock, Precommits: precommits}
+
+			aggregated, err := latestCommit.Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).Should(Succeed())
+			Expect(aggregated.Verify(2*f+2, signatories, aggregator)).ShouldNot(Succeed())
+
+			aggregated.Block = RandomBlock(block.Standard)
+			Expect(aggregated.Verify(2*f+1, signatories, aggregator)).ShouldNot(Succeed())
+		})
+	})
+})
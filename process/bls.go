@@ -0,0 +1,204 @@
+package process
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/renproject/id"
+)
+
+// BLSPublicKeyLength is the length of the encoding of a BLS public key.
+const BLSPublicKeyLength = 128
+
+// blsSignatureLength is the length of the encoding of a BLS signature. It is
+// one byte shorter than an `id.Signature`, so BLS signatures are padded with a
+// zero byte when they are stored in Messages.
+const blsSignatureLength = 64
+
+// A BLSPrivateKey signs sighashes using BLS signatures on the BN256 curve.
+// Signatures are points on G1, and public keys are points on G2. Unlike ECDSA
+// signatures, the BLS signatures of many signatories can be aggregated into
+// one signature.
+type BLSPrivateKey struct {
+	scalar *big.Int
+	pubKey *bn256.G2
+}
+
+// GenerateBLSKey returns a BLSPrivateKey that is generated using randomness
+// read from r.
+func GenerateBLSKey(r io.Reader) (*BLSPrivateKey, error) {
+	scalar, pubKey, err := bn256.RandomG2(r)
+	if err != nil {
+		return nil, fmt.Errorf("error generating bls key: %v", err)
+	}
+	return &BLSPrivateKey{scalar: scalar, pubKey: pubKey}, nil
+}
+
+// PublicKey returns the encoding of the public key of the BLSPrivateKey.
+func (key *BLSPrivateKey) PublicKey() []byte {
+	return key.pubKey.Marshal()
+}
+
+// BLSSignatory returns the `id.Signatory` of an encoded BLS public key, which is
+// the SHA256 hash of the encoding.
+func BLSSignatory(pubKey []byte) id.Signatory {
+	return sha256.Sum256(pubKey)
+}
+
+type blsSigner struct {
+	key       *BLSPrivateKey
+	signatory id.Signatory
+}
+
+// NewBLSSigner returns a Signer that signs using a BLSPrivateKey. Messages that
+// it signs must be verified using a Verifier returned by NewBLSAggregator.
+func NewBLSSigner(key *BLSPrivateKey) Signer {
+	return &blsSigner{
+		key:       key,
+		signatory: BLSSignatory(key.PublicKey()),
+	}
+}
+
+// Signatory implements the `Signer` interface.
+func (signer *blsSigner) Signatory() id.Signatory {
+	return signer.signatory
+}
+
+// Sign implements the `Signer` interface.
+func (signer *blsSigner) Sign(hash []byte) ([]byte, error) {
+	sig := new(bn256.G1).ScalarMult(hashToG1(signer.signatory, hash), signer.key.scalar)
+	return append(sig.Marshal(), 0), nil
+}
+
+type blsAggregator struct {
+	pubKeys map[id.Signatory]*bn256.G2
+}
+
+// NewBLSAggregator returns an Aggregator for the BLS signatures of the
+// signatories with the given encoded public keys. Signatures from any other
+// signatory are rejected. Every signatory signs the sighash together with its
+// own `id.Signatory`, so the messages in an aggregate are always distinct, and
+// public keys do not need a proof of possession.
+func NewBLSAggregator(pubKeys [][]byte) (Aggregator, error) {
+	aggregator := &blsAggregator{pubKeys: make(map[id.Signatory]*bn256.G2, len(pubKeys))}
+	for _, data := range pubKeys {
+		if len(data) != BLSPublicKeyLength {
+			return nil, fmt.Errorf("expected bls public key len=%v, got len=%v", BLSPublicKeyLength, len(data))
+		}
+		if bytes.Equal(data, make([]byte, BLSPublicKeyLength)) {
+			return nil, fmt.Errorf("bad bls public key: point at infinity")
+		}
+		pubKey := new(bn256.G2)
+		if _, err := pubKey.Unmarshal(data); err != nil {
+			return nil, fmt.Errorf("bad bls public key: %v", err)
+		}
+		aggregator.pubKeys[BLSSignatory(data)] = pubKey
+	}
+	return aggregator, nil
+}
+
+// Verify implements the `Verifier` interface.
+func (aggregator *blsAggregator) Verify(hash, sig []byte, signatory id.Signatory) error {
+	return aggregator.VerifyAggregate([][]byte{hash}, sig, []id.Signatory{signatory})
+}
+
+// Aggregate implements the `Aggregator` interface.
+func (aggregator *blsAggregator) Aggregate(sigs [][]byte) ([]byte, error) {
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("expected at least 1 signature, got 0 signatures")
+	}
+	aggregate := new(bn256.G1)
+	for i, sig := range sigs {
+		point, err := unmarshalBLSSignature(sig)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			aggregate.Set(point)
+			continue
+		}
+		aggregate.Add(aggregate, point)
+	}
+	return append(aggregate.Marshal(), 0), nil
+}
+
+// VerifyAggregate implements the `Aggregator` interface. It checks that the
+// pairing of the aggregate signature with the generator of G2 is equal to the
+// product of the pairings of every hashed message with the public key of its
+// signatory.
+func (aggregator *blsAggregator) VerifyAggregate(hashes [][]byte, sig []byte, signatories []id.Signatory) error {
+	if len(hashes) == 0 || len(hashes) != len(signatories) {
+		return fmt.Errorf("expected a sighash for every signatory, got %v sighashes and %v signatories", len(hashes), len(signatories))
+	}
+	aggregate, err := unmarshalBLSSignature(sig)
+	if err != nil {
+		return err
+	}
+	g1s := make([]*bn256.G1, 0, len(hashes)+1)
+	g2s := make([]*bn256.G2, 0, len(hashes)+1)
+	for i, signatory := range signatories {
+		pubKey, ok := aggregator.pubKeys[signatory]
+		if !ok {
+			return fmt.Errorf("unknown bls signatory=%v", signatory)
+		}
+		g1s = append(g1s, hashToG1(signatory, hashes[i]))
+		g2s = append(g2s, pubKey)
+	}
+	g1s = append(g1s, new(bn256.G1).Neg(aggregate))
+	g2s = append(g2s, new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
+	if !bn256.PairingCheck(g1s, g2s) {
+		return fmt.Errorf("bad bls signature from signatories=%v", signatories)
+	}
+	return nil
+}
+
+func unmarshalBLSSignature(sig []byte) (*bn256.G1, error) {
+	if len(sig) != id.SignatureLength || sig[blsSignatureLength] != 0 {
+		return nil, fmt.Errorf("bad bls signature: expected %v bytes and a zero byte", blsSignatureLength)
+	}
+	point := new(bn256.G1)
+	if _, err := point.Unmarshal(sig[:blsSignatureLength]); err != nil {
+		return nil, fmt.Errorf("bad bls signature: %v", err)
+	}
+	return point, nil
+}
+
+// hashToG1 maps a sighash, signed by a signatory, to a point on G1 by trying
+// successive counters until the hash is the x-coordinate of a point on the
+// curve y^2 = x^3 + 3. The group has a cofactor of 1, so every point on the
+// curve is in G1. Half of all x-coordinates are on the curve, so this almost
+// never takes more than a few tries.
+func hashToG1(signatory id.Signatory, hash []byte) *bn256.G1 {
+	three := big.NewInt(3)
+	data := make([]byte, 0, len(signatory)+len(hash)+8)
+	data = append(data, signatory[:]...)
+	data = append(data, hash...)
+	data = append(data, make([]byte, 8)...)
+	for counter := uint64(0); ; counter++ {
+		binary.LittleEndian.PutUint64(data[len(data)-8:], counter)
+		digest := sha256.Sum256(data)
+		x := new(big.Int).SetBytes(digest[:])
+		x.Mod(x, bn256.P)
+		y2 := new(big.Int).Exp(x, three, bn256.P)
+		y2.Add(y2, three)
+		y2.Mod(y2, bn256.P)
+		y := new(big.Int).ModSqrt(y2, bn256.P)
+		if y == nil || x.Sign() == 0 {
+			continue
+		}
+		encoded := make([]byte, blsSignatureLength)
+		xBytes, yBytes := x.Bytes(), y.Bytes()
+		copy(encoded[32-len(xBytes):32], xBytes)
+		copy(encoded[64-len(yBytes):], yBytes)
+		point := new(bn256.G1)
+		if _, err := point.Unmarshal(encoded); err != nil {
+			panic(fmt.Errorf("invariant violation: error mapping sighash to G1: %v", err))
+		}
+		return point
+	}
+}
@@ -0,0 +1,366 @@
+package process
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// The canonical encodings of votes, polkas, and commits follow the rules of
+// the canonical encoding of `block.Block`. The canonical encoding of a vote
+// starts with its MessageType, followed by the fields that are signed, and
+// ends with its signatory and signature. The SigHash of a vote is the SHA256
+// hash of the signed part of its canonical encoding, so signatures are
+// computed over the canonical encoding. Collections of votes are sorted by
+// signatory, and decoding rejects collections that are not sorted, or that
+// contain more than one vote from the same signatory.
+
+// MarshalCanonical returns the canonical encoding of the Prevote. The
+// NilReasons are sorted by key.
+func (prevote Prevote) MarshalCanonical() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := prevote.writeCanonicalSigned(buf); err != nil {
+		return buf.Bytes(), err
+	}
+	if err := writeCanonicalSignature(buf, prevote.signatory, prevote.sig); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write prevote: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a Prevote.
+func (prevote *Prevote) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := prevote.readCanonical(buf); err != nil {
+		return err
+	}
+	return block.CheckCanonicalEOF(buf)
+}
+
+func (prevote Prevote) writeCanonicalSigned(buf *bytes.Buffer) error {
+	fields := []interface{}{
+		MessageType(PrevoteMessageType),
+		prevote.height,
+		prevote.round,
+		prevote.blockHash,
+		uint64(len(prevote.nilReasons)),
+	}
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot write prevote: %v", err)
+		}
+	}
+	keys := make([]string, 0, len(prevote.nilReasons))
+	for key := range prevote.nilReasons {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := block.WriteCanonicalBytes(buf, []byte(key)); err != nil {
+			return fmt.Errorf("cannot write prevote.nilReasons key: %v", err)
+		}
+		if err := block.WriteCanonicalBytes(buf, prevote.nilReasons[key]); err != nil {
+			return fmt.Errorf("cannot write prevote.nilReasons val: %v", err)
+		}
+	}
+	if err := block.WriteCanonicalBytes(buf, prevote.extension); err != nil {
+		return fmt.Errorf("cannot write prevote.extension: %v", err)
+	}
+	return nil
+}
+
+func (prevote *Prevote) readCanonical(buf *bytes.Buffer) error {
+	if err := readCanonicalMessageType(buf, PrevoteMessageType); err != nil {
+		return fmt.Errorf("cannot read prevote: %v", err)
+	}
+	fields := []interface{}{
+		&prevote.height,
+		&prevote.round,
+		&prevote.blockHash,
+	}
+	for _, field := range fields {
+		if err := binary.Read(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot read prevote: %v", err)
+		}
+	}
+	var lenNilReasons uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenNilReasons); err != nil {
+		return fmt.Errorf("cannot read prevote.nilReasons len: %v", err)
+	}
+	prevote.nilReasons = nil
+	prevKey := ""
+	for i := uint64(0); i < lenNilReasons; i++ {
+		key, err := block.ReadCanonicalBytes(buf)
+		if err != nil {
+			return fmt.Errorf("cannot read prevote.nilReasons key: %v", err)
+		}
+		if i > 0 && string(key) <= prevKey {
+			return fmt.Errorf("cannot read prevote.nilReasons: key=%q is not sorted", key)
+		}
+		val, err := block.ReadCanonicalBytes(buf)
+		if err != nil {
+			return fmt.Errorf("cannot read prevote.nilReasons val: %v", err)
+		}
+		if prevote.nilReasons == nil {
+			prevote.nilReasons = NilReasons{}
+		}
+		prevote.nilReasons[string(key)] = val
+		prevKey = string(key)
+	}
+	extension, err := block.ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read prevote.extension: %v", err)
+	}
+	prevote.extension = extension
+	if err := readCanonicalSignature(buf, &prevote.signatory, &prevote.sig); err != nil {
+		return fmt.Errorf("cannot read prevote: %v", err)
+	}
+	return nil
+}
+
+// MarshalCanonical returns the canonical encoding of the Precommit.
+func (precommit Precommit) MarshalCanonical() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := precommit.writeCanonicalSigned(buf); err != nil {
+		return buf.Bytes(), err
+	}
+	if err := writeCanonicalSignature(buf, precommit.signatory, precommit.sig); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write precommit: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a Precommit.
+func (precommit *Precommit) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := precommit.readCanonical(buf); err != nil {
+		return err
+	}
+	return block.CheckCanonicalEOF(buf)
+}
+
+func (precommit Precommit) writeCanonicalSigned(buf *bytes.Buffer) error {
+	fields := []interface{}{
+		MessageType(PrecommitMessageType),
+		precommit.height,
+		precommit.round,
+		precommit.blockHash,
+	}
+	for _, field := range fields {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot write precommit: %v", err)
+		}
+	}
+	if err := block.WriteCanonicalBytes(buf, precommit.extension); err != nil {
+		return fmt.Errorf("cannot write precommit.extension: %v", err)
+	}
+	return nil
+}
+
+func (precommit *Precommit) readCanonical(buf *bytes.Buffer) error {
+	if err := readCanonicalMessageType(buf, PrecommitMessageType); err != nil {
+		return fmt.Errorf("cannot read precommit: %v", err)
+	}
+	fields := []interface{}{
+		&precommit.height,
+		&precommit.round,
+		&precommit.blockHash,
+	}
+	for _, field := range fields {
+		if err := binary.Read(buf, binary.LittleEndian, field); err != nil {
+			return fmt.Errorf("cannot read precommit: %v", err)
+		}
+	}
+	extension, err := block.ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read precommit.extension: %v", err)
+	}
+	precommit.extension = extension
+	if err := readCanonicalSignature(buf, &precommit.signatory, &precommit.sig); err != nil {
+		return fmt.Errorf("cannot read precommit: %v", err)
+	}
+	return nil
+}
+
+// MarshalCanonical returns the canonical encoding of the Polka: the number of
+// prevotes, followed by the canonical encoding of every prevote, sorted by
+// signatory. The Polka itself is not modified. An error is returned if more
+// than one prevote is from the same signatory.
+func (polka Polka) MarshalCanonical() ([]byte, error) {
+	sorted := make(Polka, len(polka))
+	copy(sorted, polka)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].signatory[:], sorted[j].signatory[:]) < 0
+	})
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(sorted))); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write polka len: %v", err)
+	}
+	for i, prevote := range sorted {
+		if i > 0 && sorted[i-1].signatory.Equal(prevote.signatory) {
+			return buf.Bytes(), fmt.Errorf("cannot write polka: duplicate signatory=%v", prevote.signatory)
+		}
+		data, err := prevote.MarshalCanonical()
+		if err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot marshal polka prevote: %v", err)
+		}
+		if err := block.WriteCanonicalBytes(buf, data); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write polka prevote: %v", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a Polka. The prevotes
+// must be sorted by signatory, without duplicates.
+func (polka *Polka) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	var lenPolka uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenPolka); err != nil {
+		return fmt.Errorf("cannot read polka len: %v", err)
+	}
+	var prevotes Polka
+	for i := uint64(0); i < lenPolka; i++ {
+		prevoteData, err := block.ReadCanonicalBytes(buf)
+		if err != nil {
+			return fmt.Errorf("cannot read polka prevote: %v", err)
+		}
+		prevote := Prevote{}
+		if err := prevote.UnmarshalCanonical(prevoteData); err != nil {
+			return fmt.Errorf("cannot unmarshal polka prevote: %v", err)
+		}
+		if i > 0 && bytes.Compare(prevotes[i-1].signatory[:], prevote.signatory[:]) >= 0 {
+			return fmt.Errorf("cannot read polka: signatory=%v is not sorted", prevote.signatory)
+		}
+		prevotes = append(prevotes, prevote)
+	}
+	if err := block.CheckCanonicalEOF(buf); err != nil {
+		return err
+	}
+	*polka = prevotes
+	return nil
+}
+
+// MarshalCanonical returns the canonical encoding of the LatestCommit: the
+// canonical encoding of the committed `block.Block`, followed by the number of
+// precommits, and the canonical encoding of every precommit, sorted by
+// signatory. The LatestCommit itself is not modified. An error is returned if
+// more than one precommit is from the same signatory.
+func (latestCommit LatestCommit) MarshalCanonical() ([]byte, error) {
+	sorted := make([]Precommit, len(latestCommit.Precommits))
+	copy(sorted, latestCommit.Precommits)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].signatory[:], sorted[j].signatory[:]) < 0
+	})
+
+	buf := new(bytes.Buffer)
+	blockData, err := latestCommit.Block.MarshalCanonical()
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot marshal latestCommit.Block: %v", err)
+	}
+	if err := block.WriteCanonicalBytes(buf, blockData); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write latestCommit.Block: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(sorted))); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write latestCommit.Precommits len: %v", err)
+	}
+	for i, precommit := range sorted {
+		if i > 0 && sorted[i-1].signatory.Equal(precommit.signatory) {
+			return buf.Bytes(), fmt.Errorf("cannot write latestCommit.Precommits: duplicate signatory=%v", precommit.signatory)
+		}
+		data, err := precommit.MarshalCanonical()
+		if err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot marshal latestCommit.Precommits: %v", err)
+		}
+		if err := block.WriteCanonicalBytes(buf, data); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write latestCommit.Precommits: %v", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCanonical decodes the canonical encoding of a LatestCommit. The
+// precommits must be sorted by signatory, without duplicates.
+func (latestCommit *LatestCommit) UnmarshalCanonical(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	blockData, err := block.ReadCanonicalBytes(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read latestCommit.Block: %v", err)
+	}
+	committedBlock := block.Block{}
+	if err := committedBlock.UnmarshalCanonical(blockData); err != nil {
+		return fmt.Errorf("cannot unmarshal latestCommit.Block: %v", err)
+	}
+	var lenPrecommits uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenPrecommits); err != nil {
+		return fmt.Errorf("cannot read latestCommit.Precommits len: %v", err)
+	}
+	var precommits []Precommit
+	for i := uint64(0); i < lenPrecommits; i++ {
+		precommitData, err := block.ReadCanonicalBytes(buf)
+		if err != nil {
+			return fmt.Errorf("cannot read latestCommit.Precommits: %v", err)
+		}
+		precommit := Precommit{}
+		if err := precommit.UnmarshalCanonical(precommitData); err != nil {
+			return fmt.Errorf("cannot unmarshal latestCommit.Precommits: %v", err)
+		}
+		if i > 0 && bytes.Compare(precommits[i-1].signatory[:], precommit.signatory[:]) >= 0 {
+			return fmt.Errorf("cannot read latestCommit.Precommits: signatory=%v is not sorted", precommit.signatory)
+		}
+		precommits = append(precommits, precommit)
+	}
+	if err := block.CheckCanonicalEOF(buf); err != nil {
+		return err
+	}
+	latestCommit.Block = committedBlock
+	latestCommit.Precommits = precommits
+	return nil
+}
+
+// canonicalSigned returns the signed part of the canonical encoding of a vote,
+// which is the data that is hashed to compute its sighash.
+func canonicalSigned(writeSigned func(*bytes.Buffer) error) []byte {
+	buf := new(bytes.Buffer)
+	if err := writeSigned(buf); err != nil {
+		// Writing to a `bytes.Buffer` cannot fail
+		panic(fmt.Errorf("invariant violation: %v", err))
+	}
+	return buf.Bytes()
+}
+
+func writeCanonicalSignature(buf *bytes.Buffer, signatory id.Signatory, sig id.Signature) error {
+	if err := binary.Write(buf, binary.LittleEndian, signatory); err != nil {
+		return fmt.Errorf("cannot write signatory: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, sig); err != nil {
+		return fmt.Errorf("cannot write sig: %v", err)
+	}
+	return nil
+}
+
+func readCanonicalSignature(buf *bytes.Buffer, signatory *id.Signatory, sig *id.Signature) error {
+	if err := binary.Read(buf, binary.LittleEndian, signatory); err != nil {
+		return fmt.Errorf("cannot read signatory: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, sig); err != nil {
+		return fmt.Errorf("cannot read sig: %v", err)
+	}
+	return nil
+}
+
+func readCanonicalMessageType(buf *bytes.Buffer, expected MessageType) error {
+	var messageType MessageType
+	if err := binary.Read(buf, binary.LittleEndian, &messageType); err != nil {
+		return fmt.Errorf("cannot read message type: %v", err)
+	}
+	if messageType != expected {
+		return fmt.Errorf("expected message type=%v, got message type=%v", expected, messageType)
+	}
+	return nil
+}
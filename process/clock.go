@@ -0,0 +1,26 @@
+package process
+
+import "time"
+
+// A Clock tells the current time, and waits for durations of time to pass. It
+// allows time to be injected into a Process, so that timeouts can be tested
+// deterministically.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+// NewSystemClock returns a Clock that uses the system time.
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
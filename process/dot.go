@@ -0,0 +1,101 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// stepEdge is a transition between two Steps, and the condition (and action)
+// that causes it.
+type stepEdge struct {
+	from, to Step
+	label    string
+}
+
+// stepNodes describes what the Process is waiting for at each Step.
+var stepNodes = []struct {
+	step  Step
+	name  string
+	label string
+}{
+	{StepPropose, "StepPropose", "waiting for propose"},
+	{StepPrevote, "StepPrevote", "waiting for polka"},
+	{StepPrecommit, "StepPrecommit", "waiting for commit"},
+}
+
+// stepEdges are the transitions made by the handlers of the Process. They
+// must be kept in sync with the handlers, so that the exported graph matches
+// the implementation.
+var stepEdges = []stepEdge{
+	// handlePropose
+	{StepPropose, StepPrevote, "Propose{h, r, b, -1} from proposer:\nprevote b (or nil if invalid)"},
+	// checkProposeInCurrentHeightAndRoundWithPrevotes
+	{StepPropose, StepPrevote, "Propose{h, r, b, vr} from proposer\nand 2f+1 Prevote{h, vr, b}:\nprevote b (or nil if invalid)"},
+	// resign
+	{StepPropose, StepPrevote, "no proposal to propose:\nresign and prevote nil"},
+	// handleResign
+	{StepPropose, StepPrevote, "Resign{h, r} from proposer:\nprevote nil"},
+	// timeoutPropose
+	{StepPropose, StepPrevote, "TimeoutPropose{h, r}:\nprevote nil"},
+	// handlePrevote
+	{StepPrevote, StepPrevote, "2f+1 Prevote{h, r, *}:\nschedule TimeoutPrevote"},
+	// checkProposeInCurrentHeightAndRoundWithPrevotesForTheFirstTime
+	{StepPrevote, StepPrecommit, "Propose{h, r, b, *} from proposer\nand 2f+1 Prevote{h, r, b}:\nlock and precommit b"},
+	// handlePrevote
+	{StepPrevote, StepPrecommit, "2f+1 Prevote{h, r, nil}:\nprecommit nil"},
+	// timeoutPrevote
+	{StepPrevote, StepPrecommit, "TimeoutPrevote{h, r}:\nprecommit nil"},
+}
+
+// anyStepEdgeLabels are the transitions made by the handlers of the Process
+// that start a new round, or a new height, regardless of the current Step.
+var anyStepEdgeLabels = []string{
+	// handlePrecommit and timeoutPrecommit
+	"2f+1 Precommit{h, r, *}\nthen TimeoutPrecommit{h, r}:\nstart round r+1",
+	// checkNilCommitInCurrentHeightAndRound
+	"2f+1 Precommit{h, r, nil}:\nstart round r+1",
+	// checkRoundSkip
+	"f+1 *{h, r', *} with r' > r:\nstart round r'",
+	// checkProposeInCurrentHeightWithPrecommits
+	"Propose{h, r', b, *} from proposer\nand 2f+1 Precommit{h, r', b}:\ncommit b and start height h+1",
+}
+
+// ExportDOT returns a Graphviz DOT description of the Steps of the Process,
+// and the transitions between them. The current Step of the State is
+// highlighted, and the graph is labelled with the current `block.Height` and
+// `block.Round`.
+func (state State) ExportDOT() []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "digraph Process {\n")
+	fmt.Fprintf(buf, "\tlabel=%q;\n", fmt.Sprintf("height=%v, round=%v", state.CurrentHeight, state.CurrentRound))
+	fmt.Fprintf(buf, "\tnode [shape=box];\n")
+	for _, node := range stepNodes {
+		if node.step == state.CurrentStep {
+			fmt.Fprintf(buf, "\t%v [label=%q, style=filled];\n", node.name, node.name+"\n"+node.label)
+			continue
+		}
+		fmt.Fprintf(buf, "\t%v [label=%q];\n", node.name, node.name+"\n"+node.label)
+	}
+
+	edges := make([]stepEdge, 0, len(stepEdges)+len(stepNodes)*len(anyStepEdgeLabels))
+	edges = append(edges, stepEdges...)
+	for _, node := range stepNodes {
+		for _, label := range anyStepEdgeLabels {
+			edges = append(edges, stepEdge{node.step, StepPropose, label})
+		}
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(buf, "\t%v -> %v [label=%q];\n", stepNodeName(edge.from), stepNodeName(edge.to), edge.label)
+	}
+	fmt.Fprintf(buf, "}\n")
+	return buf.Bytes()
+}
+
+func stepNodeName(step Step) string {
+	for _, node := range stepNodes {
+		if node.step == step {
+			return node.name
+		}
+	}
+	panic(fmt.Errorf("invariant violation: unexpected step=%v", step))
+}
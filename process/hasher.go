@@ -0,0 +1,68 @@
+package process
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/id"
+)
+
+// A Hasher computes the sighashes of Messages, the digests that are signed by
+// a Signer and authenticated by a Verifier. It decouples the hashing scheme
+// from the messages, so that schemes other than SHA256 can be selected when
+// integrating with other chains. Every Process in a Shard must use the same
+// Hasher, or none of their signatures will verify.
+type Hasher interface {
+	Hash(data []byte) id.Hash
+}
+
+type sha256Hasher struct{}
+
+// NewSHA256Hasher returns a Hasher that uses SHA256. This is the default
+// hashing scheme, and is the scheme used by `Message.SigHash`.
+func NewSHA256Hasher() Hasher {
+	return sha256Hasher{}
+}
+
+// Hash implements the `Hasher` interface.
+func (sha256Hasher) Hash(data []byte) id.Hash {
+	return sha256.Sum256(data)
+}
+
+type keccak256Hasher struct{}
+
+// NewKeccak256Hasher returns a Hasher that uses the Keccak256 hash used by
+// Ethereum.
+func NewKeccak256Hasher() Hasher {
+	return keccak256Hasher{}
+}
+
+// Hash implements the `Hasher` interface.
+func (keccak256Hasher) Hash(data []byte) id.Hash {
+	hash := id.Hash{}
+	copy(hash[:], crypto.Keccak256(data))
+	return hash
+}
+
+// SigHashWith returns the sighash of a message using a Hasher. The data that
+// is hashed is the same for all Hashers, so `SigHashWith(m, NewSHA256Hasher())`
+// is equal to `m.SigHash()`.
+func SigHashWith(m Message, hasher Hasher) id.Hash {
+	switch m := m.(type) {
+	case *Propose:
+		return hasher.Hash([]byte(m.String()))
+	case *Prevote:
+		return hasher.Hash(canonicalSigned(m.writeCanonicalSigned))
+	case *Precommit:
+		return hasher.Hash(canonicalSigned(m.writeCanonicalSigned))
+	case *Resign:
+		return hasher.Hash([]byte(m.String()))
+	case *CatchUpRequest:
+		return hasher.Hash([]byte(m.String()))
+	case *CommitRange:
+		return hasher.Hash([]byte(m.String()))
+	default:
+		panic(fmt.Errorf("invariant violation: unexpected message type=%T", m))
+	}
+}
@@ -0,0 +1,73 @@
+package process_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/process"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var _ = Describe("Hasher", func() {
+	newSigner := func() Signer {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return NewECDSASigner(*privateKey)
+	}
+
+	Context("when computing sighashes with the SHA256 hasher", func() {
+		It("should equal the sighash of the message", func() {
+			test := func() bool {
+				msg := RandomMessage(RandomMessageType())
+				Expect(SigHashWith(msg, NewSHA256Hasher())).Should(Equal(msg.SigHash()))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when signing and verifying with the same hasher", func() {
+		It("should verify under the SHA256 hasher", func() {
+			test := func() bool {
+				msg := RandomMessage(RandomMessageType())
+				Expect(SignWithHasher(msg, newSigner(), NewSHA256Hasher())).Should(Succeed())
+				Expect(VerifyWithHasher(msg, NewECDSAVerifier(), NewSHA256Hasher())).Should(Succeed())
+				Expect(Verify(msg)).Should(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should verify under the Keccak256 hasher", func() {
+			test := func() bool {
+				msg := RandomMessage(RandomMessageType())
+				Expect(SignWithHasher(msg, newSigner(), NewKeccak256Hasher())).Should(Succeed())
+				Expect(VerifyWithHasher(msg, NewECDSAVerifier(), NewKeccak256Hasher())).Should(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when signing and verifying with different hashers", func() {
+		It("should not verify", func() {
+			test := func() bool {
+				msg := RandomMessage(RandomMessageType())
+				Expect(SignWithHasher(msg, newSigner(), NewKeccak256Hasher())).Should(Succeed())
+				Expect(VerifyWithHasher(msg, NewECDSAVerifier(), NewSHA256Hasher())).ShouldNot(Succeed())
+				Expect(Verify(msg)).ShouldNot(Succeed())
+
+				msg = RandomMessage(RandomMessageType())
+				Expect(SignWithHasher(msg, newSigner(), NewSHA256Hasher())).Should(Succeed())
+				Expect(VerifyWithHasher(msg, NewECDSAVerifier(), NewKeccak256Hasher())).ShouldNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+})
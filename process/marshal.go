@@ -88,6 +88,7 @@ func (propose Propose) MarshalJSON() ([]byte, error) {
 		Block        block.Block  `json:"block"`
 		ValidRound   block.Round  `json:"validRound"`
 		LatestCommit LatestCommit `json:"latestCommit"`
+		Polka        Polka        `json:"polka"`
 	}{
 		propose.sig,
 		propose.signatory,
@@ -96,6 +97,7 @@ func (propose Propose) MarshalJSON() ([]byte, error) {
 		propose.block,
 		propose.validRound,
 		propose.latestCommit,
+		propose.polka,
 	})
 }
 
@@ -110,6 +112,7 @@ func (propose *Propose) UnmarshalJSON(data []byte) error {
 		Block        block.Block  `json:"block"`
 		ValidRound   block.Round  `json:"validRound"`
 		LatestCommit LatestCommit `json:"latestCommit"`
+		Polka        Polka        `json:"polka"`
 	}{}
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return err
@@ -121,6 +124,7 @@ func (propose *Propose) UnmarshalJSON(data []byte) error {
 	propose.block = tmp.Block
 	propose.validRound = tmp.ValidRound
 	propose.latestCommit = tmp.LatestCommit
+	propose.polka = tmp.Polka
 	return nil
 }
 
@@ -179,6 +183,22 @@ func (propose Propose) MarshalBinary() ([]byte, error) {
 			return buf.Bytes(), fmt.Errorf("cannot write propose.latestCommit precommit data: %v", err)
 		}
 	}
+	lenPolka := len(propose.polka)
+	if err := binary.Write(buf, binary.LittleEndian, uint64(lenPolka)); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write propose.polka len: %v", err)
+	}
+	for i := 0; i < lenPolka; i++ {
+		prevoteBytes, err := propose.polka[i].MarshalBinary()
+		if err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot marshal propose.polka prevote: %v", err)
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint64(len(prevoteBytes))); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write propose.polka prevote len: %v", err)
+		}
+		if err := binary.Write(buf, binary.LittleEndian, prevoteBytes); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write propose.polka prevote data: %v", err)
+		}
+	}
 	return buf.Bytes(), nil
 }
 
@@ -241,6 +261,25 @@ func (propose *Propose) UnmarshalBinary(data []byte) error {
 			return fmt.Errorf("cannot unmarshal propose.latestCommit precommit: %v", err)
 		}
 	}
+	var lenPolka uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenPolka); err != nil {
+		return fmt.Errorf("cannot read propose.polka len: %v", err)
+	}
+	if lenPolka > 0 {
+		propose.polka = make(Polka, lenPolka)
+	}
+	for i := uint64(0); i < lenPolka; i++ {
+		if err := binary.Read(buf, binary.LittleEndian, &numBytes); err != nil {
+			return fmt.Errorf("cannot read propose.polka prevote len: %v", err)
+		}
+		prevoteBytes := make([]byte, numBytes)
+		if _, err := buf.Read(prevoteBytes); err != nil {
+			return fmt.Errorf("cannot read propose.polka prevote data: %v", err)
+		}
+		if err := propose.polka[i].UnmarshalBinary(prevoteBytes); err != nil {
+			return fmt.Errorf("cannot unmarshal propose.polka prevote: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -253,6 +292,7 @@ func (prevote Prevote) MarshalJSON() ([]byte, error) {
 		Round      block.Round  `json:"round"`
 		BlockHash  id.Hash      `json:"blockHash"`
 		NilReasons NilReasons   `json:"nilReasons"`
+		Extension  []byte       `json:"extension"`
 	}{
 		prevote.sig,
 		prevote.signatory,
@@ -260,6 +300,7 @@ func (prevote Prevote) MarshalJSON() ([]byte, error) {
 		prevote.round,
 		prevote.blockHash,
 		prevote.nilReasons,
+		prevote.extension,
 	})
 }
 
@@ -273,6 +314,7 @@ func (prevote *Prevote) UnmarshalJSON(data []byte) error {
 		Round      block.Round  `json:"round"`
 		BlockHash  id.Hash      `json:"blockHash"`
 		NilReasons NilReasons   `json:"nilReasons"`
+		Extension  []byte       `json:"extension"`
 	}{}
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return err
@@ -283,6 +325,7 @@ func (prevote *Prevote) UnmarshalJSON(data []byte) error {
 	prevote.round = tmp.Round
 	prevote.blockHash = tmp.BlockHash
 	prevote.nilReasons = tmp.NilReasons
+	prevote.extension = copyExtension(tmp.Extension)
 	return nil
 }
 
@@ -315,6 +358,9 @@ func (prevote Prevote) MarshalBinary() ([]byte, error) {
 	if err := binary.Write(buf, binary.LittleEndian, nilReasonsBytes); err != nil {
 		return buf.Bytes(), fmt.Errorf("cannot write nilReasonsBytes data: %v", err)
 	}
+	if err := marshalExtension(buf, prevote.extension); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write prevote.extension: %v", err)
+	}
 	return buf.Bytes(), nil
 }
 
@@ -350,6 +396,11 @@ func (prevote *Prevote) UnmarshalBinary(data []byte) error {
 			return fmt.Errorf("cannot unmarshal nilReasonsBytes: %v", err)
 		}
 	}
+	extension, err := unmarshalExtension(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read prevote.extension: %v", err)
+	}
+	prevote.extension = extension
 	return nil
 }
 
@@ -362,12 +413,14 @@ func (precommit Precommit) MarshalJSON() ([]byte, error) {
 		Height    block.Height `json:"height"`
 		Round     block.Round  `json:"round"`
 		BlockHash id.Hash      `json:"blockHash"`
+		Extension []byte       `json:"extension"`
 	}{
 		precommit.sig,
 		precommit.signatory,
 		precommit.height,
 		precommit.round,
 		precommit.blockHash,
+		precommit.extension,
 	})
 }
 
@@ -380,6 +433,7 @@ func (precommit *Precommit) UnmarshalJSON(data []byte) error {
 		Height    block.Height `json:"height"`
 		Round     block.Round  `json:"round"`
 		BlockHash id.Hash      `json:"blockHash"`
+		Extension []byte       `json:"extension"`
 	}{}
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return err
@@ -389,6 +443,7 @@ func (precommit *Precommit) UnmarshalJSON(data []byte) error {
 	precommit.height = tmp.Height
 	precommit.round = tmp.Round
 	precommit.blockHash = tmp.BlockHash
+	precommit.extension = copyExtension(tmp.Extension)
 	return nil
 }
 
@@ -411,6 +466,9 @@ func (precommit Precommit) MarshalBinary() ([]byte, error) {
 	if err := binary.Write(buf, binary.LittleEndian, precommit.blockHash); err != nil {
 		return buf.Bytes(), fmt.Errorf("cannot write precommit.blockHash: %v", err)
 	}
+	if err := marshalExtension(buf, precommit.extension); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write precommit.extension: %v", err)
+	}
 	return buf.Bytes(), nil
 }
 
@@ -433,7 +491,302 @@ func (precommit *Precommit) UnmarshalBinary(data []byte) error {
 	if err := binary.Read(buf, binary.LittleEndian, &precommit.blockHash); err != nil {
 		return fmt.Errorf("cannot read precommit.blockHash: %v", err)
 	}
+	extension, err := unmarshalExtension(buf)
+	if err != nil {
+		return fmt.Errorf("cannot read precommit.extension: %v", err)
+	}
+	precommit.extension = extension
+	return nil
+}
+
+// marshalExtension writes the length of a vote extension, followed by its
+// bytes.
+func marshalExtension(buf *bytes.Buffer, extension []byte) error {
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(extension))); err != nil {
+		return fmt.Errorf("cannot write len: %v", err)
+	}
+	if _, err := buf.Write(extension); err != nil {
+		return fmt.Errorf("cannot write data: %v", err)
+	}
+	return nil
+}
+
+// unmarshalExtension reads a vote extension written by marshalExtension. Votes
+// that were marshaled before extensions existed end without an extension, and
+// are read as having no extension.
+func unmarshalExtension(buf *bytes.Buffer) ([]byte, error) {
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	var lenExtension uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenExtension); err != nil {
+		return nil, fmt.Errorf("cannot read len: %v", err)
+	}
+	if lenExtension > uint64(buf.Len()) {
+		return nil, fmt.Errorf("cannot read data: expected %v bytes, got %v bytes", lenExtension, buf.Len())
+	}
+	return copyExtension(buf.Next(int(lenExtension))), nil
+}
+
+// MarshalJSON implements the `json.Marshaler` interface for the `Resign` type.
+func (resign Resign) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Sig       id.Signature `json:"sig"`
+		Signatory id.Signatory `json:"signatory"`
+		Height    block.Height `json:"height"`
+		Round     block.Round  `json:"round"`
+	}{
+		resign.sig,
+		resign.signatory,
+		resign.height,
+		resign.round,
+	})
+}
+
+// UnmarshalJSON implements the `json.Unmarshaler` interface for the `Resign`
+// type.
+func (resign *Resign) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Sig       id.Signature `json:"sig"`
+		Signatory id.Signatory `json:"signatory"`
+		Height    block.Height `json:"height"`
+		Round     block.Round  `json:"round"`
+	}{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	resign.sig = tmp.Sig
+	resign.signatory = tmp.Signatory
+	resign.height = tmp.Height
+	resign.round = tmp.Round
+	return nil
+}
 
+// MarshalBinary implements the `encoding.BinaryMarshaler` interface for the
+// `Resign` type.
+func (resign Resign) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, resign.sig); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write resign.sig: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, resign.signatory); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write resign.signatory: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, resign.height); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write resign.height: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, resign.round); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write resign.round: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the `encoding.BinaryUnmarshaler` interface for the
+// `Resign` type.
+func (resign *Resign) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := binary.Read(buf, binary.LittleEndian, &resign.sig); err != nil {
+		return fmt.Errorf("cannot read resign.sig: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &resign.signatory); err != nil {
+		return fmt.Errorf("cannot read resign.signatory: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &resign.height); err != nil {
+		return fmt.Errorf("cannot read resign.height: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &resign.round); err != nil {
+		return fmt.Errorf("cannot read resign.round: %v", err)
+	}
+	return nil
+}
+
+// MarshalJSON implements the `json.Marshaler` interface for the
+// `CatchUpRequest` type.
+func (request CatchUpRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Sig       id.Signature `json:"sig"`
+		Signatory id.Signatory `json:"signatory"`
+		Height    block.Height `json:"height"`
+	}{
+		request.sig,
+		request.signatory,
+		request.height,
+	})
+}
+
+// UnmarshalJSON implements the `json.Unmarshaler` interface for the
+// `CatchUpRequest` type.
+func (request *CatchUpRequest) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Sig       id.Signature `json:"sig"`
+		Signatory id.Signatory `json:"signatory"`
+		Height    block.Height `json:"height"`
+	}{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	request.sig = tmp.Sig
+	request.signatory = tmp.Signatory
+	request.height = tmp.Height
+	return nil
+}
+
+// MarshalBinary implements the `encoding.BinaryMarshaler` interface for the
+// `CatchUpRequest` type.
+func (request CatchUpRequest) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, request.sig); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write request.sig: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, request.signatory); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write request.signatory: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, request.height); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write request.height: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the `encoding.BinaryUnmarshaler` interface for the
+// `CatchUpRequest` type.
+func (request *CatchUpRequest) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := binary.Read(buf, binary.LittleEndian, &request.sig); err != nil {
+		return fmt.Errorf("cannot read request.sig: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &request.signatory); err != nil {
+		return fmt.Errorf("cannot read request.signatory: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &request.height); err != nil {
+		return fmt.Errorf("cannot read request.height: %v", err)
+	}
+	return nil
+}
+
+// MarshalJSON implements the `json.Marshaler` interface for the `CommitRange`
+// type.
+func (commitRange CommitRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Sig       id.Signature   `json:"sig"`
+		Signatory id.Signatory   `json:"signatory"`
+		Commits   []LatestCommit `json:"commits"`
+	}{
+		commitRange.sig,
+		commitRange.signatory,
+		commitRange.commits,
+	})
+}
+
+// UnmarshalJSON implements the `json.Unmarshaler` interface for the
+// `CommitRange` type.
+func (commitRange *CommitRange) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Sig       id.Signature   `json:"sig"`
+		Signatory id.Signatory   `json:"signatory"`
+		Commits   []LatestCommit `json:"commits"`
+	}{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	commitRange.sig = tmp.Sig
+	commitRange.signatory = tmp.Signatory
+	commitRange.commits = tmp.Commits
+	return nil
+}
+
+// MarshalBinary implements the `encoding.BinaryMarshaler` interface for the
+// `CommitRange` type.
+func (commitRange CommitRange) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, commitRange.sig); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write commitRange.sig: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, commitRange.signatory); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write commitRange.signatory: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(commitRange.commits))); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write commitRange.commits len: %v", err)
+	}
+	for _, commit := range commitRange.commits {
+		commitBlockData, err := commit.Block.MarshalBinary()
+		if err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot marshal commitRange commit block: %v", err)
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint64(len(commitBlockData))); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write commitRange commit block len: %v", err)
+		}
+		if err := binary.Write(buf, binary.LittleEndian, commitBlockData); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write commitRange commit block data: %v", err)
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint64(len(commit.Precommits))); err != nil {
+			return buf.Bytes(), fmt.Errorf("cannot write commitRange commit precommits len: %v", err)
+		}
+		for _, precommit := range commit.Precommits {
+			precommitData, err := precommit.MarshalBinary()
+			if err != nil {
+				return buf.Bytes(), fmt.Errorf("cannot marshal commitRange commit precommit: %v", err)
+			}
+			if err := binary.Write(buf, binary.LittleEndian, uint64(len(precommitData))); err != nil {
+				return buf.Bytes(), fmt.Errorf("cannot write commitRange commit precommit len: %v", err)
+			}
+			if err := binary.Write(buf, binary.LittleEndian, precommitData); err != nil {
+				return buf.Bytes(), fmt.Errorf("cannot write commitRange commit precommit data: %v", err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the `encoding.BinaryUnmarshaler` interface for the
+// `CommitRange` type.
+func (commitRange *CommitRange) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewBuffer(data)
+	if err := binary.Read(buf, binary.LittleEndian, &commitRange.sig); err != nil {
+		return fmt.Errorf("cannot read commitRange.sig: %v", err)
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &commitRange.signatory); err != nil {
+		return fmt.Errorf("cannot read commitRange.signatory: %v", err)
+	}
+	var lenCommits uint64
+	if err := binary.Read(buf, binary.LittleEndian, &lenCommits); err != nil {
+		return fmt.Errorf("cannot read commitRange.commits len: %v", err)
+	}
+	commitRange.commits = nil
+	if lenCommits > 0 {
+		commitRange.commits = make([]LatestCommit, lenCommits)
+	}
+	for i := uint64(0); i < lenCommits; i++ {
+		var numBytes uint64
+		if err := binary.Read(buf, binary.LittleEndian, &numBytes); err != nil {
+			return fmt.Errorf("cannot read commitRange commit block len: %v", err)
+		}
+		commitBlockBytes := make([]byte, numBytes)
+		if _, err := buf.Read(commitBlockBytes); err != nil {
+			return fmt.Errorf("cannot read commitRange commit block data: %v", err)
+		}
+		if err := commitRange.commits[i].Block.UnmarshalBinary(commitBlockBytes); err != nil {
+			return fmt.Errorf("cannot unmarshal commitRange commit block: %v", err)
+		}
+		var lenPrecommits uint64
+		if err := binary.Read(buf, binary.LittleEndian, &lenPrecommits); err != nil {
+			return fmt.Errorf("cannot read commitRange commit precommits len: %v", err)
+		}
+		if lenPrecommits > 0 {
+			commitRange.commits[i].Precommits = make([]Precommit, lenPrecommits)
+		}
+		for j := uint64(0); j < lenPrecommits; j++ {
+			if err := binary.Read(buf, binary.LittleEndian, &numBytes); err != nil {
+				return fmt.Errorf("cannot read commitRange commit precommit len: %v", err)
+			}
+			precommitBytes := make([]byte, numBytes)
+			if _, err := buf.Read(precommitBytes); err != nil {
+				return fmt.Errorf("cannot read commitRange commit precommit data: %v", err)
+			}
+			if err := commitRange.commits[i].Precommits[j].UnmarshalBinary(precommitBytes); err != nil {
+				return fmt.Errorf("cannot unmarshal commitRange commit precommit: %v", err)
+			}
+		}
+	}
 	return nil
 }
 
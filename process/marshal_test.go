@@ -1,10 +1,15 @@
 package process_test
 
 import (
+	"math/rand"
+	"testing/quick"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/renproject/hyperdrive/process"
 	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
 )
 
 var _ = Describe("Marshaling", func() {
@@ -46,4 +51,146 @@ var _ = Describe("Marshaling", func() {
 			}
 		})
 	})
+
+	Context("when canonically marshaling votes", func() {
+		It("should round-trip prevotes exactly", func() {
+			test := func() bool {
+				prevote := RandomSignedMessage(PrevoteMessageType).(*Prevote)
+				data, err := prevote.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+
+				newPrevote := Prevote{}
+				Expect(newPrevote.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newPrevote.String()).Should(Equal(prevote.String()))
+				Expect(newPrevote.Signatory()).Should(Equal(prevote.Signatory()))
+				Expect(newPrevote.Sig()).Should(Equal(prevote.Sig()))
+				Expect(Verify(&newPrevote)).Should(Succeed())
+
+				newData, err := newPrevote.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should round-trip precommits exactly", func() {
+			test := func() bool {
+				precommit := RandomSignedMessage(PrecommitMessageType).(*Precommit)
+				data, err := precommit.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+
+				newPrecommit := Precommit{}
+				Expect(newPrecommit.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newPrecommit.String()).Should(Equal(precommit.String()))
+				Expect(newPrecommit.Signatory()).Should(Equal(precommit.Signatory()))
+				Expect(newPrecommit.Sig()).Should(Equal(precommit.Sig()))
+				Expect(Verify(&newPrecommit)).Should(Succeed())
+
+				newData, err := newPrecommit.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should not depend on the insertion order of nil reasons", func() {
+			test := func(keys []string, blockHash [32]byte) bool {
+				nilReasons, reversed := NilReasons{}, NilReasons{}
+				for _, key := range keys {
+					nilReasons[key] = []byte(key)
+				}
+				for i := len(keys) - 1; i >= 0; i-- {
+					reversed[keys[i]] = []byte(keys[i])
+				}
+				data, err := NewPrevote(1, 0, blockHash, nilReasons).MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				reversedData, err := NewPrevote(1, 0, blockHash, reversed).MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reversedData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should not be possible to unmarshal a prevote as a precommit", func() {
+			data, err := RandomSignedMessage(PrevoteMessageType).(*Prevote).MarshalCanonical()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(new(Precommit).UnmarshalCanonical(data)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when canonically marshaling polkas and commits", func() {
+		It("should not depend on the order of the prevotes in a polka", func() {
+			test := func() bool {
+				polka := make(Polka, 1+rand.Intn(10))
+				for i := range polka {
+					polka[i] = *RandomSignedMessage(PrevoteMessageType).(*Prevote)
+				}
+				data, err := polka.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+
+				shuffled := make(Polka, len(polka))
+				for i, j := range rand.Perm(len(polka)) {
+					shuffled[i] = polka[j]
+				}
+				shuffledData, err := shuffled.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(shuffledData).Should(Equal(data))
+
+				// Expect the polka to round-trip exactly
+				newPolka := Polka{}
+				Expect(newPolka.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newPolka).Should(HaveLen(len(polka)))
+				newData, err := newPolka.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should not depend on the order of the precommits in a commit", func() {
+			test := func() bool {
+				commit := LatestCommit{
+					Block:      RandomBlock(block.Standard),
+					Precommits: make([]Precommit, 1+rand.Intn(10)),
+				}
+				for i := range commit.Precommits {
+					commit.Precommits[i] = *RandomSignedMessage(PrecommitMessageType).(*Precommit)
+				}
+				data, err := commit.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+
+				shuffled := LatestCommit{
+					Block:      commit.Block,
+					Precommits: make([]Precommit, len(commit.Precommits)),
+				}
+				for i, j := range rand.Perm(len(commit.Precommits)) {
+					shuffled.Precommits[i] = commit.Precommits[j]
+				}
+				shuffledData, err := shuffled.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(shuffledData).Should(Equal(data))
+
+				// Expect the commit to round-trip exactly
+				newCommit := LatestCommit{}
+				Expect(newCommit.UnmarshalCanonical(data)).Should(Succeed())
+				Expect(newCommit.Block.Equal(commit.Block)).Should(BeTrue())
+				Expect(newCommit.Precommits).Should(HaveLen(len(commit.Precommits)))
+				newData, err := newCommit.MarshalCanonical()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newData).Should(Equal(data))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should reject polkas with more than one prevote from the same signatory", func() {
+			prevote := *RandomSignedMessage(PrevoteMessageType).(*Prevote)
+			_, err := Polka{prevote, prevote}.MarshalCanonical()
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -1,18 +1,20 @@
 package process
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding"
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/id"
 )
 
-// MessageType distinguished between the three valid (and one invalid) messages
+// MessageType distinguished between the four valid (and one invalid) messages
 // types that are supported during consensus rounds.
 type MessageType uint64
 
@@ -27,8 +29,37 @@ const (
 	// PrecommitMessageType is used by messages that are precommitting for block
 	// hashes (or nil precommitting).
 	PrecommitMessageType = 3
+	// ResignMessageType is used by messages that resign from proposing a block
+	// for the round.
+	ResignMessageType = 4
+	// CatchUpRequestMessageType is used by messages that request committed
+	// blocks from other processes.
+	CatchUpRequestMessageType = 5
+	// CommitRangeMessageType is used by messages that respond to catch-up
+	// requests with committed blocks.
+	CommitRangeMessageType = 6
 )
 
+// String implements the `fmt.Stringer` interface.
+func (t MessageType) String() string {
+	switch t {
+	case ProposeMessageType:
+		return "Propose"
+	case PrevoteMessageType:
+		return "Prevote"
+	case PrecommitMessageType:
+		return "Precommit"
+	case ResignMessageType:
+		return "Resign"
+	case CatchUpRequestMessageType:
+		return "CatchUpRequest"
+	case CommitRangeMessageType:
+		return "CommitRange"
+	default:
+		return "Nil"
+	}
+}
+
 // Messages is a wrapper around the `[]Message` type.
 type Messages []Message
 
@@ -67,12 +98,88 @@ type Message interface {
 	Type() MessageType
 }
 
+// A Signer produces signatures over sighashes on behalf of an `id.Signatory`.
+// It decouples the signature scheme from the messages, so that schemes other
+// than ECDSA can be selected when constructing a Process.
+type Signer interface {
+	// Signatory returns the identity on behalf of which signatures are
+	// produced.
+	Signatory() id.Signatory
+	// Sign the sighash and return the signature bytes.
+	Sign(hash []byte) ([]byte, error)
+}
+
+// A Verifier authenticates that a signature over a sighash was produced by a
+// specific `id.Signatory`. It must be compatible with the Signer used by the
+// sender.
+type Verifier interface {
+	Verify(hash, sig []byte, signatory id.Signatory) error
+}
+
+type ecdsaSigner struct {
+	privKey   ecdsa.PrivateKey
+	signatory id.Signatory
+}
+
+// NewECDSASigner returns a Signer that signs using an ECDSA private key on the
+// secp256k1 curve. This is the default signature scheme.
+func NewECDSASigner(privKey ecdsa.PrivateKey) Signer {
+	return &ecdsaSigner{
+		privKey:   privKey,
+		signatory: id.NewSignatory(privKey.PublicKey),
+	}
+}
+
+// Signatory implements the `Signer` interface.
+func (signer *ecdsaSigner) Signatory() id.Signatory {
+	return signer.signatory
+}
+
+// Sign implements the `Signer` interface.
+func (signer *ecdsaSigner) Sign(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, &signer.privKey)
+}
+
+type ecdsaVerifier struct{}
+
+// NewECDSAVerifier returns a Verifier that recovers the ECDSA public key from
+// a signature and compares its `id.Signatory` against the claimed one. This is
+// the default signature scheme.
+func NewECDSAVerifier() Verifier {
+	return ecdsaVerifier{}
+}
+
+// Verify implements the `Verifier` interface.
+func (ecdsaVerifier) Verify(hash, sig []byte, signatory id.Signatory) error {
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return fmt.Errorf("error verifying message: %v", err)
+	}
+	recovered := id.NewSignatory(*pubKey)
+	if !signatory.Equal(recovered) {
+		return fmt.Errorf("bad signatory: expected signatory=%v, got signatory=%v", signatory, recovered)
+	}
+	return nil
+}
+
 // Sign a message using an ECDSA private key. The resulting signature will be
 // stored inside the message.
 func Sign(m Message, privKey ecdsa.PrivateKey) error {
-	sigHash := m.SigHash()
-	signatory := id.NewSignatory(privKey.PublicKey)
-	sig, err := crypto.Sign(sigHash[:], &privKey)
+	return SignWith(m, NewECDSASigner(privKey))
+}
+
+// SignWith signs a message using a Signer. The resulting signature, and the
+// `id.Signatory` of the Signer, will be stored inside the message.
+func SignWith(m Message, signer Signer) error {
+	return SignWithHasher(m, signer, NewSHA256Hasher())
+}
+
+// SignWithHasher signs the sighash of a message, computed using a Hasher,
+// using a Signer. The message must be verified using the same Hasher.
+func SignWithHasher(m Message, signer Signer, hasher Hasher) error {
+	sigHash := SigHashWith(m, hasher)
+	signatory := signer.Signatory()
+	sig, err := signer.Sign(sigHash[:])
 	if err != nil {
 		return fmt.Errorf("invariant violation: error signing message: %v", err)
 	}
@@ -90,6 +197,15 @@ func Sign(m Message, privKey ecdsa.PrivateKey) error {
 	case *Precommit:
 		m.signatory = signatory
 		copy(m.sig[:], sig)
+	case *Resign:
+		m.signatory = signatory
+		copy(m.sig[:], sig)
+	case *CatchUpRequest:
+		m.signatory = signatory
+		copy(m.sig[:], sig)
+	case *CommitRange:
+		m.signatory = signatory
+		copy(m.sig[:], sig)
 	default:
 		panic(fmt.Errorf("invariant violation: unexpected message type=%T", m))
 	}
@@ -100,18 +216,22 @@ func Sign(m Message, privKey ecdsa.PrivateKey) error {
 // is done by checking the `Message.Sig()` against the `Message.SigHash()` and
 // `Message.Signatory()`.
 func Verify(m Message) error {
-	sigHash := m.SigHash()
-	sig := m.Sig()
-	pubKey, err := crypto.SigToPub(sigHash[:], sig[:])
-	if err != nil {
-		return fmt.Errorf("error verifying message: %v", err)
-	}
+	return VerifyWith(m, NewECDSAVerifier())
+}
 
-	signatory := id.NewSignatory(*pubKey)
-	if !m.Signatory().Equal(signatory) {
-		return fmt.Errorf("bad signatory: expected signatory=%v, got signatory=%v", m.Signatory(), signatory)
-	}
-	return nil
+// VerifyWith verifies that the signature in a message is from the expected
+// signatory using a Verifier.
+func VerifyWith(m Message, verifier Verifier) error {
+	return VerifyWithHasher(m, verifier, NewSHA256Hasher())
+}
+
+// VerifyWithHasher verifies that the signature in a message is from the
+// expected signatory using a Verifier, where the sighash is computed using a
+// Hasher. It must be the same Hasher that was used to sign the message.
+func VerifyWithHasher(m Message, verifier Verifier, hasher Hasher) error {
+	sigHash := SigHashWith(m, hasher)
+	sig := m.Sig()
+	return verifier.Verify(sigHash[:], sig[:], m.Signatory())
 }
 
 // Proposes is a wrapper around the `[]Propose` type.
@@ -127,6 +247,7 @@ type Propose struct {
 	validRound block.Round
 
 	latestCommit LatestCommit
+	polka        Polka
 }
 
 // The LatestCommit can be attached to a proposal. It stores the latest
@@ -139,6 +260,119 @@ type LatestCommit struct {
 	Precommits []Precommit
 }
 
+// Extensions returns the extensions of the precommits for the committed block,
+// by signatory. Precommits without extensions, and precommits for other
+// blocks, are ignored.
+func (latestCommit LatestCommit) Extensions() map[id.Signatory][]byte {
+	extensions := map[id.Signatory][]byte{}
+	for _, precommit := range latestCommit.Precommits {
+		if len(precommit.extension) == 0 || !precommit.blockHash.Equal(latestCommit.Block.Hash()) {
+			continue
+		}
+		extensions[precommit.signatory] = precommit.extension
+	}
+	return extensions
+}
+
+// Round returns the round in which the block was committed, as claimed by the
+// precommits. This can be later than the round of the block, if the block was
+// re-proposed. It returns an invalid round if there are no precommits. Every
+// precommit retains its own round, so the claim can be checked by calling
+// Verify.
+func (latestCommit LatestCommit) Round() block.Round {
+	if len(latestCommit.Precommits) == 0 {
+		return block.InvalidRound
+	}
+	return latestCommit.Precommits[0].round
+}
+
+// Verify that the LatestCommit contains at least a threshold number of
+// precommits for its block, at the height of the block. The precommits must
+// all be at the same round, and this round must not be earlier than the round
+// of the block. Every precommit must be signed by a distinct signatory from
+// the set of signatories. This guards against fast forwarding to forged
+// commits.
+func (latestCommit LatestCommit) Verify(threshold int, signatories id.Signatories) error {
+	return latestCommit.verify(threshold, signatories, NewECDSAVerifier(), NewSHA256Hasher())
+}
+
+func (latestCommit LatestCommit) verify(threshold int, signatories id.Signatories, verifier Verifier, hasher Hasher) error {
+	if err := latestCommit.check(threshold, signatories); err != nil {
+		return err
+	}
+	for i := range latestCommit.Precommits {
+		if err := VerifyWithHasher(&latestCommit.Precommits[i], verifier, hasher); err != nil {
+			return fmt.Errorf("unverified precommit: %v", err)
+		}
+	}
+	return nil
+}
+
+// check that the LatestCommit has enough precommits for its block, from
+// distinct signatories, without verifying their signatures.
+func (latestCommit LatestCommit) check(threshold int, signatories id.Signatories) error {
+	if len(latestCommit.Precommits) < threshold {
+		return fmt.Errorf("expected at least %v precommits, got %v precommits", threshold, len(latestCommit.Precommits))
+	}
+	header := latestCommit.Block.Header()
+	round := latestCommit.Round()
+	if len(latestCommit.Precommits) > 0 && round < header.Round() {
+		return fmt.Errorf("expected precommits at round>=%v, got precommits at round=%v", header.Round(), round)
+	}
+	if err := checkPrecommitsForBlock(latestCommit.Precommits, header.Height(), round, latestCommit.Block.Hash()); err != nil {
+		return err
+	}
+
+	members := make(map[id.Signatory]struct{}, len(signatories))
+	for _, sig := range signatories {
+		members[sig] = struct{}{}
+	}
+	voters := make(map[id.Signatory]struct{}, len(latestCommit.Precommits))
+	for i := range latestCommit.Precommits {
+		precommit := &latestCommit.Precommits[i]
+		if _, ok := members[precommit.signatory]; !ok {
+			return fmt.Errorf("unexpected precommit from signatory=%v", precommit.signatory)
+		}
+		if _, ok := voters[precommit.signatory]; ok {
+			return fmt.Errorf("duplicate precommit from signatory=%v", precommit.signatory)
+		}
+		voters[precommit.signatory] = struct{}{}
+	}
+	return nil
+}
+
+// Merge returns the union of two partial LatestCommits for the same block, with
+// precommits at the same round, ordered by signatory. If both LatestCommits
+// contain a precommit from the same signatory, the precommit from this
+// LatestCommit is kept. Neither LatestCommit is modified, and the merged
+// LatestCommit must still be verified.
+func (latestCommit LatestCommit) Merge(other LatestCommit) (LatestCommit, error) {
+	if !latestCommit.Block.Hash().Equal(other.Block.Hash()) {
+		return LatestCommit{}, fmt.Errorf("expected block=%v, got block=%v", latestCommit.Block.Hash(), other.Block.Hash())
+	}
+	if len(latestCommit.Precommits) > 0 && len(other.Precommits) > 0 {
+		if err := checkPrecommitsForBlock(other.Precommits, latestCommit.Block.Header().Height(), latestCommit.Round(), latestCommit.Block.Hash()); err != nil {
+			return LatestCommit{}, err
+		}
+	}
+
+	merged := make([]Precommit, 0, len(latestCommit.Precommits)+len(other.Precommits))
+	voters := make(map[id.Signatory]struct{}, len(latestCommit.Precommits)+len(other.Precommits))
+	for _, precommits := range [][]Precommit{latestCommit.Precommits, other.Precommits} {
+		for _, precommit := range precommits {
+			if _, ok := voters[precommit.signatory]; ok {
+				continue
+			}
+			voters[precommit.signatory] = struct{}{}
+			merged = append(merged, precommit)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return bytes.Compare(merged[i].signatory[:], merged[j].signatory[:]) < 0
+	})
+	return LatestCommit{Block: latestCommit.Block, Precommits: merged}, nil
+}
+
 func NewPropose(height block.Height, round block.Round, block block.Block, validRound block.Round) *Propose {
 	return &Propose{
 		height:     height,
@@ -153,7 +387,7 @@ func (propose *Propose) Signatory() id.Signatory {
 }
 
 func (propose *Propose) SigHash() id.Hash {
-	return sha256.Sum256([]byte(propose.String()))
+	return SigHashWith(propose, NewSHA256Hasher())
 }
 
 func (propose *Propose) Sig() id.Signature {
@@ -184,6 +418,22 @@ func (propose *Propose) ValidRound() block.Round {
 	return propose.validRound
 }
 
+// LatestCommit returns the commit of the block at the previous height that is
+// attached to the Propose, so that processes that have fallen behind can fast
+// forward. It has not been verified.
+func (propose *Propose) LatestCommit() LatestCommit {
+	return propose.latestCommit
+}
+
+// Polka returns the prevotes that justify the valid round of the Propose. They
+// are embedded by the proposer so that processes that did not see the polka
+// themselves (for example, because they skipped the valid round) can still
+// accept the Propose. It is empty if the valid round is invalid, or if the
+// proposer did not embed the polka.
+func (propose *Propose) Polka() Polka {
+	return propose.polka
+}
+
 func (propose *Propose) String() string {
 	return fmt.Sprintf("Propose(Height=%v,Round=%v,BlockHash=%v,ValidRound=%v)", propose.Height(), propose.Round(), propose.BlockHash(), propose.ValidRound())
 }
@@ -191,6 +441,91 @@ func (propose *Propose) String() string {
 // Prevotes is a wrapper around the `[]Prevote` type.
 type Prevotes []Prevote
 
+// A Polka is a set of prevotes for the same block hash, at the same height and
+// round. It is only a valid justification if it has been verified. Every
+// prevote keeps its own signature. With an Aggregator, a Polka can be turned
+// into an AggregatedPolka that carries one signature instead.
+type Polka []Prevote
+
+// Verify that the Polka contains at least a threshold number of prevotes for
+// the same block hash, at the same height and round. Every prevote must be
+// signed by a distinct signatory from the set of signatories. This guards
+// against forged polkas that are embedded in messages.
+func (polka Polka) Verify(threshold int, signatories id.Signatories) error {
+	return polka.verify(threshold, signatories, NewECDSAVerifier(), NewSHA256Hasher())
+}
+
+func (polka Polka) verify(threshold int, signatories id.Signatories, verifier Verifier, hasher Hasher) error {
+	if err := polka.check(threshold, signatories); err != nil {
+		return err
+	}
+	for i := range polka {
+		if err := VerifyWithHasher(&polka[i], verifier, hasher); err != nil {
+			return fmt.Errorf("unverified prevote: %v", err)
+		}
+	}
+	return nil
+}
+
+// check that the Polka has enough prevotes for the same block hash, from
+// distinct signatories, without verifying their signatures.
+func (polka Polka) check(threshold int, signatories id.Signatories) error {
+	if len(polka) < threshold {
+		return fmt.Errorf("expected at least %v prevotes, got %v prevotes", threshold, len(polka))
+	}
+
+	members := make(map[id.Signatory]struct{}, len(signatories))
+	for _, sig := range signatories {
+		members[sig] = struct{}{}
+	}
+	voters := make(map[id.Signatory]struct{}, len(polka))
+	for i := range polka {
+		prevote := &polka[i]
+		if prevote.height != polka[0].height || prevote.round != polka[0].round || !prevote.blockHash.Equal(polka[0].blockHash) {
+			return fmt.Errorf("expected prevote=%v, got prevote=%v", polka[0].String(), prevote.String())
+		}
+		if _, ok := members[prevote.signatory]; !ok {
+			return fmt.Errorf("unexpected prevote from signatory=%v", prevote.signatory)
+		}
+		if _, ok := voters[prevote.signatory]; ok {
+			return fmt.Errorf("duplicate prevote from signatory=%v", prevote.signatory)
+		}
+		voters[prevote.signatory] = struct{}{}
+	}
+	return nil
+}
+
+// Merge returns the union of two partial Polkas for the same block hash, at
+// the same height and round, ordered by signatory. If both Polkas contain a
+// prevote from the same signatory, the prevote from this Polka is kept. This
+// is useful for reaching a threshold number of prevotes by combining the
+// prevotes seen by different processes. Neither Polka is modified, and the
+// merged Polka must still be verified.
+func (polka Polka) Merge(other Polka) (Polka, error) {
+	if len(polka) > 0 && len(other) > 0 {
+		expected, got := &polka[0], &other[0]
+		if expected.height != got.height || expected.round != got.round || !expected.blockHash.Equal(got.blockHash) {
+			return nil, fmt.Errorf("expected prevote=%v, got prevote=%v", expected.String(), got.String())
+		}
+	}
+
+	merged := make(Polka, 0, len(polka)+len(other))
+	voters := make(map[id.Signatory]struct{}, len(polka)+len(other))
+	for _, prevotes := range []Polka{polka, other} {
+		for _, prevote := range prevotes {
+			if _, ok := voters[prevote.signatory]; ok {
+				continue
+			}
+			voters[prevote.signatory] = struct{}{}
+			merged = append(merged, prevote)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return bytes.Compare(merged[i].signatory[:], merged[j].signatory[:]) < 0
+	})
+	return merged, nil
+}
+
 // Prevote for a block hash.
 type Prevote struct {
 	signatory  id.Signatory
@@ -199,6 +534,7 @@ type Prevote struct {
 	round      block.Round
 	blockHash  id.Hash
 	nilReasons NilReasons
+	extension  []byte
 }
 
 func NewPrevote(height block.Height, round block.Round, blockHash id.Hash, nilReasons NilReasons) *Prevote {
@@ -210,12 +546,23 @@ func NewPrevote(height block.Height, round block.Round, blockHash id.Hash, nilRe
 	}
 }
 
+// NewPrevoteWithExtension returns a Prevote that carries an opaque extension.
+// The extension is signed along with the Prevote. An empty extension is the
+// same as no extension.
+func NewPrevoteWithExtension(height block.Height, round block.Round, blockHash id.Hash, nilReasons NilReasons, extension []byte) *Prevote {
+	prevote := NewPrevote(height, round, blockHash, nilReasons)
+	prevote.extension = copyExtension(extension)
+	return prevote
+}
+
 func (prevote *Prevote) Signatory() id.Signatory {
 	return prevote.signatory
 }
 
+// SigHash returns the SHA256 hash of the signed part of the canonical encoding
+// of the Prevote.
 func (prevote *Prevote) SigHash() id.Hash {
-	return sha256.Sum256([]byte(prevote.String()))
+	return SigHashWith(prevote, NewSHA256Hasher())
 }
 
 func (prevote *Prevote) Sig() id.Signature {
@@ -238,6 +585,12 @@ func (prevote *Prevote) NilReasons() NilReasons {
 	return prevote.nilReasons
 }
 
+// Extension returns the opaque extension attached to the Prevote, or nil if
+// there is no extension.
+func (prevote *Prevote) Extension() []byte {
+	return prevote.extension
+}
+
 func (prevote *Prevote) Type() MessageType {
 	return PrevoteMessageType
 }
@@ -245,10 +598,10 @@ func (prevote *Prevote) Type() MessageType {
 func (prevote *Prevote) String() string {
 	nilReasonsBytes, err := prevote.NilReasons().MarshalBinary()
 	if err != nil {
-		return fmt.Sprintf("Prevote(Height=%v,Round=%v,BlockHash=%v)", prevote.Height(), prevote.Round(), prevote.BlockHash())
+		return fmt.Sprintf("Prevote(Height=%v,Round=%v,BlockHash=%v%v)", prevote.Height(), prevote.Round(), prevote.BlockHash(), extensionString(prevote.extension))
 	}
 	nilReasonsHash := id.Hash(sha256.Sum256(nilReasonsBytes))
-	return fmt.Sprintf("Prevote(Height=%v,Round=%v,BlockHash=%v,NilReasons=%v)", prevote.Height(), prevote.Round(), prevote.BlockHash(), nilReasonsHash.String())
+	return fmt.Sprintf("Prevote(Height=%v,Round=%v,BlockHash=%v,NilReasons=%v%v)", prevote.Height(), prevote.Round(), prevote.BlockHash(), nilReasonsHash.String(), extensionString(prevote.extension))
 }
 
 // Precommits is a wrapper around the `[]Precommit` type.
@@ -261,6 +614,7 @@ type Precommit struct {
 	height    block.Height
 	round     block.Round
 	blockHash id.Hash
+	extension []byte
 }
 
 func NewPrecommit(height block.Height, round block.Round, blockHash id.Hash) *Precommit {
@@ -271,12 +625,23 @@ func NewPrecommit(height block.Height, round block.Round, blockHash id.Hash) *Pr
 	}
 }
 
+// NewPrecommitWithExtension returns a Precommit that carries an opaque
+// extension. The extension is signed along with the Precommit. An empty
+// extension is the same as no extension.
+func NewPrecommitWithExtension(height block.Height, round block.Round, blockHash id.Hash, extension []byte) *Precommit {
+	precommit := NewPrecommit(height, round, blockHash)
+	precommit.extension = copyExtension(extension)
+	return precommit
+}
+
 func (precommit *Precommit) Signatory() id.Signatory {
 	return precommit.signatory
 }
 
+// SigHash returns the SHA256 hash of the signed part of the canonical encoding
+// of the Precommit.
 func (precommit *Precommit) SigHash() id.Hash {
-	return sha256.Sum256([]byte(precommit.String()))
+	return SigHashWith(precommit, NewSHA256Hasher())
 }
 
 func (precommit *Precommit) Sig() id.Signature {
@@ -295,12 +660,221 @@ func (precommit *Precommit) BlockHash() id.Hash {
 	return precommit.blockHash
 }
 
+// Extension returns the opaque extension attached to the Precommit, or nil if
+// there is no extension.
+func (precommit *Precommit) Extension() []byte {
+	return precommit.extension
+}
+
 func (precommit *Precommit) Type() MessageType {
 	return PrecommitMessageType
 }
 
 func (precommit *Precommit) String() string {
-	return fmt.Sprintf("Precommit(Height=%v,Round=%v,BlockHash=%v)", precommit.Height(), precommit.Round(), precommit.BlockHash())
+	return fmt.Sprintf("Precommit(Height=%v,Round=%v,BlockHash=%v%v)", precommit.Height(), precommit.Round(), precommit.BlockHash(), extensionString(precommit.extension))
+}
+
+// A VoteExtender returns the opaque extension that is attached to a Prevote or
+// Precommit, of the given type, before it is signed and broadcast. It can
+// return nil for no extension. Extensions are passed through the Process
+// unchanged, and are not used for consensus.
+type VoteExtender func(messageType MessageType, height block.Height, round block.Round, blockHash id.Hash) []byte
+
+// copyExtension returns a copy of the extension, or nil if it is empty, so
+// that empty extensions are always represented in the same way.
+func copyExtension(extension []byte) []byte {
+	if len(extension) == 0 {
+		return nil
+	}
+	copied := make([]byte, len(extension))
+	copy(copied, extension)
+	return copied
+}
+
+// extensionString returns the part of the string of a vote that covers its
+// extension. It is empty when there is no extension, so that the strings of
+// votes without extensions are unchanged.
+func extensionString(extension []byte) string {
+	if len(extension) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(",Extension=%v", id.Hash(sha256.Sum256(extension)).String())
+}
+
+// Resigns is a wrapper around the `[]Resign` type.
+type Resigns []Resign
+
+// Resign from proposing a block. A Resign is broadcast by the proposer of a
+// round when it knows that it cannot build a valid block, so that other
+// processes can prevote nil without waiting for the propose timeout.
+type Resign struct {
+	signatory id.Signatory
+	sig       id.Signature
+	height    block.Height
+	round     block.Round
+}
+
+func NewResign(height block.Height, round block.Round) *Resign {
+	return &Resign{
+		height: height,
+		round:  round,
+	}
+}
+
+func (resign *Resign) Signatory() id.Signatory {
+	return resign.signatory
+}
+
+func (resign *Resign) SigHash() id.Hash {
+	return SigHashWith(resign, NewSHA256Hasher())
+}
+
+func (resign *Resign) Sig() id.Signature {
+	return resign.sig
+}
+
+func (resign *Resign) Height() block.Height {
+	return resign.height
+}
+
+func (resign *Resign) Round() block.Round {
+	return resign.round
+}
+
+// BlockHash always returns `block.InvalidHash`, because a Resign does not
+// concern any block.
+func (resign *Resign) BlockHash() id.Hash {
+	return block.InvalidHash
+}
+
+func (resign *Resign) Type() MessageType {
+	return ResignMessageType
+}
+
+func (resign *Resign) String() string {
+	return fmt.Sprintf("Resign(Height=%v,Round=%v)", resign.Height(), resign.Round())
+}
+
+// A CatchUpRequest is broadcast by a process that has fallen behind, to request
+// the blocks that have been committed since it last made progress. Other
+// processes respond with a CommitRange. A CatchUpRequest does not concern any
+// round.
+type CatchUpRequest struct {
+	signatory id.Signatory
+	sig       id.Signature
+	height    block.Height
+}
+
+// NewCatchUpRequest returns a CatchUpRequest for the blocks committed at, and
+// above, the given height.
+func NewCatchUpRequest(fromHeight block.Height) *CatchUpRequest {
+	return &CatchUpRequest{
+		height: fromHeight,
+	}
+}
+
+func (request *CatchUpRequest) Signatory() id.Signatory {
+	return request.signatory
+}
+
+func (request *CatchUpRequest) SigHash() id.Hash {
+	return SigHashWith(request, NewSHA256Hasher())
+}
+
+func (request *CatchUpRequest) Sig() id.Signature {
+	return request.sig
+}
+
+// Height returns the height of the first block that is being requested.
+func (request *CatchUpRequest) Height() block.Height {
+	return request.height
+}
+
+// Round always returns `block.InvalidRound`, because a CatchUpRequest does not
+// concern any round.
+func (request *CatchUpRequest) Round() block.Round {
+	return block.InvalidRound
+}
+
+// BlockHash always returns `block.InvalidHash`, because a CatchUpRequest does
+// not concern any block.
+func (request *CatchUpRequest) BlockHash() id.Hash {
+	return block.InvalidHash
+}
+
+func (request *CatchUpRequest) Type() MessageType {
+	return CatchUpRequestMessageType
+}
+
+func (request *CatchUpRequest) String() string {
+	return fmt.Sprintf("CatchUpRequest(Height=%v)", request.Height())
+}
+
+// A CommitRange is broadcast in response to a CatchUpRequest. It stores a
+// sequence of committed blocks, in order of height, and the precommits that
+// prove each block was committed. The receiver must verify each commit before
+// applying it.
+type CommitRange struct {
+	signatory id.Signatory
+	sig       id.Signature
+	commits   []LatestCommit
+}
+
+func NewCommitRange(commits []LatestCommit) *CommitRange {
+	return &CommitRange{
+		commits: commits,
+	}
+}
+
+func (commitRange *CommitRange) Signatory() id.Signatory {
+	return commitRange.signatory
+}
+
+func (commitRange *CommitRange) SigHash() id.Hash {
+	return SigHashWith(commitRange, NewSHA256Hasher())
+}
+
+func (commitRange *CommitRange) Sig() id.Signature {
+	return commitRange.sig
+}
+
+// Height returns the height of the first block in the CommitRange, or
+// `block.InvalidHeight` if the CommitRange is empty.
+func (commitRange *CommitRange) Height() block.Height {
+	if len(commitRange.commits) == 0 {
+		return block.InvalidHeight
+	}
+	return commitRange.commits[0].Block.Header().Height()
+}
+
+// Round always returns `block.InvalidRound`, because a CommitRange can span
+// many rounds.
+func (commitRange *CommitRange) Round() block.Round {
+	return block.InvalidRound
+}
+
+// BlockHash always returns `block.InvalidHash`, because a CommitRange can
+// contain many blocks.
+func (commitRange *CommitRange) BlockHash() id.Hash {
+	return block.InvalidHash
+}
+
+// Commits returns the committed blocks, and their precommits, in order of
+// height.
+func (commitRange *CommitRange) Commits() []LatestCommit {
+	return commitRange.commits
+}
+
+func (commitRange *CommitRange) Type() MessageType {
+	return CommitRangeMessageType
+}
+
+func (commitRange *CommitRange) String() string {
+	blockHashes := make([]id.Hash, len(commitRange.commits))
+	for i, commit := range commitRange.commits {
+		blockHashes[i] = commit.Block.Hash()
+	}
+	return fmt.Sprintf("CommitRange(Height=%v,BlockHashes=%v)", commitRange.Height(), blockHashes)
 }
 
 // An Inbox is storage container for one type message. Any type of message can
@@ -309,7 +883,8 @@ func (precommit *Precommit) String() string {
 // to track how many messages (of particular types) have been received, and
 // under what conditions. For example, inboxes are used to track when `2F+1`
 // prevote messages have been received for a specific block hash for the first
-// time.
+// time. An Inbox is not safe for concurrent use. The Process that owns an Inbox
+// only inserts, queries, and drops messages while it holds its mutex.
 type Inbox struct {
 	f           int
 	messages    map[block.Height]map[block.Round]map[id.Signatory]Message
@@ -384,6 +959,40 @@ func (inbox *Inbox) Delete(height block.Height) {
 	delete(inbox.messages, height)
 }
 
+// DropRound removes all messages at a given height and round.
+func (inbox *Inbox) DropRound(height block.Height, round block.Round) {
+	if _, ok := inbox.messages[height]; !ok {
+		return
+	}
+	delete(inbox.messages[height], round)
+	if len(inbox.messages[height]) == 0 {
+		delete(inbox.messages, height)
+	}
+}
+
+// rounds returns all rounds at the specified height for which at least one
+// message has been received.
+func (inbox *Inbox) rounds(height block.Height) []block.Round {
+	rounds := make([]block.Round, 0, len(inbox.messages[height]))
+	for round := range inbox.messages[height] {
+		rounds = append(rounds, round)
+	}
+	return rounds
+}
+
+// counts returns the number of unique messages that have been received at
+// every height and round. The result does not share memory with the Inbox.
+func (inbox *Inbox) counts() map[block.Height]map[block.Round]int {
+	counts := make(map[block.Height]map[block.Round]int, len(inbox.messages))
+	for height, rounds := range inbox.messages {
+		counts[height] = make(map[block.Round]int, len(rounds))
+		for round, messages := range rounds {
+			counts[height][round] = len(messages)
+		}
+	}
+	return counts
+}
+
 // QueryMessagesByHeightRound returns all unique messages that have been
 // received at the specified height and round. The specific block hash of the
 // messages are ignored and might be different from each other.
@@ -446,6 +1055,93 @@ func (inbox *Inbox) QueryByHeightRoundBlockHash(height block.Height, round block
 	return
 }
 
+// QueryMessagesByHeightRoundBlockHash returns all unique messages that have
+// been received at the specified height and round. Only messages that
+// reference the specified block hash are returned.
+func (inbox *Inbox) QueryMessagesByHeightRoundBlockHash(height block.Height, round block.Round, blockHash id.Hash) []Message {
+	if _, ok := inbox.messages[height]; !ok {
+		return nil
+	}
+	if _, ok := inbox.messages[height][round]; !ok {
+		return nil
+	}
+	messages := make([]Message, 0, len(inbox.messages[height][round]))
+	for _, message := range inbox.messages[height][round] {
+		if blockHash.Equal(message.BlockHash()) {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Polka returns the polka at the highest round of the specified height, and
+// that round. A polka is more than `2F` prevotes for the same block at the same
+// round. Prevotes for nil do not form a polka, because they cannot justify the
+// valid round of a proposal. If more than one block has a polka at the same
+// round (only possible when messages from more than `3F+1` signatories have
+// been inserted), the polka for the block with the lowest hash is returned, so
+// the result never depends on the order in which prevotes were inserted. It
+// returns nil, and an invalid round, if there is no polka at the height. The
+// inbox must be an inbox of prevotes.
+func (inbox *Inbox) Polka(height block.Height) (Polka, block.Round) {
+	polka, round := Polka(nil), block.InvalidRound
+	for _, r := range inbox.rounds(height) {
+		if r <= round {
+			continue
+		}
+		if p, ok := inbox.polkaAtRound(height, r); ok {
+			polka, round = p, r
+		}
+	}
+	return polka, round
+}
+
+// PolkasByRound returns every polka at the specified height, ordered by round.
+// It uses the same rules as Polka to select the polka at each round, and is
+// intended for debugging. The inbox must be an inbox of prevotes.
+func (inbox *Inbox) PolkasByRound(height block.Height) []Polka {
+	rounds := inbox.rounds(height)
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+	polkas := make([]Polka, 0, len(rounds))
+	for _, round := range rounds {
+		if polka, ok := inbox.polkaAtRound(height, round); ok {
+			polkas = append(polkas, polka)
+		}
+	}
+	return polkas
+}
+
+// polkaAtRound returns the polka at the specified height and round, with its
+// prevotes ordered by signatory.
+func (inbox *Inbox) polkaAtRound(height block.Height, round block.Round) (Polka, bool) {
+	if inbox.messageType != PrevoteMessageType {
+		panic(fmt.Sprintf("pre-condition violation: expected type %v, got type %v", PrevoteMessageType, inbox.messageType))
+	}
+
+	prevotesByBlockHash := map[id.Hash]Polka{}
+	for _, message := range inbox.messages[height][round] {
+		prevote := message.(*Prevote)
+		prevotesByBlockHash[prevote.blockHash] = append(prevotesByBlockHash[prevote.blockHash], *prevote)
+	}
+
+	var polka Polka
+	for blockHash, prevotes := range prevotesByBlockHash {
+		if blockHash.Equal(block.InvalidHash) || len(prevotes) <= 2*inbox.F() {
+			continue
+		}
+		if polka == nil || bytes.Compare(blockHash[:], polka[0].blockHash[:]) < 0 {
+			polka = prevotes
+		}
+	}
+	if polka == nil {
+		return nil, false
+	}
+	sort.Slice(polka, func(i, j int) bool {
+		return bytes.Compare(polka[i].signatory[:], polka[j].signatory[:]) < 0
+	})
+	return polka, true
+}
+
 // QueryByHeightRoundSignatory the message (or nil) sent by a specific signatory
 // at a specific height and round.
 func (inbox *Inbox) QueryByHeightRoundSignatory(height block.Height, round block.Round, sig id.Signatory) Message {
@@ -476,6 +1172,13 @@ func (inbox *Inbox) F() int {
 	return inbox.f
 }
 
+// OneThirdThreshold returns the minimum number of distinct signatories that
+// guarantees at least one of them is honest. It is derived from the consensus
+// threshold, and is always F+1.
+func (inbox *Inbox) OneThirdThreshold() int {
+	return inbox.f + 1
+}
+
 func (inbox *Inbox) MessageType() MessageType {
 	return inbox.messageType
 }
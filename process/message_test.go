@@ -1,9 +1,12 @@
 package process_test
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	cRand "crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"reflect"
 	"testing/quick"
@@ -94,6 +97,61 @@ var _ = Describe("Messages", func() {
 			})
 		})
 
+		Context("when marshaling with an embedded polka", func() {
+			randomPolka := func() []Prevote {
+				polka := make([]Prevote, rand.Intn(10)+1)
+				for i := range polka {
+					prevote := RandomPrevote()
+					privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(Sign(prevote, *privateKey)).Should(Succeed())
+					polka[i] = *prevote
+				}
+				return polka
+			}
+
+			expectEqualPolkas := func(polka, newPolka []Prevote) {
+				Expect(newPolka).Should(HaveLen(len(polka)))
+				for i := range polka {
+					Expect(newPolka[i].String()).Should(Equal(polka[i].String()))
+					Expect(newPolka[i].Sig()).Should(Equal(polka[i].Sig()))
+					Expect(newPolka[i].Signatory()).Should(Equal(polka[i].Signatory()))
+				}
+			}
+
+			It("should equal itself after json marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := ProposeWithPolka(RandomPropose(), randomPolka())
+					data, err := json.Marshal(msg)
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg Propose
+					Expect(json.Unmarshal(data, &newMsg)).Should(Succeed())
+					Expect(newMsg.String()).Should(Equal(msg.String()))
+					expectEqualPolkas(msg.Polka(), newMsg.Polka())
+					return true
+				}
+
+				Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+			})
+
+			It("should equal itself after binary marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := ProposeWithPolka(RandomPropose(), randomPolka())
+					data, err := msg.MarshalBinary()
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg Propose
+					Expect(newMsg.UnmarshalBinary(data)).Should(Succeed())
+					Expect(newMsg.String()).Should(Equal(msg.String()))
+					expectEqualPolkas(msg.Polka(), newMsg.Polka())
+					return true
+				}
+
+				Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+			})
+		})
+
 		Context("when signing and verifying", func() {
 			It("should verify if a message if has been signed properly", func() {
 				test := func() bool {
@@ -113,6 +171,281 @@ var _ = Describe("Messages", func() {
 		})
 	})
 
+	Context("LatestCommit", func() {
+		// newLatestCommit returns a commit for a random block with one
+		// precommit from each key, and the signatories of the keys.
+		newLatestCommit := func(n int) (LatestCommit, id.Signatories) {
+			committedBlock := RandomBlock(block.Standard)
+			precommits := make([]Precommit, n)
+			signatories := make(id.Signatories, n)
+			for i := range precommits {
+				privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				precommit := NewPrecommit(committedBlock.Header().Height(), committedBlock.Header().Round(), committedBlock.Hash())
+				Expect(Sign(precommit, *privateKey)).Should(Succeed())
+				precommits[i] = *precommit
+				signatories[i] = precommit.Signatory()
+			}
+			return LatestCommit{Block: committedBlock, Precommits: precommits}, signatories
+		}
+
+		Context("when the commit meets the threshold", func() {
+			It("should verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				Expect(latestCommit.Verify(2*f+1, signatories)).Should(Succeed())
+			})
+		})
+
+		Context("when the commit is under the threshold", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2 * f)
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the commit has precommits for a different block", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				latestCommit.Block = RandomBlock(block.Standard)
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the commit has duplicate signatories", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2 * f)
+				latestCommit.Precommits = append(latestCommit.Precommits, latestCommit.Precommits[rand.Intn(2*f)])
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the commit has non-validator signatories", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				signatories[rand.Intn(len(signatories))] = RandomSignatory()
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the commit has unsigned precommits", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				header := latestCommit.Block.Header()
+				latestCommit.Precommits[0] = *NewPrecommit(header.Height(), header.Round(), latestCommit.Block.Hash())
+				signatories = append(signatories, latestCommit.Precommits[0].Signatory())
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the block was committed in a later round than it was proposed", func() {
+			// newLaterCommit returns a commit for a random block proposed at
+			// the proposed round, with one precommit from each key at the
+			// committed round.
+			newLaterCommit := func(n int, proposedRound, committedRound block.Round) (LatestCommit, id.Signatories) {
+				header := RandomBlockHeaderJSON(block.Standard)
+				header.Round = proposedRound
+				committedBlock := block.New(header.ToBlockHeader(), nil, nil, nil)
+				precommits := make([]Precommit, n)
+				signatories := make(id.Signatories, n)
+				for i := range precommits {
+					privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					precommit := NewPrecommit(header.Height, committedRound, committedBlock.Hash())
+					Expect(Sign(precommit, *privateKey)).Should(Succeed())
+					precommits[i] = *precommit
+					signatories[i] = precommit.Signatory()
+				}
+				return LatestCommit{Block: committedBlock, Precommits: precommits}, signatories
+			}
+
+			It("should retain the round of every precommit in the proof", func() {
+				f := rand.Intn(10) + 1
+				proposedRound := block.Round(rand.Intn(10))
+				committedRound := proposedRound + block.Round(rand.Intn(10)+1)
+				latestCommit, signatories := newLaterCommit(2*f+1, proposedRound, committedRound)
+				Expect(latestCommit.Round()).Should(Equal(committedRound))
+				Expect(latestCommit.Verify(2*f+1, signatories)).Should(Succeed())
+
+				// Marshal the proof, as if it was sent to a verifier
+				data, err := NewCommitRange([]LatestCommit{latestCommit}).MarshalBinary()
+				Expect(err).NotTo(HaveOccurred())
+				commitRange := CommitRange{}
+				Expect(commitRange.UnmarshalBinary(data)).Should(Succeed())
+				Expect(commitRange.Commits()).Should(HaveLen(1))
+
+				proof := commitRange.Commits()[0]
+				Expect(proof.Round()).Should(Equal(committedRound))
+				for _, precommit := range proof.Precommits {
+					Expect(precommit.Round()).Should(Equal(committedRound))
+				}
+				Expect(proof.Verify(2*f+1, signatories)).Should(Succeed())
+			})
+
+			It("should not verify if the precommits are before the round of the block", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLaterCommit(2*f+1, 1, 0)
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+
+			It("should not verify if the precommits are at different rounds", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				header := latestCommit.Block.Header()
+				privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				precommit := NewPrecommit(header.Height(), header.Round()+1, latestCommit.Block.Hash())
+				Expect(Sign(precommit, *privateKey)).Should(Succeed())
+				latestCommit.Precommits[rand.Intn(2*f)+1] = *precommit
+				signatories = append(signatories, precommit.Signatory())
+				Expect(latestCommit.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when merging two partial commits", func() {
+			It("should verify if the merged commit meets the threshold", func() {
+				f := rand.Intn(10) + 1
+				latestCommit, signatories := newLatestCommit(2*f + 1)
+				split := rand.Intn(2*f-1) + 2
+				// Overlap the partial commits by one precommit
+				first := LatestCommit{Block: latestCommit.Block, Precommits: latestCommit.Precommits[:split]}
+				second := LatestCommit{Block: latestCommit.Block, Precommits: latestCommit.Precommits[split-1:]}
+				Expect(first.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+				Expect(second.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+
+				merged, err := first.Merge(second)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(merged.Precommits).Should(HaveLen(2*f + 1))
+				Expect(merged.Verify(2*f+1, signatories)).Should(Succeed())
+			})
+
+			It("should return an error if the blocks are different", func() {
+				latestCommit, _ := newLatestCommit(2)
+				other, _ := newLatestCommit(2)
+				_, err := latestCommit.Merge(other)
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("should return an error if the precommits are at different rounds", func() {
+				latestCommit, _ := newLatestCommit(2)
+				header := latestCommit.Block.Header()
+				privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				precommit := NewPrecommit(header.Height(), header.Round()+1, latestCommit.Block.Hash())
+				Expect(Sign(precommit, *privateKey)).Should(Succeed())
+				_, err = latestCommit.Merge(LatestCommit{Block: latestCommit.Block, Precommits: []Precommit{*precommit}})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Polka", func() {
+		// newPolka returns a polka with one prevote from each key, and the
+		// signatories of the keys.
+		newPolka := func(n int) (Polka, id.Signatories) {
+			height, round, blockHash := block.Height(rand.Int63()), block.Round(rand.Int63()), RandomHash()
+			polka := make(Polka, n)
+			signatories := make(id.Signatories, n)
+			for i := range polka {
+				privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				prevote := NewPrevote(height, round, blockHash, nil)
+				Expect(Sign(prevote, *privateKey)).Should(Succeed())
+				polka[i] = *prevote
+				signatories[i] = prevote.Signatory()
+			}
+			return polka, signatories
+		}
+
+		Context("when the polka meets the threshold", func() {
+			It("should verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2*f + 1)
+				Expect(polka.Verify(2*f+1, signatories)).Should(Succeed())
+			})
+		})
+
+		Context("when the polka is under the threshold", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2 * f)
+				Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the polka has duplicate signatories", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2 * f)
+				polka = append(polka, polka[rand.Intn(len(polka))])
+				Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the polka has non-validator signatories", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2*f + 1)
+				signatories[rand.Intn(len(signatories))] = RandomSignatory()
+				Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the polka has prevotes for different blocks", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2*f + 1)
+				other, otherSignatories := newPolka(1)
+				polka[len(polka)-1] = other[0]
+				signatories[len(signatories)-1] = otherSignatories[0]
+				Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the polka has unsigned prevotes", func() {
+			It("should not verify", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2*f + 1)
+				polka[0] = *NewPrevote(polka[0].Height(), polka[0].Round(), polka[0].BlockHash(), nil)
+				signatories = append(signatories, polka[0].Signatory())
+				Expect(polka.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when merging two partial polkas", func() {
+			It("should verify if the merged polka meets the threshold", func() {
+				f := rand.Intn(10) + 1
+				polka, signatories := newPolka(2*f + 1)
+				split := rand.Intn(2*f-1) + 2
+				// Overlap the partial polkas by one prevote
+				first, second := polka[:split], polka[split-1:]
+				Expect(first.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+				Expect(second.Verify(2*f+1, signatories)).ShouldNot(Succeed())
+
+				merged, err := first.Merge(second)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(merged).Should(HaveLen(2*f + 1))
+				Expect(merged.Verify(2*f+1, signatories)).Should(Succeed())
+				for i := 1; i < len(merged); i++ {
+					prev, next := merged[i-1].Signatory(), merged[i].Signatory()
+					Expect(bytes.Compare(prev[:], next[:])).Should(Equal(-1))
+				}
+			})
+
+			It("should return an error if the prevotes are for different blocks", func() {
+				polka, _ := newPolka(2)
+				other, _ := newPolka(2)
+				_, err := polka.Merge(other)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
 	Context("Prevote", func() {
 		Context("when initializing", func() {
 			It("should return a message with fields equal to those passed during creation", func() {
@@ -295,6 +628,270 @@ var _ = Describe("Messages", func() {
 		})
 	})
 
+	Context("Resign", func() {
+		Context("when initializing", func() {
+			It("should return a message with fields equal to those passed during creation", func() {
+				test := func() bool {
+					height := block.Height(rand.Int63())
+					round := block.Round(rand.Int63())
+
+					resign := NewResign(height, round)
+
+					Expect(resign.Height()).Should(Equal(height))
+					Expect(resign.Round()).Should(Equal(round))
+					Expect(resign.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+					Expect(resign.Type()).Should(Equal(MessageType(ResignMessageType)))
+					return true
+				}
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when marshaling random", func() {
+			It("should equal itself after json marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomResign()
+					data, err := json.Marshal(msg)
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg Resign
+					Expect(json.Unmarshal(data, &newMsg)).Should(Succeed())
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should equal itself after binary marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomResign()
+					data, err := msg.MarshalBinary()
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg Resign
+					Expect(newMsg.UnmarshalBinary(data)).Should(Succeed())
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when signing and verifying", func() {
+			It("should verify if a message if has been signed properly", func() {
+				test := func() bool {
+					resign := RandomResign()
+					Expect(Verify(resign)).ShouldNot(Succeed())
+
+					privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(Sign(resign, *privateKey)).Should(Succeed())
+					Expect(Verify(resign)).Should(Succeed())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+	})
+
+	Context("CatchUpRequest", func() {
+		Context("when initializing", func() {
+			It("should return a message with fields equal to those passed during creation", func() {
+				test := func() bool {
+					height := block.Height(rand.Int63())
+
+					request := NewCatchUpRequest(height)
+
+					Expect(request.Height()).Should(Equal(height))
+					Expect(request.Round()).Should(Equal(block.InvalidRound))
+					Expect(request.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+					Expect(request.Type()).Should(Equal(MessageType(CatchUpRequestMessageType)))
+					return true
+				}
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when marshaling random", func() {
+			It("should equal itself after json marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomCatchUpRequest()
+					data, err := json.Marshal(msg)
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg CatchUpRequest
+					Expect(json.Unmarshal(data, &newMsg)).Should(Succeed())
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should equal itself after binary marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomCatchUpRequest()
+					data, err := msg.MarshalBinary()
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg CatchUpRequest
+					Expect(newMsg.UnmarshalBinary(data)).Should(Succeed())
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when signing and verifying", func() {
+			It("should verify if a message if has been signed properly", func() {
+				test := func() bool {
+					request := RandomCatchUpRequest()
+					Expect(Verify(request)).ShouldNot(Succeed())
+
+					privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(Sign(request, *privateKey)).Should(Succeed())
+					Expect(Verify(request)).Should(Succeed())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+	})
+
+	Context("CommitRange", func() {
+		Context("when initializing", func() {
+			It("should return a message with fields equal to those passed during creation", func() {
+				test := func() bool {
+					commits := RandomCommitRange().Commits()
+
+					commitRange := NewCommitRange(commits)
+
+					Expect(commitRange.Commits()).Should(Equal(commits))
+					if len(commits) == 0 {
+						Expect(commitRange.Height()).Should(Equal(block.InvalidHeight))
+					} else {
+						Expect(commitRange.Height()).Should(Equal(commits[0].Block.Header().Height()))
+					}
+					Expect(commitRange.Round()).Should(Equal(block.InvalidRound))
+					Expect(commitRange.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+					Expect(commitRange.Type()).Should(Equal(MessageType(CommitRangeMessageType)))
+					return true
+				}
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when marshaling random", func() {
+			It("should equal itself after json marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomCommitRange()
+					data, err := json.Marshal(msg)
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg CommitRange
+					Expect(json.Unmarshal(data, &newMsg)).Should(Succeed())
+					Expect(newMsg.Commits()).Should(HaveLen(len(msg.Commits())))
+					for i, commit := range msg.Commits() {
+						Expect(newMsg.Commits()[i].Precommits).Should(HaveLen(len(commit.Precommits)))
+						for j, precommit := range commit.Precommits {
+							Expect(newMsg.Commits()[i].Precommits[j]).Should(Equal(precommit))
+						}
+					}
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should equal itself after binary marshaling and then unmarshaling", func() {
+				test := func() bool {
+					msg := RandomCommitRange()
+					data, err := msg.MarshalBinary()
+					Expect(err).NotTo(HaveOccurred())
+
+					var newMsg CommitRange
+					Expect(newMsg.UnmarshalBinary(data)).Should(Succeed())
+					Expect(newMsg.Commits()).Should(HaveLen(len(msg.Commits())))
+					for i, commit := range msg.Commits() {
+						Expect(newMsg.Commits()[i].Precommits).Should(HaveLen(len(commit.Precommits)))
+						for j, precommit := range commit.Precommits {
+							Expect(newMsg.Commits()[i].Precommits[j]).Should(Equal(precommit))
+						}
+					}
+					return msg.String() == newMsg.String()
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when signing and verifying", func() {
+			It("should verify if a message if has been signed properly", func() {
+				test := func() bool {
+					commitRange := RandomCommitRange()
+					Expect(Verify(commitRange)).ShouldNot(Succeed())
+
+					privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(Sign(commitRange, *privateKey)).Should(Succeed())
+					Expect(Verify(commitRange)).Should(Succeed())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+	})
+
+	Context("when signing and verifying with a custom signature scheme", func() {
+		It("should verify using the matching verifier", func() {
+			test := func(signatory id.Signatory) bool {
+				message := RandomMessage(RandomMessageType())
+				signer := mockSigner{signatory: signatory}
+				Expect(SignWith(message, signer)).Should(Succeed())
+				Expect(message.Signatory().Equal(signatory)).Should(BeTrue())
+
+				Expect(VerifyWith(message, mockVerifier{})).Should(Succeed())
+				Expect(Verify(message)).ShouldNot(Succeed())
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should not verify a message signed by a different signatory", func() {
+			test := func(signatory, other id.Signatory) bool {
+				message := RandomMessage(RandomMessageType())
+				Expect(SignWith(message, mockSigner{signatory: signatory})).Should(Succeed())
+
+				sigHash := message.SigHash()
+				sig := message.Sig()
+				Expect(mockVerifier{}.Verify(sigHash[:], sig[:], other)).ShouldNot(Succeed())
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should verify ECDSA signatures through the default scheme", func() {
+			privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			signer := NewECDSASigner(*privateKey)
+			Expect(signer.Signatory().Equal(id.NewSignatory(privateKey.PublicKey))).Should(BeTrue())
+
+			message := RandomMessage(RandomMessageType())
+			Expect(SignWith(message, signer)).Should(Succeed())
+			Expect(VerifyWith(message, NewECDSAVerifier())).Should(Succeed())
+			Expect(Verify(message)).Should(Succeed())
+		})
+	})
+
 	Context("when initializing a new inbox", func() {
 		It("should have the given f and message type", func() {
 			test := func() bool {
@@ -526,6 +1123,36 @@ var _ = Describe("Messages", func() {
 			})
 		})
 
+		Context("when querying messages by height, round and block hash", func() {
+			It("should only return the messages for the block hash", func() {
+				test := func() bool {
+					f := rand.Intn(100) + 1
+					inbox := NewInbox(f, PrecommitMessageType)
+					height, round, blockHash := RandomHeight(), RandomRound(), RandomHash()
+
+					numMessages := rand.Intn(10) + 1
+					for i := 0; i < numMessages; i++ {
+						inbox.Insert(RandomSingedMessageWithHeightAndRound(height, round, PrecommitMessageType))
+						precommit := NewPrecommit(height, round, blockHash)
+						privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(Sign(precommit, *privateKey)).Should(Succeed())
+						inbox.Insert(precommit)
+					}
+
+					messages := inbox.QueryMessagesByHeightRoundBlockHash(height, round, blockHash)
+					Expect(messages).Should(HaveLen(numMessages))
+					for _, message := range messages {
+						Expect(message.BlockHash().Equal(blockHash)).Should(BeTrue())
+					}
+					Expect(inbox.QueryMessagesByHeightRoundBlockHash(height, round+1, blockHash)).Should(BeEmpty())
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
 		Context("when querying by height, round and signatory", func() {
 			It("should return the message if exist", func() {
 				test := func(height block.Height, round block.Round) bool {
@@ -617,4 +1244,231 @@ var _ = Describe("Messages", func() {
 			Expect(quick.Check(test, nil)).Should(Succeed())
 		})
 	})
+
+	Context("when dropping a round from an inbox", func() {
+		It("should only remove the messages at that height and round", func() {
+			test := func() bool {
+				f := rand.Intn(100) + 1
+				inbox := NewInbox(f, PrevoteMessageType)
+				height, round := RandomHeight(), block.Round(rand.Intn(100)+1)
+				for _, r := range []block.Round{round - 1, round, round + 1} {
+					inbox.Insert(RandomSingedMessageWithHeightAndRound(height, r, PrevoteMessageType))
+				}
+				inbox.Insert(RandomSingedMessageWithHeightAndRound(height+1, round, PrevoteMessageType))
+
+				inbox.DropRound(height, round)
+				Expect(inbox.QueryByHeightRound(height, round)).Should(Equal(0))
+				Expect(inbox.QueryByHeightRound(height, round-1)).Should(Equal(1))
+				Expect(inbox.QueryByHeightRound(height, round+1)).Should(Equal(1))
+				Expect(inbox.QueryByHeightRound(height+1, round)).Should(Equal(1))
+
+				inbox.DropRound(height, round)
+				Expect(inbox.QueryByHeightRound(height, round)).Should(Equal(0))
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when votes have extensions", func() {
+		newEcdsaKey := func() *ecdsa.PrivateKey {
+			privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			return privateKey
+		}
+
+		It("should equal itself after marshaling and then unmarshaling", func() {
+			for _, extension := range [][]byte{nil, {}, RandomBytesSlice(), []byte("extension")} {
+				prevote := NewPrevoteWithExtension(block.Height(rand.Int63()), block.Round(rand.Int63()), RandomHash(), nil, extension)
+				precommit := NewPrecommitWithExtension(block.Height(rand.Int63()), block.Round(rand.Int63()), RandomHash(), extension)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+
+				data, err := json.Marshal(prevote)
+				Expect(err).NotTo(HaveOccurred())
+				newPrevote := new(Prevote)
+				Expect(json.Unmarshal(data, newPrevote)).Should(Succeed())
+				Expect(newPrevote).Should(Equal(prevote))
+				data, err = prevote.MarshalBinary()
+				Expect(err).NotTo(HaveOccurred())
+				newPrevote = new(Prevote)
+				Expect(newPrevote.UnmarshalBinary(data)).Should(Succeed())
+				Expect(newPrevote).Should(Equal(prevote))
+				Expect(Verify(newPrevote)).Should(Succeed())
+
+				data, err = json.Marshal(precommit)
+				Expect(err).NotTo(HaveOccurred())
+				newPrecommit := new(Precommit)
+				Expect(json.Unmarshal(data, newPrecommit)).Should(Succeed())
+				Expect(newPrecommit).Should(Equal(precommit))
+				data, err = precommit.MarshalBinary()
+				Expect(err).NotTo(HaveOccurred())
+				newPrecommit = new(Precommit)
+				Expect(newPrecommit.UnmarshalBinary(data)).Should(Succeed())
+				Expect(newPrecommit).Should(Equal(precommit))
+				Expect(Verify(newPrecommit)).Should(Succeed())
+
+				if len(extension) == 0 {
+					Expect(newPrevote.Extension()).Should(BeNil())
+					Expect(newPrecommit.Extension()).Should(BeNil())
+				} else {
+					Expect(newPrevote.Extension()).Should(Equal(extension))
+					Expect(newPrecommit.Extension()).Should(Equal(extension))
+				}
+			}
+		})
+
+		It("should not change the sighash of votes without extensions", func() {
+			blockHash := RandomHash()
+			Expect(NewPrevoteWithExtension(1, 2, blockHash, nil, nil).SigHash()).Should(Equal(NewPrevote(1, 2, blockHash, nil).SigHash()))
+			Expect(NewPrecommitWithExtension(1, 2, blockHash, nil).SigHash()).Should(Equal(NewPrecommit(1, 2, blockHash).SigHash()))
+			Expect(NewPrecommitWithExtension(1, 2, blockHash, []byte{1}).SigHash()).ShouldNot(Equal(NewPrecommit(1, 2, blockHash).SigHash()))
+		})
+
+		It("should not verify if the extension has been changed after signing", func() {
+			precommit := NewPrecommitWithExtension(1, 2, RandomHash(), []byte("original"))
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+
+			data, err := json.Marshal(precommit)
+			Expect(err).NotTo(HaveOccurred())
+			tmp := map[string]interface{}{}
+			Expect(json.Unmarshal(data, &tmp)).Should(Succeed())
+			tmp["extension"] = []byte("tampered")
+			data, err = json.Marshal(tmp)
+			Expect(err).NotTo(HaveOccurred())
+
+			tampered := new(Precommit)
+			Expect(json.Unmarshal(data, tampered)).Should(Succeed())
+			Expect(tampered.Extension()).Should(Equal([]byte("tampered")))
+			Expect(Verify(tampered)).ShouldNot(Succeed())
+		})
+
+		It("should aggregate the extensions of the precommits for the committed block", func() {
+			committedBlock := RandomBlock(block.Standard)
+			latestCommit := LatestCommit{Block: committedBlock}
+			extensions := map[id.Signatory][]byte{}
+			for i := 0; i < 5; i++ {
+				extension := []byte(fmt.Sprintf("extension %v", i))
+				precommit := NewPrecommitWithExtension(1, 0, committedBlock.Hash(), extension)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				latestCommit.Precommits = append(latestCommit.Precommits, *precommit)
+				extensions[precommit.Signatory()] = extension
+			}
+
+			// Expect precommits without extensions, and precommits for other
+			// blocks, to be ignored
+			precommit := NewPrecommit(1, 0, committedBlock.Hash())
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+			latestCommit.Precommits = append(latestCommit.Precommits, *precommit)
+			precommit = NewPrecommitWithExtension(1, 0, RandomHash(), []byte("other block"))
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+			latestCommit.Precommits = append(latestCommit.Precommits, *precommit)
+
+			Expect(latestCommit.Extensions()).Should(Equal(extensions))
+		})
+	})
+
+	Context("when querying polkas from an inbox", func() {
+		// insertPrevotes inserts n prevotes for the block hash, each from a
+		// new key.
+		insertPrevotes := func(inbox *Inbox, n int, height block.Height, round block.Round, blockHash id.Hash) {
+			for i := 0; i < n; i++ {
+				privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				prevote := NewPrevote(height, round, blockHash, nil)
+				Expect(Sign(prevote, *privateKey)).Should(Succeed())
+				inbox.Insert(prevote)
+			}
+		}
+
+		Context("when there are polkas at multiple rounds", func() {
+			It("should return the polka at the highest round", func() {
+				f := rand.Intn(5) + 1
+				height := block.Height(rand.Int63())
+				inbox := NewInbox(f, PrevoteMessageType)
+				blockHash2, blockHash5 := RandomHash(), RandomHash()
+				insertPrevotes(inbox, 2*f+1, height, 2, blockHash2)
+				insertPrevotes(inbox, 2*f+1, height, 5, blockHash5)
+				insertPrevotes(inbox, 2*f+1, height, 6, block.InvalidHash)
+				insertPrevotes(inbox, 2*f, height, 7, RandomHash())
+
+				polka, round := inbox.Polka(height)
+				Expect(round).Should(Equal(block.Round(5)))
+				Expect(polka).Should(HaveLen(2*f + 1))
+				Expect(polka[0].BlockHash()).Should(Equal(blockHash5))
+
+				polkas := inbox.PolkasByRound(height)
+				Expect(polkas).Should(HaveLen(2))
+				Expect(polkas[0][0].Round()).Should(Equal(block.Round(2)))
+				Expect(polkas[0][0].BlockHash()).Should(Equal(blockHash2))
+				Expect(polkas[1][0].Round()).Should(Equal(block.Round(5)))
+				Expect(polkas[1]).Should(Equal(polka))
+			})
+		})
+
+		Context("when there are polkas for different blocks at the same round", func() {
+			It("should return the polka for the lowest block hash", func() {
+				test := func() bool {
+					f := rand.Intn(5) + 1
+					height, round := block.Height(rand.Int63()), block.Round(rand.Intn(100))
+					inbox := NewInbox(f, PrevoteMessageType)
+					blockHash1, blockHash2 := RandomHash(), RandomHash()
+					insertPrevotes(inbox, 2*f+1, height, round, blockHash1)
+					insertPrevotes(inbox, 2*f+1, height, round, blockHash2)
+
+					expected := blockHash1
+					if bytes.Compare(blockHash2[:], blockHash1[:]) < 0 {
+						expected = blockHash2
+					}
+					polka, polkaRound := inbox.Polka(height)
+					Expect(polkaRound).Should(Equal(round))
+					Expect(polka[0].BlockHash()).Should(Equal(expected))
+					return true
+				}
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+
+		Context("when there is no polka", func() {
+			It("should return an invalid round", func() {
+				f := rand.Intn(5) + 1
+				height := block.Height(rand.Int63())
+				inbox := NewInbox(f, PrevoteMessageType)
+				insertPrevotes(inbox, 2*f, height, 0, RandomHash())
+
+				polka, round := inbox.Polka(height)
+				Expect(polka).Should(BeNil())
+				Expect(round).Should(Equal(block.InvalidRound))
+				Expect(inbox.PolkasByRound(height)).Should(BeEmpty())
+			})
+		})
+	})
 })
+
+// mockSigner produces signatures by hashing the signatory together with the
+// sighash. It is not secure, and is only used to test that the signature
+// scheme can be swapped.
+type mockSigner struct {
+	signatory id.Signatory
+}
+
+func (signer mockSigner) Signatory() id.Signatory {
+	return signer.signatory
+}
+
+func (signer mockSigner) Sign(hash []byte) ([]byte, error) {
+	digest := sha256.Sum256(append(signer.signatory[:], hash...))
+	sig := make([]byte, id.SignatureLength)
+	copy(sig, digest[:])
+	return sig, nil
+}
+
+type mockVerifier struct{}
+
+func (mockVerifier) Verify(hash, sig []byte, signatory id.Signatory) error {
+	expected, _ := mockSigner{signatory: signatory}.Sign(hash)
+	if !bytes.Equal(sig, expected) {
+		return fmt.Errorf("bad signature")
+	}
+	return nil
+}
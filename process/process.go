@@ -2,6 +2,9 @@ package process
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -21,19 +24,37 @@ const (
 	StepPrecommit
 )
 
+// String implements the `fmt.Stringer` interface.
+func (step Step) String() string {
+	switch step {
+	case StepPropose:
+		return "Propose"
+	case StepPrevote:
+		return "Prevote"
+	case StepPrecommit:
+		return "Precommit"
+	default:
+		return "Nil"
+	}
+}
+
 // NilReasons can be used to provide contextual information alongside an error
 // upon validating blocks.
 type NilReasons map[string][]byte
 
 // A Blockchain defines a storage interface for Blocks that is based around
-// Height.
+// Height. InsertBlockAtHeight returns an error if the Block cannot be stored
+// (for example, because the disk is full). The Block is then not committed,
+// and the Process stops advancing until the Block can be stored.
 type Blockchain interface {
-	InsertBlockAtHeight(block.Height, block.Block)
+	InsertBlockAtHeight(block.Height, block.Block) error
 	BlockAtHeight(block.Height) (block.Block, bool)
 	BlockExistsAtHeight(block.Height) bool
 }
 
-// A Proposer builds a `block.Block` for proposals.
+// A Proposer builds a `block.Block` for proposals. If the Proposer knows that
+// it cannot build a valid `block.Block`, it can return `block.InvalidBlock` to
+// resign from proposing in the round.
 type Proposer interface {
 	BlockProposal(block.Height, block.Round) block.Block
 }
@@ -47,6 +68,14 @@ type Validator interface {
 type Observer interface {
 	DidCommitBlock(block.Height)
 	DidReceiveSufficientNilPrevotes(messages Messages, f int)
+	// DidBecomeProposer is called when the Process starts a round in which it
+	// is the proposer, before the proposal is requested from the Proposer.
+	DidBecomeProposer(block.Height, block.Round)
+	// DidFailToCommitBlock is called when a Block has been committed by the
+	// network, but the Blockchain cannot store it. The Process does not advance
+	// to the next height, and keeps its state, so that it can retry storing
+	// the Block whenever it handles another Message that commits it.
+	DidFailToCommitBlock(block.Height, error)
 }
 
 // A Scheduler determines which `id.Signatory` should be broadcasting
@@ -66,11 +95,23 @@ type Timer interface {
 	Timeout(step Step, round block.Round) time.Duration
 }
 
+// A ParticipationTracker reports whether a signatory is known to be offline at
+// a `block.Height`, because it has not participated in consensus recently.
+type ParticipationTracker interface {
+	IsOffline(signatory id.Signatory, height block.Height) bool
+}
+
 // Processes defines a wrapper type around the []Process type.
 type Processes []Process
 
 // A Process defines a state machine in the distributed replicated state
 // machine. See https://arxiv.org/pdf/1807.04938.pdf for more information.
+//
+// A Process is safe for concurrent use. Every transition, whether it is caused
+// by a Message, a timeout, or a call to one of its methods, holds the mutex of
+// the Process from start to finish, so transitions never interleave. The
+// State, including its Inboxes, is only ever read or written while the mutex
+// is held, and the Inboxes are not synchronized themselves.
 type Process struct {
 	logger logrus.FieldLogger
 	mu     *sync.Mutex
@@ -84,7 +125,58 @@ type Process struct {
 	scheduler   Scheduler
 	broadcaster Broadcaster
 	timer       Timer
+	clock       Clock
+	unlock      UnlockStrategy
+	verifier    Verifier
+	hasher      Hasher
 	observer    Observer
+
+	transitions *transitionLog
+	offline     ParticipationTracker
+	recorder    io.Writer
+
+	// waitForTrigger makes the Process wait for TriggerPropose before
+	// proposing, and awaitingTrigger is true while the Process is the proposer
+	// of the current round and has not yet proposed
+	waitForTrigger  bool
+	awaitingTrigger bool
+
+	// lastCommit is the most recent block committed (or synced) by the
+	// Process, and the precommits that committed it. It is not part of the
+	// State, so it is lost when the Process is restored
+	lastCommit LatestCommit
+
+	// didViolateInvariant is called with every invariant violation that the
+	// Process recovers from, instead of panicking
+	didViolateInvariant func(error)
+
+	// didStartRound is called whenever the Process starts a round, including
+	// the first round of every height
+	didStartRound func(block.Height, block.Round)
+
+	// didTimeout is called with the transition of every timeout that causes
+	// the Process to change its step or round
+	didTimeout func(Transition)
+
+	// didCommit is called with the Propose of every block that is committed
+	// by the Process
+	didCommit func(*Propose)
+
+	// extendVote returns the extension that is attached to every Prevote and
+	// Precommit broadcast by the Process
+	extendVote VoteExtender
+
+	// handovers are the sets of signatories scheduled by ChangeSignatories,
+	// ordered by the height from which they are used
+	handovers []handover
+
+	// action is the type of the most recent Message broadcast by the
+	// Process, and is reset at the beginning of every transition
+	action MessageType
+
+	// done is closed when the Process is stopped, to cancel scheduled
+	// timeouts
+	done chan struct{}
 }
 
 // New Process initialised to the default state, starting in the first round.
@@ -103,13 +195,19 @@ func New(logger logrus.FieldLogger, signatory id.Signatory, blockchain Blockchai
 		broadcaster: broadcaster,
 		scheduler:   scheduler,
 		timer:       timer,
+		clock:       NewSystemClock(),
+		unlock:      NewSpecUnlockStrategy(),
+		verifier:    NewECDSAVerifier(),
+		hasher:      NewSHA256Hasher(),
+
+		done: make(chan struct{}),
 	}
 	return p
 }
 
 // MarshalJSON implements the `json.Marshaler` interface for the Process type,
 // by marshaling its isolated State.
-func (p Process) MarshalJSON() ([]byte, error) {
+func (p *Process) MarshalJSON() ([]byte, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return json.Marshal(p.state)
@@ -125,7 +223,7 @@ func (p *Process) UnmarshalJSON(data []byte) error {
 
 // MarshalBinary implements the `encoding.BinaryMarshaler` interface for the
 // Process type, by marshaling its isolated State.
-func (p Process) MarshalBinary() ([]byte, error) {
+func (p *Process) MarshalBinary() ([]byte, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.state.MarshalBinary()
@@ -144,6 +242,8 @@ func (p *Process) Start() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.record(startInputType, p.state.CurrentHeight, p.state.CurrentRound, nil)
+
 	// Log the starting state of process for debugging purpose.
 	p.logger.Debugf("🎰 starting process at height=%v, round=%v, step=%v", p.state.CurrentHeight, p.state.CurrentRound, p.state.CurrentStep)
 	numProposes := p.state.Proposals.QueryByHeightRound(p.state.CurrentHeight, p.state.CurrentRound)
@@ -160,7 +260,7 @@ func (p *Process) Start() {
 			p.resend(p.state.CurrentHeight, p.state.CurrentRound-1)
 		} else if p.state.CurrentHeight > 0 {
 			maxRound := block.Round(0)
-			for round := range p.state.Proposals.messages[p.state.CurrentHeight-1] {
+			for round := range p.state.Precommits.messages[p.state.CurrentHeight-1] {
 				if round > maxRound {
 					maxRound = round
 				}
@@ -175,12 +275,49 @@ func (p *Process) Start() {
 	}
 }
 
+// Stop the Process by cancelling all scheduled timeouts. No timeouts will be
+// scheduled after the Process has been stopped. Stopping a Process that has
+// already been stopped does nothing. Stop is safe for concurrent use.
+func (p *Process) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+}
+
 // StartRound is safe for concurrent use. See
 // https://arxiv.org/pdf/1807.04938.pdf for more information.
 func (p *Process) StartRound(round block.Round) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+
+	p.record(startRoundInputType, p.state.CurrentHeight, round, nil)
+	p.startRound(round)
+}
+
+// ForceRound starts a higher round at the current height, as if the rounds in
+// between had timed out. It is meant for recovery tooling and chaos testing,
+// when an operator knows that the current round is dead. The lock of the
+// Process is kept, so in the new round it can only prevote for the locked
+// block (unless the UnlockStrategy allows it to unlock). It returns an error,
+// and does nothing, if the round is not higher than the current round, because
+// starting a round again could make the Process vote twice in it. ForceRound
+// is safe for concurrent use.
+func (p *Process) ForceRound(round block.Round) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if round <= p.state.CurrentRound {
+		return fmt.Errorf("cannot force round=%v at height=%v: current round=%v is not lower", round, p.state.CurrentHeight, p.state.CurrentRound)
+	}
+	p.logger.Warnf("forcing round=%v at height=%v (current round=%v)", round, p.state.CurrentHeight, p.state.CurrentRound)
+	p.record(startRoundInputType, p.state.CurrentHeight, round, nil)
 	p.startRound(round)
+	return nil
 }
 
 // HandleMessage is safe for concurrent use. See
@@ -189,6 +326,24 @@ func (p *Process) HandleMessage(m Message) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	switch m.(type) {
+	case *Propose, *Prevote, *Precommit, *Resign:
+	default:
+		p.violateInvariant(fmt.Errorf("invariant violation: unexpected message type=%T", m))
+		return
+	}
+	p.record(messageInputType, m.Height(), m.Round(), func() ([]byte, error) {
+		return marshalInputMessage(m)
+	})
+
+	from := p.state.CurrentStep
+	p.action = NilMessageType
+	transition := newMessageTransition(m)
+	isTransition := m.Height() == p.state.CurrentHeight
+	if isTransition {
+		p.transitions.record(transition)
+	}
+
 	switch m := m.(type) {
 	case *Propose:
 		p.handlePropose(m)
@@ -196,7 +351,477 @@ func (p *Process) HandleMessage(m Message) {
 		p.handlePrevote(m)
 	case *Precommit:
 		p.handlePrecommit(m)
+	case *Resign:
+		p.handleResign(m)
+	}
+
+	if isTransition {
+		p.logTransition(transition, from)
+	}
+}
+
+// EnableTransitionLog makes the Process record the most recent transitions
+// that it processes at its current height, up to a maximum number of
+// transitions per height. When the maximum is exceeded, the oldest transition
+// at the height is evicted and passed to the eviction callback, which must not
+// call back into the Process. Transitions are dropped at the same time as the
+// messages at the same height. A non-positive maximum disables the log.
+// EnableTransitionLog is safe for concurrent use.
+func (p *Process) EnableTransitionLog(maxTransitionsPerHeight int, didEvict func(Transition)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.transitions = newTransitionLog(maxTransitionsPerHeight, didEvict)
+}
+
+// OnInvariantViolation makes the Process call the given function whenever it
+// recovers from an invariant violation, such as being asked to handle a
+// Message of an unexpected type. Invariant violations are always logged at the
+// error level, and the Process is left in the State it was in before the
+// violation. The function must not call back into the Process.
+// OnInvariantViolation is safe for concurrent use.
+func (p *Process) OnInvariantViolation(didViolateInvariant func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.didViolateInvariant = didViolateInvariant
+}
+
+// OnStartRound makes the Process call the given function whenever it starts a
+// round, including the first round of every height, before it proposes or
+// schedules the propose timeout. The function is called while the Process is
+// locked, so it must return quickly and must not call back into the Process.
+// OnStartRound is safe for concurrent use.
+func (p *Process) OnStartRound(didStartRound func(block.Height, block.Round)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.didStartRound = didStartRound
+}
+
+// OnTimeout makes the Process call the given function with the transition of
+// every timeout that causes it to change its step or round. Timeouts that are
+// ignored, because the Process has already moved on, are not passed to the
+// function. The function is called while the Process is locked, so it must
+// return quickly and must not call back into the Process. OnTimeout is safe
+// for concurrent use.
+func (p *Process) OnTimeout(didTimeout func(Transition)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.didTimeout = didTimeout
+}
+
+// OnCommit makes the Process call the given function with the Propose of every
+// block that it commits, after the block has been stored. Blocks that are
+// synced from a LatestCommit are not passed to the function, because they were
+// not proposed to the Process. The function is called while the Process is
+// locked, so it must return quickly and must not call back into the Process.
+// OnCommit is safe for concurrent use.
+func (p *Process) OnCommit(didCommit func(*Propose)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.didCommit = didCommit
+}
+
+// UseVoteExtender makes the Process attach the extension returned by the given
+// VoteExtender to every Prevote and Precommit that it broadcasts, so that the
+// extension is signed along with the vote. The VoteExtender is called while
+// the Process is locked, so it must return quickly and must not call back into
+// the Process. A nil VoteExtender stops extensions from being attached.
+// UseVoteExtender is safe for concurrent use.
+func (p *Process) UseVoteExtender(extender VoteExtender) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.extendVote = extender
+}
+
+// UseClock makes the Process wait for timeouts using the given Clock, instead
+// of the system time. UseClock is safe for concurrent use, but only affects
+// timeouts that are scheduled after it is called.
+func (p *Process) UseClock(clock Clock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clock = clock
+}
+
+// UseVerifier makes the Process verify the prevotes embedded in Proposes, and
+// the precommits embedded in LatestCommits, using the given Verifier. It must
+// verify the same signature scheme that is used to sign Messages. A nil
+// Verifier restores the ECDSA Verifier. UseVerifier is safe for concurrent
+// use.
+func (p *Process) UseVerifier(verifier Verifier) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if verifier == nil {
+		verifier = NewECDSAVerifier()
+	}
+	p.verifier = verifier
+}
+
+// UseHasher makes the Process verify the prevotes embedded in Proposes, and
+// the precommits embedded in LatestCommits, using sighashes computed by the
+// given Hasher. It must be the same Hasher that is used to sign and verify
+// Messages. A nil Hasher restores the SHA256 Hasher. UseHasher is safe for
+// concurrent use.
+func (p *Process) UseHasher(hasher Hasher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if hasher == nil {
+		hasher = NewSHA256Hasher()
+	}
+	p.hasher = hasher
+}
+
+// UseUnlockStrategy makes the Process consult the given UnlockStrategy when it
+// is locked on a block and a different block is proposed. A nil UnlockStrategy
+// restores the spec UnlockStrategy, which is the only UnlockStrategy that is
+// safe to use in production. UseUnlockStrategy is safe for concurrent use.
+func (p *Process) UseUnlockStrategy(strategy UnlockStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if strategy == nil {
+		strategy = NewSpecUnlockStrategy()
+	}
+	p.unlock = strategy
+}
+
+// SkipOfflineProposers makes the Process prevote nil as soon as it starts a
+// round in which the proposer is known to be offline, instead of waiting for
+// the propose timeout. A nil ParticipationTracker always waits for the propose
+// timeout. SkipOfflineProposers is safe for concurrent use.
+func (p *Process) SkipOfflineProposers(tracker ParticipationTracker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.offline = tracker
+}
+
+// WaitForProposeTrigger makes the Process wait for TriggerPropose to be called
+// before proposing in rounds in which it is the proposer, instead of
+// proposing as soon as the round starts. This allows block production to be
+// paced externally. The propose timeout is still scheduled, so the round is
+// abandoned if TriggerPropose is not called in time. WaitForProposeTrigger is
+// safe for concurrent use, but only affects rounds that start after it is
+// called.
+func (p *Process) WaitForProposeTrigger(wait bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.waitForTrigger = wait
+}
+
+// TriggerPropose makes the Process propose immediately, if it is waiting for a
+// trigger to propose in the current round (see WaitForProposeTrigger). It
+// returns true if a proposal was broadcast, and false if the Process is not
+// the proposer, has already proposed, or has timed out waiting for the
+// proposal. TriggerPropose is safe for concurrent use.
+func (p *Process) TriggerPropose() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record(triggerProposeInputType, p.state.CurrentHeight, p.state.CurrentRound, nil)
+
+	if !p.awaitingTrigger || p.state.CurrentStep != StepPropose {
+		return false
+	}
+	p.awaitingTrigger = false
+	p.propose()
+	return true
+}
+
+// Prune drops the proposals and prevotes at heights below the given height
+// from the State, and the precommits at heights below the previous height.
+// Every commit already drops the Messages below the previous height, so Prune
+// is used to drop the proposals (and their blocks) and prevotes that are kept
+// at the previous height after a commit. The Messages at the current height
+// are never dropped, and neither are the precommits at the previous height,
+// because they justify the latest commit. Prune is safe for concurrent use.
+func (p *Process) Prune(height block.Height) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record(pruneInputType, height, block.InvalidRound, nil)
+
+	if height > p.state.CurrentHeight {
+		height = p.state.CurrentHeight
+	}
+	p.state.Proposals.Reset(height)
+	p.state.Prevotes.Reset(height)
+	if height > p.state.CurrentHeight-1 {
+		height = p.state.CurrentHeight - 1
+	}
+	p.state.Precommits.Reset(height)
+}
+
+// ChangeSignatories makes the Process use a new set of signatories from the
+// height onwards. When the Process advances to the height, F is changed to
+// match the number of signatories before the first round of the height is
+// started, and polkas and commits at, or above, the height are verified against
+// the new signatories instead of those of the genesis block. It returns false,
+// and makes no change, if the Process has already reached the height. Changes
+// are not part of the State, so they are lost when the Process is restored.
+// ChangeSignatories is safe for concurrent use.
+func (p *Process) ChangeSignatories(height block.Height, signatories id.Signatories) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record(changeSignatoriesInputType, height, block.InvalidRound, func() ([]byte, error) {
+		data := make([]byte, 0, len(signatories)*len(id.Signatory{}))
+		for _, signatory := range signatories {
+			data = append(data, signatory[:]...)
+		}
+		return data, nil
+	})
+
+	if height <= p.state.CurrentHeight {
+		return false
+	}
+	handovers := make([]handover, 0, len(p.handovers)+1)
+	for _, h := range p.handovers {
+		// Changes at, or above, the height are replaced by the new change
+		if h.height < height {
+			handovers = append(handovers, h)
+		}
+	}
+	p.handovers = append(handovers, handover{height: height, signatories: signatories})
+	return true
+}
+
+// CanCommitThisRound returns true if more than 2F precommits for the same
+// block (not nil) have been received at the current `block.Height` and
+// `block.Round`. It is advisory: the block is only committed once its proposal
+// has also been received, and is valid. CanCommitThisRound is safe for
+// concurrent use.
+func (p *Process) CanCommitThisRound() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tallies := map[id.Hash]int{}
+	for _, m := range p.state.Precommits.QueryMessagesByHeightRound(p.state.CurrentHeight, p.state.CurrentRound) {
+		if m.BlockHash().Equal(block.InvalidHash) {
+			continue
+		}
+		tallies[m.BlockHash()]++
+		if tallies[m.BlockHash()] > 2*p.state.Precommits.F() {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns a StateSnapshot of the current State of the Process. It is
+// a deep copy, so it is not affected by messages that are handled after it is
+// returned. Snapshot is safe for concurrent use.
+func (p *Process) Snapshot() StateSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state.Snapshot()
+}
+
+// TransitionLog returns the ordered transitions that were recorded at a
+// height. It returns nil if the transition log is not enabled, or if the
+// height has been dropped. TransitionLog is safe for concurrent use.
+func (p *Process) TransitionLog(height block.Height) []Transition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.transitions.at(height)
+}
+
+// CurrentHeight returns the height at which the Process is currently trying to
+// reach consensus. CurrentHeight is safe for concurrent use.
+func (p *Process) CurrentHeight() block.Height {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state.CurrentHeight
+}
+
+// CurrentRound returns the round in which the Process is currently trying to
+// reach consensus. CurrentRound is safe for concurrent use.
+func (p *Process) CurrentRound() block.Round {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state.CurrentRound
+}
+
+// NextTimeout returns the duration after which a timeout is expected for the
+// current step, or zero if the current step is not waiting for a timeout. A
+// process waits for the propose timeout when it is not the proposer, for the
+// prevote timeout after 2F+1 prevotes, and for the precommit timeout after
+// 2F+1 precommits. NextTimeout is safe for concurrent use.
+func (p *Process) NextTimeout() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state.CurrentStep {
+	case StepPropose:
+		if !p.signatory.Equal(p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)) {
+			return p.timer.Timeout(StepPropose, p.state.CurrentRound)
+		}
+	case StepPrevote:
+		if p.state.Prevotes.QueryByHeightRound(p.state.CurrentHeight, p.state.CurrentRound) > 2*p.state.Prevotes.F() {
+			return p.timer.Timeout(StepPrevote, p.state.CurrentRound)
+		}
+	case StepPrecommit:
+		if p.state.Precommits.QueryByHeightRound(p.state.CurrentHeight, p.state.CurrentRound) > 2*p.state.Precommits.F() {
+			return p.timer.Timeout(StepPrecommit, p.state.CurrentRound)
+		}
+	}
+	return 0
+}
+
+// CurrentProposer returns the signatory that is scheduled to propose at the
+// current height and round. CurrentProposer is safe for concurrent use.
+func (p *Process) CurrentProposer() id.Signatory {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)
+}
+
+// Signatory returns the identity of the Process.
+func (p *Process) Signatory() id.Signatory {
+	return p.signatory
+}
+
+// HasReceived returns true if a Message of the same type has already been
+// received from the same signatory at the same height and round. HasReceived is
+// safe for concurrent use.
+func (p *Process) HasReceived(m Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var inbox *Inbox
+	switch m.(type) {
+	case *Propose:
+		inbox = p.state.Proposals
+	case *Prevote:
+		inbox = p.state.Prevotes
+	case *Precommit:
+		inbox = p.state.Precommits
+	default:
+		return false
+	}
+	return inbox.QueryByHeightRoundSignatory(m.Height(), m.Round(), m.Signatory()) != nil
+}
+
+// Proposal returns the Propose that has been received from a signatory at a
+// height and round, if any. Proposal is safe for concurrent use.
+func (p *Process) Proposal(height block.Height, round block.Round, signatory id.Signatory) (*Propose, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m := p.state.Proposals.QueryByHeightRoundSignatory(height, round, signatory)
+	if m == nil {
+		return nil, false
+	}
+	return m.(*Propose), true
+}
+
+// Vote returns the Prevote or Precommit, depending on the MessageType, that has
+// been received from a signatory at a height and round, if any. Vote is safe
+// for concurrent use.
+func (p *Process) Vote(t MessageType, height block.Height, round block.Round, signatory id.Signatory) (Message, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var inbox *Inbox
+	switch t {
+	case PrevoteMessageType:
+		inbox = p.state.Prevotes
+	case PrecommitMessageType:
+		inbox = p.state.Precommits
+	default:
+		return nil, false
+	}
+	m := inbox.QueryByHeightRoundSignatory(height, round, signatory)
+	return m, m != nil
+}
+
+// LastCommit returns the most recent block that was committed, or synced, by
+// the Process, together with the 2F+1 precommits that prove it was committed.
+// It returns false if the Process has not committed a block since it was
+// created. LastCommit is safe for concurrent use.
+func (p *Process) LastCommit() (LatestCommit, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastCommit.Block.Hash().Equal(block.InvalidHash) {
+		return LatestCommit{}, false
+	}
+	precommits := make([]Precommit, len(p.lastCommit.Precommits))
+	copy(precommits, p.lastCommit.Precommits)
+	return LatestCommit{Block: p.lastCommit.Block, Precommits: precommits}, true
+}
+
+// PolkasByRound returns every polka that the Process has seen at the height,
+// ordered by round. It is intended for debugging why the Process locked on, or
+// proposed, a block. It is safe for concurrent use.
+func (p *Process) PolkasByRound(height block.Height) []Polka {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state.Prevotes.PolkasByRound(height)
+}
+
+// VerifyCommit returns an error if a LatestCommit is not backed by 2F+1 valid
+// precommits from the signatories at the height of its block. Unlike
+// SyncCommit, it does not validate the block, and it can verify commits at
+// heights that have already been committed. VerifyCommit is safe for
+// concurrent use.
+func (p *Process) VerifyCommit(latestCommit LatestCommit) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	signatories, f, ok := p.signatoriesAt(latestCommit.Block.Header().Height())
+	if !ok {
+		err := errors.New("invariant violation: genesis block not found")
+		p.violateInvariant(err)
+		return err
 	}
+	return latestCommit.verify(2*f+1, signatories, p.verifier, p.hasher)
+}
+
+// SignatoriesAt returns the signatories that are allowed to vote at the height,
+// taking handovers into account. It returns false if the genesis block cannot
+// be found. SignatoriesAt is safe for concurrent use.
+func (p *Process) SignatoriesAt(height block.Height) (id.Signatories, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	signatories, _, ok := p.signatoriesAt(height)
+	if !ok {
+		return nil, false
+	}
+	copied := make(id.Signatories, len(signatories))
+	copy(copied, signatories)
+	return copied, true
+}
+
+// SyncCommit fast-forwards the Process to the height after a committed block,
+// if the block has not already been committed and it is backed by 2F+1 valid
+// precommits.
+// An error is returned if the commit cannot be synced. SyncCommit is safe for
+// concurrent use.
+func (p *Process) SyncCommit(latestCommit LatestCommit) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record(syncCommitInputType, latestCommit.Block.Header().Height(), latestCommit.Round(), func() ([]byte, error) {
+		return marshalInputCommit(latestCommit)
+	})
+
+	return p.syncLatestCommit(latestCommit)
 }
 
 func (p *Process) resend(height block.Height, round block.Round) {
@@ -204,64 +829,206 @@ func (p *Process) resend(height block.Height, round block.Round) {
 	prevote := p.state.Prevotes.QueryByHeightRoundSignatory(height, round, p.signatory)
 	precommit := p.state.Precommits.QueryByHeightRoundSignatory(height, round, p.signatory)
 	if proposal != nil {
-		p.broadcaster.Broadcast(proposal)
+		p.broadcast(proposal)
 	}
 	if prevote != nil {
-		p.broadcaster.Broadcast(prevote)
+		p.broadcast(prevote)
 	}
 	if precommit != nil {
-		p.broadcaster.Broadcast(precommit)
+		p.broadcast(precommit)
 	}
 }
 
 func (p *Process) startRound(round block.Round) {
 	p.state.CurrentRound = round
 	p.state.CurrentStep = StepPropose
+	p.awaitingTrigger = false
+	p.dropAbandonedRounds()
+	if p.didStartRound != nil {
+		p.didStartRound(p.state.CurrentHeight, p.state.CurrentRound)
+	}
 
 	// If process p is the proposer.
-	if p.signatory.Equal(p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)) {
-		var proposal block.Block
-		if p.state.ValidBlock.Hash() != block.InvalidHash {
-			proposal = p.state.ValidBlock
+	proposer := p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)
+	if p.signatory.Equal(proposer) {
+		if p.observer != nil {
+			p.observer.DidBecomeProposer(p.state.CurrentHeight, p.state.CurrentRound)
+		}
+		if p.waitForTrigger {
+			p.awaitingTrigger = true
+			p.scheduleTimeoutPropose(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPropose, p.state.CurrentRound))
+			return
+		}
+		p.propose()
+	} else if propose := p.bufferedProposal(proposer); propose != nil {
+		// Act on the proposal for the round, if it was received (and
+		// buffered) before the round started
+		p.scheduleTimeoutPropose(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPropose, p.state.CurrentRound))
+		if propose.ValidRound() == block.InvalidRound {
+			p.prevoteProposal(propose)
 		} else {
-			proposal = p.proposer.BlockProposal(p.state.CurrentHeight, p.state.CurrentRound)
+			p.checkProposeInCurrentHeightAndRoundWithPrevotes()
 		}
-		propose := NewPropose(
-			p.state.CurrentHeight,
-			p.state.CurrentRound,
-			proposal,
-			p.state.ValidRound,
-		)
+	} else if p.offline != nil && p.offline.IsOffline(proposer, p.state.CurrentHeight) {
+		// Do not wait for a proposal from an offline proposer
+		p.logger.Debugf("skipped propose timeout at height=%v and round=%v (offline proposer=%v)", p.state.CurrentHeight, p.state.CurrentRound, proposer)
+		p.timeoutPropose(p.state.CurrentHeight, p.state.CurrentRound)
+	} else {
+		p.scheduleTimeoutPropose(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPropose, p.state.CurrentRound))
+	}
+}
 
-		// Include the previous block for nodes to catch up
-		previousBlock, ok := p.blockchain.BlockAtHeight(p.state.CurrentHeight - 1)
-		if !ok {
-			panic("fail to get previous block from storage")
+// bufferedProposal returns the proposal from the proposer of the current height
+// and round, if it has already been received. Proposals are only acted upon in
+// their own round: proposals for earlier rounds are never prevoted, and
+// proposals for later rounds are buffered until their round starts.
+func (p *Process) bufferedProposal(proposer id.Signatory) *Propose {
+	m := p.state.Proposals.QueryByHeightRoundSignatory(p.state.CurrentHeight, p.state.CurrentRound, proposer)
+	if m == nil {
+		return nil
+	}
+	return m.(*Propose)
+}
+
+// propose broadcasts a proposal at the current height and round, or resigns if
+// there is no block to propose. It must only be called by the proposer.
+func (p *Process) propose() {
+	proposal, validRound := p.reproposal()
+	if proposal.Hash().Equal(block.InvalidHash) {
+		proposal = p.proposer.BlockProposal(p.state.CurrentHeight, p.state.CurrentRound)
+		if proposal.Hash().Equal(block.InvalidHash) {
+			p.resign()
+			return
 		}
-		messages := p.state.Precommits.QueryMessagesByHeightWithHighestRound(p.state.CurrentHeight - 1)
-		commits := make([]Precommit, 0, len(messages))
+	}
+	propose := NewPropose(
+		p.state.CurrentHeight,
+		p.state.CurrentRound,
+		proposal,
+		validRound,
+	)
+
+	// Include the previous block for nodes to catch up
+	previousBlock, ok := p.blockchain.BlockAtHeight(p.state.CurrentHeight - 1)
+	if !ok {
+		p.violateInvariant(fmt.Errorf("invariant violation: previous block at height=%v not found", p.state.CurrentHeight-1))
+		p.resign()
+		return
+	}
+	messages := p.state.Precommits.QueryMessagesByHeightWithHighestRound(p.state.CurrentHeight - 1)
+	commits := make([]Precommit, 0, len(messages))
+	for _, message := range messages {
+		commit := message.(*Precommit)
+		if commit.blockHash.Equal(previousBlock.Hash()) {
+			commits = append(commits, *commit)
+		}
+	}
+	propose.latestCommit = LatestCommit{
+		Block:      previousBlock,
+		Precommits: commits,
+	}
+
+	// Include the polka that justifies the valid round, for nodes that did
+	// not see it to accept the proposal
+	if validRound > block.InvalidRound {
+		messages := p.state.Prevotes.QueryMessagesByHeightRoundBlockHash(p.state.CurrentHeight, validRound, proposal.Hash())
+		propose.polka = make(Polka, 0, len(messages))
 		for _, message := range messages {
-			commit := message.(*Precommit)
-			if commit.blockHash.Equal(previousBlock.Hash()) {
-				commits = append(commits, *commit)
-			}
+			propose.polka = append(propose.polka, *message.(*Prevote))
 		}
-		propose.latestCommit = LatestCommit{
-			Block:      previousBlock,
-			Precommits: commits,
+	}
+	p.logger.Infof("🔊 proposed block=%v at height=%v and round=%v", propose.BlockHash(), propose.height, propose.round)
+	p.broadcast(propose)
+}
+
+// reproposal returns the block that must be re-proposed, and the round that
+// justifies it, when the Process is the proposer. The valid block is preferred,
+// because it is at least as recent as the locked block. The locked block is
+// re-proposed if there is no valid block, so that a locked Process never
+// proposes a block that it cannot prevote for. If the Process is neither
+// locked, nor has a valid block, then an invalid block is returned and a new
+// block must be proposed.
+func (p *Process) reproposal() (block.Block, block.Round) {
+	if !p.state.ValidBlock.Hash().Equal(block.InvalidHash) {
+		return p.state.ValidBlock, p.state.ValidRound
+	}
+	if !p.state.LockedBlock.Hash().Equal(block.InvalidHash) {
+		return p.state.LockedBlock, p.state.LockedRound
+	}
+	return block.InvalidBlock, block.InvalidRound
+}
+
+// dropAbandonedRounds drops the prevotes at the current `block.Height` for
+// rounds that can no longer affect the Process, so that the memory used by the
+// Inboxes is bounded when a height takes many rounds. Prevotes for the previous
+// round are kept, because a late polka can still lock on its proposal.
+// Prevotes for the locked round, and all rounds after it, are kept, because
+// they can justify unlocking. Prevotes for the valid round are kept, because
+// they are embedded as a polka when proposing the valid block. Proposals and
+// precommits are kept for every round until the height is committed, because
+// late precommits for any round can still commit its proposal.
+func (p *Process) dropAbandonedRounds() {
+	keepFrom := p.state.CurrentRound - 1
+	if p.state.LockedRound != block.InvalidRound && p.state.LockedRound < keepFrom {
+		keepFrom = p.state.LockedRound
+	}
+	for _, round := range p.state.Prevotes.rounds(p.state.CurrentHeight) {
+		if round >= keepFrom || round == p.state.ValidRound {
+			continue
 		}
-		p.logger.Infof("🔊 proposed block=%v at height=%v and round=%v", propose.BlockHash(), propose.height, propose.round)
-		p.broadcaster.Broadcast(propose)
-	} else {
-		p.scheduleTimeoutPropose(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPropose, p.state.CurrentRound))
+		p.state.Prevotes.DropRound(p.state.CurrentHeight, round)
 	}
 }
 
+// resign from proposing in the current round, and immediately prevote nil
+// instead of waiting for the other processes to timeout.
+func (p *Process) resign() {
+	resign := NewResign(p.state.CurrentHeight, p.state.CurrentRound)
+	p.logger.Infof("🏳️ resigned at height=%v and round=%v", resign.height, resign.round)
+	p.broadcast(resign)
+
+	prevote := NewPrevote(
+		p.state.CurrentHeight,
+		p.state.CurrentRound,
+		block.InvalidHash,
+		nil,
+	)
+	p.logger.Debugf("prevoted=<nil> at height=%v and round=%v (resigned)", prevote.height, prevote.round)
+	p.state.CurrentStep = StepPrevote
+	p.broadcast(prevote)
+}
+
 func (p *Process) handlePropose(propose *Propose) {
-	p.syncLatestCommit(propose.latestCommit)
+	// Sync to the latest commit if it is from the future
+	if propose.latestCommit.Block.Header().Height() > p.state.CurrentHeight {
+		p.syncLatestCommit(propose.latestCommit)
+	}
 
 	p.logger.Debugf("received propose at height=%v and round=%v", propose.height, propose.round)
-	n, firstTime, _, _, _ := p.state.Proposals.Insert(propose)
+
+	// Only the first proposal from a signatory at a height and round is used.
+	// A competing proposal is equivocation, and it never replaces the first
+	// one, because other processes may have already acted on the first one.
+	// Proposals with a higher valid round are only preferred across rounds,
+	// where the polka that they embed can unlock a stale lock.
+	if p.state.Proposals.QueryByHeightRoundSignatory(propose.height, propose.round, propose.signatory) != nil {
+		p.logger.Debugf("ignored propose at height=%v and round=%v (already received a propose from signatory=%v)", propose.height, propose.round, propose.signatory)
+		return
+	}
+
+	// Proposals that embed a polka are only accepted if the polka justifies
+	// the valid round. Proposals without a polka are accepted as before, and
+	// rely on prevotes that have been received directly.
+	if len(propose.polka) > 0 {
+		if err := p.checkPolka(propose); err != nil {
+			p.logger.Warnf("ignored propose at height=%v and round=%v (bad polka: %v)", propose.height, propose.round, err)
+			return
+		}
+		for i := range propose.polka {
+			p.handlePrevote(&propose.polka[i])
+		}
+	}
+	_, firstTime, _, _, _ := p.state.Proposals.Insert(propose)
 
 	// upon Propose{currentHeight, currentRound, block, -1}
 	if propose.Height() == p.state.CurrentHeight && propose.Round() == p.state.CurrentRound && propose.ValidRound() == block.InvalidRound {
@@ -269,35 +1036,12 @@ func (p *Process) handlePropose(propose *Propose) {
 		if propose.Signatory().Equal(p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)) {
 			// while currentStep = StepPropose
 			if p.state.CurrentStep == StepPropose {
-				var prevote *Prevote
-				nilReasons, err := p.validator.IsBlockValid(propose.Block(), true)
-				if err == nil && (p.state.LockedRound == block.InvalidRound || p.state.LockedBlock.Equal(propose.Block())) {
-					prevote = NewPrevote(
-						p.state.CurrentHeight,
-						p.state.CurrentRound,
-						propose.Block().Hash(),
-						nilReasons,
-					)
-					p.logger.Debugf("prevoted=%v at height=%v and round=%v", propose.BlockHash(), propose.height, propose.round)
-				} else {
-					prevote = NewPrevote(
-						p.state.CurrentHeight,
-						p.state.CurrentRound,
-						block.InvalidHash,
-						nilReasons,
-					)
-					p.logger.Warnf("prevoted=<nil> at height=%v and round=%v (invalid propose: %v)", propose.height, propose.round, err)
-				}
-				p.state.CurrentStep = StepPrevote
-				p.broadcaster.Broadcast(prevote)
+				p.prevoteProposal(propose)
 			}
 		}
 	}
 
-	// upon f+1 *{currentHeight, round, *, *} and round > currentRound
-	if n > p.state.Prevotes.F() && propose.Height() == p.state.CurrentHeight && propose.Round() > p.state.CurrentRound {
-		p.startRound(propose.Round())
-	}
+	p.checkRoundSkip(propose.Height(), propose.Round())
 
 	p.checkProposeInCurrentHeightAndRoundWithPrevotes()
 	if firstTime {
@@ -306,13 +1050,65 @@ func (p *Process) handlePropose(propose *Propose) {
 	p.checkProposeInCurrentHeightWithPrecommits(propose.Round())
 }
 
+// prevoteProposal prevotes for the proposed block if it is valid, and if the
+// Process can prevote for it, and otherwise prevotes nil. It must only be
+// called for a Propose{currentHeight, currentRound, block, -1} from the
+// proposer while currentStep = StepPropose.
+func (p *Process) prevoteProposal(propose *Propose) {
+	var prevote *Prevote
+	nilReasons, err := p.validator.IsBlockValid(propose.Block(), true)
+	if err == nil && p.canPrevote(propose) {
+		prevote = NewPrevote(
+			p.state.CurrentHeight,
+			p.state.CurrentRound,
+			propose.Block().Hash(),
+			nilReasons,
+		)
+		p.logger.Debugf("prevoted=%v at height=%v and round=%v", propose.BlockHash(), propose.height, propose.round)
+	} else {
+		prevote = NewPrevote(
+			p.state.CurrentHeight,
+			p.state.CurrentRound,
+			block.InvalidHash,
+			nilReasons,
+		)
+		p.logger.Warnf("prevoted=<nil> at height=%v and round=%v (invalid propose: %v)", propose.height, propose.round, err)
+	}
+	p.state.CurrentStep = StepPrevote
+	p.broadcast(prevote)
+}
+
+// checkRoundSkip starts a higher round at the current `block.Height` as soon
+// as messages of any type have been received from at least
+// `OneThirdThreshold` distinct signatories in that round. At least one of them
+// is honest, so the Process is behind, and waiting for timeouts would only
+// delay it further. If the proposal for the round has already been received,
+// it is acted upon as soon as the round starts.
+func (p *Process) checkRoundSkip(height block.Height, round block.Round) {
+	// upon f+1 *{currentHeight, round, *, *} and round > currentRound
+	if height != p.state.CurrentHeight || round <= p.state.CurrentRound {
+		return
+	}
+	signatories := map[id.Signatory]struct{}{}
+	for _, inbox := range []*Inbox{p.state.Proposals, p.state.Prevotes, p.state.Precommits} {
+		for _, m := range inbox.QueryMessagesByHeightRound(height, round) {
+			signatories[m.Signatory()] = struct{}{}
+		}
+	}
+	if len(signatories) < p.state.Prevotes.OneThirdThreshold() {
+		return
+	}
+	p.logger.Debugf("skipping to round=%v at height=%v (messages from %v signatories)", round, height, len(signatories))
+	p.startRound(round)
+}
+
 func (p *Process) handlePrevote(prevote *Prevote) {
 	prevoteDebugStr := "<nil>"
 	if !prevote.blockHash.Equal(block.InvalidHash) {
 		prevoteDebugStr = prevote.blockHash.String()
 	}
 	p.logger.Debugf("received prevote=%v at height=%v and round=%v", prevoteDebugStr, prevote.height, prevote.round)
-	n, _, _, firstTimeExceeding2F, firstTimeExceeding2FOnBlockHash := p.state.Prevotes.Insert(prevote)
+	_, _, _, firstTimeExceeding2F, firstTimeExceeding2FOnBlockHash := p.state.Prevotes.Insert(prevote)
 	if firstTimeExceeding2F && prevote.Height() == p.state.CurrentHeight && prevote.Round() == p.state.CurrentRound && p.state.CurrentStep == StepPrevote {
 		// upon 2f+1 Prevote{currentHeight, currentRound, *} while step = StepPrevote for the first time
 		p.scheduleTimeoutPrevote(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPrevote, p.state.CurrentRound))
@@ -335,13 +1131,10 @@ func (p *Process) handlePrevote(prevote *Prevote) {
 		)
 		p.logger.Debugf("precommited=<nil> at height=%v and round=%v (2f+1 prevote=<nil>)", precommit.height, precommit.round)
 		p.state.CurrentStep = StepPrecommit
-		p.broadcaster.Broadcast(precommit)
+		p.broadcast(precommit)
 	}
 
-	// upon f+1 *{currentHeight, round, *, *} and round > currentRound
-	if n > p.state.Prevotes.F() && prevote.Height() == p.state.CurrentHeight && prevote.Round() > p.state.CurrentRound {
-		p.startRound(prevote.Round())
-	}
+	p.checkRoundSkip(prevote.Height(), prevote.Round())
 
 	p.checkProposeInCurrentHeightAndRoundWithPrevotes()
 	if firstTimeExceeding2FOnBlockHash {
@@ -356,24 +1149,46 @@ func (p *Process) handlePrecommit(precommit *Precommit) {
 	}
 	p.logger.Debugf("received precommit=%v at height=%v and round=%v", precommitDebugStr, precommit.height, precommit.round)
 	// upon 2f+1 Precommit{currentHeight, currentRound, *} for the first time
-	n, _, _, firstTimeExceeding2F, _ := p.state.Precommits.Insert(precommit)
+	_, _, _, firstTimeExceeding2F, _ := p.state.Precommits.Insert(precommit)
 	if firstTimeExceeding2F && precommit.Height() == p.state.CurrentHeight && precommit.Round() == p.state.CurrentRound {
 		p.scheduleTimeoutPrecommit(p.state.CurrentHeight, p.state.CurrentRound, p.timer.Timeout(StepPrecommit, p.state.CurrentRound))
 	}
 
-	// upon f+1 *{currentHeight, round, *, *} and round > currentRound
-	if n > p.state.Precommits.F() && precommit.Height() == p.state.CurrentHeight && precommit.Round() > p.state.CurrentRound {
-		p.startRound(precommit.Round())
+	// upon 2f+1 Precommit{currentHeight, currentRound, nil}
+	if precommit.Height() == p.state.CurrentHeight && precommit.Round() == p.state.CurrentRound && precommit.blockHash.Equal(block.InvalidHash) {
+		p.checkNilCommitInCurrentHeightAndRound()
 	}
 
+	p.checkRoundSkip(precommit.Height(), precommit.Round())
+
 	p.checkProposeInCurrentHeightWithPrecommits(precommit.Round())
 }
 
+func (p *Process) handleResign(resign *Resign) {
+	p.logger.Debugf("received resign at height=%v and round=%v", resign.height, resign.round)
+
+	// upon Resign{currentHeight, currentRound} from Schedule{currentHeight, currentRound} while currentStep = StepPropose
+	if resign.Height() == p.state.CurrentHeight && resign.Round() == p.state.CurrentRound && p.state.CurrentStep == StepPropose {
+		if resign.Signatory().Equal(p.scheduler.Schedule(p.state.CurrentHeight, p.state.CurrentRound)) {
+			prevote := NewPrevote(
+				p.state.CurrentHeight,
+				p.state.CurrentRound,
+				block.InvalidHash,
+				nil,
+			)
+			p.logger.Warnf("prevoted=<nil> at height=%v and round=%v (proposer resigned)", prevote.height, prevote.round)
+			p.state.CurrentStep = StepPrevote
+			p.broadcast(prevote)
+		}
+	}
+}
+
 // timeoutPropose checks if we have move to a new height, a new round or a new
 // step after the timeout. If not, prevote for a invalid block and broadcast
 // the vote, then move to prevote step.
 func (p *Process) timeoutPropose(height block.Height, round block.Round) {
 	if height == p.state.CurrentHeight && round == p.state.CurrentRound && p.state.CurrentStep == StepPropose {
+		p.action = NilMessageType
 		prevote := NewPrevote(
 			p.state.CurrentHeight,
 			p.state.CurrentRound,
@@ -381,61 +1196,158 @@ func (p *Process) timeoutPropose(height block.Height, round block.Round) {
 			nil,
 		)
 		p.logger.Warnf("prevoted=<nil> at height=%v and round=%v (timeout)", prevote.height, prevote.round)
+		transition := Transition{Type: TimedOutProposeTransitionType, Height: height, Round: round}
+		p.transitions.record(transition)
+		if p.didTimeout != nil {
+			p.didTimeout(transition)
+		}
 		p.state.CurrentStep = StepPrevote
-		p.broadcaster.Broadcast(prevote)
+		p.broadcast(prevote)
+		p.logTransition(transition, StepPropose)
 	}
 }
 
 func (p *Process) timeoutPrevote(height block.Height, round block.Round) {
 	if height == p.state.CurrentHeight && round == p.state.CurrentRound && p.state.CurrentStep == StepPrevote {
+		p.action = NilMessageType
 		precommit := NewPrecommit(
 			p.state.CurrentHeight,
 			p.state.CurrentRound,
 			block.InvalidHash,
 		)
 		p.logger.Warnf("precommitted=<nil> at height=%v and round=%v (timeout)", precommit.height, precommit.round)
+		transition := Transition{Type: TimedOutPrevoteTransitionType, Height: height, Round: round}
+		p.transitions.record(transition)
+		if p.didTimeout != nil {
+			p.didTimeout(transition)
+		}
 		p.state.CurrentStep = StepPrecommit
-		p.broadcaster.Broadcast(precommit)
+		p.broadcast(precommit)
+		p.logTransition(transition, StepPrevote)
 	}
 }
 
 func (p *Process) timeoutPrecommit(height block.Height, round block.Round) {
 	if height == p.state.CurrentHeight && round == p.state.CurrentRound {
+		from := p.state.CurrentStep
+		p.action = NilMessageType
+		transition := Transition{Type: TimedOutPrecommitTransitionType, Height: height, Round: round}
+		p.transitions.record(transition)
+		if p.didTimeout != nil {
+			p.didTimeout(transition)
+		}
 		p.startRound(p.state.CurrentRound + 1)
+		p.logTransition(transition, from)
 	}
 }
 
 func (p *Process) scheduleTimeoutPropose(height block.Height, round block.Round, duration time.Duration) {
-	go func() {
-		time.Sleep(duration)
+	p.afterTimeout(duration, func() {
+		p.handleTimeoutPropose(height, round)
+	})
+}
 
-		p.mu.Lock()
-		defer p.mu.Unlock()
+func (p *Process) scheduleTimeoutPrevote(height block.Height, round block.Round, duration time.Duration) {
+	p.afterTimeout(duration, func() {
+		p.handleTimeoutPrevote(height, round)
+	})
+}
 
-		p.timeoutPropose(height, round)
-	}()
+func (p *Process) scheduleTimeoutPrecommit(height block.Height, round block.Round, duration time.Duration) {
+	p.afterTimeout(duration, func() {
+		p.handleTimeoutPrecommit(height, round)
+	})
 }
 
-func (p *Process) scheduleTimeoutPrevote(height block.Height, round block.Round, duration time.Duration) {
+// afterTimeout calls a function, while holding the lock of the Process, once
+// a duration has passed on its Clock. The function is not called if the
+// Process is stopped before the duration has passed. The Clock is asked to
+// wait before afterTimeout returns, so that advancing the Clock afterwards is
+// guaranteed to trigger the timeout.
+func (p *Process) afterTimeout(duration time.Duration, f func()) {
+	timeout := p.clock.After(duration)
 	go func() {
-		time.Sleep(duration)
+		select {
+		case <-timeout:
+		case <-p.done:
+			return
+		}
 
 		p.mu.Lock()
 		defer p.mu.Unlock()
 
-		p.timeoutPrevote(height, round)
+		select {
+		case <-p.done:
+		default:
+			f()
+		}
 	}()
 }
 
-func (p *Process) scheduleTimeoutPrecommit(height block.Height, round block.Round, duration time.Duration) {
-	go func() {
-		time.Sleep(duration)
+// violateInvariant logs an invariant violation, and reports it to the callback
+// (if there is one). It must only be called when the Process can recover from
+// the violation.
+func (p *Process) violateInvariant(err error) {
+	p.logger.Errorf("%v", err)
+	if p.didViolateInvariant != nil {
+		p.didViolateInvariant(err)
+	}
+}
 
-		p.mu.Lock()
-		defer p.mu.Unlock()
+// didFailToCommit reports to the Observer (if there is one) that a committed
+// Block cannot be stored.
+func (p *Process) didFailToCommit(height block.Height, err error) {
+	if p.observer != nil {
+		p.observer.DidFailToCommitBlock(height, err)
+	}
+}
 
-		p.timeoutPrecommit(height, round)
-	}()
+// broadcast a Message, and remember its type as the action of the current
+// transition. Votes are extended before they are broadcast, unless they are
+// being resent with the extension that they were first broadcast with.
+func (p *Process) broadcast(m Message) {
+	if p.extendVote != nil {
+		switch m := m.(type) {
+		case *Prevote:
+			if m.extension == nil {
+				m.extension = copyExtension(p.extendVote(m.Type(), m.height, m.round, m.blockHash))
+			}
+		case *Precommit:
+			if m.extension == nil {
+				m.extension = copyExtension(p.extendVote(m.Type(), m.height, m.round, m.blockHash))
+			}
+		}
+	}
+	p.action = m.Type()
+	p.broadcaster.Broadcast(m)
+}
+
+// logTransition logs a Transition, the Step before and after it, and the type
+// of the most recent Message broadcast as a result of it. Nothing is allocated
+// if the logger does not log at the debug level.
+func (p *Process) logTransition(transition Transition, from Step) {
+	if !isDebugEnabled(p.logger) {
+		return
+	}
+	p.logger.WithFields(logrus.Fields{
+		"height":     transition.Height,
+		"round":      transition.Round,
+		"from":       from,
+		"to":         p.state.CurrentStep,
+		"transition": transition.Type,
+		"action":     p.action,
+	}).Debugf("transitioned from step=%v to step=%v", from, p.state.CurrentStep)
+}
+
+func isDebugEnabled(logger logrus.FieldLogger) bool {
+	switch logger := logger.(type) {
+	case *logrus.Logger:
+		return logger.IsLevelEnabled(logrus.DebugLevel)
+	case *logrus.Entry:
+		return logger.Logger.IsLevelEnabled(logrus.DebugLevel)
+	default:
+		return true
+	}
 }
 
 func (p *Process) checkProposeInCurrentHeightAndRoundWithPrevotes() {
@@ -454,7 +1366,7 @@ func (p *Process) checkProposeInCurrentHeightAndRoundWithPrevotes() {
 			if p.state.CurrentStep == StepPropose && propose.ValidRound() < p.state.CurrentRound {
 				var prevote *Prevote
 				nilReasons, err := p.validator.IsBlockValid(propose.Block(), true)
-				if err == nil && (p.state.LockedRound <= propose.ValidRound() || p.state.LockedBlock.Equal(propose.Block())) {
+				if err == nil && p.canPrevote(propose) {
 					prevote = NewPrevote(
 						p.state.CurrentHeight,
 						p.state.CurrentRound,
@@ -473,12 +1385,30 @@ func (p *Process) checkProposeInCurrentHeightAndRoundWithPrevotes() {
 				}
 
 				p.state.CurrentStep = StepPrevote
-				p.broadcaster.Broadcast(prevote)
+				p.broadcast(prevote)
 			}
 		}
 	}
 }
 
+// canPrevote returns true if the Process is not locked, if it is locked on the
+// proposed block, or if the UnlockStrategy allows it to unlock. Blocks are
+// compared by hash, because the hash is computed over the contents of the
+// block, and it is the hash that Prevotes and Precommits vote for.
+//
+// The only basis for unlocking is a polka for the proposed block, at the valid
+// round of the proposal. A polka for nil, even at a round higher than the
+// locked round, is never a basis for unlocking: a locked Process prevotes nil
+// for every other block, so a polka for nil can form after its locked block
+// has been committed, and unlocking on it would allow a conflicting block to
+// be committed.
+func (p *Process) canPrevote(propose *Propose) bool {
+	if p.state.LockedRound == block.InvalidRound || p.state.LockedBlock.Hash().Equal(propose.BlockHash()) {
+		return true
+	}
+	return p.unlock.CanUnlock(p.state.LockedRound, p.state.LockedBlock, propose)
+}
+
 // checkProposeInCurrentHeightAndRoundWithPrevotesForTheFirstTime must only be
 // called when a Propose and 2f+1 Prevotes has been seen for the first time at
 // the current `block.Height` and `block.Round`. This can happen when a Propose
@@ -510,7 +1440,7 @@ func (p *Process) checkProposeInCurrentHeightAndRoundWithPrevotesForTheFirstTime
 					propose.Block().Hash(),
 				)
 				p.logger.Debugf("precommitted=%v at height=%v and round=%v", precommit.blockHash, p.state.CurrentHeight, p.state.CurrentRound)
-				p.broadcaster.Broadcast(precommit)
+				p.broadcast(precommit)
 			}
 		} else {
 			p.logger.Warnf("nothing precommitted at height=%v, round=%v and step=%v (invalid block: %v)", propose.height, propose.round, p.state.CurrentStep, err)
@@ -518,6 +1448,12 @@ func (p *Process) checkProposeInCurrentHeightAndRoundWithPrevotesForTheFirstTime
 	}
 }
 
+// checkProposeInCurrentHeightWithPrecommits commits the proposal at the
+// `block.Round` if 2f+1 precommits have been received for it. It does not
+// depend on the current Step, or on whether a polka for the proposal has been
+// seen, so a Process that has fallen behind can commit using the precommits of
+// other processes. It is called whenever a Propose or a Precommit is received,
+// so that the commit forms regardless of which one arrives last.
 func (p *Process) checkProposeInCurrentHeightWithPrecommits(round block.Round) {
 	// upon Propose{currentHeight, round, block, *} from Schedule(currentHeight, round)
 	m := p.state.Proposals.QueryByHeightRoundSignatory(p.state.CurrentHeight, round, p.scheduler.Schedule(p.state.CurrentHeight, round))
@@ -531,11 +1467,29 @@ func (p *Process) checkProposeInCurrentHeightWithPrecommits(round block.Round) {
 	if n > 2*p.state.Precommits.F() {
 		// while !BlockExistsAtHeight(currentHeight)
 		if !p.blockchain.BlockExistsAtHeight(p.state.CurrentHeight) {
+			// Only the precommits for the proposed block back the commit, even
+			// if precommits for other blocks were received in the round
+			messages := p.state.Precommits.QueryMessagesByHeightRoundBlockHash(p.state.CurrentHeight, round, propose.BlockHash())
+			precommits := make([]Precommit, 0, len(messages))
+			for _, message := range messages {
+				precommits = append(precommits, *message.(*Precommit))
+			}
+
 			_, err := p.validator.IsBlockValid(propose.Block(), false)
 			if err == nil {
-				p.blockchain.InsertBlockAtHeight(p.state.CurrentHeight, propose.Block())
+				if err := p.blockchain.InsertBlockAtHeight(p.state.CurrentHeight, propose.Block()); err != nil {
+					p.logger.Errorf("nothing committed at height=%v and round=%v (cannot store block: %v)", propose.height, round, err)
+					p.didFailToCommit(p.state.CurrentHeight, err)
+					return
+				}
+				p.lastCommit = LatestCommit{Block: propose.Block(), Precommits: precommits}
+				if p.didCommit != nil {
+					p.didCommit(propose)
+				}
 				p.state.CurrentHeight++
 				p.state.Reset(p.state.CurrentHeight - 1)
+				p.changeF()
+				p.transitions.drop(p.state.CurrentHeight - 1)
 				if p.observer != nil {
 					p.observer.DidCommitBlock(p.state.CurrentHeight - 1)
 				}
@@ -548,63 +1502,149 @@ func (p *Process) checkProposeInCurrentHeightWithPrecommits(round block.Round) {
 	}
 }
 
-func (p *Process) syncLatestCommit(latestCommit LatestCommit) {
-	// Check that the latest commit is from the future
-	if latestCommit.Block.Header().Height() <= p.state.CurrentHeight {
+// checkNilCommitInCurrentHeightAndRound starts the next round, without waiting
+// for the precommit timeout, if there are 2f+1 nil precommits at the current
+// `block.Height` and `block.Round`. No block can be committed in a round in
+// which 2f+1 processes have precommitted nil. Like every other Message, the
+// nil precommits are expected to have been verified before they were handled.
+func (p *Process) checkNilCommitInCurrentHeightAndRound() {
+	n := p.state.Precommits.QueryByHeightRoundBlockHash(p.state.CurrentHeight, p.state.CurrentRound, block.InvalidHash)
+	if n <= 2*p.state.Precommits.F() {
 		return
 	}
 
+	p.logger.Infof("⏭️ skipped round=%v at height=%v (2f+1 precommit=<nil>)", p.state.CurrentRound, p.state.CurrentHeight)
+	p.startRound(p.state.CurrentRound + 1)
+}
+
+func (p *Process) syncLatestCommit(latestCommit LatestCommit) error {
+	// Check that the latest commit has not already been committed
+	if latestCommit.Block.Header().Height() < p.state.CurrentHeight {
+		return fmt.Errorf("expected commit at height>=%v, got commit at height=%v", p.state.CurrentHeight, latestCommit.Block.Header().Height())
+	}
+
 	// Check the proposed block and previous block without historical data. It
 	// needs the validator to store the previous execute state.
 	_, err := p.validator.IsBlockValid(latestCommit.Block, false)
 	if err != nil {
 		p.logger.Warnf("error syncing to height=%v and round=%v (invalid block: %v)", latestCommit.Block.Header().Height(), latestCommit.Block.Header().Round(), err)
-		return
+		return fmt.Errorf("invalid block: %v", err)
 	}
 
 	// Validate the commits
-	signatories := map[id.Signatory]struct{}{}
-	baseBlock, ok := p.blockchain.BlockAtHeight(0)
+	signatories, f, ok := p.signatoriesAt(latestCommit.Block.Header().Height())
 	if !ok {
-		panic("no genesis block")
-	}
-	for _, sig := range baseBlock.Header().Signatories() {
-		signatories[sig] = struct{}{}
-	}
-	for _, commit := range latestCommit.Precommits {
-		if err := Verify(&commit); err != nil {
-			return
-		}
-		if _, ok := signatories[commit.signatory]; !ok {
-			return
-		}
-		if !commit.blockHash.Equal(latestCommit.Block.Hash()) {
-			return
-		}
-		if commit.height != latestCommit.Block.Header().Height() {
-			return
-		}
-		if commit.round != latestCommit.Block.Header().Round() {
-			return
-		}
+		err := errors.New("invariant violation: genesis block not found")
+		p.violateInvariant(err)
+		return err
 	}
-
-	// Check we have 2f+1 distinct commits
-	signatories = map[id.Signatory]struct{}{}
-	for _, commit := range latestCommit.Precommits {
-		signatories[commit.Signatory()] = struct{}{}
-	}
-	if len(signatories) < 2*p.state.Proposals.f+1 {
-		return
+	if err := latestCommit.verify(2*f+1, signatories, p.verifier, p.hasher); err != nil {
+		p.logger.Warnf("error syncing to height=%v and round=%v (bad commit: %v)", latestCommit.Block.Header().Height(), latestCommit.Block.Header().Round(), err)
+		return fmt.Errorf("bad commit: %v", err)
 	}
 
 	// if the commits are valid, store the block if we don't have one
 	if !p.blockchain.BlockExistsAtHeight(latestCommit.Block.Header().Height()) {
-		p.blockchain.InsertBlockAtHeight(latestCommit.Block.Header().Height(), latestCommit.Block)
+		if err := p.blockchain.InsertBlockAtHeight(latestCommit.Block.Header().Height(), latestCommit.Block); err != nil {
+			p.logger.Errorf("error syncing to height=%v and round=%v (cannot store block: %v)", latestCommit.Block.Header().Height(), latestCommit.Round(), err)
+			p.didFailToCommit(latestCommit.Block.Header().Height(), err)
+			return fmt.Errorf("cannot store block: %v", err)
+		}
 	}
+	p.lastCommit = latestCommit
 	p.logger.Infof("syncing from height=%v to height=%v", p.state.CurrentHeight, latestCommit.Block.Header().Height()+1)
 	p.state.CurrentHeight = latestCommit.Block.Header().Height() + 1
 	p.state.CurrentRound = 0
 	p.state.Reset(latestCommit.Block.Header().Height())
+	p.changeF()
+	p.transitions.drop(latestCommit.Block.Header().Height())
 	p.startRound(p.state.CurrentRound)
+	return nil
+}
+
+// checkPolka returns an error if the polka embedded in a Propose does not
+// contain 2F+1 distinct, valid prevotes for the proposed block at the valid
+// round of the Propose.
+func (p *Process) checkPolka(propose *Propose) error {
+	if propose.validRound == block.InvalidRound || propose.validRound >= propose.round {
+		return fmt.Errorf("unexpected polka for valid round=%v at round=%v", propose.validRound, propose.round)
+	}
+	for _, prevote := range propose.polka {
+		if prevote.height != propose.height || prevote.round != propose.validRound {
+			return fmt.Errorf("expected prevote at height=%v and round=%v, got prevote at height=%v and round=%v", propose.height, propose.validRound, prevote.height, prevote.round)
+		}
+		if !prevote.blockHash.Equal(propose.BlockHash()) {
+			return fmt.Errorf("expected prevote for block=%v, got prevote for block=%v", propose.BlockHash(), prevote.blockHash)
+		}
+	}
+
+	signatories, f, ok := p.signatoriesAt(propose.height)
+	if !ok {
+		err := errors.New("invariant violation: genesis block not found")
+		p.violateInvariant(err)
+		return err
+	}
+	return propose.polka.verify(2*f+1, signatories, p.verifier, p.hasher)
+}
+
+// A handover is a set of signatories that is used from a height onwards.
+type handover struct {
+	height      block.Height
+	signatories id.Signatories
+}
+
+// signatoriesAt returns the signatories of the latest handover at, or below,
+// the height, and the F that they tolerate. If there is no such handover, it
+// returns the signatories of the genesis block, and the F that they tolerate.
+// It returns false if the genesis block is needed, but cannot be found.
+func (p *Process) signatoriesAt(height block.Height) (id.Signatories, int, bool) {
+	for i := len(p.handovers) - 1; i >= 0; i-- {
+		if p.handovers[i].height <= height {
+			return p.handovers[i].signatories, (len(p.handovers[i].signatories) - 1) / 3, true
+		}
+	}
+	baseBlock, ok := p.blockchain.BlockAtHeight(0)
+	if !ok {
+		return nil, 0, false
+	}
+
+	// The F of the Inboxes cannot be used, because it is changed to the F of
+	// the latest handover once the handover height has been reached
+	signatories := baseBlock.Header().Signatories()
+	return signatories, (len(signatories) - 1) / 3, true
+}
+
+// changeF changes the F of every Inbox to match the signatories at the current
+// height, if they have been changed by a handover. It must be called whenever
+// the Process advances to a new height, before the first round of the height
+// is started.
+func (p *Process) changeF() {
+	for i := len(p.handovers) - 1; i >= 0; i-- {
+		if p.handovers[i].height <= p.state.CurrentHeight {
+			f := (len(p.handovers[i].signatories) - 1) / 3
+			p.state.Proposals.f = f
+			p.state.Prevotes.f = f
+			p.state.Precommits.f = f
+			return
+		}
+	}
+}
+
+// checkPrecommitsForBlock returns an error if any of the precommits is not for
+// the block hash at the expected height and round. Precommits that form a
+// commit must all reference the committed block, otherwise the commit is
+// ambiguous and must be rejected.
+func checkPrecommitsForBlock(precommits []Precommit, height block.Height, round block.Round, blockHash id.Hash) error {
+	for _, precommit := range precommits {
+		if !precommit.blockHash.Equal(blockHash) {
+			return fmt.Errorf("expected precommit for block=%v, got precommit for block=%v", blockHash, precommit.blockHash)
+		}
+		if precommit.height != height {
+			return fmt.Errorf("expected precommit at height=%v, got precommit at height=%v", height, precommit.height)
+		}
+		if precommit.round != round {
+			return fmt.Errorf("expected precommit at round=%v, got precommit at round=%v", round, precommit.round)
+		}
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	cRand "crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
@@ -20,6 +21,11 @@ import (
 	"github.com/renproject/id"
 )
 
+// unexpectedMessage is a Message whose type is not handled by a Process.
+type unexpectedMessage struct {
+	Message
+}
+
 var _ = Describe("Process", func() {
 
 	newEcdsaKey := func() *ecdsa.PrivateKey {
@@ -100,9 +106,148 @@ var _ = Describe("Process", func() {
 					proposal.Block().Equal(block)
 				})
 			})
+
+			Context("when the process is locked on a block", func() {
+				It("should re-propose the locked block with its valid round", func() {
+					// Init a default process that is locked on a block from a
+					// previous round
+					processOrigin := NewProcessOrigin(100)
+					lockedBlock := processOrigin.Proposer.BlockProposal(1, 0)
+					processOrigin.State.LockedBlock = lockedBlock
+					processOrigin.State.LockedRound = 0
+					processOrigin.State.ValidBlock = lockedBlock
+					processOrigin.State.ValidRound = 0
+					process := processOrigin.ToProcess()
+					process.StartRound(1)
+
+					// Expect the proposer to re-propose the locked block, instead
+					// of a new block
+					var message Message
+					Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+					proposal, ok := message.(*Propose)
+					Expect(ok).Should(BeTrue())
+					Expect(proposal.Round()).Should(Equal(block.Round(1)))
+					Expect(proposal.BlockHash().Equal(lockedBlock.Hash())).Should(BeTrue())
+					Expect(proposal.ValidRound()).Should(Equal(block.Round(0)))
+				})
+
+				It("should re-propose the locked block if there is no valid block", func() {
+					// Init a default process that is locked on a block, but
+					// does not have a valid block
+					processOrigin := NewProcessOrigin(100)
+					lockedBlock := processOrigin.Proposer.BlockProposal(1, 0)
+					processOrigin.State.LockedBlock = lockedBlock
+					processOrigin.State.LockedRound = 0
+					process := processOrigin.ToProcess()
+					process.StartRound(1)
+
+					var message Message
+					Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+					proposal, ok := message.(*Propose)
+					Expect(ok).Should(BeTrue())
+					Expect(proposal.BlockHash().Equal(lockedBlock.Hash())).Should(BeTrue())
+					Expect(proposal.ValidRound()).Should(Equal(block.Round(0)))
+				})
+			})
+
+			Context("when the process is only the proposer of some rounds", func() {
+				It("should notify the observer of the rounds in which it is the proposer", func() {
+					// Init a default process that is only the proposer of even
+					// rounds
+					processOrigin := NewProcessOrigin(100)
+					observer := &proposerObserver{}
+					processOrigin.Observer = observer
+					processOrigin.Scheduler = evenRoundScheduler{
+						signatory: id.NewSignatory(processOrigin.PrivateKey.PublicKey),
+						other:     id.NewSignatory(newEcdsaKey().PublicKey),
+					}
+					processOrigin.Timer = NewMockTimer(time.Hour)
+					process := processOrigin.ToProcess()
+					for round := block.Round(0); round < 4; round++ {
+						process.StartRound(round)
+					}
+
+					Expect(observer.heights).Should(Equal([]block.Height{1, 1}))
+					Expect(observer.rounds).Should(Equal([]block.Round{0, 2}))
+				})
+			})
+
+			Context("when the proposer cannot build a block", func() {
+				It("should resign and broadcast a nil prevote", func() {
+					// Init a default process to be modified
+					processOrigin := NewProcessOrigin(100)
+					processOrigin.Proposer = resigningProposer{}
+					process := processOrigin.ToProcess()
+					process.StartRound(0)
+
+					// Expect the proposer to broadcast a resign, followed by a
+					// nil prevote
+					var message Message
+					Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+					resign, ok := message.(*Resign)
+					Expect(ok).Should(BeTrue())
+					Expect(resign.Height()).Should(Equal(block.Height(1)))
+					Expect(resign.Round()).Should(BeZero())
+
+					Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+					prevote, ok := message.(*Prevote)
+					Expect(ok).Should(BeTrue())
+					Expect(prevote.Height()).Should(Equal(block.Height(1)))
+					Expect(prevote.Round()).Should(BeZero())
+					Expect(prevote.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+				})
+			})
 		})
 
 		Context("when the process is not proposer", func() {
+			Context("when receive a resign from the proposer before the timeout expire", func() {
+				It("should broadcast a nil prevote without waiting for the timeout", func() {
+					// Init a default process to be modified
+					processOrigin := NewProcessOrigin(100)
+
+					// Replace the scheduler and timer, and start the process
+					privateKey := newEcdsaKey()
+					processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(privateKey.PublicKey))
+					processOrigin.Timer = NewMockTimer(time.Minute)
+					process := processOrigin.ToProcess()
+					process.Start()
+
+					// Resign on behalf of the proposer
+					resign := NewResign(1, 0)
+					Expect(Sign(resign, *privateKey)).NotTo(HaveOccurred())
+					process.HandleMessage(resign)
+
+					// Expect a nil prevote long before the propose timeout
+					var message Message
+					Eventually(processOrigin.BroadcastMessages, time.Second).Should(Receive(&message))
+					prevote, ok := message.(*Prevote)
+					Expect(ok).Should(BeTrue())
+					Expect(prevote.Height()).Should(Equal(block.Height(1)))
+					Expect(prevote.Round()).Should(BeZero())
+					Expect(prevote.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+				})
+			})
+
+			Context("when receive a resign from a process that is not the proposer", func() {
+				It("should ignore the resign", func() {
+					// Init a default process to be modified
+					processOrigin := NewProcessOrigin(100)
+
+					// Replace the scheduler and timer, and start the process
+					processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+					processOrigin.Timer = NewMockTimer(time.Minute)
+					process := processOrigin.ToProcess()
+					process.Start()
+
+					// Resign on behalf of a process that is not the proposer
+					resign := NewResign(1, 0)
+					Expect(Sign(resign, *newEcdsaKey())).NotTo(HaveOccurred())
+					process.HandleMessage(resign)
+
+					Consistently(processOrigin.BroadcastMessages, time.Second).ShouldNot(Receive())
+				})
+			})
+
 			Context("when receive a propose from the proposer before the timeout expire", func() {
 				Context("when the block is valid", func() {
 					It("should broadcast a prevote to the proposal", func() {
@@ -179,6 +324,60 @@ var _ = Describe("Process", func() {
 					Expect(prevote.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
 				})
 			})
+
+			Context("when receive a propose from the proposer of a past round", func() {
+				It("should ignore the proposal", func() {
+					// Init a default process at round 2
+					processOrigin := NewProcessOrigin(100)
+					processOrigin.State.CurrentRound = 2
+
+					// Replace the scheduler and timer, and start the process
+					privateKey := newEcdsaKey()
+					processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(privateKey.PublicKey))
+					processOrigin.Timer = NewMockTimer(time.Hour)
+					process := processOrigin.ToProcess()
+					process.Start()
+
+					// Propose a valid block for round 1
+					message := NewPropose(1, 1, RandomBlock(block.Standard), block.InvalidRound)
+					Expect(Sign(message, *privateKey)).NotTo(HaveOccurred())
+					process.HandleMessage(message)
+
+					Consistently(processOrigin.BroadcastMessages, time.Second).ShouldNot(Receive())
+				})
+			})
+
+			Context("when receive a propose from the proposer of a future round", func() {
+				It("should buffer the proposal, and prevote for it when the round starts", func() {
+					// Init a default process to be modified
+					processOrigin := NewProcessOrigin(100)
+
+					// Replace the scheduler and timer, and start the process
+					privateKey := newEcdsaKey()
+					processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(privateKey.PublicKey))
+					processOrigin.Timer = NewMockTimer(time.Hour)
+					process := processOrigin.ToProcess()
+					process.Start()
+
+					// Propose a valid block for round 1
+					proposedBlock := RandomBlock(block.Standard)
+					message := NewPropose(1, 1, proposedBlock, block.InvalidRound)
+					Expect(Sign(message, *privateKey)).NotTo(HaveOccurred())
+					process.HandleMessage(message)
+					Consistently(processOrigin.BroadcastMessages, time.Second).ShouldNot(Receive())
+
+					// Expect a prevote for the buffered proposal once the
+					// process catches up with its round
+					process.StartRound(1)
+					var m Message
+					Eventually(processOrigin.BroadcastMessages).Should(Receive(&m))
+					prevote, ok := m.(*Prevote)
+					Expect(ok).Should(BeTrue())
+					Expect(prevote.Height()).Should(Equal(block.Height(1)))
+					Expect(prevote.Round()).Should(Equal(block.Round(1)))
+					Expect(prevote.BlockHash().Equal(proposedBlock.Hash())).Should(BeTrue())
+				})
+			})
 		})
 	})
 
@@ -361,6 +560,452 @@ var _ = Describe("Process", func() {
 		})
 	})
 
+	Context("when the process receives nil precommits at the current height and round", func() {
+		It("should not start the next round with fewer than 2f+1 nil precommits", func() {
+			f := rand.Intn(100) + 1
+			height, round := block.Height(rand.Int()), block.Round(rand.Intn(100))
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentStep = StepPrecommit
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			process := processOrigin.ToProcess()
+
+			// Send 2f nil precommits, and f+1 precommits for a block, so that
+			// there are 2f+1 precommits in total
+			for i := 0; i < 2*f; i++ {
+				precommit := NewPrecommit(height, round, block.InvalidHash)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			for i := 0; i < f+1; i++ {
+				precommit := NewPrecommit(height, round, RandomHash())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+
+			state := testutil.GetStateFromProcess(process, f)
+			Expect(state.CurrentRound).Should(Equal(round))
+			Expect(state.CurrentStep).Should(Equal(StepPrecommit))
+		})
+
+		It("should start the next round without waiting for the timeout with 2f+1 nil precommits", func() {
+			f := rand.Intn(100) + 1
+			height, round := block.Height(rand.Int()), block.Round(rand.Intn(100))
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentStep = StepPrecommit
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			process := processOrigin.ToProcess()
+
+			for i := 0; i < 2*f+1; i++ {
+				state := testutil.GetStateFromProcess(process, f)
+				Expect(state.CurrentRound).Should(Equal(round))
+
+				precommit := NewPrecommit(height, round, block.InvalidHash)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+
+			state := testutil.GetStateFromProcess(process, f)
+			Expect(state.CurrentRound).Should(Equal(round + 1))
+			Expect(state.CurrentStep).Should(Equal(StepPropose))
+		})
+	})
+
+	Context("when asking whether a commit is possible in the current round", func() {
+		It("should only return true once 2f+1 precommits are for the same block", func() {
+			f := rand.Intn(100) + 1
+			height, round := block.Height(rand.Int()), block.Round(rand.Intn(100))
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentStep = StepPrecommit
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			process := processOrigin.ToProcess()
+
+			// Nil precommits, and precommits for other blocks, do not count
+			// towards the commit
+			for i := 0; i < 2*f; i++ {
+				precommit := NewPrecommit(height, round, block.InvalidHash)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			precommit := NewPrecommit(height, round, RandomHash())
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+			process.HandleMessage(precommit)
+			Expect(process.CanCommitThisRound()).Should(BeFalse())
+
+			blockHash := RandomHash()
+			for i := 0; i < 2*f+1; i++ {
+				Expect(process.CanCommitThisRound()).Should(BeFalse())
+				precommit := NewPrecommit(height, round, blockHash)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			Expect(process.CanCommitThisRound()).Should(BeTrue())
+		})
+
+		It("should return false for precommits at other rounds", func() {
+			f := rand.Intn(100) + 1
+			height, round := block.Height(rand.Int()), block.Round(rand.Intn(100)+1)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			process := processOrigin.ToProcess()
+
+			blockHash := RandomHash()
+			for i := 0; i < 2*f+1; i++ {
+				precommit := NewPrecommit(height, round-1, blockHash)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			Expect(process.CanCommitThisRound()).Should(BeFalse())
+		})
+	})
+
+	Context("when starting a new round at the same height", func() {
+		It("should drop the prevotes for abandoned rounds", func() {
+			f := rand.Intn(10) + 1
+			height, round := RandomHeight(), block.Round(rand.Intn(100)+10)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			process := processOrigin.ToProcess()
+
+			// Receive f prevotes and f precommits at every round up to the
+			// current round, without triggering any transitions
+			for r := block.Round(0); r <= round; r++ {
+				for i := 0; i < f; i++ {
+					process.HandleMessage(RandomSingedMessageWithHeightAndRound(height, r, PrevoteMessageType))
+					process.HandleMessage(RandomSingedMessageWithHeightAndRound(height, r, PrecommitMessageType))
+				}
+			}
+			process.StartRound(round + 1)
+
+			// Expect the precommits for every round to be kept, because late
+			// precommits can still commit
+			state := testutil.GetStateFromProcess(process, f)
+			for r := block.Round(0); r < round; r++ {
+				Expect(state.Prevotes.QueryByHeightRound(height, r)).Should(Equal(0))
+				Expect(state.Precommits.QueryByHeightRound(height, r)).Should(Equal(f))
+			}
+			Expect(state.Prevotes.QueryByHeightRound(height, round)).Should(Equal(f))
+			Expect(state.Precommits.QueryByHeightRound(height, round)).Should(Equal(f))
+		})
+
+		It("should commit when late precommits arrive for an abandoned round", func() {
+			f := rand.Intn(10) + 1
+			height := RandomHeight()
+			proposerKey := newEcdsaKey()
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			process := processOrigin.ToProcess()
+
+			// Receive the proposal and f precommits at round 0, and then move
+			// on to round 2
+			propose := NewPropose(height, 0, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			for i := 0; i < f; i++ {
+				precommit := NewPrecommit(height, 0, propose.BlockHash())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			process.StartRound(1)
+			process.StartRound(2)
+			Expect(processOrigin.Blockchain.BlockExistsAtHeight(height)).Should(BeFalse())
+
+			// Expect the proposal to be committed once 2f+1 precommits for
+			// round 0 have arrived
+			for i := 0; i < f+1; i++ {
+				precommit := NewPrecommit(height, 0, propose.BlockHash())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			committedBlock, ok := processOrigin.Blockchain.BlockAtHeight(height)
+			Expect(ok).Should(BeTrue())
+			Expect(committedBlock.Hash()).Should(Equal(propose.BlockHash()))
+			Expect(process.CurrentHeight()).Should(Equal(height + 1))
+		})
+
+		It("should keep the messages for the locked round and later rounds", func() {
+			f := rand.Intn(10) + 1
+			height, round := RandomHeight(), block.Round(rand.Intn(100)+10)
+			lockedRound := block.Round(rand.Intn(int(round) - 1))
+			lockedBlock := RandomBlock(block.Standard)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			processOrigin.State.LockedRound = lockedRound
+			processOrigin.State.LockedBlock = lockedBlock
+			processOrigin.State.ValidRound = lockedRound
+			processOrigin.State.ValidBlock = lockedBlock
+			process := processOrigin.ToProcess()
+
+			for r := block.Round(0); r <= round; r++ {
+				for i := 0; i < f; i++ {
+					process.HandleMessage(RandomSingedMessageWithHeightAndRound(height, r, PrevoteMessageType))
+				}
+			}
+			process.StartRound(round + 1)
+
+			state := testutil.GetStateFromProcess(process, f)
+			for r := block.Round(0); r <= round; r++ {
+				if r < lockedRound {
+					Expect(state.Prevotes.QueryByHeightRound(height, r)).Should(Equal(0))
+					continue
+				}
+				Expect(state.Prevotes.QueryByHeightRound(height, r)).Should(Equal(f))
+			}
+		})
+	})
+
+	Context("when locked on a block and a different block is proposed", func() {
+		newLockedOrigin := func(f int, proposerKey *ecdsa.PrivateKey) ProcessOrigin {
+			height, round := RandomHeight(), block.Round(rand.Intn(100)+1)
+			lockedBlock := RandomBlock(block.Standard)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			processOrigin.State.LockedRound = round - 1
+			processOrigin.State.LockedBlock = lockedBlock
+			processOrigin.State.ValidRound = round - 1
+			processOrigin.State.ValidBlock = lockedBlock
+			return processOrigin
+		}
+
+		It("should prevote nil with the spec unlock strategy", func() {
+			f := rand.Intn(100) + 1
+			proposerKey := newEcdsaKey()
+			processOrigin := newLockedOrigin(f, proposerKey)
+			process := processOrigin.ToProcess()
+
+			state := processOrigin.State
+			propose := NewPropose(state.CurrentHeight, state.CurrentRound, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+		})
+
+		It("should consult a custom unlock strategy", func() {
+			f := rand.Intn(100) + 1
+			proposerKey := newEcdsaKey()
+			processOrigin := newLockedOrigin(f, proposerKey)
+			strategy := &mockUnlockStrategy{canUnlock: true}
+			process := processOrigin.ToProcess()
+			process.UseUnlockStrategy(strategy)
+
+			state := processOrigin.State
+			proposedBlock := RandomBlock(block.Standard)
+			propose := NewPropose(state.CurrentHeight, state.CurrentRound, proposedBlock, block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.BlockHash()).Should(Equal(proposedBlock.Hash()))
+
+			Expect(strategy.calls).Should(Equal(1))
+			Expect(strategy.lockedRound).Should(Equal(state.LockedRound))
+			Expect(strategy.lockedBlock.Equal(state.LockedBlock)).Should(BeTrue())
+		})
+		It("should stay locked after a polka for nil at a higher round", func() {
+			f := rand.Intn(100) + 1
+			proposerKey := newEcdsaKey()
+			processOrigin := newLockedOrigin(f, proposerKey)
+			process := processOrigin.ToProcess()
+			state := processOrigin.State
+			height, round := state.CurrentHeight, state.CurrentRound
+
+			// Expect a nil prevote for a different block
+			propose := NewPropose(height, round, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			Expect(message.(*Prevote).BlockHash()).Should(Equal(block.InvalidHash))
+
+			// Expect a polka for nil to be precommitted, but not to unlock
+			for i := 0; i < 2*f+1; i++ {
+				prevote := NewPrevote(height, round, block.InvalidHash, nil)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(prevote)
+			}
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			precommit, ok := message.(*Precommit)
+			Expect(ok).Should(BeTrue())
+			Expect(precommit.BlockHash()).Should(Equal(block.InvalidHash))
+			snapshot := process.Snapshot()
+			Expect(snapshot.LockedRound).Should(Equal(state.LockedRound))
+			Expect(snapshot.LockedBlockHash).Should(Equal(state.LockedBlock.Hash()))
+
+			// Expect a nil prevote for a different block in the next round
+			process.StartRound(round + 1)
+			propose = NewPropose(height, round+1, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Round()).Should(Equal(round + 1))
+			Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+		})
+
+		It("should unlock on a polka for the proposed block at a higher round", func() {
+			f := rand.Intn(100) + 1
+			proposerKey := newEcdsaKey()
+			processOrigin := newLockedOrigin(f, proposerKey)
+			processOrigin.State.CurrentRound++
+			process := processOrigin.ToProcess()
+			state := processOrigin.State
+			height, round := state.CurrentHeight, state.CurrentRound
+			polkaRound := round - 1
+			Expect(polkaRound).Should(BeNumerically(">", state.LockedRound))
+
+			// Receive a polka for a different block, after the locked round
+			proposedBlock := RandomBlock(block.Standard)
+			for i := 0; i < 2*f+1; i++ {
+				prevote := NewPrevote(height, polkaRound, proposedBlock.Hash(), nil)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(prevote)
+			}
+
+			// Expect a prevote for the block when it is proposed again
+			propose := NewPropose(height, round, proposedBlock, polkaRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Round()).Should(Equal(round))
+			Expect(prevote.BlockHash()).Should(Equal(proposedBlock.Hash()))
+		})
+	})
+
+	Context("when forcing a round", func() {
+		newLockedOrigin := func(f int, proposerKey *ecdsa.PrivateKey) ProcessOrigin {
+			height, round := RandomHeight(), block.Round(rand.Intn(100)+1)
+			lockedBlock := RandomBlock(block.Standard)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			processOrigin.State.LockedRound = round - 1
+			processOrigin.State.LockedBlock = lockedBlock
+			processOrigin.State.ValidRound = round - 1
+			processOrigin.State.ValidBlock = lockedBlock
+			return processOrigin
+		}
+
+		It("should keep the lock, and prevote for the locked block in the higher round", func() {
+			f := rand.Intn(100) + 1
+			proposerKey := newEcdsaKey()
+			processOrigin := newLockedOrigin(f, proposerKey)
+			process := processOrigin.ToProcess()
+			state := processOrigin.State
+			forcedRound := state.CurrentRound + block.Round(rand.Intn(10)+1)
+
+			Expect(process.ForceRound(forcedRound)).Should(Succeed())
+			snapshot := process.Snapshot()
+			Expect(snapshot.CurrentRound).Should(Equal(forcedRound))
+			Expect(snapshot.CurrentStep).Should(Equal(StepPropose))
+			Expect(snapshot.LockedRound).Should(Equal(state.LockedRound))
+			Expect(snapshot.LockedBlockHash).Should(Equal(state.LockedBlock.Hash()))
+
+			// Expect a prevote for the locked block when it is proposed again
+			propose := NewPropose(state.CurrentHeight, forcedRound, state.LockedBlock, block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Height()).Should(Equal(state.CurrentHeight))
+			Expect(prevote.Round()).Should(Equal(forcedRound))
+			Expect(prevote.BlockHash()).Should(Equal(state.LockedBlock.Hash()))
+		})
+
+		It("should reject rounds that are not higher than the current round", func() {
+			f := rand.Intn(100) + 1
+			processOrigin := newLockedOrigin(f, newEcdsaKey())
+			process := processOrigin.ToProcess()
+			round := processOrigin.State.CurrentRound
+
+			Expect(process.ForceRound(round)).ShouldNot(Succeed())
+			Expect(process.ForceRound(round - 1)).ShouldNot(Succeed())
+			Expect(process.CurrentRound()).Should(Equal(round))
+			Consistently(processOrigin.BroadcastMessages).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the timeouts are driven by a mock clock", func() {
+		It("should start the next round only when the clock is advanced past the precommit timeout", func() {
+			f := rand.Intn(100) + 1
+			height, round := block.Height(rand.Int()), block.Round(rand.Int())
+			clock := NewMockClock(time.Now())
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentStep = StepPrecommit
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			processOrigin.Blockchain.InsertBlockAtHeight(height-1, RandomBlock(block.Standard))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.Clock = clock
+			process := processOrigin.ToProcess()
+
+			for i := 0; i < 2*f+1; i++ {
+				precommit := NewPrecommit(height, round, RandomBlock(RandomBlockKind()).Hash())
+				privateKey := newEcdsaKey()
+				Expect(Sign(precommit, *privateKey)).NotTo(HaveOccurred())
+				process.HandleMessage(precommit)
+			}
+
+			// Expect nothing to happen until the clock has been advanced past
+			// the timeout
+			clock.Advance(time.Hour - time.Nanosecond)
+			Expect(processOrigin.BroadcastMessages).ShouldNot(Receive())
+			Expect(testutil.GetStateFromProcess(process, f).CurrentRound).Should(Equal(round))
+
+			// Expect the process to propose in the next round once the timeout
+			// has passed
+			clock.Advance(time.Nanosecond)
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			proposal, ok := message.(*Propose)
+			Expect(ok).Should(BeTrue())
+			Expect(proposal.Height()).Should(Equal(height))
+			Expect(proposal.Round()).Should(Equal(round + 1))
+
+			state := testutil.GetStateFromProcess(process, f)
+			Expect(state.CurrentRound).Should(Equal(round + 1))
+			Expect(state.CurrentStep).Should(Equal(StepPropose))
+		})
+	})
+
 	Context("when receiving f+1 of any message whose round is higher", func() {
 		It("should start that round", func() {
 			for _, t := range []MessageType{
@@ -400,6 +1045,48 @@ var _ = Describe("Process", func() {
 				Expect(prevote.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
 			}
 		})
+
+		It("should count distinct signatories across all message types", func() {
+			f := rand.Intn(100) + 1
+			height, round := RandomHeight(), RandomRound()
+			proposerKey := newEcdsaKey()
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentHeight = height
+			processOrigin.State.CurrentRound = round
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			process := processOrigin.ToProcess()
+
+			// Send the proposal for a higher round, and f prevotes and
+			// precommits from other signatories, so that no message type
+			// reaches f+1 on its own
+			newRound := block.Round(rand.Intn(10)+1) + round
+			propose := NewPropose(height, newRound, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			for i := 0; i < f; i++ {
+				var message Message
+				if i%2 == 0 {
+					message = NewPrevote(height, newRound, RandomHash(), nil)
+				} else {
+					message = NewPrecommit(height, newRound, RandomHash())
+				}
+				Expect(process.CurrentRound()).Should(Equal(round))
+				Expect(Sign(message, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(message)
+			}
+
+			// Expect the process to skip to the higher round, and prevote for
+			// the proposal that it has already received
+			Expect(process.CurrentRound()).Should(Equal(newRound))
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Height()).Should(Equal(height))
+			Expect(prevote.Round()).Should(Equal(newRound))
+			Expect(prevote.BlockHash()).Should(Equal(propose.BlockHash()))
+		})
 	})
 
 	Context("when process in propose state", func() {
@@ -493,6 +1180,247 @@ var _ = Describe("Process", func() {
 					})
 				})
 
+				Context("when receiving competing proposals from the proposer", func() {
+					It("should keep the first proposal and ignore the competing one", func() {
+						// Init a default process that is locked on a block
+						f := rand.Intn(100) + 1
+						height, round := block.Height(rand.Int()), block.Round(3)
+
+						processOrigin := NewProcessOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						processOrigin.State.LockedBlock = RandomBlock(RandomBlockKind())
+						processOrigin.State.LockedRound = 1
+						process := processOrigin.ToProcess()
+
+						// Send a proposal justified by a polka below the locked
+						// round, and then a competing proposal justified by a
+						// polka above the locked round
+						lowerPropose := NewPropose(height, round, RandomBlock(RandomBlockKind()), 0)
+						Expect(Sign(lowerPropose, *processOrigin.PrivateKey)).Should(Succeed())
+						process.HandleMessage(lowerPropose)
+						higherPropose := NewPropose(height, round, RandomBlock(RandomBlockKind()), 2)
+						Expect(Sign(higherPropose, *processOrigin.PrivateKey)).Should(Succeed())
+						process.HandleMessage(higherPropose)
+
+						// Send 2f+1 prevotes for both polkas
+						for i := 0; i < 2*f+1; i++ {
+							prevote := NewPrevote(height, lowerPropose.ValidRound(), lowerPropose.BlockHash(), nil)
+							Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+							process.HandleMessage(prevote)
+						}
+						for i := 0; i < 2*f+1; i++ {
+							prevote := NewPrevote(height, higherPropose.ValidRound(), higherPropose.BlockHash(), nil)
+							Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+							process.HandleMessage(prevote)
+						}
+
+						// Expect the process to stay locked and prevote nil,
+						// because the first proposal cannot unlock it
+						var message Message
+						Eventually(processOrigin.BroadcastMessages, 2*time.Second).Should(Receive(&message))
+						prevote, ok := message.(*Prevote)
+						Expect(ok).Should(BeTrue())
+						Expect(prevote.Height()).Should(Equal(height))
+						Expect(prevote.Round()).Should(Equal(round))
+						Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+
+						kept, ok := process.Proposal(height, round, lowerPropose.Signatory())
+						Expect(ok).Should(BeTrue())
+						Expect(kept.BlockHash()).Should(Equal(lowerPropose.BlockHash()))
+					})
+				})
+
+				Context("when receiving a proposal with an embedded polka", func() {
+					// newPolkaOrigin returns a process origin whose genesis
+					// block contains the signatories of the returned keys. The
+					// first key belongs to the process, and the second key
+					// belongs to the proposer.
+					newPolkaOrigin := func(f int) (ProcessOrigin, []*ecdsa.PrivateKey) {
+						processOrigin := NewProcessOrigin(f)
+						keys := []*ecdsa.PrivateKey{processOrigin.PrivateKey}
+						for i := 0; i < 3*f; i++ {
+							keys = append(keys, newEcdsaKey())
+						}
+						sigs := make(id.Signatories, len(keys))
+						for i, key := range keys {
+							sigs[i] = id.NewSignatory(key.PublicKey)
+						}
+						processOrigin.Blockchain = NewMockBlockchain(sigs)
+						processOrigin.Scheduler = NewMockScheduler(sigs[1])
+						return processOrigin, keys
+					}
+
+					newPolka := func(keys []*ecdsa.PrivateKey, height block.Height, round block.Round, blockHash id.Hash) []Prevote {
+						polka := make([]Prevote, 0, len(keys))
+						for _, key := range keys {
+							prevote := NewPrevote(height, round, blockHash, nil)
+							Expect(Sign(prevote, *key)).Should(Succeed())
+							polka = append(polka, *prevote)
+						}
+						return polka
+					}
+
+					It("should prevote for the block without receiving the prevotes directly", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						process := processOrigin.ToProcess()
+
+						proposedBlock := RandomBlock(block.Standard)
+						propose := NewPropose(height, round, proposedBlock, validRound)
+						propose = ProposeWithPolka(propose, newPolka(keys[1:2*f+2], height, validRound, proposedBlock.Hash()))
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						var message Message
+						Eventually(processOrigin.BroadcastMessages, 2*time.Second).Should(Receive(&message))
+						prevote, ok := message.(*Prevote)
+						Expect(ok).Should(BeTrue())
+						Expect(prevote.Round()).Should(Equal(round))
+						Expect(prevote.BlockHash().Equal(proposedBlock.Hash())).Should(BeTrue())
+					})
+
+					It("should unlock when the polka is above the locked round", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						processOrigin.State.LockedBlock = RandomBlock(block.Standard)
+						processOrigin.State.LockedRound = 1
+						process := processOrigin.ToProcess()
+
+						// The proposal of a later round than the lock embeds a
+						// polka for another block from after the lock
+						proposedBlock := RandomBlock(block.Standard)
+						propose := NewPropose(height, round, proposedBlock, validRound)
+						propose = ProposeWithPolka(propose, newPolka(keys[1:2*f+2], height, validRound, proposedBlock.Hash()))
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						var message Message
+						Eventually(processOrigin.BroadcastMessages, 2*time.Second).Should(Receive(&message))
+						prevote, ok := message.(*Prevote)
+						Expect(ok).Should(BeTrue())
+						Expect(prevote.Round()).Should(Equal(round))
+						Expect(prevote.BlockHash().Equal(proposedBlock.Hash())).Should(BeTrue())
+					})
+
+					It("should skip to the valid round of the polka", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentStep = StepPropose
+						process := processOrigin.ToProcess()
+
+						proposedBlock := RandomBlock(block.Standard)
+						propose := NewPropose(height, round, proposedBlock, validRound)
+						propose = ProposeWithPolka(propose, newPolka(keys[1:2*f+2], height, validRound, proposedBlock.Hash()))
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						Expect(process.CurrentRound()).Should(Equal(validRound))
+						Expect(process.HasReceived(propose)).Should(BeTrue())
+					})
+
+					It("should ignore the proposal if the polka does not have 2f+1 prevotes", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						process := processOrigin.ToProcess()
+
+						// Sign one of the prevotes twice, so that there are
+						// 2f+1 prevotes from only 2f signatories
+						proposedBlock := RandomBlock(block.Standard)
+						propose := NewPropose(height, round, proposedBlock, validRound)
+						polka := newPolka(keys[1:2*f+1], height, validRound, proposedBlock.Hash())
+						polka = append(polka, polka[0])
+						propose = ProposeWithPolka(propose, polka)
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						Expect(process.HasReceived(propose)).Should(BeFalse())
+						Consistently(processOrigin.BroadcastMessages, 100*time.Millisecond).ShouldNot(Receive())
+					})
+
+					It("should ignore the proposal if the polka is for a different block", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						process := processOrigin.ToProcess()
+
+						propose := NewPropose(height, round, RandomBlock(block.Standard), validRound)
+						propose = ProposeWithPolka(propose, newPolka(keys[1:2*f+2], height, validRound, RandomHash()))
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						Expect(process.HasReceived(propose)).Should(BeFalse())
+						Consistently(processOrigin.BroadcastMessages, 100*time.Millisecond).ShouldNot(Receive())
+					})
+
+					It("should verify the polka using the verifier of the process", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = round
+						processOrigin.State.CurrentStep = StepPropose
+						process := processOrigin.ToProcess()
+						process.UseVerifier(rejectingVerifier{})
+
+						proposedBlock := RandomBlock(block.Standard)
+						propose := NewPropose(height, round, proposedBlock, validRound)
+						propose = ProposeWithPolka(propose, newPolka(keys[1:2*f+2], height, validRound, proposedBlock.Hash()))
+						Expect(Sign(propose, *keys[1])).Should(Succeed())
+						process.HandleMessage(propose)
+
+						Expect(process.HasReceived(propose)).Should(BeFalse())
+						Consistently(processOrigin.BroadcastMessages, 100*time.Millisecond).ShouldNot(Receive())
+					})
+
+					It("should embed the polka when reproposing the valid block", func() {
+						f := rand.Intn(10) + 1
+						height, round, validRound := block.Height(rand.Int()), block.Round(3), block.Round(2)
+						processOrigin, keys := newPolkaOrigin(f)
+						processOrigin.Scheduler = NewMockScheduler(processOrigin.Signatory)
+						processOrigin.Blockchain.InsertBlockAtHeight(height-1, RandomBlock(block.Standard))
+						processOrigin.State.CurrentHeight = height
+						processOrigin.State.CurrentRound = validRound
+						processOrigin.State.CurrentStep = StepPrecommit
+						validBlock := RandomBlock(block.Standard)
+						processOrigin.State.ValidBlock = validBlock
+						processOrigin.State.ValidRound = validRound
+						for _, prevote := range newPolka(keys[1:2*f+2], height, validRound, validBlock.Hash()) {
+							prevote := prevote
+							processOrigin.State.Prevotes.Insert(&prevote)
+						}
+						process := processOrigin.ToProcess()
+
+						process.StartRound(round)
+
+						var message Message
+						Eventually(processOrigin.BroadcastMessages, 2*time.Second).Should(Receive(&message))
+						propose, ok := message.(*Propose)
+						Expect(ok).Should(BeTrue())
+						Expect(propose.ValidRound()).Should(Equal(validRound))
+						Expect(propose.Block().Equal(validBlock)).Should(BeTrue())
+						Expect(propose.Polka()).Should(HaveLen(2*f + 1))
+					})
+				})
+
 				Context("when the proposal is invalid", func() {
 					It("should broadcast a nil prevote", func() {
 						// Init a default process to be modified
@@ -537,6 +1465,523 @@ var _ = Describe("Process", func() {
 		})
 	})
 
+	Context("when driving a process with random messages", func() {
+		It("should never violate an invariant", func() {
+			f := 1
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Timer = NewMockTimer(10 * time.Millisecond)
+			go func() {
+				for range processOrigin.BroadcastMessages {
+				}
+			}()
+			process := processOrigin.ToProcess()
+
+			// The scheduled proposer signs every proposal, and the other keys
+			// only vote
+			keys := []*ecdsa.PrivateKey{processOrigin.PrivateKey}
+			for i := 0; i < 3*f; i++ {
+				keys = append(keys, newEcdsaKey())
+			}
+
+			// Votes are split between a small number of blocks at each height,
+			// so that polkas and commits happen often
+			blocks := map[block.Height][]block.Block{}
+			blocksAtHeight := func(height block.Height) []block.Block {
+				if _, ok := blocks[height]; !ok {
+					for i := 0; i < 2; i++ {
+						header := RandomBlockHeaderJSON(block.Standard)
+						header.Height = height
+						blocks[height] = append(blocks[height], block.New(header.ToBlockHeader(), nil, nil, nil))
+					}
+				}
+				return blocks[height]
+			}
+
+			process.Start()
+			prev := GetStateFromProcess(process, f)
+			Expect(prev.CheckInvariants()).Should(Succeed())
+
+			for i := 0; i < 1000; i++ {
+				height := prev.CurrentHeight
+				round := prev.CurrentRound + block.Round(rand.Intn(3))
+				candidates := blocksAtHeight(height)
+				b := candidates[rand.Intn(len(candidates))]
+				blockHash := b.Hash()
+				if rand.Intn(4) == 0 {
+					blockHash = block.InvalidHash
+				}
+
+				var message Message
+				var key *ecdsa.PrivateKey
+				switch rand.Intn(3) {
+				case 0:
+					validRound := block.Round(rand.Intn(int(round)+1)) - 1
+					message = NewPropose(height, round, b, validRound)
+					key = keys[0]
+				case 1:
+					message = NewPrevote(height, round, blockHash, nil)
+					key = keys[rand.Intn(len(keys))]
+				default:
+					message = NewPrecommit(height, round, blockHash)
+					key = keys[rand.Intn(len(keys))]
+				}
+				Expect(Sign(message, *key)).Should(Succeed())
+				process.HandleMessage(message)
+
+				next := GetStateFromProcess(process, f)
+				Expect(next.CheckInvariants()).Should(Succeed())
+				Expect(CheckProgress(prev, next)).Should(Succeed())
+				prev = next
+			}
+		})
+	})
+
+	Context("when recording transitions", func() {
+		// commit a random block at the height of the process, by sending a
+		// proposal, 2f+1 prevotes and 2f+1 precommits
+		commit := func(process *Process, processOrigin ProcessOrigin, f int, height block.Height) block.Block {
+			proposedBlock := RandomBlock(block.Standard)
+			propose := NewPropose(height, 0, proposedBlock, block.InvalidRound)
+			Expect(Sign(propose, *processOrigin.PrivateKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			for i := 0; i < 2*f+1; i++ {
+				prevote := NewPrevote(height, 0, proposedBlock.Hash(), nil)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(prevote)
+			}
+			for i := 0; i < 2*f+1; i++ {
+				precommit := NewPrecommit(height, 0, proposedBlock.Hash())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+			Expect(process.CurrentHeight()).Should(Equal(height + 1))
+			return proposedBlock
+		}
+
+		drain := func(messages chan Message) {
+			go func() {
+				for range messages {
+				}
+			}()
+		}
+
+		It("should record the sequence of transitions that led to a commit", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Intn(1000) + 1)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentHeight = height
+			drain(processOrigin.BroadcastMessages)
+			process := processOrigin.ToProcess()
+			process.EnableTransitionLog(100, nil)
+
+			committedBlock := commit(process, processOrigin, f, height)
+
+			transitions := process.TransitionLog(height)
+			Expect(transitions).Should(HaveLen(1 + 2*(2*f+1)))
+			Expect(transitions[0].Type).Should(Equal(ProposedTransitionType))
+			Expect(transitions[0].Signatory).Should(Equal(processOrigin.Signatory))
+			for i, transition := range transitions[1:] {
+				if i < 2*f+1 {
+					Expect(transition.Type).Should(Equal(PrevotedTransitionType))
+				} else {
+					Expect(transition.Type).Should(Equal(PrecommittedTransitionType))
+				}
+				Expect(transition.Height).Should(Equal(height))
+				Expect(transition.Round).Should(Equal(block.Round(0)))
+				Expect(transition.BlockHash).Should(Equal(committedBlock.Hash()))
+			}
+		})
+
+		It("should only record the most recent transitions at each height", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Intn(1000) + 1)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentHeight = height
+			drain(processOrigin.BroadcastMessages)
+			process := processOrigin.ToProcess()
+			evicted := []Transition{}
+			process.EnableTransitionLog(2*f+1, func(transition Transition) {
+				evicted = append(evicted, transition)
+			})
+
+			commit(process, processOrigin, f, height)
+
+			transitions := process.TransitionLog(height)
+			Expect(transitions).Should(HaveLen(2*f + 1))
+			for _, transition := range transitions {
+				Expect(transition.Type).Should(Equal(PrecommittedTransitionType))
+			}
+
+			// Expect the oldest transitions to have been evicted in order
+			Expect(evicted).Should(HaveLen(1 + 2*f + 1))
+			Expect(evicted[0].Type).Should(Equal(ProposedTransitionType))
+			for _, transition := range evicted[1:] {
+				Expect(transition.Type).Should(Equal(PrevotedTransitionType))
+			}
+		})
+
+		It("should cap the transitions at a height when rounds are spammed", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Intn(1000) + 1)
+			maxTransitions := rand.Intn(10) + 1
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.State.CurrentHeight = height
+			drain(processOrigin.BroadcastMessages)
+			process := processOrigin.ToProcess()
+			evicted := []Transition{}
+			process.EnableTransitionLog(maxTransitions, func(transition Transition) {
+				evicted = append(evicted, transition)
+			})
+
+			// Send one nil prevote in each of many future rounds, which is not
+			// enough to skip to those rounds
+			numRounds := maxTransitions + rand.Intn(100) + 1
+			for round := 1; round <= numRounds; round++ {
+				prevote := NewPrevote(height, block.Round(round), block.InvalidHash, nil)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(prevote)
+			}
+
+			transitions := process.TransitionLog(height)
+			Expect(transitions).Should(HaveLen(maxTransitions))
+			for i, transition := range transitions {
+				Expect(transition.Round).Should(Equal(block.Round(numRounds - maxTransitions + i + 1)))
+			}
+			Expect(evicted).Should(HaveLen(numRounds - maxTransitions))
+			for i, transition := range evicted {
+				Expect(transition.Round).Should(Equal(block.Round(i + 1)))
+			}
+		})
+
+		It("should drop the transitions of a height once the next height is committed", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Intn(1000) + 1)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentHeight = height
+			drain(processOrigin.BroadcastMessages)
+			process := processOrigin.ToProcess()
+			process.EnableTransitionLog(100, nil)
+
+			commit(process, processOrigin, f, height)
+			Expect(process.TransitionLog(height)).ShouldNot(BeEmpty())
+			commit(process, processOrigin, f, height+1)
+			Expect(process.TransitionLog(height)).Should(BeEmpty())
+			Expect(process.TransitionLog(height + 1)).ShouldNot(BeEmpty())
+		})
+
+		It("should not record transitions unless the transition log is enabled", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Intn(1000) + 1)
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.State.CurrentHeight = height
+			drain(processOrigin.BroadcastMessages)
+			process := processOrigin.ToProcess()
+
+			commit(process, processOrigin, f, height)
+			Expect(process.TransitionLog(height)).Should(BeNil())
+		})
+	})
+
+	Context("when handling a message of an unexpected type", func() {
+		It("should report an invariant violation and do nothing", func() {
+			processOrigin := NewProcessOrigin(100)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			process := processOrigin.ToProcess()
+			violations := []error{}
+			process.OnInvariantViolation(func(err error) {
+				violations = append(violations, err)
+			})
+			process.Start()
+			state := GetStateFromProcess(process, 100)
+
+			height, round := process.CurrentHeight(), process.CurrentRound()
+			Expect(func() {
+				process.HandleMessage(unexpectedMessage{RandomSingedMessageWithHeightAndRound(height, round, PrevoteMessageType)})
+				process.HandleMessage(nil)
+			}).ShouldNot(Panic())
+
+			Expect(violations).Should(HaveLen(2))
+			for _, err := range violations {
+				Expect(err.Error()).Should(ContainSubstring("unexpected message type"))
+			}
+			newState := GetStateFromProcess(process, 100)
+			Expect(newState.Equal(state)).Should(BeTrue())
+			Expect(processOrigin.BroadcastMessages).ShouldNot(Receive())
+		})
+	})
+
+	Context("when asking for the next timeout", func() {
+		Context("when waiting for a proposal", func() {
+			It("should return the propose timeout if it is not the proposer", func() {
+				processOrigin := NewProcessOrigin(100)
+				processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+				processOrigin.Timer = stepTimer{}
+				processOrigin.State.CurrentRound = 3
+				processOrigin.State.CurrentStep = StepPropose
+				process := processOrigin.ToProcess()
+
+				Expect(process.NextTimeout()).Should(Equal(stepTimer{}.Timeout(StepPropose, 3)))
+			})
+
+			It("should return zero if it is the proposer", func() {
+				processOrigin := NewProcessOrigin(100)
+				processOrigin.Timer = stepTimer{}
+				processOrigin.State.CurrentStep = StepPropose
+				process := processOrigin.ToProcess()
+
+				Expect(process.NextTimeout()).Should(BeZero())
+			})
+		})
+
+		Context("when waiting for prevotes", func() {
+			It("should return the prevote timeout only after 2f+1 prevotes", func() {
+				f := rand.Intn(100) + 1
+				height, round := block.Height(rand.Int()), block.Round(rand.Intn(100))
+				processOrigin := NewProcessOrigin(f)
+				processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+				processOrigin.Timer = stepTimer{}
+				processOrigin.State.CurrentHeight = height
+				processOrigin.State.CurrentRound = round
+				processOrigin.State.CurrentStep = StepPrevote
+				process := processOrigin.ToProcess()
+
+				for i := 0; i < 2*f+1; i++ {
+					Expect(process.NextTimeout()).Should(BeZero())
+					prevote := NewPrevote(height, round, RandomHash(), nil)
+					Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+					process.HandleMessage(prevote)
+				}
+				Expect(process.NextTimeout()).Should(Equal(stepTimer{}.Timeout(StepPrevote, round)))
+			})
+		})
+
+		Context("when waiting for precommits", func() {
+			It("should return the precommit timeout only after 2f+1 precommits", func() {
+				f := rand.Intn(100) + 1
+				height, round := block.Height(rand.Int()), block.Round(rand.Intn(100))
+				processOrigin := NewProcessOrigin(f)
+				processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+				processOrigin.Timer = stepTimer{}
+				processOrigin.State.CurrentHeight = height
+				processOrigin.State.CurrentRound = round
+				processOrigin.State.CurrentStep = StepPrecommit
+				process := processOrigin.ToProcess()
+
+				for i := 0; i < 2*f+1; i++ {
+					Expect(process.NextTimeout()).Should(BeZero())
+					precommit := NewPrecommit(height, round, RandomHash())
+					Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+					process.HandleMessage(precommit)
+				}
+				Expect(process.NextTimeout()).Should(Equal(stepTimer{}.Timeout(StepPrecommit, round)))
+			})
+		})
+	})
+
+	Context("when receiving precommits before the polka", func() {
+		It("should commit the proposal without observing the polka", func() {
+			for _, precommitRound := range []block.Round{0, 2} {
+				f := rand.Intn(100) + 1
+				height := block.Height(rand.Int())
+				proposerKey := newEcdsaKey()
+
+				processOrigin := NewProcessOrigin(f)
+				processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+				processOrigin.Timer = NewMockTimer(time.Hour)
+				processOrigin.State.CurrentHeight = height
+				process := processOrigin.ToProcess()
+
+				// Other processes have seen the polka, and precommitted, while
+				// this process was behind
+				propose := NewPropose(height, precommitRound, RandomBlock(block.Standard), block.InvalidRound)
+				Expect(Sign(propose, *proposerKey)).Should(Succeed())
+				for i := 0; i < 2*f+1; i++ {
+					precommit := NewPrecommit(height, precommitRound, propose.BlockHash())
+					Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+					process.HandleMessage(precommit)
+				}
+				Expect(processOrigin.Blockchain.BlockExistsAtHeight(height)).Should(BeFalse())
+				Expect(process.CurrentRound()).Should(Equal(precommitRound))
+
+				// Expect the proposal to be committed as soon as it is received
+				process.HandleMessage(propose)
+				Expect(processOrigin.Blockchain.BlockExistsAtHeight(height)).Should(BeTrue())
+				committedBlock, ok := processOrigin.Blockchain.BlockAtHeight(height)
+				Expect(ok).Should(BeTrue())
+				Expect(committedBlock.Hash()).Should(Equal(propose.BlockHash()))
+				Expect(process.CurrentHeight()).Should(Equal(height + 1))
+
+				// Expect the process to have never precommitted, because it
+				// never observed the polka
+				for len(processOrigin.BroadcastMessages) > 0 {
+					message := <-processOrigin.BroadcastMessages
+					Expect(message.Type()).ShouldNot(Equal(MessageType(PrecommitMessageType)))
+				}
+			}
+		})
+	})
+
+	Context("when receiving precommits for different blocks", func() {
+		It("should not commit until 2f+1 precommits are for the proposed block alone", func() {
+			f := rand.Intn(100) + 1
+			height := block.Height(rand.Int())
+			proposerKey := newEcdsaKey()
+
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			process := processOrigin.ToProcess()
+
+			propose := NewPropose(height, 0, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+
+			// Expect no commit from 2f precommits for the proposed block, even
+			// though there are more than 2f+1 precommits in total
+			otherBlockHash := RandomBlock(block.Standard).Hash()
+			for i := 0; i < 2*f; i++ {
+				for _, blockHash := range []id.Hash{propose.BlockHash(), otherBlockHash} {
+					precommit := NewPrecommit(height, 0, blockHash)
+					Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+					process.HandleMessage(precommit)
+				}
+			}
+			Expect(processOrigin.Blockchain.BlockExistsAtHeight(height)).Should(BeFalse())
+			Expect(process.CurrentHeight()).Should(Equal(height))
+
+			// Expect the proposed block to be committed once it alone has 2f+1
+			// precommits
+			precommit := NewPrecommit(height, 0, propose.BlockHash())
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+			process.HandleMessage(precommit)
+			committedBlock, ok := processOrigin.Blockchain.BlockAtHeight(height)
+			Expect(ok).Should(BeTrue())
+			Expect(committedBlock.Hash()).Should(Equal(propose.BlockHash()))
+			Expect(process.CurrentHeight()).Should(Equal(height + 1))
+
+			// Expect the commit to only be backed by the precommits for the
+			// proposed block
+			lastCommit, ok := process.LastCommit()
+			Expect(ok).Should(BeTrue())
+			Expect(lastCommit.Block.Hash()).Should(Equal(propose.BlockHash()))
+			Expect(lastCommit.Precommits).Should(HaveLen(2*f + 1))
+			for _, precommit := range lastCommit.Precommits {
+				Expect(precommit.BlockHash()).Should(Equal(propose.BlockHash()))
+				Expect(precommit.Height()).Should(Equal(height))
+				Expect(precommit.Round()).Should(Equal(block.Round(0)))
+			}
+		})
+	})
+
+	Context("when extending votes", func() {
+		It("should sign the extensions with its votes, and aggregate the extensions of the commit", func() {
+			f := rand.Intn(10) + 1
+			height := block.Height(rand.Int())
+			proposerKey := newEcdsaKey()
+
+			processOrigin := NewProcessOrigin(f)
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			process := processOrigin.ToProcess()
+			process.UseVoteExtender(func(messageType MessageType, height block.Height, round block.Round, blockHash id.Hash) []byte {
+				return []byte(fmt.Sprintf("%v/%v/%v/%v", messageType, height, round, blockHash))
+			})
+
+			propose := NewPropose(height, 0, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+
+			// Expect the prevote to be extended
+			var message Message
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			prevote, ok := message.(*Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Extension()).Should(Equal([]byte(fmt.Sprintf("%v/%v/%v/%v", MessageType(PrevoteMessageType), height, 0, propose.BlockHash()))))
+
+			// Expect the precommit to be extended
+			for i := 0; i < 2*f+1; i++ {
+				prevote := NewPrevote(height, 0, propose.BlockHash(), nil)
+				Expect(Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(prevote)
+			}
+			Eventually(processOrigin.BroadcastMessages).Should(Receive(&message))
+			precommit, ok := message.(*Precommit)
+			Expect(ok).Should(BeTrue())
+			Expect(precommit.Extension()).Should(Equal([]byte(fmt.Sprintf("%v/%v/%v/%v", MessageType(PrecommitMessageType), height, 0, propose.BlockHash()))))
+
+			// Expect the extensions of the commit to be passed through
+			// unchanged, and a nil extension to be ignored
+			extensions := map[id.Signatory][]byte{}
+			for i := 0; i < 2*f+1; i++ {
+				extension := RandomBytesSlice()
+				if i == 0 {
+					extension = nil
+				}
+				precommit := NewPrecommitWithExtension(height, 0, propose.BlockHash(), extension)
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				if len(extension) > 0 {
+					extensions[precommit.Signatory()] = extension
+				}
+				process.HandleMessage(precommit)
+			}
+			Expect(process.CurrentHeight()).Should(Equal(height + 1))
+			lastCommit, ok := process.LastCommit()
+			Expect(ok).Should(BeTrue())
+			Expect(lastCommit.Extensions()).Should(Equal(extensions))
+		})
+	})
+
+	Context("when the blockchain cannot store a committed block", func() {
+		It("should halt without losing its state, and resume once the block can be stored", func() {
+			f := rand.Intn(100) + 1
+			height := block.Height(rand.Int())
+			proposerKey := newEcdsaKey()
+
+			processOrigin := NewProcessOrigin(f)
+			blockchain := &failingBlockchain{Blockchain: processOrigin.Blockchain, err: errors.New("disk full")}
+			observer := &commitFailureObserver{}
+			processOrigin.Blockchain = blockchain
+			processOrigin.Observer = observer
+			processOrigin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			processOrigin.Timer = NewMockTimer(time.Hour)
+			processOrigin.State.CurrentHeight = height
+			process := processOrigin.ToProcess()
+
+			propose := NewPropose(height, 0, RandomBlock(block.Standard), block.InvalidRound)
+			Expect(Sign(propose, *proposerKey)).Should(Succeed())
+			process.HandleMessage(propose)
+			for i := 0; i < 2*f+1; i++ {
+				precommit := NewPrecommit(height, 0, propose.BlockHash())
+				Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+				process.HandleMessage(precommit)
+			}
+
+			// Expect the process to halt at the height, and report the error
+			Expect(blockchain.BlockExistsAtHeight(height)).Should(BeFalse())
+			Expect(process.CurrentHeight()).Should(Equal(height))
+			Expect(observer.heights).ShouldNot(BeEmpty())
+			Expect(observer.heights[0]).Should(Equal(height))
+			Expect(observer.errs[0]).Should(Equal(blockchain.err))
+			snapshot := process.Snapshot()
+			Expect(snapshot.Precommits[height][0]).Should(Equal(2*f + 1))
+
+			// Expect the process to commit the block once the blockchain
+			// recovers, and another precommit is received
+			blockchain.err = nil
+			precommit := NewPrecommit(height, 0, propose.BlockHash())
+			Expect(Sign(precommit, *newEcdsaKey())).Should(Succeed())
+			process.HandleMessage(precommit)
+			committedBlock, ok := blockchain.BlockAtHeight(height)
+			Expect(ok).Should(BeTrue())
+			Expect(committedBlock.Hash()).Should(Equal(propose.BlockHash()))
+			Expect(process.CurrentHeight()).Should(Equal(height + 1))
+		})
+	})
+
 	Context("when current block does not exist in the blockchain", func() {
 		Context("when receive 2f + 1 precommit of a proposal,", func() {
 			It("should finalize the block in blockchain, reset the state, and start from round 0 in height +1 ", func() {
@@ -593,6 +2038,74 @@ var _ = Describe("Process", func() {
 		})
 	})
 
+	Context("when receiving a propose with a latest commit from the future", func() {
+		newLatestCommit := func(keys []*ecdsa.PrivateKey) LatestCommit {
+			header := RandomBlockHeaderJSON(block.Standard)
+			header.Height = block.Height(rand.Intn(100) + 2)
+			header.Round = block.Round(rand.Intn(100))
+			committedBlock := block.New(header.ToBlockHeader(), nil, nil, nil)
+
+			precommits := make([]Precommit, len(keys))
+			for i, key := range keys {
+				precommit := NewPrecommit(committedBlock.Header().Height(), committedBlock.Header().Round(), committedBlock.Hash())
+				Expect(Sign(precommit, *key)).Should(Succeed())
+				precommits[i] = *precommit
+			}
+			return LatestCommit{
+				Block:      committedBlock,
+				Precommits: precommits,
+			}
+		}
+
+		newSyncingProcess := func() (*Process, []*ecdsa.PrivateKey) {
+			keys := make([]*ecdsa.PrivateKey, 4)
+			sigs := make(id.Signatories, 4)
+			for i := range keys {
+				keys[i] = newEcdsaKey()
+				sigs[i] = id.NewSignatory(keys[i].PublicKey)
+			}
+			processOrigin := NewProcessOrigin(1)
+			processOrigin.Blockchain = NewMockBlockchain(sigs)
+			processOrigin.Scheduler = NewMockScheduler(RandomSignatory())
+			return processOrigin.ToProcess(), keys
+		}
+
+		It("should sync to the committed block when every precommit is for the committed block", func() {
+			process, keys := newSyncingProcess()
+			latestCommit := newLatestCommit(keys[:3])
+
+			propose := ProposeWithLatestCommit(RandomPropose(), latestCommit)
+			Expect(Sign(propose, *keys[0])).Should(Succeed())
+			process.HandleMessage(propose)
+
+			state := testutil.GetStateFromProcess(process, 1)
+			Expect(state.CurrentHeight).Should(Equal(latestCommit.Block.Header().Height() + 1))
+
+			// Expect the synced commit to be the last commit
+			lastCommit, ok := process.LastCommit()
+			Expect(ok).Should(BeTrue())
+			Expect(lastCommit.Block.Hash()).Should(Equal(latestCommit.Block.Hash()))
+			Expect(lastCommit.Precommits).Should(Equal(latestCommit.Precommits))
+		})
+
+		It("should reject the latest commit when a precommit is for a different block", func() {
+			process, keys := newSyncingProcess()
+			latestCommit := newLatestCommit(keys[:3])
+
+			// Add a stray precommit for a different block
+			stray := NewPrecommit(latestCommit.Block.Header().Height(), latestCommit.Block.Header().Round(), RandomHash())
+			Expect(Sign(stray, *keys[3])).Should(Succeed())
+			latestCommit.Precommits = append(latestCommit.Precommits, *stray)
+
+			propose := ProposeWithLatestCommit(RandomPropose(), latestCommit)
+			Expect(Sign(propose, *keys[0])).Should(Succeed())
+			process.HandleMessage(propose)
+
+			state := testutil.GetStateFromProcess(process, 1)
+			Expect(state.CurrentHeight).Should(Equal(block.Height(1)))
+		})
+	})
+
 	Context("when starting the process", func() {
 		Context("when the process has messages from a previous height", func() {
 			It("should resend the most recent proposal, prevote, and precommit", func() {
@@ -686,3 +2199,93 @@ var _ = Describe("Process", func() {
 		})
 	})
 })
+
+// resigningProposer can never build a block, and so always resigns.
+type resigningProposer struct{}
+
+func (resigningProposer) BlockProposal(block.Height, block.Round) block.Block {
+	return block.InvalidBlock
+}
+
+// mockUnlockStrategy records the arguments with which it is consulted, and
+// always returns the same decision.
+type mockUnlockStrategy struct {
+	canUnlock   bool
+	calls       int
+	lockedRound block.Round
+	lockedBlock block.Block
+}
+
+func (strategy *mockUnlockStrategy) CanUnlock(lockedRound block.Round, lockedBlock block.Block, propose *Propose) bool {
+	strategy.calls++
+	strategy.lockedRound = lockedRound
+	strategy.lockedBlock = lockedBlock
+	return strategy.canUnlock
+}
+
+// rejectingVerifier never verifies any signature.
+type rejectingVerifier struct{}
+
+func (rejectingVerifier) Verify(hash, sig []byte, signatory id.Signatory) error {
+	return errors.New("rejected")
+}
+
+// stepTimer returns a different timeout for each step.
+type stepTimer struct{}
+
+func (stepTimer) Timeout(step Step, round block.Round) time.Duration {
+	return time.Duration(step)*time.Second + time.Duration(round)*time.Millisecond
+}
+
+// proposerObserver records the heights and rounds in which the process became
+// the proposer.
+type proposerObserver struct {
+	MockObserver
+	heights []block.Height
+	rounds  []block.Round
+}
+
+func (observer *proposerObserver) DidBecomeProposer(height block.Height, round block.Round) {
+	observer.heights = append(observer.heights, height)
+	observer.rounds = append(observer.rounds, round)
+}
+
+// evenRoundScheduler schedules the signatory as the proposer of even rounds,
+// and the other signatory as the proposer of odd rounds.
+type evenRoundScheduler struct {
+	signatory id.Signatory
+	other     id.Signatory
+}
+
+func (scheduler evenRoundScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	if round%2 == 0 {
+		return scheduler.signatory
+	}
+	return scheduler.other
+}
+
+// failingBlockchain fails to insert blocks while it has an error.
+type failingBlockchain struct {
+	Blockchain
+	err error
+}
+
+func (blockchain *failingBlockchain) InsertBlockAtHeight(height block.Height, b block.Block) error {
+	if blockchain.err != nil {
+		return blockchain.err
+	}
+	return blockchain.Blockchain.InsertBlockAtHeight(height, b)
+}
+
+// commitFailureObserver records the heights at which blocks could not be
+// committed, and the reasons why.
+type commitFailureObserver struct {
+	MockObserver
+	heights []block.Height
+	errs    []error
+}
+
+func (observer *commitFailureObserver) DidFailToCommitBlock(height block.Height, err error) {
+	observer.heights = append(observer.heights, height)
+	observer.errs = append(observer.errs, err)
+}
@@ -0,0 +1,323 @@
+package process
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// An inputType distinguishes between the inputs of a Process that are
+// recorded by RecordTransitions.
+type inputType uint8
+
+const (
+	startInputType inputType = iota + 1
+	startRoundInputType
+	messageInputType
+	timeoutProposeInputType
+	timeoutPrevoteInputType
+	timeoutPrecommitInputType
+	triggerProposeInputType
+	syncCommitInputType
+	pruneInputType
+	changeSignatoriesInputType
+)
+
+// An input to a Process. The payload depends on the type of the input: it is
+// the type and binary encoding of a Message, the binary encoding of a synced
+// LatestCommit, or the changed signatories.
+type input struct {
+	inputType inputType
+	height    block.Height
+	round     block.Round
+	payload   []byte
+}
+
+// RecordTransitions makes the Process write every input that can cause it to
+// transition to the writer, in the order in which the inputs are handled:
+// every call to Start, StartRound, HandleMessage, TriggerPropose, SyncCommit,
+// Prune, and ChangeSignatories, and every timeout that fires. The recording
+// can be replayed into a fresh Process using ReplayTransitions. If writing
+// fails, the error is logged and recording stops. A nil writer stops
+// recording. RecordTransitions is safe for concurrent use.
+func (p *Process) RecordTransitions(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recorder = w
+}
+
+// ReplayTransitions reads the inputs recorded by RecordTransitions, and hands
+// them to the Process in the order in which they were recorded, until the
+// reader is exhausted. If the Process was constructed in the same way as the
+// recorded Process (with the same signatory, State, Blockchain, and
+// signatories), and recording began before it handled any input, then its
+// `Snapshot` is identical to that of the recorded Process once the recording
+// has been replayed. The Process must use a Clock that never fires during the
+// replay, so that the only timeouts are those that were recorded.
+func ReplayTransitions(r io.Reader, p *Process) error {
+	for {
+		in, err := readInput(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read input: %v", err)
+		}
+		if err := p.replay(in); err != nil {
+			return fmt.Errorf("cannot replay input at height=%v and round=%v: %v", in.height, in.round, err)
+		}
+	}
+}
+
+func (p *Process) replay(in input) error {
+	switch in.inputType {
+	case startInputType:
+		p.Start()
+	case startRoundInputType:
+		p.StartRound(in.round)
+	case messageInputType:
+		m, err := unmarshalInputMessage(in.payload)
+		if err != nil {
+			return err
+		}
+		p.HandleMessage(m)
+	case timeoutProposeInputType:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.handleTimeoutPropose(in.height, in.round)
+	case timeoutPrevoteInputType:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.handleTimeoutPrevote(in.height, in.round)
+	case timeoutPrecommitInputType:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.handleTimeoutPrecommit(in.height, in.round)
+	case triggerProposeInputType:
+		p.TriggerPropose()
+	case syncCommitInputType:
+		latestCommit, err := unmarshalInputCommit(in.payload)
+		if err != nil {
+			return err
+		}
+		// The error is ignored, because the recorded Process will have
+		// returned the same error
+		_ = p.SyncCommit(latestCommit)
+	case pruneInputType:
+		p.Prune(in.height)
+	case changeSignatoriesInputType:
+		if len(in.payload)%len(id.Signatory{}) != 0 {
+			return fmt.Errorf("unexpected signatories len=%v", len(in.payload))
+		}
+		signatories := make(id.Signatories, len(in.payload)/len(id.Signatory{}))
+		for i := range signatories {
+			copy(signatories[i][:], in.payload[i*len(id.Signatory{}):])
+		}
+		p.ChangeSignatories(in.height, signatories)
+	default:
+		return fmt.Errorf("unexpected input type=%v", in.inputType)
+	}
+	return nil
+}
+
+// record an input, if recording is enabled. It must only be called while
+// holding the lock of the Process.
+func (p *Process) record(inputType inputType, height block.Height, round block.Round, payload func() ([]byte, error)) {
+	if p.recorder == nil {
+		return
+	}
+	in := input{inputType: inputType, height: height, round: round}
+	if payload != nil {
+		data, err := payload()
+		if err != nil {
+			p.logger.Errorf("stopped recording transitions: %v", err)
+			p.recorder = nil
+			return
+		}
+		in.payload = data
+	}
+	if err := writeInput(p.recorder, in); err != nil {
+		p.logger.Errorf("stopped recording transitions: %v", err)
+		p.recorder = nil
+	}
+}
+
+func (p *Process) handleTimeoutPropose(height block.Height, round block.Round) {
+	p.record(timeoutProposeInputType, height, round, nil)
+	p.timeoutPropose(height, round)
+}
+
+func (p *Process) handleTimeoutPrevote(height block.Height, round block.Round) {
+	p.record(timeoutPrevoteInputType, height, round, nil)
+	p.timeoutPrevote(height, round)
+}
+
+func (p *Process) handleTimeoutPrecommit(height block.Height, round block.Round) {
+	p.record(timeoutPrecommitInputType, height, round, nil)
+	p.timeoutPrecommit(height, round)
+}
+
+func marshalInputMessage(m Message) ([]byte, error) {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal message: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, uint64(m.Type())); err != nil {
+		return nil, fmt.Errorf("cannot write message type: %v", err)
+	}
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+func unmarshalInputMessage(data []byte) (Message, error) {
+	buf := bytes.NewBuffer(data)
+	var messageType uint64
+	if err := binary.Read(buf, binary.LittleEndian, &messageType); err != nil {
+		return nil, fmt.Errorf("cannot read message type: %v", err)
+	}
+	var m Message
+	switch MessageType(messageType) {
+	case ProposeMessageType:
+		m = new(Propose)
+	case PrevoteMessageType:
+		m = new(Prevote)
+	case PrecommitMessageType:
+		m = new(Precommit)
+	case ResignMessageType:
+		m = new(Resign)
+	default:
+		return nil, fmt.Errorf("unexpected message type=%v", messageType)
+	}
+	if err := m.UnmarshalBinary(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal message: %v", err)
+	}
+	return m, nil
+}
+
+func marshalInputCommit(latestCommit LatestCommit) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	blockData, err := latestCommit.Block.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal block: %v", err)
+	}
+	if err := writeBytes(buf, blockData); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(latestCommit.Precommits))); err != nil {
+		return nil, fmt.Errorf("cannot write precommits len: %v", err)
+	}
+	for _, precommit := range latestCommit.Precommits {
+		precommitData, err := precommit.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal precommit: %v", err)
+		}
+		if err := writeBytes(buf, precommitData); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalInputCommit(data []byte) (LatestCommit, error) {
+	buf := bytes.NewBuffer(data)
+	latestCommit := LatestCommit{}
+	blockData, err := readBytes(buf)
+	if err != nil {
+		return latestCommit, err
+	}
+	if err := latestCommit.Block.UnmarshalBinary(blockData); err != nil {
+		return latestCommit, fmt.Errorf("cannot unmarshal block: %v", err)
+	}
+	var numPrecommits uint64
+	if err := binary.Read(buf, binary.LittleEndian, &numPrecommits); err != nil {
+		return latestCommit, fmt.Errorf("cannot read precommits len: %v", err)
+	}
+	if numPrecommits > uint64(buf.Len()) {
+		return latestCommit, fmt.Errorf("unexpected precommits len=%v", numPrecommits)
+	}
+	latestCommit.Precommits = make([]Precommit, numPrecommits)
+	for i := range latestCommit.Precommits {
+		precommitData, err := readBytes(buf)
+		if err != nil {
+			return latestCommit, err
+		}
+		if err := latestCommit.Precommits[i].UnmarshalBinary(precommitData); err != nil {
+			return latestCommit, fmt.Errorf("cannot unmarshal precommit: %v", err)
+		}
+	}
+	return latestCommit, nil
+}
+
+func writeInput(w io.Writer, in input) error {
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, in.inputType); err != nil {
+		return fmt.Errorf("cannot write input type: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, in.height); err != nil {
+		return fmt.Errorf("cannot write input height: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, in.round); err != nil {
+		return fmt.Errorf("cannot write input round: %v", err)
+	}
+	if err := writeBytes(buf, in.payload); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot write input: %v", err)
+	}
+	return nil
+}
+
+func readInput(r io.Reader) (input, error) {
+	in := input{}
+	if err := binary.Read(r, binary.LittleEndian, &in.inputType); err != nil {
+		// A clean end of the recording is only possible between inputs
+		return in, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &in.height); err != nil {
+		return in, fmt.Errorf("cannot read input height: %v", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &in.round); err != nil {
+		return in, fmt.Errorf("cannot read input round: %v", err)
+	}
+	payload, err := readBytes(r)
+	if err != nil {
+		return in, err
+	}
+	in.payload = payload
+	return in, nil
+}
+
+func writeBytes(w io.Writer, data []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(data))); err != nil {
+		return fmt.Errorf("cannot write len: %v", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+		return fmt.Errorf("cannot write data: %v", err)
+	}
+	return nil
+}
+
+// readBytes reads data that was written by writeBytes. The data is read in
+// chunks, so that a corrupted length cannot cause a large allocation.
+func readBytes(r io.Reader) ([]byte, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("cannot read len: %v", err)
+	}
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("unexpected len=%v", n)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, int64(n)); err != nil {
+		return nil, fmt.Errorf("cannot read data: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package process_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/process"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("Transition recording", func() {
+	newKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when replaying a recorded session into a fresh process", func() {
+		It("should reach an identical state", func() {
+			f := 2
+			proposerKey := newKey()
+			voterKeys := make([]*ecdsa.PrivateKey, 2*f+1)
+			for i := range voterKeys {
+				voterKeys[i] = newKey()
+			}
+
+			// The process is never the proposer, so it waits for proposals,
+			// and times out when they are not received
+			origin := NewProcessOrigin(f)
+			origin.Scheduler = NewMockScheduler(id.NewSignatory(proposerKey.PublicKey))
+			clock := NewMockClock(time.Now())
+			origin.Clock = clock
+			genesis, ok := origin.Blockchain.BlockAtHeight(0)
+			Expect(ok).To(BeTrue())
+
+			recorded := origin.ToProcess()
+			recording := new(bytes.Buffer)
+			recorded.RecordTransitions(recording)
+			go func() {
+				for range origin.BroadcastMessages {
+				}
+			}()
+
+			vote := func(height block.Height, round block.Round, blockHash id.Hash) {
+				for _, key := range voterKeys {
+					prevote := NewPrevote(height, round, blockHash, nil)
+					Expect(Sign(prevote, *key)).To(Succeed())
+					recorded.HandleMessage(prevote)
+				}
+			}
+			commit := func(height block.Height, round block.Round, blockHash id.Hash) {
+				for _, key := range voterKeys {
+					precommit := NewPrecommit(height, round, blockHash)
+					Expect(Sign(precommit, *key)).To(Succeed())
+					recorded.HandleMessage(precommit)
+				}
+			}
+			propose := func(height block.Height, round block.Round) id.Hash {
+				propose := NewPropose(height, round, RandomBlock(block.Standard), block.InvalidRound)
+				Expect(Sign(propose, *proposerKey)).To(Succeed())
+				recorded.HandleMessage(propose)
+				return propose.BlockHash()
+			}
+
+			// Round 0 times out waiting for a proposal, and is skipped
+			recorded.Start()
+			clock.Advance(time.Second)
+			Eventually(func() Step { return recorded.Snapshot().CurrentStep }).Should(Equal(StepPrevote))
+			vote(1, 0, block.InvalidHash)
+			commit(1, 0, block.InvalidHash)
+			Eventually(func() block.Round { return recorded.CurrentRound() }).Should(Equal(block.Round(1)))
+
+			// Round 1 commits a block
+			blockHash := propose(1, 1)
+			vote(1, 1, blockHash)
+			commit(1, 1, blockHash)
+			Expect(recorded.CurrentHeight()).To(Equal(block.Height(2)))
+
+			// Round 0 at the next height locks on a block
+			blockHash = propose(2, 0)
+			vote(2, 0, blockHash)
+			snapshot := recorded.Snapshot()
+			Expect(snapshot.CurrentHeight).To(Equal(block.Height(2)))
+			Expect(snapshot.LockedBlockHash).To(Equal(blockHash))
+			Expect(snapshot.LockedRound).To(Equal(block.Round(0)))
+			recorded.Stop()
+
+			// Replay into a process that is constructed in the same way, with
+			// a clock that is never advanced
+			expected, ok := origin.Blockchain.BlockAtHeight(1)
+			Expect(ok).To(BeTrue())
+			blockchain := NewMockBlockchain(nil)
+			Expect(blockchain.InsertBlockAtHeight(0, genesis)).To(Succeed())
+			origin.Blockchain = blockchain
+			origin.State = DefaultState(f)
+			origin.Clock = NewMockClock(time.Now())
+			replayed := origin.ToProcess()
+			Expect(ReplayTransitions(recording, replayed)).To(Succeed())
+
+			Expect(replayed.Snapshot()).To(Equal(snapshot))
+			committed, ok := blockchain.BlockAtHeight(1)
+			Expect(ok).To(BeTrue())
+			Expect(committed.Hash()).To(Equal(expected.Hash()))
+		})
+	})
+
+	Context("when replaying a corrupted recording", func() {
+		It("should return an error", func() {
+			origin := NewProcessOrigin(2)
+			p := origin.ToProcess()
+			recording := new(bytes.Buffer)
+			p.RecordTransitions(recording)
+			p.HandleMessage(RandomMessage(PrevoteMessageType))
+
+			data := recording.Bytes()
+			origin.State = DefaultState(2)
+			Expect(ReplayTransitions(bytes.NewReader(data[:len(data)-1]), origin.ToProcess())).NotTo(Succeed())
+		})
+	})
+})
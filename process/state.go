@@ -1,7 +1,10 @@
 package process
 
 import (
+	"fmt"
+
 	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
 )
 
 // The State of a Process. It is isolated from the Process so that it can be
@@ -60,3 +63,96 @@ func (state *State) Equal(other State) bool {
 		state.ValidBlock.Equal(other.ValidBlock) &&
 		state.ValidRound == other.ValidRound
 }
+
+// CheckInvariants returns an error if the State is inconsistent. The locked
+// (and valid) block must be set if, and only if, the locked (and valid) round
+// is set, neither round can be ahead of the current round, and the valid round
+// can never be behind the locked round. It is intended to be used by tests
+// that drive a Process through random transitions.
+func (state *State) CheckInvariants() error {
+	if state.CurrentHeight <= 0 {
+		return fmt.Errorf("invariant violation: expected height>0, got height=%v", state.CurrentHeight)
+	}
+	if state.CurrentRound < 0 {
+		return fmt.Errorf("invariant violation: expected round>=0, got round=%v", state.CurrentRound)
+	}
+	if state.CurrentStep < StepPropose || state.CurrentStep > StepPrecommit {
+		return fmt.Errorf("invariant violation: unexpected step=%v", state.CurrentStep)
+	}
+	if (state.LockedRound == block.InvalidRound) != state.LockedBlock.Equal(block.InvalidBlock) {
+		return fmt.Errorf("invariant violation: locked round=%v is inconsistent with locked block=%v", state.LockedRound, state.LockedBlock.Hash())
+	}
+	if (state.ValidRound == block.InvalidRound) != state.ValidBlock.Equal(block.InvalidBlock) {
+		return fmt.Errorf("invariant violation: valid round=%v is inconsistent with valid block=%v", state.ValidRound, state.ValidBlock.Hash())
+	}
+	if state.LockedRound > state.CurrentRound {
+		return fmt.Errorf("invariant violation: locked round=%v is ahead of round=%v", state.LockedRound, state.CurrentRound)
+	}
+	if state.ValidRound > state.CurrentRound {
+		return fmt.Errorf("invariant violation: valid round=%v is ahead of round=%v", state.ValidRound, state.CurrentRound)
+	}
+	if state.ValidRound < state.LockedRound {
+		return fmt.Errorf("invariant violation: valid round=%v is behind locked round=%v", state.ValidRound, state.LockedRound)
+	}
+	return nil
+}
+
+// CheckProgress returns an error if the next State does not follow from the
+// previous State. Heights must never decrease, rounds must never decrease
+// within a height, and steps must never decrease within a round.
+func CheckProgress(prev, next State) error {
+	if next.CurrentHeight < prev.CurrentHeight {
+		return fmt.Errorf("invariant violation: height decreased from %v to %v", prev.CurrentHeight, next.CurrentHeight)
+	}
+	if next.CurrentHeight > prev.CurrentHeight {
+		return nil
+	}
+	if next.CurrentRound < prev.CurrentRound {
+		return fmt.Errorf("invariant violation: round decreased from %v to %v at height=%v", prev.CurrentRound, next.CurrentRound, next.CurrentHeight)
+	}
+	if next.CurrentRound > prev.CurrentRound {
+		return nil
+	}
+	if next.CurrentStep < prev.CurrentStep {
+		return fmt.Errorf("invariant violation: step decreased from %v to %v at height=%v and round=%v", prev.CurrentStep, next.CurrentStep, next.CurrentHeight, next.CurrentRound)
+	}
+	return nil
+}
+
+// A StateSnapshot is a read-only copy of the State of a Process, for
+// monitoring consensus progress. It does not share memory with the Process, so
+// it can be inspected, or modified, without affecting the Process. Instead of
+// the messages themselves, it has the number of unique messages of each type
+// that have been received at each height and round.
+type StateSnapshot struct {
+	CurrentHeight block.Height
+	CurrentRound  block.Round
+	CurrentStep   Step
+
+	LockedBlockHash id.Hash
+	LockedRound     block.Round
+	ValidBlockHash  id.Hash
+	ValidRound      block.Round
+
+	Proposals  map[block.Height]map[block.Round]int
+	Prevotes   map[block.Height]map[block.Round]int
+	Precommits map[block.Height]map[block.Round]int
+}
+
+// Snapshot returns a StateSnapshot of the State.
+func (state *State) Snapshot() StateSnapshot {
+	return StateSnapshot{
+		CurrentHeight: state.CurrentHeight,
+		CurrentRound:  state.CurrentRound,
+		CurrentStep:   state.CurrentStep,
+
+		LockedBlockHash: state.LockedBlock.Hash(),
+		LockedRound:     state.LockedRound,
+		ValidBlockHash:  state.ValidBlock.Hash(),
+		ValidRound:      state.ValidRound,
+
+		Proposals:  state.Proposals.counts(),
+		Prevotes:   state.Prevotes.counts(),
+		Precommits: state.Precommits.counts(),
+	}
+}
@@ -68,4 +68,179 @@ var _ = Describe("States", func() {
 			Expect(quick.Check(test, nil)).Should(Succeed())
 		})
 	})
+
+	Context("when checking invariants", func() {
+		It("should succeed for the default state", func() {
+			state := DefaultState(10)
+			Expect(state.CheckInvariants()).Should(Succeed())
+		})
+
+		It("should succeed when locked on a block in a previous round", func() {
+			state := DefaultState(10)
+			state.CurrentRound = 2
+			state.LockedBlock = RandomBlock(block.Standard)
+			state.LockedRound = 1
+			state.ValidBlock = RandomBlock(block.Standard)
+			state.ValidRound = 2
+			Expect(state.CheckInvariants()).Should(Succeed())
+		})
+
+		It("should fail when the locked round is set without a locked block", func() {
+			state := DefaultState(10)
+			state.LockedRound = 0
+			state.ValidBlock = RandomBlock(block.Standard)
+			state.ValidRound = 0
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the locked block is set without a locked round", func() {
+			state := DefaultState(10)
+			state.LockedBlock = RandomBlock(block.Standard)
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the valid round is set without a valid block", func() {
+			state := DefaultState(10)
+			state.ValidRound = 0
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the locked round is ahead of the current round", func() {
+			state := DefaultState(10)
+			state.LockedBlock = RandomBlock(block.Standard)
+			state.LockedRound = 1
+			state.ValidBlock = state.LockedBlock
+			state.ValidRound = 1
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the valid round is behind the locked round", func() {
+			state := DefaultState(10)
+			state.CurrentRound = 2
+			state.LockedBlock = RandomBlock(block.Standard)
+			state.LockedRound = 2
+			state.ValidBlock = RandomBlock(block.Standard)
+			state.ValidRound = 1
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the height is not positive", func() {
+			state := DefaultState(10)
+			state.CurrentHeight = 0
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+
+		It("should fail when the step is nil", func() {
+			state := DefaultState(10)
+			state.CurrentStep = StepNil
+			Expect(state.CheckInvariants()).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when checking progress", func() {
+		It("should succeed when the height, round, or step increases", func() {
+			prev := DefaultState(10)
+			next := DefaultState(10)
+			Expect(CheckProgress(prev, next)).Should(Succeed())
+
+			next.CurrentStep = StepPrevote
+			Expect(CheckProgress(prev, next)).Should(Succeed())
+
+			prev, next.CurrentRound, next.CurrentStep = next, 1, StepPropose
+			Expect(CheckProgress(prev, next)).Should(Succeed())
+
+			prev, next.CurrentHeight, next.CurrentRound = next, 2, 0
+			Expect(CheckProgress(prev, next)).Should(Succeed())
+		})
+
+		It("should fail when the height decreases", func() {
+			prev := DefaultState(10)
+			prev.CurrentHeight = 2
+			next := DefaultState(10)
+			next.CurrentRound = 1
+			Expect(CheckProgress(prev, next)).ShouldNot(Succeed())
+		})
+
+		It("should fail when the round decreases within a height", func() {
+			prev := DefaultState(10)
+			prev.CurrentRound = 1
+			next := DefaultState(10)
+			Expect(CheckProgress(prev, next)).ShouldNot(Succeed())
+		})
+
+		It("should fail when the step decreases within a round", func() {
+			prev := DefaultState(10)
+			prev.CurrentStep = StepPrecommit
+			next := DefaultState(10)
+			Expect(CheckProgress(prev, next)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when taking a snapshot", func() {
+		It("should reflect the messages that have been inserted", func() {
+			state := DefaultState(2)
+			height := state.CurrentHeight
+			state.Proposals.Insert(RandomSingedMessageWithHeightAndRound(height, 0, ProposeMessageType))
+			for i := 0; i < 3; i++ {
+				state.Prevotes.Insert(RandomSingedMessageWithHeightAndRound(height, 0, PrevoteMessageType))
+			}
+			state.Prevotes.Insert(RandomSingedMessageWithHeightAndRound(height, 1, PrevoteMessageType))
+			state.Precommits.Insert(RandomSingedMessageWithHeightAndRound(height+1, 0, PrecommitMessageType))
+
+			snapshot := state.Snapshot()
+			Expect(snapshot.CurrentHeight).Should(Equal(state.CurrentHeight))
+			Expect(snapshot.CurrentRound).Should(Equal(state.CurrentRound))
+			Expect(snapshot.CurrentStep).Should(Equal(state.CurrentStep))
+			Expect(snapshot.LockedRound).Should(Equal(block.InvalidRound))
+			Expect(snapshot.LockedBlockHash).Should(Equal(block.InvalidHash))
+			Expect(snapshot.Proposals).Should(Equal(map[block.Height]map[block.Round]int{height: {0: 1}}))
+			Expect(snapshot.Prevotes).Should(Equal(map[block.Height]map[block.Round]int{height: {0: 3, 1: 1}}))
+			Expect(snapshot.Precommits).Should(Equal(map[block.Height]map[block.Round]int{height + 1: {0: 1}}))
+		})
+
+		It("should not be affected by later mutations", func() {
+			state := DefaultState(2)
+			height := state.CurrentHeight
+			state.Prevotes.Insert(RandomSingedMessageWithHeightAndRound(height, 0, PrevoteMessageType))
+			snapshot := state.Snapshot()
+
+			// Mutating the state does not affect the snapshot
+			state.Prevotes.Insert(RandomSingedMessageWithHeightAndRound(height, 0, PrevoteMessageType))
+			state.Prevotes.Insert(RandomSingedMessageWithHeightAndRound(height, 1, PrevoteMessageType))
+			state.CurrentRound = 1
+			Expect(snapshot.CurrentRound).Should(Equal(block.Round(0)))
+			Expect(snapshot.Prevotes).Should(Equal(map[block.Height]map[block.Round]int{height: {0: 1}}))
+
+			// Mutating the snapshot does not affect the state
+			snapshot.Prevotes[height][0] = 100
+			delete(snapshot.Prevotes, height)
+			Expect(state.Prevotes.QueryByHeightRound(height, 0)).Should(Equal(2))
+			Expect(state.Snapshot().Prevotes).Should(Equal(map[block.Height]map[block.Round]int{height: {0: 2, 1: 1}}))
+		})
+	})
+
+	Context("when exporting a DOT graph", func() {
+		It("should contain all of the steps and the transitions between them", func() {
+			state := DefaultState(1)
+			state.CurrentStep = StepPrevote
+			dot := string(state.ExportDOT())
+
+			Expect(dot).Should(HavePrefix("digraph Process {"))
+			Expect(dot).Should(ContainSubstring(`label="height=1, round=0"`))
+			Expect(dot).Should(ContainSubstring(`StepPropose [label="StepPropose\nwaiting for propose"]`))
+			Expect(dot).Should(ContainSubstring(`StepPrevote [label="StepPrevote\nwaiting for polka", style=filled]`))
+			Expect(dot).Should(ContainSubstring(`StepPrecommit [label="StepPrecommit\nwaiting for commit"]`))
+
+			Expect(dot).Should(ContainSubstring(`StepPropose -> StepPrevote [label="TimeoutPropose{h, r}:\nprevote nil"]`))
+			Expect(dot).Should(ContainSubstring(`StepPrevote -> StepPrecommit [label="TimeoutPrevote{h, r}:\nprecommit nil"]`))
+			Expect(dot).Should(ContainSubstring(`StepPrevote -> StepPrecommit [label="Propose{h, r, b, *} from proposer\nand 2f+1 Prevote{h, r, b}:\nlock and precommit b"]`))
+			for _, step := range []string{"StepPropose", "StepPrevote", "StepPrecommit"} {
+				Expect(dot).Should(ContainSubstring(step + ` -> StepPropose [label="2f+1 Precommit{h, r, *}\nthen TimeoutPrecommit{h, r}:\nstart round r+1"]`))
+				Expect(dot).Should(ContainSubstring(step + ` -> StepPropose [label="2f+1 Precommit{h, r, nil}:\nstart round r+1"]`))
+				Expect(dot).Should(ContainSubstring(step + ` -> StepPropose [label="Propose{h, r', b, *} from proposer\nand 2f+1 Precommit{h, r', b}:\ncommit b and start height h+1"]`))
+			}
+			Expect(dot).ShouldNot(ContainSubstring("StepPrecommit -> StepPrevote"))
+			Expect(dot).Should(HaveSuffix("}\n"))
+		})
+	})
 })
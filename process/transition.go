@@ -0,0 +1,141 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// TransitionType distinguishes the different events that can be processed by
+// a Process.
+type TransitionType uint8
+
+// Define all TransitionTypes.
+const (
+	NilTransitionType TransitionType = iota
+	ProposedTransitionType
+	PrevotedTransitionType
+	PrecommittedTransitionType
+	ResignedTransitionType
+	TimedOutProposeTransitionType
+	TimedOutPrevoteTransitionType
+	TimedOutPrecommitTransitionType
+)
+
+// String implements the `fmt.Stringer` interface.
+func (t TransitionType) String() string {
+	switch t {
+	case ProposedTransitionType:
+		return "Proposed"
+	case PrevotedTransitionType:
+		return "Prevoted"
+	case PrecommittedTransitionType:
+		return "Precommitted"
+	case ResignedTransitionType:
+		return "Resigned"
+	case TimedOutProposeTransitionType:
+		return "TimedOutPropose"
+	case TimedOutPrevoteTransitionType:
+		return "TimedOutPrevote"
+	case TimedOutPrecommitTransitionType:
+		return "TimedOutPrecommit"
+	default:
+		return "Nil"
+	}
+}
+
+// A Transition is an event that was processed by a Process at its current
+// `block.Height`. Transitions caused by a Message store the signatory and block
+// hash of the Message. Transitions caused by a timeout have an empty signatory
+// and block hash.
+type Transition struct {
+	Type      TransitionType
+	Height    block.Height
+	Round     block.Round
+	Signatory id.Signatory
+	BlockHash id.Hash
+}
+
+// String implements the `fmt.Stringer` interface.
+func (t Transition) String() string {
+	return fmt.Sprintf("Transition(Type=%v,Height=%v,Round=%v,Signatory=%v,BlockHash=%v)", t.Type, t.Height, t.Round, t.Signatory, t.BlockHash)
+}
+
+func newMessageTransition(m Message) Transition {
+	transition := Transition{
+		Height:    m.Height(),
+		Round:     m.Round(),
+		Signatory: m.Signatory(),
+		BlockHash: m.BlockHash(),
+	}
+	switch m.Type() {
+	case ProposeMessageType:
+		transition.Type = ProposedTransitionType
+	case PrevoteMessageType:
+		transition.Type = PrevotedTransitionType
+	case PrecommitMessageType:
+		transition.Type = PrecommittedTransitionType
+	case ResignMessageType:
+		transition.Type = ResignedTransitionType
+	}
+	return transition
+}
+
+// transitionLog records the most recent Transitions at each `block.Height`.
+// When the number of Transitions at a `block.Height` exceeds the maximum, the
+// oldest Transitions are evicted and passed to the eviction callback (if it is
+// not nil). A nil transitionLog is valid, and records nothing.
+type transitionLog struct {
+	maxTransitionsPerHeight int
+	didEvict                func(Transition)
+	transitions             map[block.Height][]Transition
+}
+
+func newTransitionLog(maxTransitionsPerHeight int, didEvict func(Transition)) *transitionLog {
+	if maxTransitionsPerHeight <= 0 {
+		return nil
+	}
+	return &transitionLog{
+		maxTransitionsPerHeight: maxTransitionsPerHeight,
+		didEvict:                didEvict,
+		transitions:             map[block.Height][]Transition{},
+	}
+}
+
+func (log *transitionLog) record(transition Transition) {
+	if log == nil {
+		return
+	}
+	transitions := append(log.transitions[transition.Height], transition)
+	if numEvicted := len(transitions) - log.maxTransitionsPerHeight; numEvicted > 0 {
+		if log.didEvict != nil {
+			for _, evicted := range transitions[:numEvicted] {
+				log.didEvict(evicted)
+			}
+		}
+		transitions = transitions[numEvicted:]
+	}
+	log.transitions[transition.Height] = transitions
+}
+
+func (log *transitionLog) at(height block.Height) []Transition {
+	if log == nil || len(log.transitions[height]) == 0 {
+		return nil
+	}
+	transitions := make([]Transition, len(log.transitions[height]))
+	copy(transitions, log.transitions[height])
+	return transitions
+}
+
+// drop the Transitions at all heights below the given height.
+func (log *transitionLog) drop(height block.Height) {
+	if log == nil {
+		return
+	}
+	for transitionHeight := range log.transitions {
+		if transitionHeight < height {
+			delete(log.transitions, transitionHeight)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package process
+
+import "github.com/renproject/hyperdrive/block"
+
+// An UnlockStrategy decides whether a Process that is locked on a block can
+// prevote for a different proposed block. It is only consulted when the
+// Process is locked, and the proposed block is valid but is not the locked
+// block.
+//
+// The spec UnlockStrategy, returned by NewSpecUnlockStrategy, is the default
+// and is the only UnlockStrategy that is safe to use in production. Other
+// UnlockStrategies can break the safety of consensus, and exist for
+// experimenting with defensive unlocking heuristics.
+type UnlockStrategy interface {
+	CanUnlock(lockedRound block.Round, lockedBlock block.Block, propose *Propose) bool
+}
+
+type specUnlockStrategy struct{}
+
+// NewSpecUnlockStrategy returns an UnlockStrategy that only unlocks when the
+// proposal has a valid round that is not lower than the locked round (which
+// implies that 2F+1 prevotes were seen for the proposed block at the valid
+// round). Polkas for nil never unlock it, because a proposal cannot have a
+// valid round that is justified by prevotes for nil.
+func NewSpecUnlockStrategy() UnlockStrategy {
+	return specUnlockStrategy{}
+}
+
+func (specUnlockStrategy) CanUnlock(lockedRound block.Round, lockedBlock block.Block, propose *Propose) bool {
+	return propose.ValidRound() > block.InvalidRound && propose.ValidRound() >= lockedRound
+}
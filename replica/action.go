@@ -0,0 +1,152 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// ActionType distinguishes between the Actions that a Replica can take.
+type ActionType uint8
+
+// Define all ActionTypes.
+const (
+	NilActionType ActionType = iota
+	ProposeActionType
+	PrevoteActionType
+	PrecommitActionType
+	CommitActionType
+)
+
+// String implements the `fmt.Stringer` interface.
+func (t ActionType) String() string {
+	switch t {
+	case ProposeActionType:
+		return "Propose"
+	case PrevoteActionType:
+		return "Prevote"
+	case PrecommitActionType:
+		return "Precommit"
+	case CommitActionType:
+		return "Commit"
+	default:
+		return "Nil"
+	}
+}
+
+// An Action is something that a Replica did while reaching consensus: a
+// proposal, prevote, or precommit that it sent, or a block that it committed
+// (either by consensus, or by syncing). Votes for nothing have an invalid
+// block hash. The Round of a commit is the round of the committed block.
+type Action struct {
+	Type      ActionType
+	Height    block.Height
+	Round     block.Round
+	BlockHash id.Hash
+}
+
+// String implements the `fmt.Stringer` interface.
+func (action Action) String() string {
+	return fmt.Sprintf("Action(Type=%v,Height=%v,Round=%v,BlockHash=%v)", action.Type, action.Height, action.Round, action.BlockHash)
+}
+
+// An actionNotifier emits Actions to every subscriber, in the order in which
+// they were taken. Like Progress events, Actions are never allowed to block
+// consensus, so they are dropped for subscribers whose buffers are full. It is
+// shared by all copies of a Replica.
+type actionNotifier struct {
+	mu          *sync.Mutex
+	bufferSize  int
+	subscribers []chan Action
+	closed      bool
+}
+
+func newActionNotifier(bufferSize int) *actionNotifier {
+	return &actionNotifier{
+		mu:          new(sync.Mutex),
+		bufferSize:  bufferSize,
+		subscribers: []chan Action{},
+		closed:      false,
+	}
+}
+
+// subscribe returns a new channel that receives every subsequent Action. The
+// channel is closed when the actionNotifier is closed.
+func (notifier *actionNotifier) subscribe() <-chan Action {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	subscriber := make(chan Action, notifier.bufferSize)
+	if notifier.closed {
+		close(subscriber)
+		return subscriber
+	}
+	notifier.subscribers = append(notifier.subscribers, subscriber)
+	return subscriber
+}
+
+func (notifier *actionNotifier) emit(action Action) {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if notifier.closed {
+		return
+	}
+	for _, subscriber := range notifier.subscribers {
+		select {
+		case subscriber <- action:
+		default:
+		}
+	}
+}
+
+// didCommit emits a commit Action for a block.
+func (notifier *actionNotifier) didCommit(committedBlock block.Block) {
+	header := committedBlock.Header()
+	notifier.emit(Action{Type: CommitActionType, Height: header.Height(), Round: header.Round(), BlockHash: committedBlock.Hash()})
+}
+
+// close all subscriber channels. No more Actions are emitted after the
+// actionNotifier is closed. Closing an actionNotifier that has already been
+// closed does nothing.
+func (notifier *actionNotifier) close() {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if notifier.closed {
+		return
+	}
+	notifier.closed = true
+	for _, subscriber := range notifier.subscribers {
+		close(subscriber)
+	}
+	notifier.subscribers = nil
+}
+
+// An actionBroadcaster is a `process.Broadcaster` that emits an Action for
+// every proposal, prevote, and precommit of the Process, before passing it to
+// the next `process.Broadcaster`.
+type actionBroadcaster struct {
+	broadcaster process.Broadcaster
+	actions     *actionNotifier
+}
+
+// Broadcast implements the `process.Broadcaster` interface.
+func (broadcaster actionBroadcaster) Broadcast(m process.Message) {
+	action := Action{Height: m.Height(), Round: m.Round(), BlockHash: m.BlockHash()}
+	switch m.(type) {
+	case *process.Propose:
+		action.Type = ProposeActionType
+	case *process.Prevote:
+		action.Type = PrevoteActionType
+	case *process.Precommit:
+		action.Type = PrecommitActionType
+	}
+	if action.Type != NilActionType {
+		broadcaster.actions.emit(action)
+	}
+	broadcaster.broadcaster.Broadcast(m)
+}
@@ -0,0 +1,63 @@
+package replica
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+var _ = Describe("actions", func() {
+	Context("when a replica commits a block", func() {
+		It("should emit its prevote, precommit, and commit in order", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			actions := replica.SubscribeActions()
+			replica.Start()
+
+			commitAt(&replica, 1, keys[1], keys[1:6])
+			committed, ok := store.Blockchain(Shard{}).BlockAtHeight(1)
+			Expect(ok).Should(BeTrue())
+
+			expected := []Action{
+				{Type: PrevoteActionType, Height: 1, Round: 0, BlockHash: committed.Hash()},
+				{Type: PrecommitActionType, Height: 1, Round: 0, BlockHash: committed.Hash()},
+				{Type: CommitActionType, Height: 1, Round: 0, BlockHash: committed.Hash()},
+			}
+			for _, action := range expected {
+				Eventually(actions).Should(Receive(Equal(action)))
+			}
+
+			replica.Close()
+			Eventually(actions).Should(BeClosed())
+		})
+	})
+
+	Context("when a replica is the proposer", func() {
+		It("should emit its proposal", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			actions := replica.SubscribeActions()
+			replica.Start()
+			defer replica.Close()
+
+			var action Action
+			Eventually(actions).Should(Receive(&action))
+			Expect(action.Type).Should(Equal(ProposeActionType))
+			Expect(action.Height).Should(Equal(block.Height(1)))
+			Expect(action.Round).Should(Equal(block.Round(0)))
+		})
+	})
+})
@@ -1,10 +1,9 @@
 package replica
 
 import (
-	"crypto/ecdsa"
-	"fmt"
-
 	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+	"github.com/sirupsen/logrus"
 )
 
 // A Broadcaster is used to send signed, shard-specific, Messages to all
@@ -13,29 +12,94 @@ type Broadcaster interface {
 	Broadcast(Message)
 }
 
-type signer struct {
+// A DirectBroadcaster is a Broadcaster that can also send a Message to one
+// Replica. It is needed to answer CatchUpRequests, because a CommitRange is
+// only sent to the Replica that requested it.
+type DirectBroadcaster interface {
+	Broadcaster
+
+	SendTo(to id.Signatory, m Message)
+}
+
+type signerBroadcaster struct {
 	broadcaster Broadcaster
 	shard       Shard
-	privKey     ecdsa.PrivateKey
+	epoch       uint64
+	signer      process.Signer
+	hasher      process.Hasher
+	guard       *doubleSignGuard
+	logger      logrus.FieldLogger
 }
 
 // newSigner returns a `process.Broadcaster` that accepts `process.Messages`,
-// signs them, associates them with a Shard, and re-broadcasts them.
-func newSigner(broadcaster Broadcaster, shard Shard, privKey ecdsa.PrivateKey) process.Broadcaster {
-	return &signer{
+// signs their sighashes, computed by a `process.Hasher`, using a
+// `process.Signer`, associates them with a Shard and Epoch, and re-broadcasts
+// them. Votes are only signed if the `doubleSignGuard` allows them.
+func newSigner(broadcaster Broadcaster, shard Shard, epoch uint64, signer process.Signer, hasher process.Hasher, guard *doubleSignGuard, logger logrus.FieldLogger) process.Broadcaster {
+	return &signerBroadcaster{
 		broadcaster: broadcaster,
 		shard:       shard,
-		privKey:     privKey,
+		epoch:       epoch,
+		signer:      signer,
+		hasher:      hasher,
+		guard:       guard,
+		logger:      logger,
+	}
+}
+
+// Broadcast implements the `process.Broadcaster` interface. Votes that would
+// be a double sign, and Messages that cannot be signed, are dropped, instead of
+// panicking, because remote signers can fail transiently. Consensus tolerates
+// a dropped Message in the same way that it tolerates a Message lost by the
+// network.
+func (broadcaster *signerBroadcaster) Broadcast(m process.Message) {
+	if err := broadcaster.guard.allow(m); err != nil {
+		broadcaster.logger.Errorf("double sign prevented: %v", err)
+		return
+	}
+	if err := process.SignWithHasher(m, broadcaster.signer, broadcaster.hasher); err != nil {
+		broadcaster.logger.Errorf("error signing message: %v", err)
+		return
 	}
+	broadcaster.broadcaster.Broadcast(Message{
+		Message: m,
+		Shard:   broadcaster.shard,
+		Epoch:   broadcaster.epoch,
+	})
+}
+
+// sendTo signs a Message and sends it to one Replica. It returns
+// ErrDirectSendUnsupported if the underlying Broadcaster is not a
+// DirectBroadcaster.
+func (broadcaster *signerBroadcaster) sendTo(to id.Signatory, m process.Message) error {
+	direct, ok := broadcaster.broadcaster.(DirectBroadcaster)
+	if !ok {
+		return ErrDirectSendUnsupported
+	}
+	if err := process.SignWithHasher(m, broadcaster.signer, broadcaster.hasher); err != nil {
+		broadcaster.logger.Errorf("error signing message: %v", err)
+		return nil
+	}
+	direct.SendTo(to, Message{
+		Message: m,
+		Shard:   broadcaster.shard,
+		Epoch:   broadcaster.epoch,
+	})
+	return nil
 }
 
-// Broadcast implements the `process.Broadcaster` interface.
-func (broadcaster *signer) Broadcast(m process.Message) {
-	if err := process.Sign(m, broadcaster.privKey); err != nil {
-		panic(fmt.Errorf("invariant violation: error broadcasting message: %v", err))
+// resend broadcasts a Message that has already been signed, without signing it
+// again, so that a vote can be rebroadcast after a later vote has raised the
+// Watermark. Messages that were never signed (because signing them failed)
+// are broadcast as if they were new.
+func (broadcaster *signerBroadcaster) resend(m process.Message) {
+	if m.Sig() == (id.Signature{}) {
+		broadcaster.Broadcast(m)
+		return
 	}
 	broadcaster.broadcaster.Broadcast(Message{
 		Message: m,
 		Shard:   broadcaster.shard,
+		Epoch:   broadcaster.epoch,
 	})
 }
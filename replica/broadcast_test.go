@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"errors"
+	"sync"
 	"testing/quick"
 	"time"
 
@@ -12,7 +14,10 @@ import (
 	. "github.com/renproject/hyperdrive/testutil"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+	"github.com/sirupsen/logrus"
 )
 
 type mockBroadcaster struct {
@@ -30,14 +35,55 @@ func newMockBroadcaster() (Broadcaster, chan Message) {
 	}, messages
 }
 
+// mockSigner wraps an ECDSA signer and records every sighash that it is asked
+// to sign. It can be configured to fail, like a remote signer that cannot be
+// reached.
+type mockSigner struct {
+	process.Signer
+
+	mu     *sync.Mutex
+	hashes [][]byte
+	fail   bool
+}
+
+func newMockSigner(key ecdsa.PrivateKey) *mockSigner {
+	return &mockSigner{
+		Signer: process.NewECDSASigner(key),
+		mu:     new(sync.Mutex),
+	}
+}
+
+func (signer *mockSigner) Sign(hash []byte) ([]byte, error) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	if signer.fail {
+		return nil, errors.New("signer unavailable")
+	}
+	signer.hashes = append(signer.hashes, append([]byte{}, hash...))
+	return signer.Signer.Sign(hash)
+}
+
+func (signer *mockSigner) signed(hash id.Hash) bool {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	for _, signed := range signer.hashes {
+		if bytes.Equal(signed, hash[:]) {
+			return true
+		}
+	}
+	return false
+}
+
 var _ = Describe("signer", func() {
 	Context("when broadcasting message", func() {
 		It("should sign the message and then broadcast it", func() {
-			test := func(shard Shard) bool {
+			test := func(shard Shard, epoch uint64) bool {
 				key, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
 				Expect(err).NotTo(HaveOccurred())
 				broadcaster, messages := newMockBroadcaster()
-				signer := newSigner(broadcaster, shard, *key)
+				signer := newSigner(broadcaster, shard, epoch, process.NewECDSASigner(*key), process.NewSHA256Hasher(), newDoubleSignGuard(newMemoryWatermarkStorage(), shard), logrus.StandardLogger())
 
 				msg := RandomMessage(RandomMessageType())
 				signer.Broadcast(msg)
@@ -45,6 +91,7 @@ var _ = Describe("signer", func() {
 				var message Message
 				Eventually(messages, 2*time.Second).Should(Receive(&message))
 				Expect(bytes.Equal(message.Shard[:], shard[:])).Should(BeTrue())
+				Expect(message.Epoch).Should(Equal(epoch))
 				Expect(process.Verify(message.Message)).Should(Succeed())
 				return true
 
@@ -52,5 +99,144 @@ var _ = Describe("signer", func() {
 
 			Expect(quick.Check(test, nil)).Should(Succeed())
 		})
+
+		It("should drop the message if it cannot be signed", func() {
+			key, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			broadcaster, messages := newMockBroadcaster()
+			mockSigner := newMockSigner(*key)
+			mockSigner.fail = true
+			signer := newSigner(broadcaster, Shard{}, 0, mockSigner, process.NewSHA256Hasher(), newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{}), logrus.StandardLogger())
+
+			signer.Broadcast(RandomMessage(RandomMessageType()))
+			Consistently(messages).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a replica uses a custom signer", func() {
+		It("should ask the signer to sign every prevote and precommit", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				mockSigner := newMockSigner(*keys[0])
+				replica, err := NewWithSigner(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, mockSigner)
+				Expect(err).NotTo(HaveOccurred())
+
+				proposedBlock := replica.rebaser.BlockProposal(1, 0)
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				prevote, ok := message.Message.(*process.Prevote)
+				Expect(ok).Should(BeTrue())
+				Expect(prevote.Signatory()).Should(Equal(mockSigner.Signatory()))
+				Expect(mockSigner.signed(prevote.SigHash())).Should(BeTrue())
+				Expect(process.Verify(prevote)).Should(Succeed())
+
+				for _, key := range keys[1:6] {
+					prevote := process.NewPrevote(1, 0, proposedBlock.Hash(), nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				}
+				Eventually(messages).Should(Receive(&message))
+				precommit, ok := message.Message.(*process.Precommit)
+				Expect(ok).Should(BeTrue())
+				Expect(precommit.Signatory()).Should(Equal(mockSigner.Signatory()))
+				Expect(mockSigner.signed(precommit.SigHash())).Should(BeTrue())
+				Expect(process.Verify(precommit)).Should(Succeed())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a replica uses the BLS signature scheme", func() {
+		It("should sign and verify BLS signatures that can be aggregated", func() {
+			shard := Shard{}
+			keys := make([]*process.BLSPrivateKey, 7)
+			pubKeys := make([][]byte, len(keys))
+			sigs := make(id.Signatories, len(keys))
+			for i := range keys {
+				key, err := process.GenerateBLSKey(rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				keys[i] = key
+				pubKeys[i] = key.PublicKey()
+				sigs[i] = process.BLSSignatory(key.PublicKey())
+			}
+			aggregator, err := process.NewBLSAggregator(pubKeys)
+			Expect(err).NotTo(HaveOccurred())
+			store := newMockBlockStorage(sigs)
+			store.Blockchain(shard)
+			broadcaster, messages := newMockBroadcaster()
+			replica, err := NewWithSigner(Options{Verifier: aggregator}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, process.NewBLSSigner(keys[0]))
+			Expect(err).NotTo(HaveOccurred())
+
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.SignWith(propose, process.NewBLSSigner(keys[1]))).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			prevote, ok := message.Message.(*process.Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Signatory()).Should(Equal(sigs[0]))
+			Expect(process.VerifyWith(prevote, aggregator)).Should(Succeed())
+
+			// Expect prevotes with ECDSA signatures to be rejected
+			ecdsaKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			ecdsaPrevote := process.NewPrevote(1, 0, proposedBlock.Hash(), nil)
+			Expect(process.Sign(ecdsaPrevote, *ecdsaKey)).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: ecdsaPrevote})).ShouldNot(Succeed())
+
+			polka := process.Polka{*prevote}
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, proposedBlock.Hash(), nil)
+				Expect(process.SignWith(prevote, process.NewBLSSigner(key))).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				polka = append(polka, *prevote)
+			}
+			Eventually(messages).Should(Receive(&message))
+			precommit, ok := message.Message.(*process.Precommit)
+			Expect(ok).Should(BeTrue())
+			Expect(process.VerifyWith(precommit, aggregator)).Should(Succeed())
+
+			// Expect the polka that the replica precommitted on to verify as
+			// one aggregated signature
+			aggregated, err := polka.Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(aggregated.Verify(5, sigs, aggregator)).Should(Succeed())
+		})
+	})
+
+	Context("when a replica uses a custom hasher", func() {
+		It("should sign and verify sighashes computed by the hasher", func() {
+			shard := Shard{}
+			store, keys := initGenesisStorage(shard)
+			broadcaster, messages := newMockBroadcaster()
+			replica, err := New(Options{Hasher: process.NewKeccak256Hasher()}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Expect proposals signed using a different hasher to be rejected
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Equal(ErrInvalidSignature))
+
+			propose = process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.SignWithHasher(propose, process.NewECDSASigner(*keys[1]), process.NewKeccak256Hasher())).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			prevote, ok := message.Message.(*process.Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(process.VerifyWithHasher(prevote, process.NewECDSAVerifier(), process.NewKeccak256Hasher())).Should(Succeed())
+			Expect(process.Verify(prevote)).ShouldNot(Succeed())
+		})
 	})
 })
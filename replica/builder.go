@@ -0,0 +1,42 @@
+package replica
+
+import (
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+	"github.com/sirupsen/logrus"
+)
+
+// A BlockBuilder assembles the `block.Txs`, `block.Plan`, and parent
+// `block.State` of the `block.Standard` proposed by a Replica at a height, on
+// top of the parent block with the given hash. The Replica builds the header
+// of the block, and signs and broadcasts it as a `process.Propose`. If it
+// returns an error, an empty block is proposed instead, so that the Shard
+// stays live. It is given the Shard of the Replica, so that one BlockBuilder
+// can be shared by the Replicas of many Shards without mixing their
+// transactions.
+type BlockBuilder func(height block.Height, parent id.Hash, shard Shard) (block.Txs, block.Plan, block.State, error)
+
+// builderIterator is a BlockIterator that uses a BlockBuilder to build
+// `block.Standard` blocks, and the underlying BlockIterator to build all other
+// kinds of blocks.
+type builderIterator struct {
+	BlockIterator
+
+	build        BlockBuilder
+	blockStorage BlockStorage
+	logger       logrus.FieldLogger
+}
+
+// NextBlock implements the `BlockIterator` interface.
+func (iter builderIterator) NextBlock(kind block.Kind, height block.Height, shard Shard) (block.Txs, block.Plan, block.State) {
+	if kind != block.Standard {
+		return iter.BlockIterator.NextBlock(kind, height, shard)
+	}
+	parent := iter.blockStorage.LatestBlock(shard)
+	txs, plan, prevState, err := iter.build(height, parent.Hash(), shard)
+	if err != nil {
+		iter.logger.Errorf("error building block at height=%v: %v, proposing an empty block", height, err)
+		return nil, nil, nil
+	}
+	return txs, plan, prevState
+}
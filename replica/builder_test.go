@@ -0,0 +1,96 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("block builder", func() {
+	Context("when the replica is the proposer", func() {
+		It("should commit the block returned by the builder", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			txs, plan, prevState := block.Txs(RandomBytesSlice()), block.Plan(RandomBytesSlice()), block.State(RandomBytesSlice())
+			genesis := store.LatestBlock(Shard{})
+			options := Options{
+				BlockBuilder: func(height block.Height, parent id.Hash, shard Shard) (block.Txs, block.Plan, block.State, error) {
+					Expect(height).Should(Equal(block.Height(1)))
+					Expect(shard).Should(Equal(Shard{}))
+					Expect(parent).Should(Equal(genesis.Hash()))
+					return txs, plan, prevState, nil
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			defer replica.Close()
+
+			// Expect the block from the builder to be proposed, and signed by
+			// the proposer
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			propose, ok := message.Message.(*process.Propose)
+			Expect(ok).Should(BeTrue())
+			Expect(process.Verify(propose)).Should(Succeed())
+			Expect(propose.Signatory()).Should(Equal(id.NewSignatory(keys[1].PublicKey)))
+			Expect(propose.Block().Txs()).Should(Equal(txs))
+			Expect(propose.Block().Plan()).Should(Equal(plan))
+			Expect(propose.Block().PreviousState()).Should(Equal(prevState))
+			go func() {
+				for range messages {
+				}
+			}()
+			Expect(replica.HandleMessage(message)).Should(Succeed())
+
+			// Expect the block from the builder to be committed
+			voters := []*ecdsa.PrivateKey{keys[0], keys[2], keys[3], keys[4], keys[5]}
+			for _, key := range voters {
+				prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			for _, key := range voters {
+				precommit := process.NewPrecommit(1, 0, propose.BlockHash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Eventually(replica.CurrentHeight).Should(Equal(block.Height(2)))
+			committedBlock, ok := store.Blockchain(Shard{}).BlockAtHeight(1)
+			Expect(ok).Should(BeTrue())
+			Expect(committedBlock.Hash()).Should(Equal(propose.BlockHash()))
+			Expect(committedBlock.Txs()).Should(Equal(txs))
+		})
+
+		It("should propose an empty block if the builder fails", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			options := Options{
+				BlockBuilder: func(block.Height, id.Hash, Shard) (block.Txs, block.Plan, block.State, error) {
+					return nil, nil, nil, errors.New("mempool unavailable")
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			defer replica.Close()
+
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			propose, ok := message.Message.(*process.Propose)
+			Expect(ok).Should(BeTrue())
+			Expect(propose.Block().IsEmpty()).Should(BeTrue())
+			go func() {
+				for range messages {
+				}
+			}()
+		})
+	})
+})
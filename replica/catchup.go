@@ -0,0 +1,135 @@
+package replica
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// ErrDirectSendUnsupported is returned when a CatchUpRequest is received by a
+// Replica whose Broadcaster is not a DirectBroadcaster, so that it cannot reply
+// to the requester alone.
+var ErrDirectSendUnsupported = errors.New("direct send unsupported: broadcaster is not a direct broadcaster")
+
+// ErrCatchUpRateLimited is returned when a CatchUpRequest is received from an
+// `id.Signatory` whose previous CatchUpRequest was answered less than
+// catchUpReplyInterval ago.
+var ErrCatchUpRateLimited = errors.New("catch up rate limited")
+
+// maxCommitsPerRange is the maximum number of committed blocks that are sent in
+// response to one CatchUpRequest. A Replica that has fallen further behind
+// needs to request a catch up more than once.
+const maxCommitsPerRange = 100
+
+// catchUpReplyInterval is the minimum time between two replies to the
+// CatchUpRequests of the same `id.Signatory`. Each reply can carry up to
+// maxCommitsPerRange blocks, so a signatory must not be able to request them
+// as fast as it can sign.
+const catchUpReplyInterval = time.Second
+
+// RequestCatchUp broadcasts a signed CatchUpRequest for the blocks that have
+// been committed since the Replica last made progress, instead of waiting for
+// them to be gossiped. Other Replicas respond with a CommitRange that is only
+// sent to this Replica, and that is verified before it is applied. Replicas
+// answer at most one CatchUpRequest per catchUpReplyInterval from the same
+// Replica.
+func (replica *Replica) RequestCatchUp() {
+	replica.broadcaster.Broadcast(process.NewCatchUpRequest(replica.p.CurrentHeight()))
+}
+
+func (replica *Replica) handleCatchUpRequest(request *process.CatchUpRequest) error {
+	commitIterator, ok := replica.blockIterator.(CommitIterator)
+	if !ok {
+		return ErrSyncUnsupported
+	}
+	sender, ok := replica.broadcaster.(directSender)
+	if !ok {
+		return ErrDirectSendUnsupported
+	}
+	if !replica.catchUps.allow(request.Signatory()) {
+		return ErrCatchUpRateLimited
+	}
+
+	// The genesis block is never requested, because it is never committed
+	from := request.Height()
+	if from < 1 {
+		from = 1
+	}
+	commits := []process.LatestCommit{}
+	for height := from; height < replica.p.CurrentHeight() && len(commits) < maxCommitsPerRange; height++ {
+		latestCommit, ok := commitIterator.CommitAtHeight(height, replica.shard)
+		if !ok {
+			break
+		}
+		commits = append(commits, latestCommit)
+	}
+	if len(commits) == 0 {
+		// The requester is not behind (or we are behind too)
+		return nil
+	}
+	replica.options.Logger.Debugf("responding to catch up request from signatory=%v and height=%v with %v commits", request.Signatory(), from, len(commits))
+	return sender.sendTo(request.Signatory(), process.NewCommitRange(commits))
+}
+
+func (replica *Replica) handleCommitRange(commitRange *process.CommitRange) error {
+	defer replica.metrics.didProgress(replica.p)
+	defer replica.saveProcess()
+
+	for _, latestCommit := range commitRange.Commits() {
+		if latestCommit.Block.Header().Height() < replica.p.CurrentHeight() {
+			// Skip blocks that have already been committed, unless they
+			// conflict with the blocks committed by the Replica
+			if replica.checkFork(latestCommit) {
+				return ErrForked
+			}
+			continue
+		}
+		if err := replica.syncCommit(latestCommit); err != nil {
+			replica.options.Logger.Warnf("bad commit range: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// A directSender is a `process.Broadcaster` that can sign a Message and send it
+// to one Replica.
+type directSender interface {
+	sendTo(to id.Signatory, m process.Message) error
+}
+
+// catchUpLimiter limits how often the CatchUpRequests of each `id.Signatory`
+// are answered. CatchUpRequests are only accepted from members of the Shard,
+// so it remembers at most one reply time per member.
+type catchUpLimiter struct {
+	mu       *sync.Mutex
+	clock    Clock
+	interval time.Duration
+	replies  map[id.Signatory]time.Time
+}
+
+func newCatchUpLimiter(clock Clock, interval time.Duration) *catchUpLimiter {
+	return &catchUpLimiter{
+		mu:       new(sync.Mutex),
+		clock:    clock,
+		interval: interval,
+		replies:  map[id.Signatory]time.Time{},
+	}
+}
+
+// allow returns true, and remembers the current time, if the signatory has not
+// been answered within the interval.
+func (limiter *catchUpLimiter) allow(signatory id.Signatory) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.clock.Now()
+	if last, ok := limiter.replies[signatory]; ok && now.Sub(last) < limiter.interval {
+		return false
+	}
+	limiter.replies[signatory] = now
+	return true
+}
@@ -0,0 +1,188 @@
+package replica
+
+import (
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// filterCommitRanges drains the Messages sent by a PeerBroadcaster, and
+// forwards the CommitRanges.
+func filterCommitRanges(sent chan sentMessage) chan sentMessage {
+	filtered := make(chan sentMessage, 100)
+	go func() {
+		for s := range sent {
+			if s.message.Message.Type() == process.CommitRangeMessageType {
+				filtered <- s
+			}
+		}
+	}()
+	return filtered
+}
+
+// filterMessages drains the messages, and forwards the ones of the given
+// message type.
+func filterMessages(messages chan Message, messageType process.MessageType) chan Message {
+	filtered := make(chan Message, 100)
+	go func() {
+		for message := range messages {
+			if message.Message.Type() == messageType {
+				filtered <- message
+			}
+		}
+	}()
+	return filtered
+}
+
+var _ = Describe("catch up", func() {
+	Context("when a replica is behind the network", func() {
+		It("should request the missing blocks and catch up to the network height", func() {
+			test := func(shard Shard) bool {
+				// Create a replica that has committed 10 blocks
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 10, 5)
+				// Every broadcast is sent to a peer that is not the lagging
+				// replica
+				broadcaster, sent := newMockPeerBroadcaster(RandomSignatory())
+				commitRanges := filterCommitRanges(sent)
+				replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *keys[1])
+				Expect(err).NotTo(HaveOccurred())
+				_, err = replica.Sync(1, 10)
+				Expect(err).ToNot(HaveOccurred())
+
+				// Create a replica that has only seen the genesis block
+				sigs := make(id.Signatories, len(keys))
+				for i, key := range keys {
+					sigs[i] = id.NewSignatory(key.PublicKey)
+				}
+				laggingStore := newMockBlockStorage(sigs)
+				laggingStore.Blockchain(shard)
+				laggingBroadcaster, laggingMessages := newMockBroadcaster()
+				catchUpRequests := filterMessages(laggingMessages, process.CatchUpRequestMessageType)
+				laggingReplica, err := New(Options{}, mockProcessStorage{}, laggingStore, mockBlockIterator{}, nil, nil, laggingBroadcaster, shard, *keys[2])
+				Expect(err).NotTo(HaveOccurred())
+
+				// Request the missing blocks
+				laggingReplica.RequestCatchUp()
+				var request Message
+				Eventually(catchUpRequests).Should(Receive(&request))
+				Expect(request.Message.Height()).Should(Equal(block.Height(1)))
+				Expect(replica.HandleMessage(request)).Should(Succeed())
+
+				// Apply the response, which is only sent to the lagging replica
+				var response sentMessage
+				Eventually(commitRanges).Should(Receive(&response))
+				Expect(response.peer).Should(Equal(id.NewSignatory(keys[2].PublicKey)))
+				Expect(response.message.Message.(*process.CommitRange).Commits()).Should(HaveLen(10))
+				Expect(laggingReplica.HandleMessage(response.message)).Should(Succeed())
+				Expect(commitRanges).ShouldNot(Receive())
+
+				Expect(laggingReplica.p.CurrentHeight()).Should(Equal(replica.p.CurrentHeight()))
+				for height := block.Height(1); height <= 10; height++ {
+					committedBlock, ok := laggingStore.Blockchain(shard).BlockAtHeight(height)
+					Expect(ok).Should(BeTrue())
+					Expect(committedBlock.Equal(iter.commits[height].Block)).Should(BeTrue())
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a replica receives many catch up requests", func() {
+		It("should reply to each requester at most once per interval", func() {
+			shard := Shard{}
+			store, keys := initGenesisStorage(shard)
+			iter := newMockCommitIterator(store, shard, keys, 10, 5)
+			broadcaster, sent := newMockPeerBroadcaster(RandomSignatory())
+			commitRanges := filterCommitRanges(sent)
+			clock := NewMockClock(time.Now())
+			replica, err := New(Options{Clock: clock}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			_, err = replica.Sync(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+
+			request := func(key int, from block.Height) error {
+				catchUpRequest := process.NewCatchUpRequest(from)
+				Expect(process.Sign(catchUpRequest, *keys[key])).Should(Succeed())
+				return replica.HandleMessage(Message{Shard: shard, Message: catchUpRequest})
+			}
+			expectReplyTo := func(key int) {
+				var reply sentMessage
+				Eventually(commitRanges).Should(Receive(&reply))
+				Expect(reply.peer).Should(Equal(id.NewSignatory(keys[key].PublicKey)))
+			}
+
+			// Expect one reply to each requester, and none to a requester that
+			// asks again within the interval
+			Expect(request(2, 1)).Should(Succeed())
+			expectReplyTo(2)
+			Expect(request(2, 2)).Should(Equal(ErrCatchUpRateLimited))
+			Expect(request(3, 1)).Should(Succeed())
+			expectReplyTo(3)
+			Expect(request(3, 2)).Should(Equal(ErrCatchUpRateLimited))
+			Consistently(commitRanges).ShouldNot(Receive())
+
+			// Expect requesters to be answered again after the interval
+			clock.Advance(time.Second)
+			Expect(request(2, 3)).Should(Succeed())
+			expectReplyTo(2)
+			Consistently(commitRanges).ShouldNot(Receive())
+		})
+
+		It("should not reply without a direct broadcaster", func() {
+			shard := Shard{}
+			store, keys := initGenesisStorage(shard)
+			iter := newMockCommitIterator(store, shard, keys, 10, 5)
+			broadcaster, messages := newMockBroadcaster()
+			broadcasts := filterMessages(messages, process.CommitRangeMessageType)
+			replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			_, err = replica.Sync(1, 10)
+			Expect(err).ToNot(HaveOccurred())
+
+			catchUpRequest := process.NewCatchUpRequest(1)
+			Expect(process.Sign(catchUpRequest, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: catchUpRequest})).Should(Equal(ErrDirectSendUnsupported))
+			Consistently(broadcasts).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a replica receives an unverifiable commit range", func() {
+		It("should not apply it", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 10, 4)
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+				replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[2])
+				Expect(err).NotTo(HaveOccurred())
+
+				commits := make([]process.LatestCommit, 0, 10)
+				for height := block.Height(1); height <= 10; height++ {
+					commits = append(commits, iter.commits[height])
+				}
+				commitRange := process.NewCommitRange(commits)
+				Expect(process.Sign(commitRange, *keys[1])).Should(Succeed())
+
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: commitRange})).ShouldNot(Succeed())
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(1)))
+				Expect(store.Blockchain(shard).BlockExistsAtHeight(1)).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,278 @@
+package replica
+
+import (
+	"sync"
+	"time"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+	"github.com/sirupsen/logrus"
+)
+
+// A commitDelayer defers the delivery of committed blocks to a callback by a
+// fixed delay, without blocking consensus. Blocks are delivered in the order
+// in which they were committed, and each block is delivered exactly once.
+type commitDelayer struct {
+	mu       *sync.Mutex
+	clock    Clock
+	delay    time.Duration
+	onCommit func(block.Block)
+
+	// delivered is closed once the most recently committed block has been
+	// delivered to the callback
+	delivered chan struct{}
+
+	// done is closed when the commitDelayer is closed, to drop pending
+	// deliveries
+	done chan struct{}
+}
+
+func newCommitDelayer(clock Clock, delay time.Duration, onCommit func(block.Block)) *commitDelayer {
+	delivered := make(chan struct{})
+	close(delivered)
+
+	return &commitDelayer{
+		mu:       new(sync.Mutex),
+		clock:    clock,
+		delay:    delay,
+		onCommit: onCommit,
+
+		delivered: delivered,
+		done:      make(chan struct{}),
+	}
+}
+
+// DidCommit schedules the delivery of a committed block to the callback, once
+// the delay has passed. It returns immediately.
+func (delayer *commitDelayer) DidCommit(committedBlock block.Block) {
+	delayer.mu.Lock()
+	defer delayer.mu.Unlock()
+
+	previous := delayer.delivered
+	delivered := make(chan struct{})
+	delayer.delivered = delivered
+
+	// The delay begins when the block is committed, not when the previous
+	// block is delivered
+	timeout := delayer.clock.After(delayer.delay)
+	go func() {
+		defer close(delivered)
+		select {
+		case <-timeout:
+		case <-delayer.done:
+			return
+		}
+		select {
+		case <-previous:
+		case <-delayer.done:
+			return
+		}
+		delayer.onCommit(committedBlock)
+	}()
+}
+
+// awaitDelivery waits until the most recently committed block has been
+// delivered to the callback, or until the timeout has passed. It returns false
+// if the timeout passed first. It returns true immediately if the
+// commitDelayer has been closed.
+func (delayer *commitDelayer) awaitDelivery(timeout time.Duration) bool {
+	delayer.mu.Lock()
+	delivered := delayer.delivered
+	delayer.mu.Unlock()
+
+	select {
+	case <-delivered:
+		return true
+	case <-delayer.done:
+		return true
+	default:
+	}
+	select {
+	case <-delivered:
+		return true
+	case <-delayer.done:
+		return true
+	case <-delayer.clock.After(timeout):
+		return false
+	}
+}
+
+// A commitAwaitingProposer delays the proposals of a Replica until the block
+// committed at the previous height has been delivered to the OnCommit
+// callback, so that the application has applied the previous block when the
+// next one is built. It waits outside of the `process.Process`, which is made
+// to wait for a trigger to propose, so that the Replica keeps handling
+// Messages while the previous block is being applied. If the callback has not
+// returned by the timeout, the proposal is triggered anyway.
+type commitAwaitingProposer struct {
+	delayer *commitDelayer
+	timeout time.Duration
+	logger  logrus.FieldLogger
+
+	// trigger the proposal at the height and round, if the Replica is still
+	// waiting to propose at them
+	trigger func(block.Height, block.Round) bool
+}
+
+// await waits until the block committed before the height has been delivered
+// to the callback, or until the timeout has passed.
+func (proposer *commitAwaitingProposer) await(height block.Height) {
+	if !proposer.delayer.awaitDelivery(proposer.timeout) {
+		proposer.logger.Warnf("building block at height=%v before the block at height=%v has been applied (timeout=%v)", height, height-1, proposer.timeout)
+	}
+}
+
+// proposeAt triggers the proposal at the height and round once the previous
+// block has been applied. It blocks, so it is expected to be called in its own
+// goroutine whenever the Replica becomes the proposer.
+func (proposer *commitAwaitingProposer) proposeAt(height block.Height, round block.Round) {
+	proposer.await(height)
+	proposer.trigger(height, round)
+}
+
+// close the commitDelayer, dropping all blocks that have not yet been
+// delivered to the callback. Closing a commitDelayer that has already been
+// closed does nothing. A nil commitDelayer is valid, and closing it does
+// nothing.
+func (delayer *commitDelayer) close() {
+	if delayer == nil {
+		return
+	}
+
+	delayer.mu.Lock()
+	defer delayer.mu.Unlock()
+
+	select {
+	case <-delayer.done:
+	default:
+		close(delayer.done)
+	}
+}
+
+// appliedHeights remembers the lowest and highest heights of the blocks that
+// have been delivered to the OnCommit callback.
+type appliedHeights struct {
+	mu          *sync.Mutex
+	first, last block.Height
+}
+
+func newAppliedHeights() *appliedHeights {
+	return &appliedHeights{
+		mu:    new(sync.Mutex),
+		first: block.InvalidHeight,
+		last:  block.InvalidHeight,
+	}
+}
+
+func (applied *appliedHeights) didApply(height block.Height) {
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+
+	if applied.first == block.InvalidHeight || height < applied.first {
+		applied.first = height
+	}
+	if height > applied.last {
+		applied.last = height
+	}
+}
+
+func (applied *appliedHeights) heights() (block.Height, block.Height) {
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+
+	return applied.first, applied.last
+}
+
+// commitRounds counts the heights that were committed at each round. Commits
+// are counted in order of height, and each height is counted at most once, so
+// that the distribution can be extended whenever new commits are available.
+type commitRounds struct {
+	mu           *sync.Mutex
+	counted      block.Height
+	distribution map[block.Round]int
+}
+
+func newCommitRounds() *commitRounds {
+	return &commitRounds{
+		mu:           new(sync.Mutex),
+		counted:      0,
+		distribution: map[block.Round]int{},
+	}
+}
+
+// count the rounds of the commits returned by the CommitIterator, from the
+// lowest height that has not been counted until the first height for which
+// there is no commit. It returns a copy of the distribution.
+func (rounds *commitRounds) count(commitIterator CommitIterator, shard Shard) map[block.Round]int {
+	rounds.mu.Lock()
+	defer rounds.mu.Unlock()
+
+	for {
+		latestCommit, ok := commitIterator.CommitAtHeight(rounds.counted+1, shard)
+		if !ok || latestCommit.Round() == block.InvalidRound {
+			break
+		}
+		rounds.distribution[latestCommit.Round()]++
+		rounds.counted++
+	}
+
+	distribution := make(map[block.Round]int, len(rounds.distribution))
+	for round, n := range rounds.distribution {
+		distribution[round] = n
+	}
+	return distribution
+}
+
+// proposerLogSize is the number of recently committed heights for which the
+// proposer is remembered.
+const proposerLogSize = 1024
+
+// proposerLog remembers the signatory that proposed each of the most recent
+// blocks committed by the Replica. Blocks that are synced, rather than
+// committed, have no known proposer and are not remembered. Proposers are
+// kept in a ring buffer indexed by height, so remembering a proposer
+// overwrites the proposer that was remembered capacity heights below it.
+type proposerLog struct {
+	mu      *sync.Mutex
+	entries []proposerLogEntry
+}
+
+type proposerLogEntry struct {
+	height   block.Height
+	proposer id.Signatory
+	ok       bool
+}
+
+func newProposerLog(capacity int) *proposerLog {
+	return &proposerLog{
+		mu:      new(sync.Mutex),
+		entries: make([]proposerLogEntry, capacity),
+	}
+}
+
+// didCommit remembers the proposer of the block committed at the height.
+func (log *proposerLog) didCommit(height block.Height, proposer id.Signatory) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if height < 0 {
+		return
+	}
+	log.entries[int(height)%len(log.entries)] = proposerLogEntry{height: height, proposer: proposer, ok: true}
+}
+
+// at returns the proposer of the block committed at the height, if it is
+// remembered.
+func (log *proposerLog) at(height block.Height) (id.Signatory, bool) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if height < 0 {
+		return id.Signatory{}, false
+	}
+	entry := log.entries[int(height)%len(log.entries)]
+	if !entry.ok || entry.height != height {
+		return id.Signatory{}, false
+	}
+	return entry.proposer, true
+}
@@ -0,0 +1,507 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("commit delayer", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when blocks are committed", func() {
+		It("should deliver them after the delay, in order", func() {
+			clock := NewMockClock(time.Now().Add(-time.Hour))
+			delivered := make(chan block.Block, 10)
+			delayer := newCommitDelayer(clock, 10*time.Second, func(committedBlock block.Block) {
+				delivered <- committedBlock
+			})
+
+			// Commit one block per second
+			committed := make([]block.Block, 0, 5)
+			for i := 0; i < 5; i++ {
+				header := RandomBlockHeaderJSON(block.Standard)
+				header.Height = block.Height(i + 1)
+				committedBlock := block.New(header.ToBlockHeader(), nil, nil, nil)
+				committed = append(committed, committedBlock)
+				delayer.DidCommit(committedBlock)
+				clock.Advance(time.Second)
+			}
+
+			// No block is delivered before its delay has passed
+			clock.Advance(4 * time.Second)
+			Consistently(delivered, 100*time.Millisecond).ShouldNot(Receive())
+
+			// Blocks are delivered one at a time as their delay passes
+			for _, committedBlock := range committed {
+				clock.Advance(time.Second)
+				var deliveredBlock block.Block
+				Eventually(delivered).Should(Receive(&deliveredBlock))
+				Expect(deliveredBlock.Equal(committedBlock)).Should(BeTrue())
+				Consistently(delivered, 10*time.Millisecond).ShouldNot(Receive())
+			}
+		})
+	})
+
+	Context("when a replica has a commit delay", func() {
+		It("should continue consensus while the callback is deferred", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				clock := NewMockClock(time.Now().Add(-time.Hour))
+				delivered := make(chan block.Block, 10)
+				options := Options{
+					Clock:       clock,
+					CommitDelay: time.Minute,
+					OnCommit: func(committedBlock block.Block) {
+						delivered <- committedBlock
+					},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				numCommits := 3
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					proposer := keys[int(height)%len(keys)]
+					proposedBlock := replica.rebaser.BlockProposal(height, 0)
+					propose := process.NewPropose(height, 0, proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+				}
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(numCommits + 1)))
+				Expect(delivered).ShouldNot(Receive())
+
+				clock.Advance(time.Minute)
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					var deliveredBlock block.Block
+					Eventually(delivered).Should(Receive(&deliveredBlock))
+					Expect(deliveredBlock.Header().Height()).Should(Equal(height))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a replica has a commit timeout", func() {
+		newCommitTimeoutReplica := func(clock Clock, onCommit func(block.Block), built chan<- block.Height) (Replica, []*ecdsa.PrivateKey) {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			// Use timeouts that are longer than the commit timeout, so that
+			// the replica does not time out before it proposes
+			options := Options{
+				BackOffBase:   time.Hour,
+				BackOffMax:    time.Hour,
+				Clock:         clock,
+				CommitTimeout: time.Minute,
+				OnCommit:      onCommit,
+				BlockBuilder: func(height block.Height, parent id.Hash, shard Shard) (block.Txs, block.Plan, block.State, error) {
+					built <- height
+					return nil, nil, nil, nil
+				},
+			}
+			// The replica is the proposer at height 2
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[2])
+			Expect(err).NotTo(HaveOccurred())
+			return replica, keys
+		}
+
+		It("should not build the next proposal until the callback returns", func() {
+			events := make(chan string, 10)
+			release := make(chan struct{})
+			built := make(chan block.Height, 10)
+			onCommit := func(committedBlock block.Block) {
+				<-release
+				events <- "applied"
+			}
+			replica, keys := newCommitTimeoutReplica(NewMockClock(time.Now().Add(-time.Hour)), onCommit, built)
+			go func() {
+				for height := range built {
+					// The proposal at height 1 is built by the test
+					if height > 1 {
+						events <- "built"
+					}
+				}
+			}()
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				commitAt(&replica, 1, keys[1], keys[1:6])
+			}()
+			Consistently(events, 100*time.Millisecond).ShouldNot(Receive())
+
+			// Expect the replica to keep handling messages while it waits
+			Eventually(done).Should(BeClosed())
+			prevote := process.NewPrevote(2, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+			Consistently(events, 100*time.Millisecond).ShouldNot(Receive())
+
+			close(release)
+			Eventually(events).Should(Receive(Equal("applied")))
+			Eventually(events).Should(Receive(Equal("built")))
+		})
+
+		It("should not wait for the timeout when the callback calls back into the replica", func() {
+			clock := NewMockClock(time.Now().Add(-time.Hour))
+			built := make(chan block.Height, 10)
+			var replica Replica
+			heights := make(chan block.Height, 10)
+			onCommit := func(block.Block) {
+				heights <- replica.CurrentHeight()
+			}
+			replica, keys := newCommitTimeoutReplica(clock, onCommit, built)
+			replica.Start()
+			defer replica.Close()
+
+			commitAt(&replica, 1, keys[1], keys[1:6])
+			Eventually(heights).Should(Receive(Equal(block.Height(2))))
+			Eventually(built).Should(Receive(Equal(block.Height(2))))
+		})
+
+		It("should build the next proposal anyway once the timeout has passed", func() {
+			clock := NewMockClock(time.Now().Add(-time.Hour))
+			release := make(chan struct{})
+			defer close(release)
+			built := make(chan block.Height, 10)
+			onCommit := func(block.Block) {
+				<-release
+			}
+			replica, keys := newCommitTimeoutReplica(clock, onCommit, built)
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				commitAt(&replica, 1, keys[1], keys[1:6])
+			}()
+			Eventually(built).Should(Receive(Equal(block.Height(1))))
+			Consistently(built, 100*time.Millisecond).ShouldNot(Receive())
+
+			clock.Advance(time.Minute)
+			Eventually(built).Should(Receive(Equal(block.Height(2))))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("when asking for the applied heights", func() {
+		It("should grow the range as blocks are applied", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				options := Options{
+					OnCommit: func(block.Block) {},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+				first, last := replica.AppliedHeights()
+				Expect(first).Should(Equal(block.InvalidHeight))
+				Expect(last).Should(Equal(block.InvalidHeight))
+
+				numCommits := 3
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					proposer := keys[int(height)%len(keys)]
+					proposedBlock := replica.rebaser.BlockProposal(height, 0)
+					propose := process.NewPropose(height, 0, proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+
+					first, last := replica.AppliedHeights()
+					Expect(first).Should(Equal(block.Height(1)))
+					Expect(last).Should(Equal(height))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should detect committed blocks that have not been applied", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				clock := NewMockClock(time.Now().Add(-time.Hour))
+				options := Options{
+					Clock:       clock,
+					CommitDelay: time.Minute,
+					OnCommit:    func(block.Block) {},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				numCommits := 3
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					proposer := keys[int(height)%len(keys)]
+					proposedBlock := replica.rebaser.BlockProposal(height, 0)
+					propose := process.NewPropose(height, 0, proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+				}
+
+				// Expect a gap between the committed and applied heights while
+				// the callback is deferred
+				committed := replica.p.CurrentHeight() - 1
+				Expect(committed).Should(Equal(block.Height(numCommits)))
+				_, last := replica.AppliedHeights()
+				Expect(last).Should(BeNumerically("<", committed))
+
+				// Expect the gap to close once the callback has been called
+				clock.Advance(time.Minute)
+				Eventually(func() block.Height {
+					_, last := replica.AppliedHeights()
+					return last
+				}).Should(Equal(committed))
+				first, _ := replica.AppliedHeights()
+				Expect(first).Should(Equal(block.Height(1)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when asking for the committed power", func() {
+		It("should sum the stake of the signatories that precommitted", func() {
+			test := func(shard Shard, numPrecommits uint8) bool {
+				store, keys := initGenesisStorage(shard)
+				n := 5 + int(numPrecommits)%3
+				iter := newMockCommitIterator(store, shard, keys, 3, n)
+				broadcaster, _ := newMockBroadcaster()
+
+				stakes := map[id.Signatory]uint64{}
+				for i, key := range keys {
+					stakes[id.NewSignatory(key.PublicKey)] = uint64(i + 1)
+				}
+				options := Options{
+					Stake: func(sig id.Signatory) uint64 {
+						return stakes[sig]
+					},
+				}
+				replica, err := New(options, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				expected := uint64(0)
+				for _, key := range keys[:n] {
+					expected += stakes[id.NewSignatory(key.PublicKey)]
+				}
+				for height := block.Height(1); height <= 3; height++ {
+					Expect(replica.CommittedPower(height)).Should(Equal(expected))
+				}
+				Expect(replica.CommittedPower(4)).Should(BeZero())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should count each signatory once, with a stake of one by default", func() {
+			store, keys := initGenesisStorage(Shard{})
+			iter := newMockCommitIterator(store, Shard{}, keys, 1, 5)
+			latestCommit := iter.commits[1]
+			latestCommit.Precommits = append(latestCommit.Precommits, latestCommit.Precommits[0])
+			iter.commits[1] = latestCommit
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replica.CommittedPower(1)).Should(Equal(uint64(5)))
+		})
+
+		It("should only count the members of a scheduled validator set", func() {
+			store, keys := initGenesisStorage(Shard{})
+			iter := newMockCommitIterator(store, Shard{}, keys, 3, 7)
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			sigs := make(id.Signatories, 0, 4)
+			for _, key := range keys[3:] {
+				sigs = append(sigs, id.NewSignatory(key.PublicKey))
+			}
+			Expect(replica.ScheduleValidatorSet(2, sigs)).Should(Succeed())
+
+			Expect(replica.CommittedPower(1)).Should(Equal(uint64(7)))
+			Expect(replica.CommittedPower(2)).Should(Equal(uint64(4)))
+			Expect(replica.CommittedPower(3)).Should(Equal(uint64(4)))
+		})
+
+		It("should return zero if the block iterator cannot iterate over commits", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replica.CommittedPower(1)).Should(BeZero())
+		})
+	})
+
+	Context("when asking for the commit round distribution", func() {
+		// precommitAtRound replaces the precommits of the commit at the height
+		// with precommits at the round.
+		precommitAtRound := func(iter mockCommitIterator, keys []*ecdsa.PrivateKey, height block.Height, round block.Round) {
+			latestCommit := iter.commits[height]
+			precommits := make([]process.Precommit, 0, len(latestCommit.Precommits))
+			for _, key := range keys[:5] {
+				precommit := process.NewPrecommit(height, round, latestCommit.Block.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				precommits = append(precommits, *precommit)
+			}
+			latestCommit.Precommits = precommits
+			iter.commits[height] = latestCommit
+		}
+
+		It("should count the heights committed at each round", func() {
+			store, keys := initGenesisStorage(Shard{})
+			iter := newMockCommitIterator(store, Shard{}, keys, 7, 5)
+			precommitAtRound(iter, keys, 3, 1)
+			precommitAtRound(iter, keys, 4, 1)
+			precommitAtRound(iter, keys, 6, 2)
+			commit7 := iter.commits[7]
+			delete(iter.commits, 7)
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replica.CommitRoundDistribution()).Should(Equal(map[block.Round]int{0: 3, 1: 2, 2: 1}))
+
+			// Expect the distribution to be extended as heights are
+			// committed, without counting heights twice
+			iter.commits[7] = commit7
+			Expect(replica.CommitRoundDistribution()).Should(Equal(map[block.Round]int{0: 4, 1: 2, 2: 1}))
+			Expect(replica.CommitRoundDistribution()).Should(Equal(map[block.Round]int{0: 4, 1: 2, 2: 1}))
+		})
+
+		It("should return an empty distribution if the block iterator cannot iterate over commits", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replica.CommitRoundDistribution()).Should(BeEmpty())
+		})
+	})
+
+	Context("when asking for the last commit", func() {
+		It("should return the committed block and the precommits that committed it", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+				replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				// Expect no commit before the first block is committed
+				_, ok := replica.LastCommit()
+				Expect(ok).Should(BeFalse())
+
+				proposedBlock := replica.rebaser.BlockProposal(1, 0)
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+				for _, key := range keys[:5] {
+					precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+				}
+				Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+
+				// Expect the commit to be verifiable against the signatories
+				latestCommit, ok := replica.LastCommit()
+				Expect(ok).Should(BeTrue())
+				Expect(latestCommit.Block.Hash()).Should(Equal(proposedBlock.Hash()))
+				Expect(latestCommit.Precommits).Should(HaveLen(5))
+				sigs := store.LatestBaseBlock(shard).Header().Signatories()
+				Expect(latestCommit.Verify(5, sigs)).Should(Succeed())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when remembering the proposers of committed blocks", func() {
+		It("should only remember the most recent heights", func() {
+			log := newProposerLog(4)
+			proposers := make([]id.Signatory, 10)
+			for i := range proposers {
+				proposers[i] = RandomSignatory()
+				log.didCommit(block.Height(i+1), proposers[i])
+			}
+			for height := block.Height(1); height <= 6; height++ {
+				_, ok := log.at(height)
+				Expect(ok).Should(BeFalse())
+			}
+			for height := block.Height(7); height <= 10; height++ {
+				proposer, ok := log.at(height)
+				Expect(ok).Should(BeTrue())
+				Expect(proposer).Should(Equal(proposers[height-1]))
+			}
+			_, ok := log.at(11)
+			Expect(ok).Should(BeFalse())
+			_, ok = log.at(block.InvalidHeight)
+			Expect(ok).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,119 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// rebasingBlockIterator returns empty txs and plans for base blocks, because
+// base blocks are expected to have none.
+type rebasingBlockIterator struct {
+	mockBlockIterator
+}
+
+func (m rebasingBlockIterator) NextBlock(kind block.Kind, height block.Height, shard Shard) (block.Txs, block.Plan, block.State) {
+	if kind == block.Base {
+		return nil, nil, RandomBytesSlice()
+	}
+	return m.mockBlockIterator.NextBlock(kind, height, shard)
+}
+
+// commitConcurrently commits the block proposed by the replica at the height,
+// by delivering every message from many goroutines at once, as if each
+// message was gossiped by many peers, while reading the state of the replica.
+// The extra function is also called from every goroutine.
+func commitConcurrently(replica *Replica, height block.Height, keys []*ecdsa.PrivateKey, extra func()) {
+	numGoroutines := 8
+	proposer := keys[int(height)%len(keys)]
+	propose := process.NewPropose(height, 0, replica.rebaser.BlockProposal(height, 0), block.InvalidRound)
+	Expect(process.Sign(propose, *proposer)).Should(Succeed())
+	ms := []process.Message{propose}
+	for _, key := range keys[1:6] {
+		prevote := process.NewPrevote(height, 0, propose.BlockHash(), nil)
+		Expect(process.Sign(prevote, *key)).Should(Succeed())
+		precommit := process.NewPrecommit(height, 0, propose.BlockHash())
+		Expect(process.Sign(precommit, *key)).Should(Succeed())
+		ms = append(ms, prevote, precommit)
+	}
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer GinkgoRecover()
+			defer wg.Done()
+			for j := range ms {
+				m := ms[(i+j)%len(ms)]
+				replica.HandleMessage(Message{Shard: Shard{}, Message: m})
+				replica.Status()
+				replica.CurrentRound()
+				if extra != nil {
+					extra()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	Expect(replica.CurrentHeight()).Should(Equal(height + 1))
+}
+
+var _ = Describe("concurrency", func() {
+	// These tests are only meaningful when they are run with the race
+	// detector, as they are in CI.
+
+	Context("when messages are handled concurrently", func() {
+		It("should commit without any data races", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, newMemoryProcessStorage(), store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			defer replica.Close()
+
+			numHeights := 5
+			for height := block.Height(1); height <= block.Height(numHeights); height++ {
+				commitConcurrently(&replica, height, keys, nil)
+			}
+		})
+	})
+
+	Context("when a new base block is committed while messages are handled concurrently", func() {
+		It("should commit without any data races", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, newMemoryProcessStorage(), store, rebasingBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			defer replica.Close()
+
+			// Rebase onto the same signatories, again while messages are
+			// being handled, and then commit the rebase block and the base
+			// block
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			replica.Rebase(sigs)
+			commitConcurrently(&replica, 1, keys, func() {
+				replica.Rebase(sigs)
+			})
+			commitConcurrently(&replica, 2, keys, func() {
+				replica.Proposer()
+			})
+			Expect(store.LatestBaseBlock(Shard{}).Header().Height()).Should(Equal(block.Height(2)))
+			commitConcurrently(&replica, 3, keys, nil)
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package replica
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// messageKey identifies a Message for the purpose of deduplication. Two
+// Messages with the same messageKey have the same effect on the
+// `process.Process`.
+type messageKey struct {
+	signatory   id.Signatory
+	height      block.Height
+	round       block.Round
+	messageType process.MessageType
+	blockHash   id.Hash
+	validRound  block.Round
+}
+
+func newMessageKey(m process.Message) messageKey {
+	// Proposals with different valid rounds are different proposals, even for
+	// the same block, so the second one is checked for equivocation instead of
+	// being dropped as a duplicate
+	validRound := block.InvalidRound
+	if propose, ok := m.(*process.Propose); ok {
+		validRound = propose.ValidRound()
+	}
+	return messageKey{
+		signatory:   m.Signatory(),
+		height:      m.Height(),
+		round:       m.Round(),
+		messageType: m.Type(),
+		blockHash:   m.BlockHash(),
+		validRound:  validRound,
+	}
+}
+
+// A messageCache is a bounded LRU cache of the Messages that have been seen by
+// a Replica. It is used to drop Messages that are gossiped more than once,
+// before they are verified and passed to the `process.Process`. Catch-up
+// messages are never cached, because they can be retried.
+type messageCache struct {
+	mu       *sync.Mutex
+	capacity int
+	height   block.Height
+	order    *list.List
+	elements map[messageKey]*list.Element
+}
+
+func newMessageCache(capacity int) *messageCache {
+	return &messageCache{
+		mu:       new(sync.Mutex),
+		capacity: capacity,
+		height:   0,
+		order:    list.New(),
+		elements: map[messageKey]*list.Element{},
+	}
+}
+
+// contains returns true if the Message has been seen before, and marks it as
+// recently used.
+func (cache *messageCache) contains(m process.Message) bool {
+	if !isCacheable(m) {
+		return false
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, ok := cache.elements[newMessageKey(m)]
+	if ok {
+		cache.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// insert the Message, evicting the least recently used Message if the cache
+// is full. Messages below the height of the cache are ignored.
+func (cache *messageCache) insert(m process.Message) {
+	if !isCacheable(m) {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if m.Height() < cache.height {
+		return
+	}
+	key := newMessageKey(m)
+	if elem, ok := cache.elements[key]; ok {
+		cache.order.MoveToFront(elem)
+		return
+	}
+	cache.elements[key] = cache.order.PushFront(key)
+	for cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+}
+
+// evictBelow removes all Messages below the height. It must be called after
+// blocks are committed to bound the memory used by the cache.
+func (cache *messageCache) evictBelow(height block.Height) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if height <= cache.height {
+		return
+	}
+	cache.height = height
+	for elem := cache.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(messageKey).height < height {
+			cache.remove(elem)
+		}
+		elem = next
+	}
+}
+
+func (cache *messageCache) len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.order.Len()
+}
+
+func (cache *messageCache) remove(elem *list.Element) {
+	delete(cache.elements, elem.Value.(messageKey))
+	cache.order.Remove(elem)
+}
+
+func isCacheable(m process.Message) bool {
+	switch m.(type) {
+	case *process.CatchUpRequest, *process.CommitRange:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,113 @@
+package replica
+
+import (
+	"sync/atomic"
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// countingVerifier counts the number of messages that it verifies.
+type countingVerifier struct {
+	count    *int64
+	verifier process.Verifier
+}
+
+func (verifier countingVerifier) Verify(hash, sig []byte, signatory id.Signatory) error {
+	atomic.AddInt64(verifier.count, 1)
+	return verifier.verifier.Verify(hash, sig, signatory)
+}
+
+var _ = Describe("message cache", func() {
+	Context("when the cache is full", func() {
+		It("should evict the least recently used message", func() {
+			cache := newMessageCache(2)
+			first := RandomMessageWithHeightAndRound(1, 0, process.PrevoteMessageType)
+			second := RandomMessageWithHeightAndRound(1, 0, process.PrecommitMessageType)
+			third := RandomMessageWithHeightAndRound(1, 1, process.PrevoteMessageType)
+
+			cache.insert(first)
+			cache.insert(second)
+			Expect(cache.contains(first)).Should(BeTrue())
+
+			cache.insert(third)
+			Expect(cache.len()).Should(Equal(2))
+			Expect(cache.contains(first)).Should(BeTrue())
+			Expect(cache.contains(second)).Should(BeFalse())
+			Expect(cache.contains(third)).Should(BeTrue())
+		})
+	})
+
+	Context("when evicting old heights", func() {
+		It("should remove all messages below the height", func() {
+			test := func() bool {
+				cache := newMessageCache(100)
+				for height := block.Height(1); height <= 10; height++ {
+					cache.insert(RandomMessageWithHeightAndRound(height, 0, process.PrevoteMessageType))
+				}
+				Expect(cache.len()).Should(Equal(10))
+
+				cache.evictBelow(6)
+				Expect(cache.len()).Should(Equal(5))
+
+				// Messages below the evicted height are not inserted again
+				cache.insert(RandomMessageWithHeightAndRound(5, 0, process.PrevoteMessageType))
+				Expect(cache.len()).Should(Equal(5))
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when receiving catch-up messages", func() {
+		It("should not cache them", func() {
+			cache := newMessageCache(100)
+			request := RandomCatchUpRequest()
+			cache.insert(request)
+			Expect(cache.contains(request)).Should(BeFalse())
+			Expect(cache.len()).Should(BeZero())
+		})
+	})
+
+	Context("when a replica receives the same message twice", func() {
+		It("should only pass it to the process once", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				count := int64(0)
+				options := Options{
+					Verifier: countingVerifier{count: &count, verifier: process.NewECDSAVerifier()},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				prevote := process.NewPrevote(1, 0, RandomHash(), nil)
+				Expect(process.Sign(prevote, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Equal(ErrDuplicate))
+
+				// The duplicate is dropped before it is verified, or handled by
+				// the process
+				Expect(atomic.LoadInt64(&count)).Should(Equal(int64(1)))
+				Expect(replica.p.HasReceived(prevote)).Should(BeTrue())
+				Expect(replica.seen.len()).Should(Equal(1))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
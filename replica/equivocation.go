@@ -0,0 +1,73 @@
+package replica
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+)
+
+// ProposerEquivocation is evidence that a proposer signed two different
+// proposals at the same height and round. Both proposals are signed by the
+// proposer, so the evidence can be verified by anyone (for example, before
+// slashing the proposer).
+type ProposerEquivocation struct {
+	First  *process.Propose
+	Second *process.Propose
+}
+
+// String implements the `fmt.Stringer` interface.
+func (equivocation ProposerEquivocation) String() string {
+	return fmt.Sprintf("ProposerEquivocation(Signatory=%v,Height=%v,Round=%v,First=%v,Second=%v)", equivocation.First.Signatory(), equivocation.First.Height(), equivocation.First.Round(), equivocation.First.BlockHash(), equivocation.Second.BlockHash())
+}
+
+// VoteEquivocation is evidence that a signatory signed two different Prevotes,
+// or two different Precommits, at the same height and round. Like
+// ProposerEquivocation, the evidence can be verified by anyone.
+type VoteEquivocation struct {
+	First  process.Message
+	Second process.Message
+}
+
+// String implements the `fmt.Stringer` interface.
+func (equivocation VoteEquivocation) String() string {
+	return fmt.Sprintf("VoteEquivocation(Type=%v,Signatory=%v,Height=%v,Round=%v,First=%v,Second=%v)", equivocation.First.Type(), equivocation.First.Signatory(), equivocation.First.Height(), equivocation.First.Round(), equivocation.First.BlockHash(), equivocation.Second.BlockHash())
+}
+
+// checkProposerEquivocation reports evidence if a different Propose has
+// already been received from the signatory of the Propose at the same height
+// and round. Proposals that only differ in their signatures, or in the data
+// that they carry for syncing, are not considered to be different.
+func (replica *Replica) checkProposerEquivocation(propose *process.Propose) {
+	existing, ok := replica.p.Proposal(propose.Height(), propose.Round(), propose.Signatory())
+	if !ok {
+		return
+	}
+	if existing.BlockHash().Equal(propose.BlockHash()) && existing.ValidRound() == propose.ValidRound() {
+		return
+	}
+
+	equivocation := ProposerEquivocation{First: existing, Second: propose}
+	replica.options.Logger.Warnf("bad message: %v", equivocation)
+	if replica.options.OnProposerEquivocation != nil {
+		replica.options.OnProposerEquivocation(equivocation)
+	}
+}
+
+// checkVoteEquivocation reports evidence if a vote of the same type, but for a
+// different block, has already been received from the signatory of the vote
+// at the same height and round.
+func (replica *Replica) checkVoteEquivocation(vote process.Message) {
+	existing, ok := replica.p.Vote(vote.Type(), vote.Height(), vote.Round(), vote.Signatory())
+	if !ok {
+		return
+	}
+	if existing.BlockHash().Equal(vote.BlockHash()) {
+		return
+	}
+
+	equivocation := VoteEquivocation{First: existing, Second: vote}
+	replica.options.Logger.Warnf("bad message: %v", equivocation)
+	if replica.options.OnVoteEquivocation != nil {
+		replica.options.OnVoteEquivocation(equivocation)
+	}
+}
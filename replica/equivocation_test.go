@@ -0,0 +1,135 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("proposer equivocation", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when a proposer sends two different proposals at the same height and round", func() {
+		It("should report the evidence and continue with the first proposal", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				evidence := []ProposerEquivocation{}
+				options := Options{
+					OnProposerEquivocation: func(equivocation ProposerEquivocation) {
+						evidence = append(evidence, equivocation)
+					},
+				}
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				first := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+				Expect(process.Sign(first, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: first})).Should(Succeed())
+
+				// Expect the replica to prevote for the first proposal
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+				Expect(message.Message.BlockHash()).Should(Equal(first.BlockHash()))
+
+				// Build a different block by building it for another round
+				second := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 1), block.InvalidRound)
+				Expect(process.Sign(second, *keys[1])).Should(Succeed())
+				Expect(second.BlockHash()).ShouldNot(Equal(first.BlockHash()))
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: second})).Should(Equal(ErrDuplicate))
+
+				Expect(evidence).Should(HaveLen(1))
+				Expect(evidence[0].First).Should(Equal(first))
+				Expect(evidence[0].Second).Should(Equal(second))
+				Expect(process.Verify(evidence[0].First)).Should(Succeed())
+				Expect(process.Verify(evidence[0].Second)).Should(Succeed())
+				Expect(messages).ShouldNot(Receive())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a proposer sends the same proposal twice", func() {
+		It("should not report any evidence", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			evidence := []ProposerEquivocation{}
+			options := Options{
+				OnProposerEquivocation: func(equivocation ProposerEquivocation) {
+					evidence = append(evidence, equivocation)
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Equal(ErrDuplicate))
+			Expect(evidence).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("vote equivocation", func() {
+
+	Context("when a signatory sends two different prevotes at the same height and round", func() {
+		It("should report the evidence and continue with the first prevote", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, _ := newMockBroadcaster()
+				evidence := []VoteEquivocation{}
+				options := Options{
+					OnVoteEquivocation: func(equivocation VoteEquivocation) {
+						evidence = append(evidence, equivocation)
+					},
+				}
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				first := process.NewPrevote(1, 0, RandomHash(), nil)
+				Expect(process.Sign(first, *keys[2])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: first})).Should(Succeed())
+
+				second := process.NewPrevote(1, 0, block.InvalidHash, nil)
+				Expect(process.Sign(second, *keys[2])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: second})).Should(Equal(ErrDuplicate))
+
+				Expect(evidence).Should(HaveLen(1))
+				Expect(evidence[0].First).Should(Equal(first))
+				Expect(evidence[0].Second).Should(Equal(second))
+				Expect(process.Verify(evidence[0].First)).Should(Succeed())
+				Expect(process.Verify(evidence[0].Second)).Should(Succeed())
+
+				// Precommits are checked separately from prevotes
+				precommit := process.NewPrecommit(1, 0, RandomHash())
+				Expect(process.Sign(precommit, *keys[2])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+				Expect(evidence).Should(HaveLen(1))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
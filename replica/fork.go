@@ -0,0 +1,123 @@
+package replica
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// ErrForked is returned when a Message is received after the Replica has seen
+// evidence of a fork, and has halted.
+var ErrForked = errors.New("forked")
+
+// ForkEvidence is evidence that two different blocks were committed at the
+// same height, which can only happen if more than F signatories are
+// malicious. First is the block committed by the Replica, and Second is the
+// conflicting commit, which is backed by 2F+1 valid precommits. The precommits
+// of First are empty if the Replica no longer has them.
+type ForkEvidence struct {
+	Height block.Height
+	First  process.LatestCommit
+	Second process.LatestCommit
+}
+
+// String implements the `fmt.Stringer` interface.
+func (evidence ForkEvidence) String() string {
+	return fmt.Sprintf("ForkEvidence(Height=%v,First=%v,Second=%v)", evidence.Height, evidence.First.Block.Hash(), evidence.Second.Block.Hash())
+}
+
+// A forkDetector remembers the first ForkEvidence seen by a Replica. It is
+// shared by all copies of a Replica.
+type forkDetector struct {
+	mu       *sync.Mutex
+	evidence *ForkEvidence
+}
+
+func newForkDetector() *forkDetector {
+	return &forkDetector{
+		mu:       new(sync.Mutex),
+		evidence: nil,
+	}
+}
+
+// report remembers the evidence, and returns false if evidence has already
+// been reported.
+func (detector *forkDetector) report(evidence ForkEvidence) bool {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	if detector.evidence != nil {
+		return false
+	}
+	detector.evidence = &evidence
+	return true
+}
+
+func (detector *forkDetector) forked() (ForkEvidence, bool) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	if detector.evidence == nil {
+		return ForkEvidence{}, false
+	}
+	return *detector.evidence, true
+}
+
+// ForkEvidence returns the evidence of a fork, if the Replica has seen one.
+// Once a fork has been seen, the Replica halts: its timeouts are cancelled,
+// and HandleMessage returns ErrForked.
+func (replica *Replica) ForkEvidence() (ForkEvidence, bool) {
+	return replica.forks.forked()
+}
+
+// checkFork reports evidence, and halts the Replica, if a commit for a
+// different block has already been committed at the height of the
+// LatestCommit. Commits at heights that have not been committed, and commits
+// that are not backed by 2F+1 valid precommits, are ignored. It returns true
+// if evidence was reported.
+func (replica *Replica) checkFork(latestCommit process.LatestCommit) bool {
+	height := latestCommit.Block.Header().Height()
+	if height < 1 || height >= replica.p.CurrentHeight() {
+		return false
+	}
+	committed, ok := replica.blockStorage.Blockchain(replica.shard).BlockAtHeight(height)
+	if !ok || committed.Hash().Equal(latestCommit.Block.Hash()) {
+		return false
+	}
+	if err := replica.p.VerifyCommit(latestCommit); err != nil {
+		replica.options.Logger.Debugf("ignoring conflicting commit at height=%v: %v", height, err)
+		return false
+	}
+
+	evidence := ForkEvidence{
+		Height: height,
+		First:  replica.commitAt(height, committed),
+		Second: latestCommit,
+	}
+	if !replica.forks.report(evidence) {
+		return false
+	}
+	replica.options.Logger.Errorf("halting: %v", evidence)
+	replica.p.Stop()
+	if replica.options.OnFork != nil {
+		replica.options.OnFork(evidence)
+	}
+	return true
+}
+
+// commitAt returns the commit of a block committed by the Replica, with its
+// precommits if they are still known.
+func (replica *Replica) commitAt(height block.Height, committed block.Block) process.LatestCommit {
+	if latestCommit, ok := replica.p.LastCommit(); ok && latestCommit.Block.Hash().Equal(committed.Hash()) {
+		return latestCommit
+	}
+	if commitIterator, ok := replica.blockIterator.(CommitIterator); ok {
+		if latestCommit, ok := commitIterator.CommitAtHeight(height, replica.shard); ok && latestCommit.Block.Hash().Equal(committed.Hash()) {
+			return latestCommit
+		}
+	}
+	return process.LatestCommit{Block: committed}
+}
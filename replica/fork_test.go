@@ -0,0 +1,123 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("fork detection", func() {
+	// commitFirstHeight returns a replica that has committed a block at the
+	// first height, its keys, and a channel of the evidence that it reports.
+	commitFirstHeight := func() (Replica, []*ecdsa.PrivateKey, chan ForkEvidence) {
+		store, keys := initGenesisStorage(Shard{})
+		broadcaster, messages := newMockBroadcaster()
+		go func() {
+			for range messages {
+			}
+		}()
+		forks := make(chan ForkEvidence, 10)
+		options := Options{
+			OnFork: func(evidence ForkEvidence) {
+				forks <- evidence
+			},
+		}
+		replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+		Expect(err).NotTo(HaveOccurred())
+
+		propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+		Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+		Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+		for _, key := range keys[1:6] {
+			precommit := process.NewPrecommit(1, 0, propose.BlockHash())
+			Expect(process.Sign(precommit, *key)).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+		}
+		Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+		return replica, keys, forks
+	}
+
+	// conflictingCommit returns a commit for a random block at the first
+	// height, with a precommit from each key.
+	conflictingCommit := func(keys []*ecdsa.PrivateKey) process.LatestCommit {
+		header := RandomBlockHeaderJSON(block.Standard)
+		header.Height = 1
+		header.Round = 0
+		conflicting := block.New(header.ToBlockHeader(), nil, nil, nil)
+		latestCommit := process.LatestCommit{Block: conflicting}
+		for _, key := range keys {
+			precommit := process.NewPrecommit(1, 0, conflicting.Hash())
+			Expect(process.Sign(precommit, *key)).Should(Succeed())
+			latestCommit.Precommits = append(latestCommit.Precommits, *precommit)
+		}
+		return latestCommit
+	}
+
+	Context("when a conflicting commit is received in a commit range", func() {
+		It("should report fork evidence and halt", func() {
+			replica, keys, forks := commitFirstHeight()
+			committed, ok := replica.LastCommit()
+			Expect(ok).Should(BeTrue())
+
+			conflicting := conflictingCommit(keys[1:6])
+			commitRange := process.NewCommitRange([]process.LatestCommit{conflicting})
+			Expect(process.Sign(commitRange, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: commitRange})).Should(Equal(ErrForked))
+
+			var evidence ForkEvidence
+			Eventually(forks).Should(Receive(&evidence))
+			Expect(evidence.Height).Should(Equal(block.Height(1)))
+			Expect(evidence.First.Block.Hash()).Should(Equal(committed.Block.Hash()))
+			Expect(evidence.First.Precommits).Should(HaveLen(5))
+			Expect(evidence.Second.Block.Hash()).Should(Equal(conflicting.Block.Hash()))
+			Expect(evidence.Second.Precommits).Should(HaveLen(5))
+
+			reported, ok := replica.ForkEvidence()
+			Expect(ok).Should(BeTrue())
+			Expect(reported.String()).Should(Equal(evidence.String()))
+
+			// Expect the replica to have halted
+			prevote := process.NewPrevote(2, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrForked))
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+			Consistently(forks).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a conflicting commit is embedded in a proposal", func() {
+		It("should report fork evidence and halt", func() {
+			replica, keys, forks := commitFirstHeight()
+
+			conflicting := conflictingCommit(keys[1:6])
+			propose := ProposeWithLatestCommit(process.NewPropose(2, 0, replica.rebaser.BlockProposal(2, 0), block.InvalidRound), conflicting)
+			Expect(process.Sign(propose, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Equal(ErrForked))
+
+			var evidence ForkEvidence
+			Eventually(forks).Should(Receive(&evidence))
+			Expect(evidence.Height).Should(Equal(block.Height(1)))
+			Expect(evidence.Second.Block.Hash()).Should(Equal(conflicting.Block.Hash()))
+		})
+	})
+
+	Context("when a conflicting commit is not backed by enough precommits", func() {
+		It("should not report fork evidence", func() {
+			replica, keys, forks := commitFirstHeight()
+
+			conflicting := conflictingCommit(keys[1:5])
+			commitRange := process.NewCommitRange([]process.LatestCommit{conflicting})
+			Expect(process.Sign(commitRange, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: commitRange})).Should(Succeed())
+
+			Consistently(forks).ShouldNot(Receive())
+			_, ok := replica.ForkEvidence()
+			Expect(ok).Should(BeFalse())
+		})
+	})
+})
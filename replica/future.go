@@ -0,0 +1,118 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// A futureBuffer is a bounded buffer of Messages above the current height,
+// that are held by a Replica until it reaches their height. Messages are
+// replayed in the order in which they were received. It is shared by all
+// copies of a Replica.
+type futureBuffer struct {
+	mu       *sync.Mutex
+	size     int
+	messages []Message
+}
+
+// newFutureBuffer returns a futureBuffer that can hold the given number of
+// Messages, or nil if the size is zero.
+func newFutureBuffer(size int) *futureBuffer {
+	if size == 0 {
+		return nil
+	}
+	return &futureBuffer{
+		mu:       new(sync.Mutex),
+		size:     size,
+		messages: make([]Message, 0, size),
+	}
+}
+
+// push buffers the Message, and returns ErrFutureHeight if the buffer is full.
+func (buffer *futureBuffer) push(m Message) error {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	if len(buffer.messages) >= buffer.size {
+		return ErrFutureHeight
+	}
+	buffer.messages = append(buffer.messages, m)
+	return nil
+}
+
+// pop removes, and returns, the Messages that are no longer above the given
+// height, in the order in which they were buffered. It is safe to call on a
+// nil futureBuffer.
+func (buffer *futureBuffer) pop(height block.Height) []Message {
+	if buffer == nil {
+		return nil
+	}
+
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	var popped []Message
+	remaining := buffer.messages[:0]
+	for _, m := range buffer.messages {
+		if m.Message.Height() <= height {
+			popped = append(popped, m)
+			continue
+		}
+		remaining = append(remaining, m)
+	}
+	buffer.messages = remaining
+	return popped
+}
+
+// isFutureMessage returns true if the Message must be held in the future
+// buffer of the Replica, instead of being handled immediately. Catch-up
+// messages concern blocks that have already been committed, so they are never
+// held. Neither are proposals that carry a commit above the current height,
+// so that the Replica can still sync to the commit.
+func (replica *Replica) isFutureMessage(m Message) bool {
+	if replica.future == nil {
+		return false
+	}
+	switch message := m.Message.(type) {
+	case *process.CatchUpRequest, *process.CommitRange:
+		return false
+	case *process.Propose:
+		if message.LatestCommit().Block.Header().Height() > replica.p.CurrentHeight() {
+			return false
+		}
+	}
+	return m.Message.Height() > replica.p.CurrentHeight()
+}
+
+// replayFutureMessages handles the buffered Messages that have been reached by
+// the current height, until the height stops advancing. Buffered Messages are
+// checked again, because the Replica may have learned of a new ValidatorSet,
+// or already received the Message, since they were buffered.
+func (replica *Replica) replayFutureMessages() {
+	for {
+		if _, forked := replica.forks.forked(); forked {
+			return
+		}
+		messages := replica.future.pop(replica.p.CurrentHeight())
+		if len(messages) == 0 {
+			return
+		}
+		for _, m := range messages {
+			if err := replica.replayFutureMessage(m); err != nil {
+				replica.options.Logger.Debugf("dropped buffered message: %v", err)
+			}
+		}
+	}
+}
+
+func (replica *Replica) replayFutureMessage(m Message) error {
+	if !replica.validatorsAt(m.Message.Height()).Contains(m.Message.Signatory()) {
+		return ErrInvalidSignatory
+	}
+	if err := replica.checkProgress(m); err != nil {
+		return err
+	}
+	return replica.handleProcessMessage(m)
+}
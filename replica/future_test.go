@@ -0,0 +1,88 @@
+package replica
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("future buffer", func() {
+	Context("when a proposal for the next height arrives before the current height is committed", func() {
+		It("should buffer the proposal, and prevote for it as soon as the current height is committed", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			prevotes := make(chan *process.Prevote, 10)
+			go func() {
+				for m := range messages {
+					if prevote, ok := m.Message.(*process.Prevote); ok {
+						prevotes <- prevote
+					}
+				}
+			}()
+			replica, err := New(Options{FutureBufferSize: 1}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Build the blocks for the first two heights
+			genesis := store.LatestBaseBlock(Shard{})
+			block1 := replica.rebaser.BlockProposal(1, 0)
+			header2 := block.NewHeader(block.Standard, block1.Hash(), genesis.Hash(), block.Txs{}.Hash(), block.Plan{}.Hash(), block.State{}.Hash(), 2, 0, block.Timestamp(time.Now().Unix()), nil)
+			block2 := block.New(header2, nil, nil, nil)
+
+			// Receive the proposal for the second height early, and expect it to
+			// be held until the first height is committed
+			propose2 := process.NewPropose(2, 0, block2, block.InvalidRound)
+			Expect(process.Sign(propose2, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose2})).Should(Succeed())
+			Expect(replica.p.HasReceived(propose2)).Should(BeFalse())
+
+			// Expect messages to be rejected while the buffer is full
+			prevote := process.NewPrevote(2, 0, block2.Hash(), nil)
+			Expect(process.Sign(prevote, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrFutureHeight))
+			Expect(replica.Status().MessagesRejected).Should(Equal(map[string]uint64{"future_height": 1}))
+
+			// Commit the first height
+			propose1 := process.NewPropose(1, 0, block1, block.InvalidRound)
+			Expect(process.Sign(propose1, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose1})).Should(Succeed())
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, block1.Hash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			for _, key := range keys[1:6] {
+				precommit := process.NewPrecommit(1, 0, block1.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+
+			// Expect the buffered proposal to have been handled, without
+			// waiting for it to be sent again
+			Expect(replica.p.HasReceived(propose2)).Should(BeTrue())
+			Eventually(func() bool {
+				for {
+					select {
+					case prevote := <-prevotes:
+						if prevote.Height() == 2 {
+							Expect(prevote.BlockHash()).Should(Equal(block2.Hash()))
+							return true
+						}
+					default:
+						return false
+					}
+				}
+			}).Should(BeTrue())
+
+			// Expect the buffer to have room again
+			prevote3 := process.NewPrevote(3, 0, block2.Hash(), nil)
+			Expect(process.Sign(prevote3, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote3})).Should(Succeed())
+			Expect(replica.p.HasReceived(prevote3)).Should(BeFalse())
+		})
+	})
+})
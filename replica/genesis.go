@@ -0,0 +1,38 @@
+package replica
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+// initGenesis inserts the genesis block from the Options into the
+// BlockStorage, unless a block already exists at its height, and returns the
+// height at which the Process starts: the height after the genesis block.
+// Without a genesis block, the BlockStorage must already contain a base block
+// at height zero, and the Process starts at height one. It returns an error if
+// the genesis block is not a base block, or if a different block already
+// exists at its height.
+func initGenesis(options Options, blockStorage BlockStorage, shard Shard) (block.Height, error) {
+	genesis := options.Genesis
+	if genesis.Hash().Equal(block.InvalidHash) {
+		return 1, nil
+	}
+
+	header := genesis.Header()
+	if header.Kind() != block.Base {
+		return 0, fmt.Errorf("genesis block=%v has unexpected kind=%v", genesis.Hash(), header.Kind())
+	}
+	if header.Height() < 0 {
+		return 0, fmt.Errorf("genesis block=%v has unexpected height=%v", genesis.Hash(), header.Height())
+	}
+	blockchain := blockStorage.Blockchain(shard)
+	if existing, ok := blockchain.BlockAtHeight(header.Height()); ok {
+		if !existing.Hash().Equal(genesis.Hash()) {
+			return 0, fmt.Errorf("expected genesis block=%v at height=%v, got block=%v", genesis.Hash(), header.Height(), existing.Hash())
+		}
+	} else if err := blockchain.InsertBlockAtHeight(header.Height(), genesis); err != nil {
+		return 0, fmt.Errorf("error inserting genesis block=%v: %v", genesis.Hash(), err)
+	}
+	return header.Height() + 1, nil
+}
@@ -0,0 +1,79 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	cRand "crypto/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("genesis", func() {
+	newGenesis := func(height block.Height) (block.Block, []*ecdsa.PrivateKey) {
+		sigs := make(id.Signatories, 7)
+		keys := make([]*ecdsa.PrivateKey, 7)
+		for i := range sigs {
+			privateKey, err := ecdsa.GenerateKey(crypto.S256(), cRand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			keys[i] = privateKey
+			sigs[i] = id.NewSignatory(privateKey.PublicKey)
+		}
+		header := block.NewHeader(block.Base, RandomHash(), RandomHash(), RandomHash(), RandomHash(), RandomHash(), height, 0, 0, sigs)
+		return block.New(header, nil, nil, nil), keys
+	}
+
+	for _, height := range []block.Height{0, 5} {
+		height := height
+
+		Context("when a replica is constructed with a genesis block", func() {
+			It("should start at the height after the genesis block", func() {
+				genesis, keys := newGenesis(height)
+				store := newMockBlockStorage(nil)
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(Options{Genesis: genesis}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(replica.Status().Height).Should(Equal(height + 1))
+				Expect(replica.Status().LastCommitHeight).Should(Equal(height))
+				Expect(replica.Status().Round).Should(Equal(block.Round(0)))
+				Expect(replica.Status().State).Should(Equal(process.StepPropose))
+				Expect(store.LatestBlock(Shard{}).Hash()).Should(Equal(genesis.Hash()))
+
+				// Expect the first proposal to be built, and validated, on top
+				// of the genesis block
+				proposal := replica.rebaser.BlockProposal(height+1, 0)
+				Expect(proposal.Header().ParentHash()).Should(Equal(genesis.Hash()))
+				Expect(proposal.Header().BaseHash()).Should(Equal(genesis.Hash()))
+
+				replica.Start()
+				defer replica.Close()
+				proposer := keys[(int(height)+1)%len(keys)]
+				propose := process.NewPropose(height+1, 0, proposal, block.InvalidRound)
+				Expect(process.Sign(propose, *proposer)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				prevote, ok := message.Message.(*process.Prevote)
+				Expect(ok).Should(BeTrue())
+				Expect(prevote.BlockHash()).Should(Equal(proposal.Hash()))
+			})
+		})
+	}
+
+	Context("when the block storage already has a different block at the genesis height", func() {
+		It("should return an error", func() {
+			store, keys := initGenesisStorage(Shard{})
+			genesis, _ := newGenesis(0)
+			broadcaster, _ := newMockBroadcaster()
+			_, err := New(Options{Genesis: genesis}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
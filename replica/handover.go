@@ -0,0 +1,136 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// A validatorHandover is a ValidatorSet that has been scheduled to replace the
+// ValidatorSet of the latest base block from a future height onwards, and the
+// scheduler of its proposers.
+type validatorHandover struct {
+	height     block.Height
+	validators ValidatorSet
+	scheduler  scheduler
+}
+
+// validatorHandovers are the validatorHandovers of a Replica, ordered by
+// height.
+type validatorHandovers struct {
+	mu        *sync.RWMutex
+	handovers []validatorHandover
+}
+
+func newValidatorHandovers() *validatorHandovers {
+	return &validatorHandovers{
+		mu:        new(sync.RWMutex),
+		handovers: nil,
+	}
+}
+
+// at returns the latest validatorHandover at, or below, the height. It returns
+// false if there is none.
+func (handovers *validatorHandovers) at(height block.Height) (validatorHandover, bool) {
+	handovers.mu.RLock()
+	defer handovers.mu.RUnlock()
+
+	for i := len(handovers.handovers) - 1; i >= 0; i-- {
+		if handovers.handovers[i].height <= height {
+			return handovers.handovers[i], true
+		}
+	}
+	return validatorHandover{}, false
+}
+
+// insert a validatorHandover, replacing all validatorHandovers at, or above,
+// its height. It returns a function that restores the replaced
+// validatorHandovers.
+func (handovers *validatorHandovers) insert(handover validatorHandover) func() {
+	handovers.mu.Lock()
+	defer handovers.mu.Unlock()
+
+	prev := handovers.handovers
+	next := make([]validatorHandover, 0, len(prev)+1)
+	for _, h := range prev {
+		if h.height < handover.height {
+			next = append(next, h)
+		}
+	}
+	handovers.handovers = append(next, handover)
+
+	return func() {
+		handovers.mu.Lock()
+		defer handovers.mu.Unlock()
+
+		handovers.handovers = prev
+	}
+}
+
+// handoverScheduler is a scheduler that uses the scheduler of the latest
+// validatorHandover at, or below, a height, and the underlying scheduler if
+// there is none.
+type handoverScheduler struct {
+	scheduler
+
+	handovers *validatorHandovers
+}
+
+// Schedule implements the `process.Scheduler` interface.
+func (scheduler handoverScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	if handover, ok := scheduler.handovers.at(height); ok {
+		return handover.scheduler.Schedule(height, round)
+	}
+	return scheduler.scheduler.Schedule(height, round)
+}
+
+// ScheduleValidatorSet installs a new ValidatorSet that takes effect exactly
+// when the Replica reaches the given height, for example when a governance
+// transaction changes the signatories of the Shard. At, and above, the height,
+// the proposer schedule and the consensus threshold are derived from the new
+// signatories, and Messages from signatories that are not members of the new
+// ValidatorSet are rejected with ErrInvalidSignatory. Below the height, the
+// ValidatorSet of the latest base block is still used.
+//
+// An error is returned, and nothing is changed, if the height is not strictly
+// above the current height, or if the number of signatories is not 3f+1.
+// Scheduling another ValidatorSet at, or below, the height of a previously
+// scheduled ValidatorSet replaces it. Scheduled ValidatorSets are not
+// persisted, so they must be scheduled again after a restart.
+// ScheduleValidatorSet is safe for concurrent use.
+func (replica *Replica) ScheduleValidatorSet(atHeight block.Height, validators id.Signatories) error {
+	if len(validators)%3 != 1 {
+		return fmt.Errorf("expected 3f+1 validators, got %v", len(validators))
+	}
+	if currentHeight := replica.p.CurrentHeight(); atHeight <= currentHeight {
+		return fmt.Errorf("expected handover height>%v, got height=%v", currentHeight, atHeight)
+	}
+
+	// Install the new schedule and ValidatorSet before changing the
+	// signatories of the `process.Process`, so that both are in place when it
+	// reaches the height and schedules its first proposer, and restore the
+	// previous handover if the `process.Process` has reached the height in
+	// the meantime
+	restore := replica.handovers.insert(validatorHandover{
+		height:     atHeight,
+		validators: NewValidatorSet(validators),
+		scheduler:  newScheduler(replica.options, validators),
+	})
+	if !replica.p.ChangeSignatories(atHeight, validators) {
+		restore()
+		return fmt.Errorf("expected handover height>%v, got height=%v", replica.p.CurrentHeight(), atHeight)
+	}
+	return nil
+}
+
+// validatorsAt returns the ValidatorSet of the Shard at the height. It is the
+// ValidatorSet of the latest base block, unless a ValidatorSet has been
+// scheduled at, or below, the height.
+func (replica *Replica) validatorsAt(height block.Height) ValidatorSet {
+	if handover, ok := replica.handovers.at(height); ok {
+		return handover.validators
+	}
+	return replica.cache.validatorsOf(replica.blockStorage.LatestBaseBlock(replica.shard))
+}
@@ -0,0 +1,177 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// commitAt commits the block proposed by the proposer at the height, in the
+// first round, using votes from the voters.
+func commitAt(replica *Replica, height block.Height, proposer *ecdsa.PrivateKey, voters []*ecdsa.PrivateKey) {
+	propose := process.NewPropose(height, 0, replica.rebaser.BlockProposal(height, 0), block.InvalidRound)
+	Expect(process.Sign(propose, *proposer)).Should(Succeed())
+	Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+	for _, key := range voters {
+		prevote := process.NewPrevote(height, 0, propose.BlockHash(), nil)
+		Expect(process.Sign(prevote, *key)).Should(Succeed())
+		Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+	}
+	for _, key := range voters {
+		precommit := process.NewPrecommit(height, 0, propose.BlockHash())
+		Expect(process.Sign(precommit, *key)).Should(Succeed())
+		Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+	}
+	Expect(replica.CurrentHeight()).Should(Equal(height + 1))
+}
+
+var _ = Describe("validator set handover", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when scheduling a validator set", func() {
+		It("should reject handover heights that are not in the future", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			Expect(replica.ScheduleValidatorSet(0, sigs)).ShouldNot(Succeed())
+			Expect(replica.ScheduleValidatorSet(1, sigs)).ShouldNot(Succeed())
+			Expect(replica.ScheduleValidatorSet(2, sigs)).Should(Succeed())
+		})
+
+		It("should reject validator sets that are not 3f+1", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			Expect(replica.ScheduleValidatorSet(2, sigs[:6])).ShouldNot(Succeed())
+			Expect(replica.ScheduleValidatorSet(2, nil)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when committing across the handover height", func() {
+		It("should switch the proposer schedule and membership at the handover height", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Replace the last validator with a new one at height 2
+			newKey := newEcdsaKey()
+			validators := make(id.Signatories, 0, 7)
+			for _, key := range keys[:6] {
+				validators = append(validators, id.NewSignatory(key.PublicKey))
+			}
+			validators = append(validators, id.NewSignatory(newKey.PublicKey))
+			Expect(replica.ScheduleValidatorSet(2, validators)).Should(Succeed())
+
+			// Expect the old validator to be a member until the handover
+			prevote := process.NewPrevote(1, 1, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[6])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			commitAt(&replica, 1, keys[1], keys[1:6])
+
+			Expect(replica.Validators().Signatories()).Should(Equal(validators))
+			Expect(replica.Proposer()).Should(Equal(validators[2]))
+
+			// Expect votes from the old validator to be rejected, and votes
+			// from the new validator to be accepted
+			prevote = process.NewPrevote(2, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[6])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrInvalidSignatory))
+			prevote = process.NewPrevote(2, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *newKey)).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+		})
+
+		It("should switch the consensus threshold at the handover height", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Shrink the validator set to 4 validators at height 2
+			validators := make(id.Signatories, 0, 4)
+			for _, key := range keys[:4] {
+				validators = append(validators, id.NewSignatory(key.PublicKey))
+			}
+			Expect(replica.ScheduleValidatorSet(2, validators)).Should(Succeed())
+			commitAt(&replica, 1, keys[1], keys[1:6])
+
+			// Expect 3 votes to be enough to commit after the handover
+			Expect(replica.Proposer()).Should(Equal(validators[2]))
+			commitAt(&replica, 2, keys[2], keys[1:4])
+		})
+
+		It("should keep the consensus threshold of the old validator set for commits below the handover height", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Shrink the validator set to 4 validators at height 2, and reach
+			// the handover height
+			validators := make(id.Signatories, 0, 4)
+			for _, key := range keys[:4] {
+				validators = append(validators, id.NewSignatory(key.PublicKey))
+			}
+			Expect(replica.ScheduleValidatorSet(2, validators)).Should(Succeed())
+			commitAt(&replica, 1, keys[1], keys[1:6])
+
+			// Build a conflicting commit at the first height, with enough
+			// precommits for the new validator set, but not the old one
+			header := RandomBlockHeaderJSON(block.Standard)
+			header.Height = 1
+			header.Round = 0
+			conflicting := process.LatestCommit{Block: block.New(header.ToBlockHeader(), nil, nil, nil)}
+			for _, key := range keys[1:4] {
+				precommit := process.NewPrecommit(1, 0, conflicting.Block.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				conflicting.Precommits = append(conflicting.Precommits, *precommit)
+			}
+			Expect(replica.p.VerifyCommit(conflicting)).ShouldNot(Succeed())
+
+			// Expect the conflicting commit to be synced without being
+			// mistaken for a fork
+			commitRange := process.NewCommitRange([]process.LatestCommit{conflicting})
+			Expect(process.Sign(commitRange, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: commitRange})).Should(Succeed())
+			_, forked := replica.ForkEvidence()
+			Expect(forked).Should(BeFalse())
+
+			// Expect the commit that was committed to still be verified
+			committed, ok := replica.LastCommit()
+			Expect(ok).Should(BeTrue())
+			Expect(replica.p.VerifyCommit(committed)).Should(Succeed())
+		})
+	})
+})
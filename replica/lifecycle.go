@@ -0,0 +1,54 @@
+package replica
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle guards against using a Replica after it has been closed. It is
+// shared by all copies of a Replica.
+type lifecycle struct {
+	mu     *sync.RWMutex
+	closed bool
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		mu:     new(sync.RWMutex),
+		closed: false,
+	}
+}
+
+// Run starts the Replica, and blocks until the context is done. The Replica is
+// closed before Run returns.
+func (replica *Replica) Run(ctx context.Context) {
+	replica.Start()
+	<-ctx.Done()
+	replica.Close()
+}
+
+// Close the Replica. Messages that are being handled are allowed to finish,
+// Messages that are still queued are dropped, the `process.Process` is saved
+// to storage, scheduled timeouts are cancelled, committed blocks that have not
+// yet been delivered to the commit callback are dropped, and Progress and
+// Action subscriptions are closed. After the Replica has been closed,
+// HandleMessage returns ErrClosed. Closing a Replica that has already been
+// closed does nothing.
+func (replica *Replica) Close() {
+	replica.lifecycle.mu.Lock()
+	defer replica.lifecycle.mu.Unlock()
+
+	if replica.lifecycle.closed {
+		return
+	}
+	replica.lifecycle.closed = true
+
+	replica.p.Stop()
+	if replica.queue != nil {
+		replica.queue.close()
+	}
+	replica.saveProcess()
+	replica.delayer.close()
+	replica.progress.close()
+	replica.actions.close()
+}
@@ -0,0 +1,117 @@
+package replica
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"go.uber.org/goleak"
+)
+
+var _ = Describe("lifecycle", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	// commit a block at a height on behalf of the scheduled proposer and 2f+1
+	// signatories.
+	commit := func(replica Replica, keys []*ecdsa.PrivateKey, shard Shard, height block.Height) {
+		proposedBlock := replica.rebaser.BlockProposal(height, 0)
+		propose := process.NewPropose(height, 0, proposedBlock, block.InvalidRound)
+		Expect(process.Sign(propose, *keys[int(height)%len(keys)])).Should(Succeed())
+		Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+		for _, key := range keys[:5] {
+			precommit := process.NewPrecommit(height, 0, proposedBlock.Hash())
+			Expect(process.Sign(precommit, *key)).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+		}
+	}
+
+	Context("when running a replica until the context is cancelled", func() {
+		It("should close the replica without leaking goroutines", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+
+			// Ignore goroutines that were started by other tests
+			ignoreCurrent := goleak.IgnoreCurrent()
+
+			// Use timeouts and a commit delay that never pass, so that
+			// goroutines are leaked unless they are cancelled
+			options := Options{
+				BackOffBase: time.Hour,
+				BackOffMax:  time.Hour,
+				Clock:       NewMockClock(time.Now()),
+				OnCommit:    func(block.Block) {},
+				CommitDelay: time.Hour,
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				replica.Run(ctx)
+			}()
+			commit(replica, keys, Shard{}, 1)
+
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Eventually(func() error { return goleak.Find(ignoreCurrent) }, 2*time.Second).Should(Succeed())
+		})
+	})
+
+	Context("when a replica has been closed", func() {
+		It("should reject messages", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			replica.Close()
+
+			prevote := process.NewPrevote(1, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[0])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrClosed))
+		})
+
+		It("should do nothing when closed again", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			replica.Close()
+			Expect(replica.Close).ShouldNot(Panic())
+		})
+
+		It("should not start", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			ignoreCurrent := goleak.IgnoreCurrent()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			replica.Close()
+			replica.Start()
+			Expect(replica.p.CurrentRound()).Should(Equal(block.Round(0)))
+			Eventually(func() error { return goleak.Find(ignoreCurrent) }, 2*time.Second).Should(Succeed())
+		})
+	})
+})
@@ -2,6 +2,7 @@ package replica
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -9,28 +10,79 @@ import (
 	"github.com/renproject/hyperdrive/process"
 )
 
+// MessageVersion is the version of the wire format of a Message. It is encoded
+// in both the binary and JSON forms of every Message, so that Messages from
+// peers using a different wire format are rejected instead of being silently
+// corrupted. It must be incremented whenever the wire format changes.
+const MessageVersion uint8 = 2
+
+// MarshalBinary implements the `encoding.BinaryMarshaler` interface. The Shard
+// is encoded as its 32 raw bytes, so equal Shards always have equal encodings.
+func (shard Shard) MarshalBinary() ([]byte, error) {
+	data := make([]byte, len(shard))
+	copy(data, shard[:])
+	return data, nil
+}
+
+// UnmarshalBinary implements the `encoding.BinaryUnmarshaler` interface.
+func (shard *Shard) UnmarshalBinary(data []byte) error {
+	if len(data) != len(shard) {
+		return fmt.Errorf("cannot unmarshal shard: expected len=%v, got len=%v", len(shard), len(data))
+	}
+	copy(shard[:], data)
+	return nil
+}
+
+// MarshalJSON implements the `json.Marshaler` interface. The Shard is encoded
+// as the same unpadded base64 string that is returned by `Shard.String`.
+func (shard Shard) MarshalJSON() ([]byte, error) {
+	return json.Marshal(shard.String())
+}
+
+// UnmarshalJSON implements the `json.Unmarshaler` interface.
+func (shard *Shard) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("cannot unmarshal shard: %v", err)
+	}
+	shardBytes, err := base64.RawStdEncoding.DecodeString(str)
+	if err != nil {
+		return fmt.Errorf("cannot decode shard: %v", err)
+	}
+	return shard.UnmarshalBinary(shardBytes)
+}
+
 func (m Message) MarshalJSON() ([]byte, error) {
 	tmp := struct {
+		Version     uint8               `json:"v"`
 		MessageType process.MessageType `json:"type"`
 		Message     process.Message     `json:"message"`
 		Shard       Shard               `json:"shard"`
+		Epoch       uint64              `json:"epoch"`
 	}{
+		Version:     MessageVersion,
 		MessageType: m.Message.Type(),
 		Message:     m.Message,
 		Shard:       m.Shard,
+		Epoch:       m.Epoch,
 	}
 	return json.Marshal(tmp)
 }
 
 func (m *Message) UnmarshalJSON(data []byte) error {
 	tmp := struct {
+		Version     uint8               `json:"v"`
 		MessageType process.MessageType `json:"type"`
 		Message     json.RawMessage     `json:"message"`
 		Shard       Shard               `json:"shard"`
+		Epoch       uint64              `json:"epoch"`
 	}{}
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return err
 	}
+	if tmp.Version != MessageVersion {
+		return fmt.Errorf("unsupported message version: expected version=%v, got version=%v", MessageVersion, tmp.Version)
+	}
 
 	switch tmp.MessageType {
 	case process.ProposeMessageType:
@@ -51,14 +103,36 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		m.Message = precommit
+	case process.ResignMessageType:
+		resign := new(process.Resign)
+		if err := resign.UnmarshalJSON(tmp.Message); err != nil {
+			return err
+		}
+		m.Message = resign
+	case process.CatchUpRequestMessageType:
+		request := new(process.CatchUpRequest)
+		if err := request.UnmarshalJSON(tmp.Message); err != nil {
+			return err
+		}
+		m.Message = request
+	case process.CommitRangeMessageType:
+		commitRange := new(process.CommitRange)
+		if err := commitRange.UnmarshalJSON(tmp.Message); err != nil {
+			return err
+		}
+		m.Message = commitRange
 	}
 	m.Shard = tmp.Shard
+	m.Epoch = tmp.Epoch
 
 	return nil
 }
 
 func (m Message) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, MessageVersion); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write version: %v", err)
+	}
 	messageData, err := m.Message.MarshalBinary()
 	if err != nil {
 		return buf.Bytes(), fmt.Errorf("cannot marshal m.Message: %v", err)
@@ -72,14 +146,28 @@ func (m Message) MarshalBinary() ([]byte, error) {
 	if err := binary.Write(buf, binary.LittleEndian, messageData); err != nil {
 		return buf.Bytes(), fmt.Errorf("cannot write m.Message data: %v", err)
 	}
-	if err := binary.Write(buf, binary.LittleEndian, m.Shard); err != nil {
+	shardData, err := m.Shard.MarshalBinary()
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot marshal m.Shard: %v", err)
+	}
+	if err := binary.Write(buf, binary.LittleEndian, shardData); err != nil {
 		return buf.Bytes(), fmt.Errorf("cannot write m.Shard: %v", err)
 	}
+	if err := binary.Write(buf, binary.LittleEndian, m.Epoch); err != nil {
+		return buf.Bytes(), fmt.Errorf("cannot write m.Epoch: %v", err)
+	}
 	return buf.Bytes(), nil
 }
 
 func (m *Message) UnmarshalBinary(data []byte) error {
 	buf := bytes.NewBuffer(data)
+	var version uint8
+	if err := binary.Read(buf, binary.LittleEndian, &version); err != nil {
+		return fmt.Errorf("cannot read version: %v", err)
+	}
+	if version != MessageVersion {
+		return fmt.Errorf("unsupported message version: expected version=%v, got version=%v", MessageVersion, version)
+	}
 	var numBytes uint64
 	if err := binary.Read(buf, binary.LittleEndian, &numBytes); err != nil {
 		return fmt.Errorf("cannot read m.Message len: %v", err)
@@ -88,6 +176,9 @@ func (m *Message) UnmarshalBinary(data []byte) error {
 	if err := binary.Read(buf, binary.LittleEndian, &messageType); err != nil {
 		return fmt.Errorf("cannot read m.Message.Type: %v", err)
 	}
+	if numBytes > uint64(buf.Len()) {
+		return fmt.Errorf("cannot read m.Message data: expected len<=%v, got len=%v", buf.Len(), numBytes)
+	}
 	messageBytes := make([]byte, numBytes)
 	if _, err := buf.Read(messageBytes); err != nil {
 		return fmt.Errorf("cannot read m.Message data: %v", err)
@@ -106,6 +197,18 @@ func (m *Message) UnmarshalBinary(data []byte) error {
 		precommit := new(process.Precommit)
 		err = precommit.UnmarshalBinary(messageBytes)
 		m.Message = precommit
+	case process.ResignMessageType:
+		resign := new(process.Resign)
+		err = resign.UnmarshalBinary(messageBytes)
+		m.Message = resign
+	case process.CatchUpRequestMessageType:
+		request := new(process.CatchUpRequest)
+		err = request.UnmarshalBinary(messageBytes)
+		m.Message = request
+	case process.CommitRangeMessageType:
+		commitRange := new(process.CommitRange)
+		err = commitRange.UnmarshalBinary(messageBytes)
+		m.Message = commitRange
 	default:
 		return fmt.Errorf("unexpected message type %d", messageType)
 	}
@@ -115,5 +218,8 @@ func (m *Message) UnmarshalBinary(data []byte) error {
 	if err := binary.Read(buf, binary.LittleEndian, &m.Shard); err != nil {
 		return fmt.Errorf("cannot read m.Shard: %v", err)
 	}
+	if err := binary.Read(buf, binary.LittleEndian, &m.Epoch); err != nil {
+		return fmt.Errorf("cannot read m.Epoch: %v", err)
+	}
 	return nil
 }
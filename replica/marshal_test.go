@@ -1,21 +1,31 @@
 package replica_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing/quick"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/renproject/hyperdrive/replica"
 	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/process"
 )
 
 var _ = Describe("Marshaling", func() {
 	Context("when marshaling and unmarshaling a message", func() {
 		It("should equal itself after binary marshaling/unmarshaling", func() {
 			for i := 0; i < 10; i++ {
+				shard := Shard{}
+				rand.Read(shard[:])
 				message := Message{
 					Message: RandomMessage(RandomMessageType()),
-					Shard:   Shard{},
+					Shard:   shard,
+					Epoch:   rand.Uint64(),
 				}
 				messageBytes, err := message.MarshalBinary()
 				Expect(err).ToNot(HaveOccurred())
@@ -28,9 +38,12 @@ var _ = Describe("Marshaling", func() {
 
 		It("should equal itself after json marshaling/unmarshaling", func() {
 			for i := 0; i < 10; i++ {
+				shard := Shard{}
+				rand.Read(shard[:])
 				message := Message{
 					Message: RandomMessage(RandomMessageType()),
-					Shard:   Shard{},
+					Shard:   shard,
+					Epoch:   rand.Uint64(),
 				}
 				messageBytes, err := json.Marshal(message)
 				Expect(err).ToNot(HaveOccurred())
@@ -41,4 +54,188 @@ var _ = Describe("Marshaling", func() {
 			}
 		})
 	})
+
+	Context("when marshaling and unmarshaling a versioned message", func() {
+		It("should prepend the version to the binary layout", func() {
+			for i := 0; i < 10; i++ {
+				shard := Shard{}
+				rand.Read(shard[:])
+				message := Message{
+					Message: RandomMessage(RandomMessageType()),
+					Shard:   shard,
+					Epoch:   rand.Uint64(),
+				}
+				messageBytes, err := message.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				innerBytes, err := message.Message.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+
+				// Expect the version, followed by the length, type and data of
+				// the inner message, followed by the shard and the epoch
+				Expect(messageBytes[0]).Should(Equal(MessageVersion))
+				Expect(binary.LittleEndian.Uint64(messageBytes[1:9])).Should(Equal(uint64(len(innerBytes))))
+				Expect(binary.LittleEndian.Uint64(messageBytes[9:17])).Should(Equal(uint64(message.Message.Type())))
+				Expect(messageBytes[17 : 17+len(innerBytes)]).Should(Equal(innerBytes))
+				Expect(messageBytes[17+len(innerBytes) : 49+len(innerBytes)]).Should(Equal(shard[:]))
+				Expect(binary.LittleEndian.Uint64(messageBytes[49+len(innerBytes):])).Should(Equal(message.Epoch))
+
+				// Expect the layout to be unchanged after a round trip
+				var newMessage Message
+				Expect(newMessage.UnmarshalBinary(messageBytes)).To(Succeed())
+				newMessageBytes, err := newMessage.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(newMessageBytes).Should(Equal(messageBytes))
+			}
+		})
+
+		It("should return an error for binary payloads without the version", func() {
+			for i := 0; i < 10; i++ {
+				message := Message{
+					Message: RandomMessage(RandomMessageType()),
+					Shard:   Shard{},
+				}
+				messageBytes, err := message.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+
+				// Payloads from peers that do not tag the version have the
+				// same layout, without the version
+				var newMessage Message
+				Expect(newMessage.UnmarshalBinary(messageBytes[1:])).ShouldNot(Succeed())
+			}
+		})
+
+		It("should return an error for binary payloads with an unknown version", func() {
+			message := Message{
+				Message: RandomMessage(RandomMessageType()),
+				Shard:   Shard{},
+			}
+			messageBytes, err := message.MarshalBinary()
+			Expect(err).ToNot(HaveOccurred())
+			messageBytes[0] = MessageVersion + 1
+
+			var newMessage Message
+			Expect(newMessage.UnmarshalBinary(messageBytes)).ShouldNot(Succeed())
+		})
+
+		It("should tag the json form with the version", func() {
+			message := Message{
+				Message: RandomMessage(RandomMessageType()),
+				Shard:   Shard{},
+			}
+			messageBytes, err := json.Marshal(message)
+			Expect(err).ToNot(HaveOccurred())
+
+			fields := map[string]json.RawMessage{}
+			Expect(json.Unmarshal(messageBytes, &fields)).To(Succeed())
+			Expect(fields).Should(HaveKeyWithValue("v", json.RawMessage(fmt.Sprintf("%v", MessageVersion))))
+
+			// Expect payloads without the version, or with an unknown
+			// version, to be rejected
+			for _, version := range []string{"", fmt.Sprintf("%v", MessageVersion+1)} {
+				if version == "" {
+					delete(fields, "v")
+				} else {
+					fields["v"] = json.RawMessage(version)
+				}
+				data, err := json.Marshal(fields)
+				Expect(err).ToNot(HaveOccurred())
+
+				var newMessage Message
+				Expect(json.Unmarshal(data, &newMessage)).ShouldNot(Succeed())
+			}
+		})
+	})
+
+	Context("when marshaling and unmarshaling a propose with an embedded polka", func() {
+		It("should preserve the polka", func() {
+			for i := 0; i < 10; i++ {
+				polka := make([]process.Prevote, rand.Intn(10)+1)
+				for j := range polka {
+					polka[j] = *RandomPrevote()
+				}
+				message := Message{
+					Message: ProposeWithPolka(RandomPropose(), polka),
+					Shard:   Shard{},
+				}
+
+				messageBytes, err := message.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				var binaryMessage Message
+				Expect(binaryMessage.UnmarshalBinary(messageBytes)).To(Succeed())
+
+				messageBytes, err = json.Marshal(message)
+				Expect(err).ToNot(HaveOccurred())
+				var jsonMessage Message
+				Expect(json.Unmarshal(messageBytes, &jsonMessage)).To(Succeed())
+
+				for _, newMessage := range []Message{binaryMessage, jsonMessage} {
+					newPolka := newMessage.Message.(*process.Propose).Polka()
+					Expect(newPolka).To(HaveLen(len(polka)))
+					for j := range polka {
+						Expect(newPolka[j].String()).To(Equal(polka[j].String()))
+					}
+				}
+			}
+		})
+	})
+
+	Context("when marshaling and unmarshaling a shard", func() {
+		It("should equal itself after binary marshaling/unmarshaling", func() {
+			test := func(shard Shard) bool {
+				data, err := shard.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+
+				var newShard Shard
+				Expect(newShard.UnmarshalBinary(data)).To(Succeed())
+				Expect(newShard.Equal(shard)).Should(BeTrue())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should equal itself after json marshaling/unmarshaling", func() {
+			test := func(shard Shard) bool {
+				data, err := json.Marshal(shard)
+				Expect(err).ToNot(HaveOccurred())
+
+				var newShard Shard
+				Expect(json.Unmarshal(data, &newShard)).To(Succeed())
+				Expect(newShard.Equal(shard)).Should(BeTrue())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should serialize equal shards identically", func() {
+			test := func(shard Shard) bool {
+				other := shard
+
+				data, err := shard.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				otherData, err := other.MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(data, otherData)).Should(BeTrue())
+
+				data, err = json.Marshal(shard)
+				Expect(err).ToNot(HaveOccurred())
+				otherData, err = json.Marshal(other)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(data, otherData)).Should(BeTrue())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should return an error when the data has the wrong length", func() {
+			test := func(data []byte) bool {
+				var shard Shard
+				if len(data) == len(shard) {
+					return true
+				}
+				Expect(shard.UnmarshalBinary(data)).ShouldNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
 })
@@ -0,0 +1,191 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// Metrics track the consensus progress of a Replica. All metrics are labelled
+// with the Shard of the Replica, so that the Metrics of multiple Replicas can
+// be registered with the same `prometheus.Registerer`. A nil Metrics is valid,
+// and records nothing.
+type Metrics struct {
+	// CommittedBlocks counts the number of blocks that have been committed.
+	CommittedBlocks prometheus.Counter
+	// Height is the height at which the Replica is currently trying to reach
+	// consensus.
+	Height prometheus.Gauge
+	// Round is the round in which the Replica is currently trying to reach
+	// consensus. It is updated as messages are handled, and when blocks are
+	// committed.
+	Round prometheus.Gauge
+	// RejectedMessages counts the number of messages that have been rejected,
+	// labelled by the reason for the rejection.
+	RejectedMessages *prometheus.CounterVec
+	// TimeBetweenCommits observes the number of seconds between consecutive
+	// commits.
+	TimeBetweenCommits prometheus.Histogram
+	// InvariantViolations counts the number of invariant violations that the
+	// Process has recovered from.
+	InvariantViolations prometheus.Counter
+	// RecoveredPanics counts the number of panics, while handling messages,
+	// that the Replica has recovered from.
+	RecoveredPanics prometheus.Counter
+
+	clock      Clock
+	mu         *sync.Mutex
+	lastCommit time.Time
+}
+
+// NewMetrics returns Metrics for the Shard, registered with the
+// `prometheus.Registerer`. If the `prometheus.Registerer` is nil, then nil
+// Metrics are returned, and no metrics will be recorded. If metrics for the
+// Shard have already been registered, they are reused. The time between
+// commits is measured using the Clock (the system clock is used if it is nil).
+func NewMetrics(registerer prometheus.Registerer, clock Clock, shard Shard) *Metrics {
+	if registerer == nil {
+		return nil
+	}
+	if clock == nil {
+		clock = process.NewSystemClock()
+	}
+	labels := prometheus.Labels{"shard": shard.String()}
+
+	return &Metrics{
+		CommittedBlocks: register(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "hyperdrive",
+			Name:        "committed_blocks_total",
+			Help:        "Number of blocks that have been committed.",
+			ConstLabels: labels,
+		})).(prometheus.Counter),
+		Height: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   "hyperdrive",
+			Name:        "height",
+			Help:        "Height at which consensus is currently being reached.",
+			ConstLabels: labels,
+		})).(prometheus.Gauge),
+		Round: register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   "hyperdrive",
+			Name:        "round",
+			Help:        "Round in which consensus is currently being reached.",
+			ConstLabels: labels,
+		})).(prometheus.Gauge),
+		RejectedMessages: register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "hyperdrive",
+			Name:        "rejected_messages_total",
+			Help:        "Number of messages that have been rejected, by reason.",
+			ConstLabels: labels,
+		}, []string{"reason"})).(*prometheus.CounterVec),
+		TimeBetweenCommits: register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   "hyperdrive",
+			Name:        "time_between_commits_seconds",
+			Help:        "Number of seconds between consecutive commits.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.5, 2, 10),
+		})).(prometheus.Histogram),
+		InvariantViolations: register(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "hyperdrive",
+			Name:        "invariant_violations_total",
+			Help:        "Number of invariant violations that have been recovered from.",
+			ConstLabels: labels,
+		})).(prometheus.Counter),
+		RecoveredPanics: register(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "hyperdrive",
+			Name:        "recovered_panics_total",
+			Help:        "Number of panics, while handling messages, that have been recovered from.",
+			ConstLabels: labels,
+		})).(prometheus.Counter),
+
+		clock:      clock,
+		mu:         new(sync.Mutex),
+		lastCommit: time.Time{},
+	}
+}
+
+func (metrics *Metrics) didCommit(height block.Height) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	now := metrics.clock.Now()
+	if !metrics.lastCommit.IsZero() {
+		metrics.TimeBetweenCommits.Observe(now.Sub(metrics.lastCommit).Seconds())
+	}
+	metrics.lastCommit = now
+
+	metrics.CommittedBlocks.Inc()
+	metrics.Height.Set(float64(height + 1))
+	metrics.Round.Set(0)
+}
+
+func (metrics *Metrics) didProgress(p *process.Process) {
+	if metrics == nil {
+		return
+	}
+	metrics.Height.Set(float64(p.CurrentHeight()))
+	metrics.Round.Set(float64(p.CurrentRound()))
+}
+
+func (metrics *Metrics) didViolateInvariant(err error) {
+	if metrics == nil {
+		return
+	}
+	metrics.InvariantViolations.Inc()
+}
+
+func (metrics *Metrics) didRecoverPanic() {
+	if metrics == nil {
+		return
+	}
+	metrics.RecoveredPanics.Inc()
+}
+
+func (metrics *Metrics) didReject(err error) {
+	if metrics == nil {
+		return
+	}
+	metrics.RejectedMessages.WithLabelValues(rejectionReason(err)).Inc()
+}
+
+func rejectionReason(err error) string {
+	switch err {
+	case ErrWrongShard:
+		return "wrong_shard"
+	case ErrInvalidSignatory:
+		return "invalid_signatory"
+	case ErrInvalidSignature:
+		return "invalid_signature"
+	case ErrStaleHeight:
+		return "stale_height"
+	case ErrDuplicate:
+		return "duplicate"
+	case ErrFutureRound:
+		return "future_round"
+	case ErrFutureHeight:
+		return "future_height"
+	case ErrStaleEpoch:
+		return "stale_epoch"
+	case ErrQueueFull:
+		return "queue_full"
+	default:
+		return "unknown"
+	}
+}
+
+func register(registerer prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(collector); err != nil {
+		if alreadyRegistered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return alreadyRegistered.ExistingCollector
+		}
+		panic(fmt.Errorf("invariant violation: cannot register metric: %v", err))
+	}
+	return collector
+}
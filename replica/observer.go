@@ -0,0 +1,39 @@
+package replica
+
+import (
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// NewObserver returns a Replica that verifies Messages from the Shard, and
+// tracks the blocks that they finalise, without taking part in consensus. It
+// does not hold a private key, so it cannot sign Messages; it never proposes,
+// prevotes, or precommits; and it never saves its `process.Process` to
+// storage. All of the query APIs, and the Options that observe the Replica,
+// work as they do for a Replica returned by New.
+func NewObserver(options Options, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, shard Shard) (Replica, error) {
+	return newObserver(options, blockStorage, blockIterator, validator, observer, silentBroadcaster{}, shard)
+}
+
+func newObserver(options Options, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster process.Broadcaster, shard Shard) (Replica, error) {
+	// The empty signatory is never a member of the Shard, so the Process
+	// never believes itself to be the proposer
+	return newReplica(options, noProcessStorage{}, blockStorage, blockIterator, validator, observer, broadcaster, shard, id.Signatory{})
+}
+
+// silentBroadcaster is a `process.Broadcaster` that drops all Messages without
+// signing them.
+type silentBroadcaster struct{}
+
+// Broadcast implements the `process.Broadcaster` interface.
+func (silentBroadcaster) Broadcast(process.Message) {}
+
+// noProcessStorage is a ProcessStorage that neither saves, nor restores, a
+// `process.Process`.
+type noProcessStorage struct{}
+
+// SaveProcess implements the ProcessStorage interface.
+func (noProcessStorage) SaveProcess(*process.Process, Shard) {}
+
+// RestoreProcess implements the ProcessStorage interface.
+func (noProcessStorage) RestoreProcess(*process.Process, Shard) {}
@@ -0,0 +1,140 @@
+package replica
+
+import (
+	"sync"
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+type mockProcessBroadcaster struct {
+	mu       *sync.Mutex
+	messages []process.Message
+}
+
+func newMockProcessBroadcaster() *mockProcessBroadcaster {
+	return &mockProcessBroadcaster{
+		mu:       new(sync.Mutex),
+		messages: []process.Message{},
+	}
+}
+
+func (m *mockProcessBroadcaster) Broadcast(message process.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+}
+
+func (m *mockProcessBroadcaster) Messages() []process.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]process.Message{}, m.messages...)
+}
+
+var _ = Describe("observer", func() {
+
+	Context("when the shard commits a block", func() {
+		It("should track the committed block without signing any messages", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster := newMockProcessBroadcaster()
+				committed := []block.Block{}
+				options := Options{
+					OnCommit: func(committedBlock block.Block) {
+						committed = append(committed, committedBlock)
+					},
+				}
+				observer, err := newObserver(options, store, mockBlockIterator{}, nil, nil, broadcaster, shard)
+				Expect(err).NotTo(HaveOccurred())
+
+				propose := process.NewPropose(1, 0, observer.rebaser.BlockProposal(1, 0), block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(observer.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+				for _, key := range keys[:5] {
+					precommit := process.NewPrecommit(1, 0, propose.BlockHash())
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(observer.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+				}
+
+				Expect(observer.p.CurrentHeight()).Should(Equal(block.Height(2)))
+				Expect(committed).Should(HaveLen(1))
+				Expect(committed[0].Hash()).Should(Equal(propose.BlockHash()))
+				Expect(store.Blockchain(shard).BlockExistsAtHeight(1)).Should(BeTrue())
+
+				// The Process still reacts to the proposal, but nothing that it
+				// broadcasts is ever signed
+				Expect(broadcaster.Messages()).ShouldNot(BeEmpty())
+				for _, message := range broadcaster.Messages() {
+					Expect(message.Signatory()).Should(Equal(id.Signatory{}))
+					Expect(message.Sig()).Should(Equal(id.Signature{}))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the observer is created", func() {
+		It("should never be the proposer", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster := newMockProcessBroadcaster()
+			observer, err := newObserver(Options{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{})
+			Expect(err).NotTo(HaveOccurred())
+			for round := block.Round(0); round < 7; round++ {
+				observer.p.StartRound(round)
+				for _, message := range broadcaster.Messages() {
+					Expect(message.Type()).ShouldNot(Equal(process.MessageType(process.ProposeMessageType)))
+					Expect(message.Type()).ShouldNot(Equal(process.MessageType(process.ResignMessageType)))
+				}
+			}
+		})
+	})
+
+	Context("when a replica is created without a signer", func() {
+		It("should follow the shard, and serve its last commit and status, without broadcasting", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				follower, err := NewWithSigner(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, nil)
+				Expect(err).NotTo(HaveOccurred())
+				follower.Start()
+
+				for height := block.Height(1); height <= 3; height++ {
+					proposer := keys[int(height)%len(keys)]
+					propose := process.NewPropose(height, 0, follower.rebaser.BlockProposal(height, 0), block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(follower.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+					for _, key := range keys[:5] {
+						prevote := process.NewPrevote(height, 0, propose.BlockHash(), nil)
+						Expect(process.Sign(prevote, *key)).Should(Succeed())
+						Expect(follower.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+					}
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, propose.BlockHash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(follower.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+
+					latestCommit, ok := follower.LastCommit()
+					Expect(ok).Should(BeTrue())
+					Expect(latestCommit.Block.Hash()).Should(Equal(propose.BlockHash()))
+					Expect(latestCommit.Precommits).Should(HaveLen(5))
+					Expect(follower.Status().LastCommitHeight).Should(Equal(height))
+					Expect(follower.Status().Height).Should(Equal(height + 1))
+				}
+				Expect(follower.IsProposing()).Should(BeFalse())
+				Consistently(messages, 50*time.Millisecond).ShouldNot(Receive())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
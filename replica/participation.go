@@ -0,0 +1,59 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+)
+
+// participationTracker remembers the most recent height at which each
+// signatory sent a valid message. A signatory is considered to be offline if
+// it has not sent a message during the most recent window of heights. To avoid
+// considering every signatory to be offline when the Replica starts, nothing
+// is considered to be offline until the tracker has observed a full window. A
+// nil participationTracker is valid, and tracks nothing.
+type participationTracker struct {
+	mu       *sync.Mutex
+	window   block.Height
+	since    block.Height
+	lastSeen map[id.Signatory]block.Height
+}
+
+func newParticipationTracker(window, since block.Height) *participationTracker {
+	return &participationTracker{
+		mu:       new(sync.Mutex),
+		window:   window,
+		since:    since,
+		lastSeen: map[id.Signatory]block.Height{},
+	}
+}
+
+func (tracker *participationTracker) didParticipate(signatory id.Signatory, height block.Height) {
+	if tracker == nil {
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if lastSeen, ok := tracker.lastSeen[signatory]; !ok || height > lastSeen {
+		tracker.lastSeen[signatory] = height
+	}
+}
+
+// IsOffline implements the `process.ParticipationTracker` interface.
+func (tracker *participationTracker) IsOffline(signatory id.Signatory, height block.Height) bool {
+	if tracker == nil {
+		return false
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if height-tracker.window < tracker.since {
+		return false
+	}
+	lastSeen, ok := tracker.lastSeen[signatory]
+	return !ok || lastSeen < height-tracker.window
+}
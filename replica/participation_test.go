@@ -0,0 +1,62 @@
+package replica
+
+import (
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+var _ = Describe("participation", func() {
+	Context("when a full window has not been observed", func() {
+		It("should not consider any signatory to be offline", func() {
+			test := func(window, since uint8) bool {
+				tracker := newParticipationTracker(block.Height(window)+1, block.Height(since))
+				for height := block.Height(since); height < block.Height(since)+block.Height(window)+1; height++ {
+					Expect(tracker.IsOffline(RandomSignatory(), height)).Should(BeFalse())
+				}
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when a full window has been observed", func() {
+		It("should consider signatories without recent messages to be offline", func() {
+			test := func(window, since uint8) bool {
+				tracker := newParticipationTracker(block.Height(window)+1, block.Height(since))
+				height := block.Height(since) + block.Height(window) + 1
+
+				online, offline, silent := RandomSignatory(), RandomSignatory(), RandomSignatory()
+				tracker.didParticipate(online, height-block.Height(window)-1)
+				tracker.didParticipate(offline, height-block.Height(window)-2)
+
+				Expect(tracker.IsOffline(online, height)).Should(BeFalse())
+				Expect(tracker.IsOffline(offline, height)).Should(BeTrue())
+				Expect(tracker.IsOffline(silent, height)).Should(BeTrue())
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should remember the most recent height at which a signatory participated", func() {
+			tracker := newParticipationTracker(1, 1)
+			signatory := RandomSignatory()
+			tracker.didParticipate(signatory, 10)
+			tracker.didParticipate(signatory, 5)
+			Expect(tracker.IsOffline(signatory, 11)).Should(BeFalse())
+			Expect(tracker.IsOffline(signatory, 12)).Should(BeTrue())
+		})
+	})
+
+	Context("when the tracker is nil", func() {
+		It("should not panic, or consider any signatory to be offline", func() {
+			var tracker *participationTracker
+			Expect(func() { tracker.didParticipate(RandomSignatory(), 1) }).ShouldNot(Panic())
+			Expect(tracker.IsOffline(RandomSignatory(), 1)).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,140 @@
+package replica
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/renproject/id"
+)
+
+// ErrPeersUnsupported is returned when changing the peers of a Replica whose
+// Broadcaster is not a PeerBroadcaster.
+var ErrPeersUnsupported = errors.New("peers unsupported: broadcaster is not a peer broadcaster")
+
+// A PeerBroadcaster is a Broadcaster whose peers can be changed at runtime, as
+// the topology of the network changes, without reconstructing the Replica.
+// Once RemovePeer returns, Messages (including rebroadcast votes) must no
+// longer be sent to the removed peer.
+type PeerBroadcaster interface {
+	Broadcaster
+
+	AddPeer(peer id.Signatory)
+	RemovePeer(peer id.Signatory)
+	Peers() id.Signatories
+}
+
+// A SendFunc sends a Message to one peer. It must not add or remove peers.
+type SendFunc func(peer id.Signatory, m Message)
+
+type peerBroadcaster struct {
+	mu    *sync.RWMutex
+	send  SendFunc
+	peers id.Signatories
+}
+
+// NewPeerBroadcaster returns a PeerBroadcaster that uses the SendFunc to send
+// every Message to each of its peers, in the order in which they were added.
+// It starts with the given peers. Adding a peer that has already been added,
+// or removing a peer that has not been added, does nothing.
+func NewPeerBroadcaster(send SendFunc, peers ...id.Signatory) PeerBroadcaster {
+	broadcaster := &peerBroadcaster{
+		mu:    new(sync.RWMutex),
+		send:  send,
+		peers: make(id.Signatories, 0, len(peers)),
+	}
+	for _, peer := range peers {
+		broadcaster.AddPeer(peer)
+	}
+	return broadcaster
+}
+
+// Broadcast implements the `Broadcaster` interface. Peers cannot be added or
+// removed while a Message is being sent, so that RemovePeer only returns once
+// no more Messages will be sent to the removed peer.
+func (broadcaster *peerBroadcaster) Broadcast(m Message) {
+	broadcaster.mu.RLock()
+	defer broadcaster.mu.RUnlock()
+
+	for _, peer := range broadcaster.peers {
+		broadcaster.send(peer, m)
+	}
+}
+
+// SendTo implements the `DirectBroadcaster` interface. The Message is sent even
+// if the Replica is not one of the peers.
+func (broadcaster *peerBroadcaster) SendTo(to id.Signatory, m Message) {
+	broadcaster.mu.RLock()
+	defer broadcaster.mu.RUnlock()
+
+	broadcaster.send(to, m)
+}
+
+// AddPeer implements the `PeerBroadcaster` interface.
+func (broadcaster *peerBroadcaster) AddPeer(peer id.Signatory) {
+	broadcaster.mu.Lock()
+	defer broadcaster.mu.Unlock()
+
+	for _, existing := range broadcaster.peers {
+		if existing.Equal(peer) {
+			return
+		}
+	}
+	broadcaster.peers = append(broadcaster.peers, peer)
+}
+
+// RemovePeer implements the `PeerBroadcaster` interface.
+func (broadcaster *peerBroadcaster) RemovePeer(peer id.Signatory) {
+	broadcaster.mu.Lock()
+	defer broadcaster.mu.Unlock()
+
+	for i, existing := range broadcaster.peers {
+		if existing.Equal(peer) {
+			// Copy, instead of removing in place, so that the order of the
+			// remaining peers is kept
+			peers := make(id.Signatories, 0, len(broadcaster.peers)-1)
+			peers = append(peers, broadcaster.peers[:i]...)
+			broadcaster.peers = append(peers, broadcaster.peers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Peers implements the `PeerBroadcaster` interface.
+func (broadcaster *peerBroadcaster) Peers() id.Signatories {
+	broadcaster.mu.RLock()
+	defer broadcaster.mu.RUnlock()
+
+	peers := make(id.Signatories, len(broadcaster.peers))
+	copy(peers, broadcaster.peers)
+	return peers
+}
+
+// AddPeer adds a peer to the Broadcaster of the Replica. It returns
+// ErrPeersUnsupported if the Broadcaster is not a PeerBroadcaster.
+func (replica *Replica) AddPeer(peer id.Signatory) error {
+	if replica.peers == nil {
+		return ErrPeersUnsupported
+	}
+	replica.peers.AddPeer(peer)
+	return nil
+}
+
+// RemovePeer removes a peer from the Broadcaster of the Replica, so that it no
+// longer receives Messages, or rebroadcast votes, from the Replica. It returns
+// ErrPeersUnsupported if the Broadcaster is not a PeerBroadcaster.
+func (replica *Replica) RemovePeer(peer id.Signatory) error {
+	if replica.peers == nil {
+		return ErrPeersUnsupported
+	}
+	replica.peers.RemovePeer(peer)
+	return nil
+}
+
+// Peers returns the peers of the Broadcaster of the Replica, or nil if the
+// Broadcaster is not a PeerBroadcaster.
+func (replica *Replica) Peers() id.Signatories {
+	if replica.peers == nil {
+		return nil
+	}
+	return replica.peers.Peers()
+}
@@ -0,0 +1,122 @@
+package replica
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// sentMessage is a Message that was sent to a peer by a PeerBroadcaster.
+type sentMessage struct {
+	peer    id.Signatory
+	message Message
+}
+
+func newMockPeerBroadcaster(peers ...id.Signatory) (PeerBroadcaster, chan sentMessage) {
+	sent := make(chan sentMessage, 100)
+	return NewPeerBroadcaster(func(peer id.Signatory, m Message) {
+		sent <- sentMessage{peer: peer, message: m}
+	}, peers...), sent
+}
+
+var _ = Describe("peer broadcaster", func() {
+	Context("when adding and removing peers", func() {
+		It("should only send messages to the current peers", func() {
+			peer1, peer2, peer3 := RandomSignatory(), RandomSignatory(), RandomSignatory()
+			broadcaster, sent := newMockPeerBroadcaster(peer1, peer2)
+			broadcaster.AddPeer(peer3)
+			broadcaster.AddPeer(peer1)
+			Expect(broadcaster.Peers()).Should(Equal(id.Signatories{peer1, peer2, peer3}))
+
+			m := Message{Message: process.NewResign(1, 0)}
+			broadcaster.Broadcast(m)
+			for _, peer := range []id.Signatory{peer1, peer2, peer3} {
+				var s sentMessage
+				Eventually(sent).Should(Receive(&s))
+				Expect(s.peer).Should(Equal(peer))
+			}
+
+			broadcaster.RemovePeer(peer2)
+			broadcaster.RemovePeer(RandomSignatory())
+			Expect(broadcaster.Peers()).Should(Equal(id.Signatories{peer1, peer3}))
+			broadcaster.Broadcast(m)
+			for _, peer := range []id.Signatory{peer1, peer3} {
+				var s sentMessage
+				Eventually(sent).Should(Receive(&s))
+				Expect(s.peer).Should(Equal(peer))
+			}
+			Expect(sent).ShouldNot(Receive())
+		})
+
+		It("should send a message to one replica, even if it is not a peer", func() {
+			peer, other := RandomSignatory(), RandomSignatory()
+			broadcaster, sent := newMockPeerBroadcaster(peer)
+			direct, ok := broadcaster.(DirectBroadcaster)
+			Expect(ok).Should(BeTrue())
+
+			m := Message{Message: process.NewResign(1, 0)}
+			for _, to := range []id.Signatory{peer, other} {
+				direct.SendTo(to, m)
+				var s sentMessage
+				Eventually(sent).Should(Receive(&s))
+				Expect(s.peer).Should(Equal(to))
+			}
+			Expect(sent).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a replica has a peer broadcaster", func() {
+		It("should stop rebroadcasting to removed peers", func() {
+			store, keys := initGenesisStorage(Shard{})
+			peer1, peer2 := RandomSignatory(), RandomSignatory()
+			broadcaster, sent := newMockPeerBroadcaster()
+
+			// The replica is the proposer at height 1
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replica.AddPeer(peer1)).Should(Succeed())
+			Expect(replica.AddPeer(peer2)).Should(Succeed())
+			Expect(replica.Peers()).Should(Equal(id.Signatories{peer1, peer2}))
+			replica.Start()
+			defer replica.Close()
+
+			var propose sentMessage
+			Eventually(sent).Should(Receive(&propose))
+			Expect(propose.peer).Should(Equal(peer1))
+			Eventually(sent).Should(Receive(&propose))
+			Expect(propose.peer).Should(Equal(peer2))
+
+			// Prevote after removing the second peer
+			Expect(replica.RemovePeer(peer2)).Should(Succeed())
+			Expect(replica.HandleMessage(propose.message)).Should(Succeed())
+			var prevote sentMessage
+			Eventually(sent).Should(Receive(&prevote))
+			Expect(prevote.peer).Should(Equal(peer1))
+			Expect(prevote.message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+
+			replica.Rebroadcast()
+			Eventually(sent).Should(Receive(&prevote))
+			Expect(prevote.peer).Should(Equal(peer1))
+			Expect(prevote.message.Message.Height()).Should(Equal(block.Height(1)))
+			Consistently(sent, 100*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a replica does not have a peer broadcaster", func() {
+		It("should not support peers", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replica.AddPeer(RandomSignatory())).Should(Equal(ErrPeersUnsupported))
+			Expect(replica.RemovePeer(RandomSignatory())).Should(Equal(ErrPeersUnsupported))
+			Expect(replica.Peers()).Should(BeEmpty())
+		})
+	})
+})
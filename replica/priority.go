@@ -0,0 +1,132 @@
+package replica
+
+import (
+	"container/heap"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+// A messageQueue orders Messages by their distance from the current height and
+// round of a Replica, so that proposals and votes that can make progress are
+// handled before stale and far-future Messages. Messages at the same distance
+// are handled in the order in which they were pushed.
+type messageQueue struct {
+	items  []queuedMessage
+	seq    uint64
+	height block.Height
+	round  block.Round
+}
+
+type queuedMessage struct {
+	m     Message
+	index int
+	seq   uint64
+}
+
+func newMessageQueue() *messageQueue {
+	return &messageQueue{
+		items:  []queuedMessage{},
+		seq:    0,
+		height: block.InvalidHeight,
+		round:  block.InvalidRound,
+	}
+}
+
+// push a Message, remembering its index in the batch that it was received in.
+func (queue *messageQueue) push(m Message, index int) {
+	heap.Push(queue, queuedMessage{m: m, index: index, seq: queue.seq})
+	queue.seq++
+}
+
+// pop the Message that is closest to the height and round. The queue is
+// re-ordered whenever the height or round has changed since the previous pop.
+func (queue *messageQueue) pop(height block.Height, round block.Round) (Message, int, bool) {
+	if len(queue.items) == 0 {
+		return Message{}, -1, false
+	}
+	if height != queue.height || round != queue.round {
+		queue.height, queue.round = height, round
+		heap.Init(queue)
+	}
+	item := heap.Pop(queue).(queuedMessage)
+	return item.m, item.index, true
+}
+
+// distance of a Message from the height and round of the queue. Messages at
+// the current height are always closer than Messages at other heights, and
+// Messages at future heights are ordered by their round.
+func (queue *messageQueue) distance(m Message) (block.Height, block.Round) {
+	height, round := m.Message.Height(), m.Message.Round()
+	heightDistance := height - queue.height
+	if heightDistance < 0 {
+		heightDistance = -heightDistance
+	}
+	if heightDistance != 0 {
+		return heightDistance, round
+	}
+	roundDistance := round - queue.round
+	if roundDistance < 0 {
+		roundDistance = -roundDistance
+	}
+	return 0, roundDistance
+}
+
+// Len implements the `heap.Interface` interface.
+func (queue *messageQueue) Len() int {
+	return len(queue.items)
+}
+
+// Less implements the `heap.Interface` interface.
+func (queue *messageQueue) Less(i, j int) bool {
+	iHeight, iRound := queue.distance(queue.items[i].m)
+	jHeight, jRound := queue.distance(queue.items[j].m)
+	if iHeight != jHeight {
+		return iHeight < jHeight
+	}
+	if iRound != jRound {
+		return iRound < jRound
+	}
+	return queue.items[i].seq < queue.items[j].seq
+}
+
+// Swap implements the `heap.Interface` interface.
+func (queue *messageQueue) Swap(i, j int) {
+	queue.items[i], queue.items[j] = queue.items[j], queue.items[i]
+}
+
+// Push implements the `heap.Interface` interface.
+func (queue *messageQueue) Push(x interface{}) {
+	queue.items = append(queue.items, x.(queuedMessage))
+}
+
+// Pop implements the `heap.Interface` interface.
+func (queue *messageQueue) Pop() interface{} {
+	n := len(queue.items)
+	item := queue.items[n-1]
+	queue.items = queue.items[:n-1]
+	return item
+}
+
+// HandleMessages passes a batch of Messages to the underlying
+// `process.Process`, in order of their distance from the current height and
+// round, instead of the order in which they were received. This keeps the
+// Replica live when a congested network delivers a backlog of stale, or far
+// future, Messages ahead of the proposal and votes for the current round. The
+// distance is re-evaluated whenever the Replica advances to a new height or
+// round. It returns the error returned by HandleMessage for every Message, in
+// the order in which the Messages were received.
+func (replica *Replica) HandleMessages(messages Messages) []error {
+	queue := newMessageQueue()
+	for i, m := range messages {
+		queue.push(m, i)
+	}
+
+	errs := make([]error, len(messages))
+	for {
+		m, i, ok := queue.pop(replica.p.CurrentHeight(), replica.p.CurrentRound())
+		if !ok {
+			return errs
+		}
+		errs[i] = replica.HandleMessage(m)
+	}
+}
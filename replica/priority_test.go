@@ -0,0 +1,86 @@
+package replica
+
+import (
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("message priority", func() {
+
+	Context("when a backlog of old messages is queued ahead of a proposal", func() {
+		It("should pop the proposal first, and the backlog in order", func() {
+			test := func(shard Shard) bool {
+				height := block.Height(RandomHeight()%1000) + 10
+				round := RandomRound() % 100
+
+				queue := newMessageQueue()
+				backlog := make([]process.Message, 10)
+				for i := range backlog {
+					backlog[i] = RandomMessageWithHeightAndRound(height-block.Height(i%5)-1, 0, RandomMessageType())
+					queue.push(Message{Shard: shard, Message: backlog[i]}, i)
+				}
+				propose := RandomMessageWithHeightAndRound(height, round, process.ProposeMessageType)
+				queue.push(Message{Shard: shard, Message: propose}, len(backlog))
+
+				m, i, ok := queue.pop(height, round)
+				Expect(ok).Should(BeTrue())
+				Expect(i).Should(Equal(len(backlog)))
+				Expect(m.Message).Should(Equal(propose))
+
+				// Expect the backlog to be ordered by height distance, and then
+				// by the order in which it was queued
+				expected := []int{0, 5, 1, 6, 2, 7, 3, 8, 4, 9}
+				for _, j := range expected {
+					m, i, ok := queue.pop(height, round)
+					Expect(ok).Should(BeTrue())
+					Expect(i).Should(Equal(j))
+					Expect(m.Message).Should(Equal(backlog[j]))
+				}
+				_, _, ok = queue.pop(height, round)
+				Expect(ok).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when handling a batch of messages", func() {
+		It("should handle the proposal, and return the errors in the order of the batch", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			batch := Messages{}
+			for _, key := range keys {
+				prevote := process.NewPrevote(0, 0, block.InvalidHash, nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				batch = append(batch, Message{Shard: Shard{}, Message: prevote})
+			}
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			batch = append(batch, Message{Shard: Shard{}, Message: propose})
+
+			errs := replica.HandleMessages(batch)
+			Expect(errs).Should(HaveLen(len(batch)))
+			for _, err := range errs[:len(keys)] {
+				Expect(err).Should(Equal(ErrStaleHeight))
+			}
+			Expect(errs[len(keys)]).ShouldNot(HaveOccurred())
+
+			// Expect the proposal to have been prevoted
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			prevote, ok := message.Message.(*process.Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.BlockHash()).Should(Equal(propose.BlockHash()))
+		})
+	})
+})
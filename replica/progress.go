@@ -0,0 +1,86 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+// Progress is the height and round in which a Replica is trying to reach
+// consensus. A Progress event is emitted whenever either of them changes.
+type Progress struct {
+	Height block.Height
+	Round  block.Round
+}
+
+// A progressNotifier emits Progress events to every subscriber. Events are
+// never allowed to block consensus, so events are dropped for subscribers
+// whose buffers are full. It is shared by all copies of a Replica.
+type progressNotifier struct {
+	mu          *sync.Mutex
+	bufferSize  int
+	subscribers []chan Progress
+	latest      Progress
+	closed      bool
+}
+
+func newProgressNotifier(bufferSize int) *progressNotifier {
+	return &progressNotifier{
+		mu:          new(sync.Mutex),
+		bufferSize:  bufferSize,
+		subscribers: []chan Progress{},
+		latest:      Progress{Height: block.InvalidHeight, Round: block.InvalidRound},
+		closed:      false,
+	}
+}
+
+// subscribe returns a new channel that receives every subsequent Progress
+// event. The channel is closed when the progressNotifier is closed.
+func (notifier *progressNotifier) subscribe() <-chan Progress {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	subscriber := make(chan Progress, notifier.bufferSize)
+	if notifier.closed {
+		close(subscriber)
+		return subscriber
+	}
+	notifier.subscribers = append(notifier.subscribers, subscriber)
+	return subscriber
+}
+
+// didStartRound emits a Progress event to every subscriber, unless the height
+// and round have not changed since the previous event.
+func (notifier *progressNotifier) didStartRound(height block.Height, round block.Round) {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	progress := Progress{Height: height, Round: round}
+	if notifier.closed || progress == notifier.latest {
+		return
+	}
+	notifier.latest = progress
+	for _, subscriber := range notifier.subscribers {
+		select {
+		case subscriber <- progress:
+		default:
+		}
+	}
+}
+
+// close all subscriber channels. No more Progress events are emitted after the
+// progressNotifier is closed. Closing a progressNotifier that has already been
+// closed does nothing.
+func (notifier *progressNotifier) close() {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if notifier.closed {
+		return
+	}
+	notifier.closed = true
+	for _, subscriber := range notifier.subscribers {
+		close(subscriber)
+	}
+	notifier.subscribers = nil
+}
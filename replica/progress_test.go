@@ -0,0 +1,85 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("progress", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when subscribing to the progress of a replica", func() {
+		It("should emit an event when a block is committed and when a round is skipped", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			progress := replica.Subscribe()
+
+			// Commit a block at the first height
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			for _, key := range keys[:5] {
+				precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Expect(progress).Should(Receive(Equal(Progress{Height: 2, Round: 0})))
+
+			// Skip to the next round after receiving F+1 prevotes for it
+			for _, key := range keys[:3] {
+				prevote := process.NewPrevote(2, 1, block.InvalidHash, nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Expect(progress).Should(Receive(Equal(Progress{Height: 2, Round: 1})))
+			Expect(progress).ShouldNot(Receive())
+
+			// Expect the subscription to be closed with the replica
+			replica.Close()
+			Expect(progress).Should(BeClosed())
+		})
+
+		It("should drop events instead of blocking when the subscriber is slow", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{ProgressBufferSize: 1}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			progress := replica.Subscribe()
+
+			// Skip two rounds without reading any events
+			for round := block.Round(1); round <= 2; round++ {
+				for _, key := range keys[:3] {
+					prevote := process.NewPrevote(1, round, block.InvalidHash, nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+				}
+			}
+			Expect(replica.CurrentRound()).Should(Equal(block.Round(2)))
+			Expect(progress).Should(Receive(Equal(Progress{Height: 1, Round: 1})))
+			Expect(progress).ShouldNot(Receive())
+		})
+	})
+})
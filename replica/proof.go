@@ -0,0 +1,65 @@
+package replica
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A LightCommitProof is a self-contained proof that a block was committed by a
+// Shard. It contains the committed block, the precommits that committed it,
+// and the signatories that were allowed to precommit at its height, so that it
+// can be verified by light clients and bridges that do not run consensus.
+type LightCommitProof struct {
+	Block      block.Block         `json:"block"`
+	Precommits []process.Precommit `json:"precommits"`
+	Validators id.Signatories      `json:"validators"`
+}
+
+// Verify returns an error unless the validators of the proof have the given
+// hash (see `ValidatorSet.Hash`), the block matches its hash, and the block is
+// backed by precommits from 2F+1 distinct validators at the height of the
+// block. The hash of the block is recomputed from its contents, because it is
+// not recomputed when the block is unmarshaled. Precommits are expected to be
+// signed over SHA256 sighashes, which is the default Hasher.
+func (proof LightCommitProof) Verify(validatorSetHash id.Hash) error {
+	if hash := NewValidatorSet(proof.Validators).Hash(); !hash.Equal(validatorSetHash) {
+		return fmt.Errorf("expected validator set=%v, got validator set=%v", validatorSetHash, hash)
+	}
+	committed := proof.Block
+	if hash := block.ComputeHash(committed.Header(), committed.Txs(), committed.Plan(), committed.PreviousState()); !hash.Equal(committed.Hash()) {
+		return fmt.Errorf("expected block=%v, got block=%v", committed.Hash(), hash)
+	}
+	latestCommit := process.LatestCommit{Block: committed, Precommits: proof.Precommits}
+	if err := latestCommit.Verify(block.ConsensusThreshold(len(proof.Validators)), proof.Validators); err != nil {
+		return fmt.Errorf("unverified commit at height=%v: %v", committed.Header().Height(), err)
+	}
+	return nil
+}
+
+// CommitProof returns a LightCommitProof that the block at the height was
+// committed. The precommits are taken from the latest commit of the Replica,
+// or from the CommitIterator for earlier heights. It returns an error if no
+// block has been committed at the height, or if its precommits are no longer
+// known.
+func (replica *Replica) CommitProof(height block.Height) (LightCommitProof, error) {
+	committed, ok := replica.blockStorage.Blockchain(replica.shard).BlockAtHeight(height)
+	if !ok || height < 1 {
+		return LightCommitProof{}, fmt.Errorf("no block committed at height=%v", height)
+	}
+	latestCommit := replica.commitAt(height, committed)
+	if len(latestCommit.Precommits) == 0 {
+		return LightCommitProof{}, fmt.Errorf("no precommits for block=%v at height=%v", committed.Hash(), height)
+	}
+	validators, ok := replica.p.SignatoriesAt(height)
+	if !ok {
+		return LightCommitProof{}, fmt.Errorf("no validators at height=%v", height)
+	}
+	return LightCommitProof{
+		Block:      latestCommit.Block,
+		Precommits: latestCommit.Precommits,
+		Validators: validators,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package replica
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("light commit proofs", func() {
+	newCommittedReplica := func() (Replica, id.Hash) {
+		store, keys := initGenesisStorage(Shard{})
+		broadcaster, messages := newMockBroadcaster()
+		go func() {
+			for range messages {
+			}
+		}()
+		replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+		Expect(err).NotTo(HaveOccurred())
+		commitAt(&replica, 1, keys[1], keys[1:6])
+		return replica, replica.Validators().Hash()
+	}
+
+	Context("when a block has been committed", func() {
+		It("should return a proof that verifies, before and after marshaling", func() {
+			replica, validatorSetHash := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(proof.Block.Header().Height()).Should(Equal(block.Height(1)))
+			Expect(proof.Verify(validatorSetHash)).Should(Succeed())
+
+			data, err := json.Marshal(proof)
+			Expect(err).ShouldNot(HaveOccurred())
+			var unmarshaled LightCommitProof
+			Expect(json.Unmarshal(data, &unmarshaled)).Should(Succeed())
+			Expect(unmarshaled.Verify(validatorSetHash)).Should(Succeed())
+		})
+
+		It("should fail to verify against a different validator set", func() {
+			replica, _ := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(proof.Verify(RandomHash())).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when the proof has been tampered with", func() {
+		It("should fail to verify if a validator is replaced", func() {
+			replica, validatorSetHash := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			proof.Validators[0] = RandomSignatory()
+			Expect(proof.Verify(validatorSetHash)).ShouldNot(Succeed())
+		})
+
+		It("should fail to verify if the block is replaced", func() {
+			replica, validatorSetHash := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			proof.Block = block.New(proof.Block.Header(), block.Txs(RandomBytesSlice()), proof.Block.Plan(), proof.Block.PreviousState())
+			Expect(proof.Verify(validatorSetHash)).ShouldNot(Succeed())
+		})
+
+		It("should fail to verify if the contents of the block do not match its hash", func() {
+			replica, validatorSetHash := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// Change the txs, but keep the hash of the block
+			data, err := json.Marshal(proof)
+			Expect(err).ShouldNot(HaveOccurred())
+			fields := map[string]json.RawMessage{}
+			Expect(json.Unmarshal(data, &fields)).Should(Succeed())
+			blockFields := map[string]interface{}{}
+			Expect(json.Unmarshal(fields["block"], &blockFields)).Should(Succeed())
+			blockFields["txs"] = RandomBytesSlice()
+			fields["block"], err = json.Marshal(blockFields)
+			Expect(err).ShouldNot(HaveOccurred())
+			data, err = json.Marshal(fields)
+			Expect(err).ShouldNot(HaveOccurred())
+			var tampered LightCommitProof
+			Expect(json.Unmarshal(data, &tampered)).Should(Succeed())
+			Expect(tampered.Block.Hash()).Should(Equal(proof.Block.Hash()))
+			Expect(tampered.Verify(validatorSetHash)).ShouldNot(Succeed())
+		})
+
+		It("should fail to verify if a precommit is removed or replaced", func() {
+			replica, validatorSetHash := newCommittedReplica()
+			proof, err := replica.CommitProof(1)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			removed := proof
+			removed.Precommits = proof.Precommits[1:]
+			Expect(removed.Verify(validatorSetHash)).ShouldNot(Succeed())
+
+			replaced := proof
+			replaced.Precommits = append([]process.Precommit{}, proof.Precommits...)
+			precommit := process.NewPrecommit(1, proof.Precommits[0].Round(), RandomHash())
+			Expect(process.Sign(precommit, *newVerificationKey())).Should(Succeed())
+			replaced.Precommits[0] = *precommit
+			Expect(replaced.Verify(validatorSetHash)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when no block has been committed at the height", func() {
+		It("should return an error", func() {
+			replica, _ := newCommittedReplica()
+			_, err := replica.CommitProof(0)
+			Expect(err).Should(HaveOccurred())
+			_, err = replica.CommitProof(2)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
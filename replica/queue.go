@@ -0,0 +1,71 @@
+package replica
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned when a Message is received while the inbound queue
+// of the Replica is full.
+var ErrQueueFull = errors.New("queue full")
+
+// An inboundQueue is a bounded queue of Messages that are waiting to be handled
+// by a Replica. Messages are handled in the order in which they were queued,
+// by a worker that is started with the Replica. It is shared by all copies of
+// a Replica.
+type inboundQueue struct {
+	messages chan Message
+	start    *sync.Once
+	done     chan struct{}
+}
+
+// newInboundQueue returns an inboundQueue that can hold the given number of
+// Messages, or nil if the size is zero.
+func newInboundQueue(size int) *inboundQueue {
+	if size == 0 {
+		return nil
+	}
+	return &inboundQueue{
+		messages: make(chan Message, size),
+		start:    new(sync.Once),
+		done:     make(chan struct{}),
+	}
+}
+
+// push queues the Message without blocking. It returns ErrClosed if the queue
+// has been closed, and ErrQueueFull if the queue is full.
+func (queue *inboundQueue) push(m Message) error {
+	select {
+	case <-queue.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case queue.messages <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// run starts the worker, unless it has already been started. The worker hands
+// each Message to the handler until the queue is closed.
+func (queue *inboundQueue) run(handle func(Message)) {
+	queue.start.Do(func() {
+		go func() {
+			for {
+				select {
+				case <-queue.done:
+					return
+				case m := <-queue.messages:
+					handle(m)
+				}
+			}
+		}()
+	})
+}
+
+// close stops the worker. Messages that are still queued are dropped.
+func (queue *inboundQueue) close() {
+	close(queue.done)
+}
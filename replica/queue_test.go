@@ -0,0 +1,107 @@
+package replica
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("message queue", func() {
+	Context("when the queue is full", func() {
+		It("should reject messages until the queue has been drained", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{MessageQueueSize: 3}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			defer replica.Close()
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			prevotes := make([]*process.Prevote, 0, 5)
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				prevotes = append(prevotes, prevote)
+			}
+
+			// Fill the queue before the worker has been started
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevotes[0]})).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevotes[1]})).Should(Succeed())
+			for i := 0; i < 3; i++ {
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevotes[2]})).Should(Equal(ErrQueueFull))
+			}
+			Expect(replica.Status().MessagesAccepted).Should(BeZero())
+			Expect(replica.Status().MessagesRejected).Should(Equal(map[string]uint64{"queue_full": 3}))
+
+			// Drain the queue, in order
+			replica.Start()
+			Eventually(func() uint64 { return replica.Status().MessagesAccepted }).Should(Equal(uint64(3)))
+			for _, prevote := range prevotes[2:] {
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Eventually(func() uint64 { return replica.Status().MessagesAccepted }).Should(Equal(uint64(6)))
+			Eventually(func() process.Step { return replica.Status().State }).Should(Equal(process.StepPrecommit))
+			Expect(replica.Status().Locked).Should(BeTrue())
+		})
+	})
+
+	Context("when the replica is closed", func() {
+		It("should reject messages", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{MessageQueueSize: 3}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			replica.Close()
+
+			prevote := process.NewPrevote(1, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrClosed))
+		})
+
+		It("should not deadlock when messages are delivered back to the replica while it is closing", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster := &loopbackBroadcaster{handled: make(chan error, 1), closed: make(chan struct{})}
+			replica, err := New(Options{MessageQueueSize: 10}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			broadcaster.replica = &replica
+
+			// The replica is the proposer, so it broadcasts its proposal while
+			// it is being started
+			replica.Start()
+			Eventually(broadcaster.handled, 5*time.Second).Should(Receive())
+			Eventually(broadcaster.closed, 5*time.Second).Should(BeClosed())
+		})
+	})
+})
+
+// loopbackBroadcaster delivers the first Message that is broadcast back to the
+// Replica, after the Replica has started closing.
+type loopbackBroadcaster struct {
+	once    sync.Once
+	replica *Replica
+	handled chan error
+	closed  chan struct{}
+}
+
+func (broadcaster *loopbackBroadcaster) Broadcast(m Message) {
+	broadcaster.once.Do(func() {
+		go func() {
+			broadcaster.replica.Close()
+			close(broadcaster.closed)
+		}()
+		// Give Close time to wait for the lifecycle lock
+		time.Sleep(50 * time.Millisecond)
+		broadcaster.handled <- broadcaster.replica.HandleMessage(m)
+	})
+}
@@ -12,7 +12,9 @@ import (
 
 // BlockStorage extends the `process.Blockchain` interface with the
 // functionality to load the last committed `block.Standard`, and the last
-// committed `block.Base`.
+// committed `block.Base`. Like the ProcessStorage, implementations that are
+// shared by the Replicas of many Shards must keep a separate
+// `process.Blockchain` for each Shard.
 type BlockStorage interface {
 	Blockchain(shard Shard) process.Blockchain
 	LatestBlock(shard Shard) block.Block
@@ -21,10 +23,76 @@ type BlockStorage interface {
 
 type BlockIterator interface {
 	// NextBlock returns the `block.Txs`, `block.Plan` and the parent
-	// `block.State` for the given `block.Height`.
+	// `block.State` for the given `block.Height`. When there are no pending
+	// transactions, it can return empty `block.Txs`, and an empty
+	// `block.Block` is proposed, so that the Shard stays live.
 	NextBlock(block.Kind, block.Height, Shard) (block.Txs, block.Plan, block.State)
 }
 
+// A CommitIterator is a BlockIterator that can also return committed blocks,
+// and the precommits that prove they were committed. Replicas that have fallen
+// behind use it to sync the blocks that they have missed.
+type CommitIterator interface {
+	BlockIterator
+
+	// CommitAtHeight returns the `block.Block` committed at the given
+	// `block.Height`, and its precommits. It returns false if no block is
+	// known to have been committed at the `block.Height`.
+	CommitAtHeight(block.Height, Shard) (process.LatestCommit, bool)
+}
+
+// blockLimits bound the cost of validating a proposed `block.Block`, and the
+// timestamps that it can have. A zero limit on the number of txs is not
+// enforced, but a zero drift means that timestamps must not be ahead of the
+// local time.
+type blockLimits struct {
+	txCounter      TxCounter
+	maxTxsPerBlock int
+	maxBlockSize   int
+
+	maxTimestampDrift time.Duration
+	strictTimestamps  bool
+}
+
+func (limits blockLimits) check(proposedBlock block.Block) error {
+	if limits.maxTxsPerBlock > 0 {
+		if numTxs := limits.txCounter.CountTxs(proposedBlock.Txs()); numTxs > limits.maxTxsPerBlock {
+			return fmt.Errorf("too many txs: expected at most %v, got %v", limits.maxTxsPerBlock, numTxs)
+		}
+	}
+	if limits.maxBlockSize > 0 {
+		data, err := proposedBlock.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("cannot marshal block: %v", err)
+		}
+		if len(data) > limits.maxBlockSize {
+			return fmt.Errorf("block too large: expected at most %v bytes, got %v bytes", limits.maxBlockSize, len(data))
+		}
+	}
+	return nil
+}
+
+func (limits blockLimits) checkTimestamp(proposedBlock, parentBlock block.Block, now time.Time) error {
+	timestamp := proposedBlock.Header().Timestamp()
+	parentTimestamp := parentBlock.Header().Timestamp()
+	if limits.strictTimestamps && timestamp <= parentTimestamp {
+		return fmt.Errorf("expected timestamp for proposed block to be greater than parent block: expected >%v, got %v", parentTimestamp, timestamp)
+	}
+	if timestamp < parentTimestamp {
+		return fmt.Errorf("expected timestamp for proposed block to be no less than parent block: expected >=%v, got %v", parentTimestamp, timestamp)
+	}
+	if maxTimestamp := block.Timestamp(now.Add(limits.maxTimestampDrift).Unix()); timestamp > maxTimestamp {
+		return fmt.Errorf("expected timestamp for proposed block to be less than current time: expected <=%v, got %v", maxTimestamp, timestamp)
+	}
+	return nil
+}
+
+// canPropose returns false if a proposed `block.Block` cannot have a valid
+// timestamp yet, because the second of its parent has not passed.
+func (limits blockLimits) canPropose(parentBlock block.Block, now time.Time) bool {
+	return !limits.strictTimestamps || block.Timestamp(now.Unix()) > parentBlock.Header().Timestamp()
+}
+
 type Validator interface {
 	IsBlockValid(block block.Block, checkHistory bool, shard Shard) (process.NilReasons, error)
 }
@@ -32,6 +100,8 @@ type Validator interface {
 type Observer interface {
 	DidCommitBlock(block.Height, Shard)
 	DidReceiveSufficientNilPrevotes(messages process.Messages, f int)
+	DidBecomeProposer(block.Height, block.Round, Shard)
+	DidFailToCommitBlock(block.Height, Shard, error)
 	IsSignatory(Shard) bool
 }
 
@@ -45,10 +115,20 @@ type shardRebaser struct {
 	blockIterator BlockIterator
 	validator     Validator
 	observer      Observer
+	metrics       *Metrics
+	limits        blockLimits
+	clock         Clock
 	shard         Shard
+
+	onCommit        func(block.Block)
+	committedHeight block.Height
+
+	// actions receives a commit Action for every committed block (it can be
+	// nil)
+	actions *actionNotifier
 }
 
-func newShardRebaser(blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, shard Shard) *shardRebaser {
+func newShardRebaser(blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, metrics *Metrics, limits blockLimits, clock Clock, onCommit func(block.Block), shard Shard) *shardRebaser {
 	return &shardRebaser{
 		mu: new(sync.Mutex),
 
@@ -59,7 +139,13 @@ func newShardRebaser(blockStorage BlockStorage, blockIterator BlockIterator, val
 		blockIterator: blockIterator,
 		validator:     validator,
 		observer:      observer,
+		metrics:       metrics,
+		limits:        limits,
+		clock:         clock,
 		shard:         shard,
+
+		onCommit:        onCommit,
+		committedHeight: 0,
 	}
 }
 
@@ -70,6 +156,12 @@ func (rebaser *shardRebaser) BlockProposal(height block.Height, round block.Roun
 	parent := rebaser.blockStorage.LatestBlock(rebaser.shard)
 	base := rebaser.blockStorage.LatestBaseBlock(rebaser.shard)
 
+	// Resign if the timestamp of the `block.Block` would not be valid
+	now := rebaser.clock.Now()
+	if !rebaser.limits.canPropose(parent, now) {
+		return block.InvalidBlock
+	}
+
 	// Check that the base `block.Block` is a valid
 	if base.Header().Kind() != block.Base {
 		panic(fmt.Errorf("invariant violation: latest base block=%v has unexpected kind=%v", base.Hash(), base.Header().Kind()))
@@ -109,7 +201,7 @@ func (rebaser *shardRebaser) BlockProposal(height block.Height, round block.Roun
 		prevState.Hash(),
 		height,
 		round,
-		block.Timestamp(time.Now().Unix()),
+		block.Timestamp(now.Unix()),
 		expectedSigs,
 	)
 
@@ -160,17 +252,34 @@ func (rebaser *shardRebaser) IsBlockValid(proposedBlock block.Block, checkHistor
 		return nilReasons, fmt.Errorf("unexpected block hash for proposed block")
 	}
 
-	// Check against the parent `block.Block`
+	// Check that the contents of the `block.Block` match the commitments in
+	// its `block.Header`, so that the `block.Header` alone can be trusted to
+	// commit to the contents of the `block.Block`.
+	if txsRef := proposedBlock.Txs().Hash(); !proposedBlock.Header().TxsRef().Equal(txsRef) {
+		return nilReasons, fmt.Errorf("unexpected txs ref for proposed block: expected %v, got %v", txsRef, proposedBlock.Header().TxsRef())
+	}
+	if planRef := proposedBlock.Plan().Hash(); !proposedBlock.Header().PlanRef().Equal(planRef) {
+		return nilReasons, fmt.Errorf("unexpected plan ref for proposed block: expected %v, got %v", planRef, proposedBlock.Header().PlanRef())
+	}
+	if prevStateRef := proposedBlock.PreviousState().Hash(); !proposedBlock.Header().PrevStateRef().Equal(prevStateRef) {
+		return nilReasons, fmt.Errorf("unexpected previous state ref for proposed block: expected %v, got %v", prevStateRef, proposedBlock.Header().PrevStateRef())
+	}
+
+	// Check against the parent `block.Block`, and the local limits on the
+	// size of the `block.Block`. The limits are only used to decide whether to
+	// prevote for a proposal, and never to reject a `block.Block` that has
+	// already been committed by the Shard, otherwise the Replica would be
+	// unable to commit it, and would halt
 	if checkHistory {
+		if err := rebaser.limits.check(proposedBlock); err != nil {
+			return nilReasons, err
+		}
 		parentBlock, ok := rebaser.blockStorage.Blockchain(rebaser.shard).BlockAtHeight(proposedBlock.Header().Height() - 1)
 		if !ok {
 			return nilReasons, fmt.Errorf("block at height=%d not found", proposedBlock.Header().Height()-1)
 		}
-		if proposedBlock.Header().Timestamp() < parentBlock.Header().Timestamp() {
-			return nilReasons, fmt.Errorf("expected timestamp for proposed block to be greater than parent block")
-		}
-		if proposedBlock.Header().Timestamp() > block.Timestamp(time.Now().Unix()) {
-			return nilReasons, fmt.Errorf("expected timestamp for proposed block to be less than current time")
+		if err := rebaser.limits.checkTimestamp(proposedBlock, parentBlock, rebaser.clock.Now()); err != nil {
+			return nilReasons, err
 		}
 		if !proposedBlock.Header().ParentHash().Equal(parentBlock.Hash()) {
 			return nilReasons, fmt.Errorf("expected parent hash for proposed block to equal parent block hash")
@@ -217,9 +326,20 @@ func (rebaser *shardRebaser) DidCommitBlock(height block.Height) {
 		rebaser.expectedKind = block.Standard
 		rebaser.expectedRebaseSigs = nil
 	}
+	rebaser.metrics.didCommit(height)
+	if rebaser.actions != nil {
+		rebaser.actions.didCommit(committedBlock)
+	}
 	if rebaser.observer != nil {
 		rebaser.observer.DidCommitBlock(height, rebaser.shard)
 	}
+
+	// Only notify the callback once per height, and in order of height, even
+	// if the commit is observed more than once
+	if rebaser.onCommit != nil && height > rebaser.committedHeight {
+		rebaser.committedHeight = height
+		rebaser.onCommit(committedBlock)
+	}
 }
 
 func (rebaser *shardRebaser) DidReceiveSufficientNilPrevotes(messages process.Messages, f int) {
@@ -228,6 +348,18 @@ func (rebaser *shardRebaser) DidReceiveSufficientNilPrevotes(messages process.Me
 	}
 }
 
+func (rebaser *shardRebaser) DidBecomeProposer(height block.Height, round block.Round) {
+	if rebaser.observer != nil {
+		rebaser.observer.DidBecomeProposer(height, round, rebaser.shard)
+	}
+}
+
+func (rebaser *shardRebaser) DidFailToCommitBlock(height block.Height, err error) {
+	if rebaser.observer != nil {
+		rebaser.observer.DidFailToCommitBlock(height, rebaser.shard, err)
+	}
+}
+
 func (rebaser *shardRebaser) rebase(sigs id.Signatories) {
 	rebaser.mu.Lock()
 	defer rebaser.mu.Unlock()
@@ -3,6 +3,7 @@ package replica
 import (
 	"crypto/ecdsa"
 	cRand "crypto/rand"
+	"encoding/json"
 	"math/rand"
 	"testing/quick"
 	"time"
@@ -29,7 +30,7 @@ var _ = Describe("shardRebaser", func() {
 			test := func(shard Shard) bool {
 				store, initHeight, _ := initStorage(shard)
 				iter := mockBlockIterator{}
-				rebaser := newShardRebaser(store, iter, nil, nil, shard)
+				rebaser := newShardRebaser(store, iter, nil, nil, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 
 				parent := store.LatestBlock(shard)
 				base := store.LatestBaseBlock(shard)
@@ -53,7 +54,7 @@ var _ = Describe("shardRebaser", func() {
 				store, initHeight, _ := initStorage(shard)
 				iter := mockBlockIterator{}
 				validator := newMockValidator(nil)
-				rebaser := newShardRebaser(store, iter, validator, nil, shard)
+				rebaser := newShardRebaser(store, iter, validator, nil, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 
 				// Generate a valid propose block.
 				parent := store.LatestBlock(shard)
@@ -63,6 +64,7 @@ var _ = Describe("shardRebaser", func() {
 				header.BaseHash = base.Hash()
 				header.ParentHash = parent.Hash()
 				header.Timestamp = block.Timestamp(time.Now().Unix())
+				setRefs(&header, nil, nil, nil)
 				proposedBlock := block.New(header.ToBlockHeader(), nil, nil, nil)
 
 				_, err := rebaser.IsBlockValid(proposedBlock, true)
@@ -77,7 +79,7 @@ var _ = Describe("shardRebaser", func() {
 				store, initHeight, _ := initStorage(shard)
 				iter := mockBlockIterator{}
 				observer := newMockObserver()
-				rebaser := newShardRebaser(store, iter, nil, observer, shard)
+				rebaser := newShardRebaser(store, iter, nil, observer, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 
 				rebaser.DidCommitBlock(0)
 				rebaser.DidCommitBlock(initHeight)
@@ -99,7 +101,7 @@ var _ = Describe("shardRebaser", func() {
 			test := func(shard Shard, sigs id.Signatories) bool {
 				store, _, _ := initStorage(shard)
 				iter := mockBlockIterator{}
-				rebaser := newShardRebaser(store, iter, nil, nil, shard)
+				rebaser := newShardRebaser(store, iter, nil, nil, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 
 				rebaser.rebase(sigs)
 				Expect(rebaser.expectedKind).Should(Equal(block.Rebase))
@@ -122,7 +124,7 @@ var _ = Describe("shardRebaser", func() {
 				}
 				store, initHeight, _ := initStorage(shard)
 				iter := mockBlockIterator{}
-				rebaser := newShardRebaser(store, iter, nil, nil, shard)
+				rebaser := newShardRebaser(store, iter, nil, nil, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 
 				rebaser.rebase(sigs)
 				parent := store.LatestBlock(shard)
@@ -160,7 +162,7 @@ var _ = Describe("shardRebaser", func() {
 				}
 				store, initHeight, _ := initStorage(shard)
 				iter := mockBlockIterator{}
-				rebaser := newShardRebaser(store, iter, nil, nil, shard)
+				rebaser := newShardRebaser(store, iter, nil, nil, nil, blockLimits{}, process.NewSystemClock(), nil, shard)
 				rebaser.rebase(sigs)
 
 				// Generate a valid rebase block.
@@ -172,6 +174,7 @@ var _ = Describe("shardRebaser", func() {
 				header.ParentHash = parent.Hash()
 				header.Timestamp = block.Timestamp(time.Now().Unix() - 1)
 				header.Signatories = sigs
+				setRefs(&header, nil, nil, nil)
 				rebaseBlock := block.New(header.ToBlockHeader(), nil, nil, nil)
 				_, err := rebaser.IsBlockValid(rebaseBlock, true)
 				Expect(err).Should(BeNil())
@@ -188,6 +191,7 @@ var _ = Describe("shardRebaser", func() {
 				baseHeader.ParentHash = parent.Hash()
 				baseHeader.Timestamp = block.Timestamp(time.Now().Unix())
 				baseHeader.Signatories = sigs
+				setRefs(&baseHeader, nil, nil, nil)
 				baseBlock := block.New(baseHeader.ToBlockHeader(), nil, nil, nil)
 
 				_, err = rebaser.IsBlockValid(baseBlock, true)
@@ -197,6 +201,123 @@ var _ = Describe("shardRebaser", func() {
 		})
 	})
 
+	Context("when validating the number of txs in a proposed block", func() {
+		It("should reject blocks with more txs than the maximum", func() {
+			test := func(shard Shard, txs block.Txs) bool {
+				store, initHeight, _ := initStorage(shard)
+				iter := mockBlockIterator{}
+				maxTxsPerBlock := rand.Intn(10) + 1
+				limits := blockLimits{txCounter: mockTxCounter{}, maxTxsPerBlock: maxTxsPerBlock}
+				rebaser := newShardRebaser(store, iter, nil, nil, nil, limits, process.NewSystemClock(), nil, shard)
+
+				parent := store.LatestBlock(shard)
+				base := store.LatestBaseBlock(shard)
+				header := RandomBlockHeaderJSON(block.Standard)
+				header.Height = initHeight + 1
+				header.BaseHash = base.Hash()
+				header.ParentHash = parent.Hash()
+				header.Timestamp = block.Timestamp(time.Now().Unix())
+				setRefs(&header, txs, nil, nil)
+				proposedBlock := block.New(header.ToBlockHeader(), txs, nil, nil)
+
+				_, err := rebaser.IsBlockValid(proposedBlock, true)
+				if len(txs) > maxTxsPerBlock {
+					Expect(err).Should(HaveOccurred())
+				} else {
+					Expect(err).ShouldNot(HaveOccurred())
+				}
+				return true
+			}
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when validating the timestamp of a proposed block", func() {
+		// newBlockAt returns a standard block, built on top of the latest
+		// block, with the timestamp. The header is unmarshaled, instead of
+		// constructed, so that it can be ahead of the local time (as if it
+		// was received from a dishonest proposer).
+		newBlockAt := func(store BlockStorage, shard Shard, height block.Height, timestamp block.Timestamp) block.Block {
+			parent, ok := store.Blockchain(shard).BlockAtHeight(height - 1)
+			Expect(ok).Should(BeTrue())
+			headerJSON := RandomBlockHeaderJSON(block.Standard)
+			headerJSON.Height = height
+			headerJSON.BaseHash = store.LatestBaseBlock(shard).Hash()
+			headerJSON.ParentHash = parent.Hash()
+			headerJSON.Timestamp = timestamp
+			setRefs(&headerJSON, nil, nil, nil)
+			data, err := json.Marshal(headerJSON)
+			Expect(err).ShouldNot(HaveOccurred())
+			header := block.Header{}
+			Expect(header.UnmarshalJSON(data)).Should(Succeed())
+			return block.New(header, nil, nil, nil)
+		}
+
+		It("should reject blocks with a timestamp before the parent block", func() {
+			test := func(shard Shard) bool {
+				store, initHeight, _ := initStorage(shard)
+				rebaser := newShardRebaser(store, mockBlockIterator{}, nil, nil, nil, blockLimits{strictTimestamps: true}, process.NewSystemClock(), nil, shard)
+
+				now := block.Timestamp(time.Now().Unix())
+				commitBlock(store, shard, newBlockAt(store, shard, initHeight+1, now-10))
+
+				_, err := rebaser.IsBlockValid(newBlockAt(store, shard, initHeight+2, now-11), true)
+				Expect(err).Should(HaveOccurred())
+				_, err = rebaser.IsBlockValid(newBlockAt(store, shard, initHeight+2, now-10), true)
+				Expect(err).Should(HaveOccurred())
+				_, err = rebaser.IsBlockValid(newBlockAt(store, shard, initHeight+2, now-9), true)
+				Expect(err).ShouldNot(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should reject blocks with a timestamp too far in the future", func() {
+			test := func(shard Shard) bool {
+				store, initHeight, _ := initStorage(shard)
+				limits := blockLimits{maxTimestampDrift: time.Minute}
+				rebaser := newShardRebaser(store, mockBlockIterator{}, nil, nil, nil, limits, process.NewSystemClock(), nil, shard)
+
+				now := time.Now()
+				_, err := rebaser.IsBlockValid(newBlockAt(store, shard, initHeight+1, block.Timestamp(now.Add(time.Hour).Unix())), true)
+				Expect(err).Should(HaveOccurred())
+				_, err = rebaser.IsBlockValid(newBlockAt(store, shard, initHeight+1, block.Timestamp(now.Add(30*time.Second).Unix())), true)
+				Expect(err).ShouldNot(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should not propose a block until the second of the parent block has passed", func() {
+			store, initHeight, _ := initStorage(Shard{})
+			rebaser := newShardRebaser(store, mockBlockIterator{}, nil, nil, nil, blockLimits{strictTimestamps: true}, process.NewSystemClock(), nil, Shard{})
+
+			commitBlock(store, Shard{}, newBlockAt(store, Shard{}, initHeight+1, block.Timestamp(time.Now().Add(time.Hour).Unix())))
+			Expect(rebaser.BlockProposal(initHeight+2, 0).Hash()).Should(Equal(block.InvalidHash))
+		})
+
+		It("should propose and check timestamps using the clock", func() {
+			store, initHeight, _ := initStorage(Shard{})
+			clock := NewMockClock(time.Now().Add(-time.Hour))
+			limits := blockLimits{maxTimestampDrift: time.Minute, strictTimestamps: true}
+			rebaser := newShardRebaser(store, mockBlockIterator{}, nil, nil, nil, limits, clock, nil, Shard{})
+
+			// Expect a block at the local time to be rejected, because it is
+			// an hour ahead of the clock
+			_, err := rebaser.IsBlockValid(newBlockAt(store, Shard{}, initHeight+1, block.Timestamp(time.Now().Unix())), true)
+			Expect(err).Should(HaveOccurred())
+
+			now := block.Timestamp(clock.Now().Unix())
+			_, err = rebaser.IsBlockValid(newBlockAt(store, Shard{}, initHeight+1, now), true)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			commitBlock(store, Shard{}, newBlockAt(store, Shard{}, initHeight+1, now-1))
+			proposedBlock := rebaser.BlockProposal(initHeight+2, 0)
+			Expect(proposedBlock.Hash()).ShouldNot(Equal(block.InvalidHash))
+			Expect(proposedBlock.Header().Timestamp()).Should(Equal(now))
+		})
+	})
+
 	// Context("when validating a proposed block", func() {
 	// 	It("should reject block which has unexpect kind", func() {
 	//
@@ -204,7 +325,28 @@ var _ = Describe("shardRebaser", func() {
 	// })
 })
 
+// setRefs sets the commitments in the header to those of the contents of the
+// block, so that the block passes validation.
+func setRefs(header *BlockHeaderJSON, txs block.Txs, plan block.Plan, prevState block.State) {
+	header.TxsRef = txs.Hash()
+	header.PlanRef = plan.Hash()
+	header.PrevStateRef = prevState.Hash()
+}
+
 func initStorage(shard Shard) (BlockStorage, block.Height, []*ecdsa.PrivateKey) {
+	store, keys := initGenesisStorage(shard)
+	initHeight := block.Height(rand.Intn(100))
+	bc := store.Blockchain(shard)
+
+	// Init standard blocks from block 1 to initHeight
+	for i := 1; i <= int(initHeight); i++ {
+		b := RandomBlock(block.Standard)
+		bc.InsertBlockAtHeight(block.Height(i), b)
+	}
+	return store, initHeight, keys
+}
+
+func initGenesisStorage(shard Shard) (BlockStorage, []*ecdsa.PrivateKey) {
 	sigs := make(id.Signatories, 7)
 	keys := make([]*ecdsa.PrivateKey, 7)
 	for i := range sigs {
@@ -216,15 +358,8 @@ func initStorage(shard Shard) (BlockStorage, block.Height, []*ecdsa.PrivateKey)
 		sigs[i] = id.NewSignatory(privateKey.PublicKey)
 	}
 	store := newMockBlockStorage(sigs)
-	initHeight := block.Height(rand.Intn(100))
 
 	// Init the genesis block at height 0
-	bc := store.Blockchain(shard)
-
-	// Init standard blocks from block 1 to initHeight
-	for i := 1; i <= int(initHeight); i++ {
-		b := RandomBlock(block.Standard)
-		bc.InsertBlockAtHeight(block.Height(i), b)
-	}
-	return store, initHeight, keys
+	store.Blockchain(shard)
+	return store, keys
 }
@@ -0,0 +1,93 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// A resender is a `process.Broadcaster` that can broadcast a Message that it
+// has already signed, without signing it again.
+type resender interface {
+	resend(m process.Message)
+}
+
+// voteTracker is a `process.Broadcaster` that remembers the Prevotes and
+// Precommits broadcast by the `process.Process` at its latest height, so that
+// they can be rebroadcast if they were lost. Votes at older heights are
+// forgotten as soon as a vote at a newer height is broadcast. Votes are
+// resent, instead of being signed again, so that the Prevote of a round can be
+// rebroadcast after the Precommit of the round has been signed.
+type voteTracker struct {
+	mu          *sync.Mutex
+	broadcaster process.Broadcaster
+	height      block.Height
+	votes       map[block.Round][]process.Message
+}
+
+func newVoteTracker(broadcaster process.Broadcaster) *voteTracker {
+	return &voteTracker{
+		mu:          new(sync.Mutex),
+		broadcaster: broadcaster,
+		height:      block.InvalidHeight,
+		votes:       map[block.Round][]process.Message{},
+	}
+}
+
+// Broadcast implements the `process.Broadcaster` interface.
+func (tracker *voteTracker) Broadcast(m process.Message) {
+	tracker.broadcaster.Broadcast(m)
+
+	switch m.(type) {
+	case *process.Prevote, *process.Precommit:
+	default:
+		return
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if m.Height() < tracker.height {
+		return
+	}
+	if m.Height() > tracker.height {
+		tracker.height = m.Height()
+		tracker.votes = map[block.Round][]process.Message{}
+	}
+	tracker.votes[m.Round()] = append(tracker.votes[m.Round()], m)
+}
+
+// rebroadcast the votes at the height and round. Nothing is rebroadcast if the
+// height is not the latest height at which votes have been broadcast.
+func (tracker *voteTracker) rebroadcast(height block.Height, round block.Round) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if height != tracker.height {
+		return
+	}
+	resender, ok := tracker.broadcaster.(resender)
+	for _, vote := range tracker.votes[round] {
+		if ok {
+			resender.resend(vote)
+			continue
+		}
+		tracker.broadcaster.Broadcast(vote)
+	}
+}
+
+// Rebroadcast the Prevotes and Precommits that the Replica has broadcast at
+// its current height and round, in case they were lost before reaching enough
+// of the Shard. It is expected to be called whenever a timeout is about to
+// expire. Votes from previous heights and rounds are never rebroadcast.
+// Rebroadcast does nothing after the Replica has been closed.
+func (replica *Replica) Rebroadcast() {
+	replica.lifecycle.mu.RLock()
+	defer replica.lifecycle.mu.RUnlock()
+
+	if replica.lifecycle.closed {
+		return
+	}
+	replica.votes.rebroadcast(replica.p.CurrentHeight(), replica.p.CurrentRound())
+}
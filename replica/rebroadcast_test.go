@@ -0,0 +1,123 @@
+package replica
+
+import (
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("rebroadcast", func() {
+
+	Context("when the prevote of the replica is lost", func() {
+		It("should rebroadcast the prevote so that the polka can form", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				// Drop the prevote of the replica, so that it never reaches
+				// anyone (including the replica itself)
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+
+				// Without the prevote of the replica, there are not enough
+				// prevotes to form a polka
+				for _, key := range keys[1:5] {
+					prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				}
+				Expect(messages).ShouldNot(Receive())
+
+				replica.Rebroadcast()
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+				Expect(message.Message.Signatory()).Should(Equal(replica.p.Signatory()))
+				Expect(message.Message.BlockHash()).Should(Equal(propose.BlockHash()))
+				Expect(process.Verify(message.Message)).Should(Succeed())
+
+				// Delivering the rebroadcast prevote forms the polka, and the
+				// replica precommits to the proposed block
+				Expect(replica.HandleMessage(message)).Should(Succeed())
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrecommitMessageType)))
+				Expect(message.Message.BlockHash()).Should(Equal(propose.BlockHash()))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the replica has precommitted", func() {
+		It("should rebroadcast both the prevote and the precommit of the round", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Eventually(messages).Should(Receive(&message))
+			Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrecommitMessageType)))
+			Expect(messages).ShouldNot(Receive())
+
+			// Expect the prevote to be rebroadcast, even though the watermark
+			// has been raised to the precommit
+			go replica.Rebroadcast()
+			for _, messageType := range []process.MessageType{process.PrevoteMessageType, process.PrecommitMessageType} {
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(messageType))
+				Expect(message.Message.Signatory()).Should(Equal(replica.p.Signatory()))
+				Expect(message.Message.BlockHash()).Should(Equal(propose.BlockHash()))
+				Expect(process.Verify(message.Message)).Should(Succeed())
+			}
+			Expect(messages).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the height advances", func() {
+		It("should not rebroadcast votes from the previous height", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			Eventually(messages).Should(Receive())
+
+			for _, key := range keys[1:6] {
+				precommit := process.NewPrecommit(1, 0, propose.BlockHash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(2)))
+			Expect(messages).ShouldNot(Receive())
+
+			replica.Rebroadcast()
+			Expect(messages).ShouldNot(Receive())
+		})
+	})
+})
@@ -4,15 +4,55 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/id"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	// ErrWrongShard is returned when a Message is received for a Shard that is
+	// not maintained by the Replica.
+	ErrWrongShard = errors.New("wrong shard")
+	// ErrStaleEpoch is returned when a Message is received from an Epoch that
+	// is lower than the current Epoch of the Replica.
+	ErrStaleEpoch = errors.New("stale epoch")
+	// ErrInvalidSignatory is returned when a Message is received from an
+	// `id.Signatory` that is not a member of the Shard.
+	ErrInvalidSignatory = errors.New("invalid signatory")
+	// ErrInvalidSignature is returned when a Message is received with a
+	// signature that cannot be verified against its `id.Signatory`.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrStaleHeight is returned when a Message is received for a height that
+	// is lower than the current height of the Replica.
+	ErrStaleHeight = errors.New("stale height")
+	// ErrDuplicate is returned when a Message of the same type has already been
+	// received from the same `id.Signatory` at the same height and round.
+	ErrDuplicate = errors.New("duplicate message")
+	// ErrFutureRound is returned when a Message is received at the current
+	// height for a round that is too far ahead of the current round of the
+	// Replica.
+	ErrFutureRound = errors.New("future round")
+	// ErrFutureHeight is returned when a Message is received for a height that
+	// is higher than the current height of the Replica, while its future
+	// buffer is full.
+	ErrFutureHeight = errors.New("future height")
+	// ErrPanicked is returned when handling a Message causes an unexpected
+	// panic. The Replica recovers from the panic, and drops the Message, so
+	// that a single malformed Message cannot crash the Replica.
+	ErrPanicked = errors.New("recovered from panic")
+	// ErrClosed is returned when a Message is received after the Replica has
+	// been closed.
+	ErrClosed = errors.New("replica closed")
+)
+
 type Shards []Shard
 
 // Shard uniquely identifies the Shard being maintained by the Replica.
@@ -32,15 +72,29 @@ type Messages []Message
 
 // A Message sent/received by a Replica is composed of a Shard and the
 // underlying `process.Message` data. It is expected that a Replica will sign
-// the underlying `process.Message` data before sending the Message.
+// the underlying `process.Message` data before sending the Message. The Epoch
+// identifies the session of the sender, so that Messages replayed from an
+// earlier session can be dropped. It is optional, and is zero for Replicas
+// that do not use sessions.
 type Message struct {
 	Message process.Message
 	Shard   Shard
+	Epoch   uint64
 }
 
 // ProcessStorage saves and restores `process.State` to persistent memory. This
 // guarantess that in the event of an unexpected shutdown, the Replica will only
-// drop the `process.Message` that was currently being handling.
+// drop the `process.Message` that was currently being handling. A Replica only
+// ever saves and restores its own Shard, so implementations that are shared by
+// the Replicas of many Shards must namespace everything they store by Shard;
+// otherwise, one Shard would restore the votes received by another. A Replica
+// never saves concurrently, but implementations that are shared by many
+// Replicas can be called concurrently by different Replicas. The saved
+// `process.State` does not grow with the height of the Shard: whenever a block
+// is committed, the Messages below the previous height are dropped, so only
+// the Messages at the current and previous heights are ever saved. The
+// proposals and prevotes at the previous height can be dropped sooner using
+// `Replica.Prune`.
 type ProcessStorage interface {
 	SaveProcess(p *process.Process, shard Shard)
 	RestoreProcess(p *process.Process, shard Shard)
@@ -56,6 +110,225 @@ type Options struct {
 	BackOffExp  float64
 	BackOffBase time.Duration
 	BackOffMax  time.Duration
+
+	// ProposeTimeoutBase replaces the BackOffBase when waiting for a proposal.
+	// It can be shorter than the BackOffBase, so that a silent proposer is
+	// prevoted nil, and its round skipped, without waiting for the full
+	// timeout (it is the BackOffBase if it is zero)
+	ProposeTimeoutBase time.Duration
+
+	// Verifier used to authenticate the signatories of received messages (it
+	// must be compatible with the signature scheme used by other Replicas).
+	// Successful verifications are cached for the current and previous
+	// heights, in a cache that is bounded by the MessageCacheSize
+	Verifier process.Verifier
+
+	// Hasher used to compute the sighashes of messages that are signed and
+	// verified (it must be the same Hasher that is used by other Replicas, and
+	// is SHA256 if it is nil)
+	Hasher process.Hasher
+
+	// Registerer used to register the Metrics of the Replica (metrics are
+	// disabled if it is nil)
+	Registerer prometheus.Registerer
+
+	// Stake is used to select proposers in proportion to their stake (if it is
+	// nil, proposers are selected round robin)
+	Stake StakeFunc
+
+	// ProposerOverride forces the proposer at every height and round,
+	// regardless of the Stake. It exists so that tests can deterministically
+	// choose a proposer, and is unsafe for production: all Replicas in the
+	// Shard must agree on the proposer, or consensus will stall
+	ProposerOverride ProposerOverride
+
+	// MessageCacheSize is the maximum number of recently seen messages that
+	// are remembered, so that gossiped duplicates can be dropped
+	MessageCacheSize int
+
+	// MessageQueueSize is the maximum number of Messages that can be waiting
+	// to be handled. If it is non-zero, HandleMessage queues Messages instead
+	// of handling them, and returns ErrQueueFull when the queue is full. Queued
+	// Messages are handled in order once the Replica has been started, and
+	// the errors from handling them are logged instead of returned
+	MessageQueueSize int
+
+	// MaxFutureRounds is the maximum number of rounds ahead of the current
+	// round that a Message at the current height can be, before it is rejected
+	// instead of being buffered
+	MaxFutureRounds block.Round
+
+	// FutureBufferSize is the maximum number of Messages above the current
+	// height that are held by the Replica, so that Messages for the next
+	// height that arrive early (before the current height is committed) are
+	// not lost. Held Messages are handled, in the order in which they were
+	// received, as soon as the Replica reaches their height. Messages that
+	// arrive while the buffer is full are rejected with ErrFutureHeight. If it
+	// is zero, Messages above the current height are passed to the
+	// `process.Process` without any bound
+	FutureBufferSize int
+
+	// Clock used to tell the current time and wait for timeouts, and TxCounter
+	// used to count the transactions in blocks. The Clock is also used to
+	// timestamp proposed blocks, so it must not run ahead of the local time
+	Clock     Clock
+	TxCounter TxCounter
+
+	// BlockBuilder assembles the transactions of the standard blocks proposed
+	// by the Replica, instead of the BlockIterator (which is still used to
+	// propose rebase and base blocks). It is not used if it is nil
+	BlockBuilder BlockBuilder
+
+	// MaxTxsPerBlock is the maximum number of transactions, as counted by the
+	// TxCounter, in a proposed block (proposed blocks with more transactions
+	// are prevoted nil, but they are still committed if the rest of the Shard
+	// commits them). It is not enforced if it is zero, and it requires a
+	// TxCounter to be set
+	MaxTxsPerBlock int
+
+	// MaxBlockSize is the maximum number of bytes in the binary encoding of a
+	// proposed block (proposed blocks that are larger are prevoted nil, but
+	// they are still committed, or synced, if the rest of the Shard commits
+	// them). It is not enforced if it is zero
+	MaxBlockSize int
+
+	// MaxTimestampDrift is the maximum duration that the timestamp of a
+	// proposed block can be ahead of the local time (proposed blocks that are
+	// further ahead are prevoted nil). StrictTimestamps requires the timestamp
+	// of a proposed block to be strictly greater than the timestamp of its
+	// parent, instead of being no less than it. Timestamps are in seconds, so
+	// strict timestamps limit the Shard to at most one block per second (the
+	// Replica resigns when it is the proposer and the second of its parent has
+	// not passed)
+	MaxTimestampDrift time.Duration
+	StrictTimestamps  bool
+
+	// OnCommit is called exactly once for every committed block, in order of
+	// height (it is not called when a round is skipped, because no block is
+	// committed). CommitDelay defers calls to OnCommit, without blocking
+	// consensus. CommitTimeout makes calls to OnCommit asynchronous, but makes
+	// the Replica wait (for at most CommitTimeout) for the block committed at
+	// the previous height to be delivered to OnCommit before it builds a
+	// proposal, so that the application never falls behind consensus by more
+	// than one block. The Replica keeps handling Messages while it waits, so
+	// OnCommit can call back into the Replica. If OnCommit has not returned by
+	// the timeout, a warning is logged and the proposal is built anyway
+	OnCommit      func(block.Block)
+	CommitDelay   time.Duration
+	CommitTimeout time.Duration
+
+	// SkipOfflineProposers makes the Replica prevote nil as soon as a round
+	// starts if the proposer of the round has not sent any messages during the
+	// most recent OfflineWindow heights, instead of waiting for the propose
+	// timeout
+	SkipOfflineProposers bool
+	OfflineWindow        block.Height
+
+	// SkipOfflineInRotation makes the Replica skip signatories that are known
+	// to be offline when it selects proposers, so that no round is spent
+	// waiting for them. When the scheduled proposer is offline, the proposer
+	// of the next round is tried instead, and so on. Offline signatories are
+	// still counted towards the consensus threshold, so safety is unaffected,
+	// but Replicas that disagree about which signatories are offline will
+	// disagree about the proposer, and can waste rounds. Liveness decides
+	// which signatories are offline. If it is nil, signatories that have not
+	// sent any messages during the most recent OfflineWindow heights are
+	// offline
+	SkipOfflineInRotation bool
+	Liveness              LivenessFunc
+
+	// ProposeOnTrigger makes the Replica wait for TriggerPropose to be called
+	// before proposing, instead of proposing as soon as it becomes the
+	// proposer, so that block production can be paced externally. The Replica
+	// still prevotes nil if TriggerPropose is not called before the propose
+	// timeout
+	ProposeOnTrigger bool
+
+	// OnProposerEquivocation is called with evidence whenever a proposer is
+	// seen sending two different proposals at the same height and round. The
+	// first proposal is used for consensus, and the second is rejected (it
+	// must not call back into the Replica)
+	OnProposerEquivocation func(ProposerEquivocation)
+
+	// OnVoteEquivocation is called with evidence whenever a signatory is seen
+	// sending two different prevotes, or two different precommits, at the
+	// same height and round. The first vote is used for consensus, and the
+	// second is rejected (it must not call back into the Replica)
+	OnVoteEquivocation func(VoteEquivocation)
+
+	// OnFork is called with evidence if a commit for a different block is
+	// seen at a height that has already been committed. The Replica halts
+	// after seeing a fork, because it can no longer guarantee safety (it must
+	// not call back into the Replica)
+	OnFork func(ForkEvidence)
+
+	// UnlockStrategy decides whether the Replica can prevote for a proposed
+	// block that is different from the block on which it is locked. It
+	// defaults to the spec UnlockStrategy, which is the only UnlockStrategy
+	// that is safe to use in production
+	UnlockStrategy process.UnlockStrategy
+
+	// VoteExtender returns an opaque extension that is attached to, and signed
+	// with, every Prevote and Precommit sent by the Replica. Extensions are
+	// not used for consensus, and the extensions of the precommits that
+	// committed a block can be aggregated using `process.LatestCommit`. No
+	// extensions are attached if it is nil
+	VoteExtender process.VoteExtender
+
+	// TransitionLogSize is the maximum number of transitions that are recorded
+	// at each height, for auditing how a height was committed. The transition
+	// log is disabled if it is zero. OnTransitionEvicted is called with the
+	// oldest transition at a height whenever the maximum is exceeded (it must
+	// not call back into the Replica)
+	TransitionLogSize   int
+	OnTransitionEvicted func(process.Transition)
+
+	// ProgressBufferSize is the number of Progress events that are buffered for
+	// each subscriber. Events are dropped for subscribers whose buffers are
+	// full, so that slow subscribers cannot stall consensus
+	ProgressBufferSize int
+
+	// ActionBufferSize is the number of Actions that are buffered for each
+	// subscriber (see SubscribeActions). Actions are dropped for subscribers
+	// whose buffers are full, so that slow subscribers cannot stall consensus
+	ActionBufferSize int
+
+	// StallThreshold is the number of consecutive timeouts at the same height
+	// after which consensus is considered to be stalled. OnStall is called at
+	// every timeout once the threshold has been reached, until a new height is
+	// reached (it must not call back into the Replica). OnStall is never
+	// called if the threshold is zero
+	StallThreshold int
+	OnStall        StallFunc
+
+	// Watermarks stores the latest vote signed by the Replica. The Replica
+	// refuses to sign a Prevote or Precommit that is not strictly later than
+	// the latest vote, and saves every vote before signing it. It defaults to
+	// storage in memory, which does not protect against double signing after
+	// a restart, so it must be durable in production
+	Watermarks WatermarkStorage
+
+	// ConsensusThreshold is the number of matching votes that the Replica
+	// expects to be needed for consensus. The threshold is always derived from
+	// the number of signatories of the latest base block, so this is only
+	// used to check that the deployment agrees with the signatories (see
+	// Validate). It is not checked if it is zero
+	ConsensusThreshold int
+
+	// Epoch is the current session of the Replica. It is attached to every
+	// Message that the Replica sends, and Messages from lower Epochs are
+	// dropped (Messages from higher Epochs are accepted, so that Replicas can
+	// move to a new Epoch one at a time). The Epoch is not signed, so it only
+	// protects against the replay of Messages from earlier sessions by honest
+	// peers, and not against a peer that rewrites it
+	Epoch uint64
+
+	// Genesis is the base block from which the Shard starts. Its hash is the
+	// parent of the first proposal, and the Replica starts at the height after
+	// it. It is inserted into the BlockStorage if no block exists at its
+	// height (if it is not set, the BlockStorage must already contain a base
+	// block at height zero)
+	Genesis block.Block
 }
 
 func (options *Options) setZerosToDefaults() {
@@ -68,9 +341,63 @@ func (options *Options) setZerosToDefaults() {
 	if options.BackOffBase == time.Duration(0) {
 		options.BackOffBase = 20 * time.Second
 	}
+	if options.ProposeTimeoutBase == time.Duration(0) {
+		options.ProposeTimeoutBase = options.BackOffBase
+	}
 	if options.BackOffMax == time.Duration(0) {
 		options.BackOffMax = 5 * time.Minute
 	}
+	if options.Verifier == nil {
+		options.Verifier = process.NewECDSAVerifier()
+	}
+	if options.Hasher == nil {
+		options.Hasher = process.NewSHA256Hasher()
+	}
+	if options.MessageCacheSize == 0 {
+		options.MessageCacheSize = 10000
+	}
+	if options.MaxFutureRounds == 0 {
+		options.MaxFutureRounds = 10
+	}
+	if options.Clock == nil {
+		options.Clock = process.NewSystemClock()
+	}
+	if options.TxCounter == nil {
+		options.TxCounter = newBlockTxCounter()
+	}
+	if options.OfflineWindow == 0 {
+		options.OfflineWindow = 10
+	}
+	if options.ProgressBufferSize == 0 {
+		options.ProgressBufferSize = 100
+	}
+	if options.ActionBufferSize == 0 {
+		options.ActionBufferSize = 100
+	}
+	if options.Watermarks == nil {
+		options.Watermarks = newMemoryWatermarkStorage()
+	}
+}
+
+// Validate returns an error if the Options cannot be used by a Replica that
+// reaches consensus among the signatories: the ConsensusThreshold, if it is
+// set, must be at least `block.ConsensusThreshold` and at most the number of
+// signatories. A lower threshold cannot guarantee safety, and a higher one can
+// never be reached. The MaxTxsPerBlock can only be set along with a TxCounter,
+// because the default TxCounter does not know how to count the transactions
+// in a block. New returns this error if the Options are not valid for the
+// signatories of the latest base block.
+func (options Options) Validate(signatories id.Signatories) error {
+	n := len(signatories)
+	if options.ConsensusThreshold != 0 && (options.ConsensusThreshold < block.ConsensusThreshold(n) || options.ConsensusThreshold > n) {
+		return fmt.Errorf("expected consensus threshold between %v and %v for %v signatories, got threshold=%v", block.ConsensusThreshold(n), n, n, options.ConsensusThreshold)
+	}
+	if options.MaxTxsPerBlock > 0 {
+		if _, ok := options.TxCounter.(blockTxCounter); ok || options.TxCounter == nil {
+			return fmt.Errorf("expected tx counter for max txs per block=%v", options.MaxTxsPerBlock)
+		}
+	}
+	return nil
 }
 
 type Replicas []Replica
@@ -79,110 +406,798 @@ type Replicas []Replica
 // to a specific Shard. It signs Messages before sending them to other Replicas,
 // and verifies Messages before accepting them from other Replicas.
 type Replica struct {
-	options      Options
-	shard        Shard
-	p            *process.Process
-	pStorage     ProcessStorage
-	blockStorage BlockStorage
+	options       Options
+	shard         Shard
+	p             *process.Process
+	pStorage      ProcessStorage
+	saves         *sync.Mutex
+	blockStorage  BlockStorage
+	blockIterator BlockIterator
 
-	scheduler *roundRobinScheduler
-	rebaser   *shardRebaser
-	cache     baseBlockCache
+	scheduler     scheduler
+	handovers     *validatorHandovers
+	rebaser       *shardRebaser
+	broadcaster   process.Broadcaster
+	peers         PeerBroadcaster
+	verifier      *verificationCache
+	votes         *voteTracker
+	cache         *baseBlockCache
+	seen          *messageCache
+	participation *participationTracker
+	delayer       *commitDelayer
+	applied       *appliedHeights
+	commitRounds  *commitRounds
+	proposers     *proposerLog
+	metrics       *Metrics
+	progress      *progressNotifier
+	actions       *actionNotifier
+	stalls        *stallDetector
+	forks         *forkDetector
+	queue         *inboundQueue
+	future        *futureBuffer
+	counters      *messageCounters
+	lifecycle     *lifecycle
+	catchUps      *catchUpLimiter
+	proposer      *commitAwaitingProposer
 
 	messagesSinceLastSave int
 }
 
-func New(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shard Shard, privKey ecdsa.PrivateKey) Replica {
+// New returns a Replica that signs its Messages using a local ECDSA private
+// key. It is equivalent to NewWithSigner using `process.NewECDSASigner`. It
+// returns an error if the Options are not valid (see Options.Validate), or if
+// the genesis block cannot be used with the BlockStorage.
+func New(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shard Shard, privKey ecdsa.PrivateKey) (Replica, error) {
+	return NewWithSigner(options, pStorage, blockStorage, blockIterator, validator, observer, broadcaster, shard, process.NewECDSASigner(privKey))
+}
+
+// NewWithSigner returns a Replica that signs its Messages using a
+// `process.Signer`, so that the private key never needs to be loaded into the
+// memory of the Replica. This allows operators to keep their keys in an HSM,
+// or behind a remote signer. The `id.Signatory` of the Signer is used as the
+// identity of the Replica. Messages that the Signer fails to sign are logged
+// and dropped. If the Signer is nil, the Replica follows the Shard without
+// taking part in consensus, and the ProcessStorage and Broadcaster are not
+// used (see NewObserver).
+func NewWithSigner(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, broadcaster Broadcaster, shard Shard, signer process.Signer) (Replica, error) {
+	if signer == nil {
+		return NewObserver(options, blockStorage, blockIterator, validator, observer, shard)
+	}
+	options.setZerosToDefaults()
+	guard := newDoubleSignGuard(options.Watermarks, shard)
+	replica, err := newReplica(options, pStorage, blockStorage, blockIterator, validator, observer, newSigner(broadcaster, shard, options.Epoch, signer, options.Hasher, guard, options.Logger.WithField("shard", shard)), shard, signer.Signatory())
+	if err != nil {
+		return Replica{}, err
+	}
+	replica.peers, _ = broadcaster.(PeerBroadcaster)
+	return replica, nil
+}
+
+// newReplica returns a Replica that uses the given `process.Broadcaster` to
+// send the Messages of its Process, and the given `id.Signatory` to decide
+// when its Process is the proposer.
+func newReplica(options Options, pStorage ProcessStorage, blockStorage BlockStorage, blockIterator BlockIterator, validator Validator, observer Observer, signer process.Broadcaster, shard Shard, signatory id.Signatory) (Replica, error) {
 	options.setZerosToDefaults()
+	startHeight, err := initGenesis(options, blockStorage, shard)
+	if err != nil {
+		return Replica{}, err
+	}
 	latestBase := blockStorage.LatestBaseBlock(shard)
-	scheduler := newRoundRobinScheduler(latestBase.Header().Signatories())
-	if len(latestBase.Header().Signatories())%3 != 1 {
-		panic(fmt.Errorf("invariant violation: number of nodes needs to be 3f +1, got %v", len(latestBase.Header().Signatories())))
+	if err := options.Validate(latestBase.Header().Signatories()); err != nil {
+		return Replica{}, err
+	}
+	handovers := newValidatorHandovers()
+	var scheduler scheduler = handoverScheduler{
+		scheduler: newScheduler(options, latestBase.Header().Signatories()),
+		handovers: handovers,
+	}
+
+	// Participation is only tracked once the Process has been restored, and
+	// nothing is considered to be offline until then
+	var participation *participationTracker
+	if options.SkipOfflineInRotation {
+		isLive := options.Liveness
+		if isLive == nil {
+			isLive = func(signatory id.Signatory, height block.Height) bool {
+				return !participation.IsOffline(signatory, height)
+			}
+		}
+		scheduler = newLivenessScheduler(scheduler, signatory, isLive)
+	}
+	metrics := NewMetrics(options.Registerer, options.Clock, shard)
+	progress := newProgressNotifier(options.ProgressBufferSize)
+	actions := newActionNotifier(options.ActionBufferSize)
+	stalls := newStallDetector(options.StallThreshold, options.OnStall)
+	applied := newAppliedHeights()
+	onCommit := options.OnCommit
+	if onCommit != nil {
+		apply := onCommit
+		onCommit = func(committedBlock block.Block) {
+			apply(committedBlock)
+			applied.didApply(committedBlock.Header().Height())
+		}
+	}
+	var delayer *commitDelayer
+	if onCommit != nil && (options.CommitDelay > 0 || options.CommitTimeout > 0) {
+		delayer = newCommitDelayer(options.Clock, options.CommitDelay, onCommit)
+		onCommit = delayer.DidCommit
+	}
+	limits := blockLimits{
+		maxTimestampDrift: options.MaxTimestampDrift,
+		strictTimestamps:  options.StrictTimestamps,
+		txCounter:         options.TxCounter,
+		maxTxsPerBlock:    options.MaxTxsPerBlock,
+		maxBlockSize:      options.MaxBlockSize,
+	}
+	proposalIterator := blockIterator
+	if options.BlockBuilder != nil {
+		proposalIterator = builderIterator{
+			BlockIterator: blockIterator,
+			build:         options.BlockBuilder,
+			blockStorage:  blockStorage,
+			logger:        options.Logger.WithField("shard", shard),
+		}
+	}
+	var proposer *commitAwaitingProposer
+	if delayer != nil && options.CommitTimeout > 0 {
+		proposer = &commitAwaitingProposer{
+			delayer: delayer,
+			timeout: options.CommitTimeout,
+			logger:  options.Logger.WithField("shard", shard),
+		}
+	}
+	shardRebaser := newShardRebaser(blockStorage, proposalIterator, validator, observer, metrics, limits, options.Clock, onCommit, shard)
+	shardRebaser.actions = actions
+	votes := newVoteTracker(signer)
+
+	// Observers never send their Messages, so they only emit commit Actions
+	var broadcaster process.Broadcaster = votes
+	if !signatory.Equal(id.Signatory{}) {
+		broadcaster = actionBroadcaster{broadcaster: votes, actions: actions}
 	}
-	shardRebaser := newShardRebaser(blockStorage, blockIterator, validator, observer, shard)
 
-	// Create a Process in the default state and then restore it
+	// Create a Process in the default state, at the height after the genesis
+	// block, and then restore it
+	state := process.DefaultState((len(latestBase.Header().Signatories()) - 1) / 3)
+	state.CurrentHeight = startHeight
 	p := process.New(
-		options.Logger,
-		id.NewSignatory(privKey.PublicKey),
+		options.Logger.WithField("shard", shard),
+		signatory,
 		blockStorage.Blockchain(shard),
-		process.DefaultState((len(latestBase.Header().Signatories())-1)/3),
+		state,
 		shardRebaser,
 		shardRebaser,
 		shardRebaser,
-		newSigner(broadcaster, shard, privKey),
+		broadcaster,
 		scheduler,
-		newBackOffTimer(options.BackOffExp, options.BackOffBase, options.BackOffMax),
+		newBackOffTimer(options.BackOffExp, options.ProposeTimeoutBase, options.BackOffBase, options.BackOffMax),
 	)
+	p.UseClock(options.Clock)
+	p.UseUnlockStrategy(options.UnlockStrategy)
+	p.OnInvariantViolation(metrics.didViolateInvariant)
+	p.OnStartRound(func(height block.Height, round block.Round) {
+		stalls.didStartRound(height, round)
+		progress.didStartRound(height, round)
+
+		// Wait for the previous block to be applied without holding the
+		// lock of the Process, unless proposals are triggered externally
+		if proposer != nil && !options.ProposeOnTrigger && scheduler.Schedule(height, round).Equal(signatory) {
+			go proposer.proposeAt(height, round)
+		}
+	})
+	p.OnTimeout(stalls.didTimeout)
+	proposers := newProposerLog(proposerLogSize)
+	p.OnCommit(func(propose *process.Propose) {
+		proposers.didCommit(propose.Height(), propose.Signatory())
+	})
+	p.UseVoteExtender(options.VoteExtender)
+	p.UseHasher(options.Hasher)
+
+	// Verify the certificates embedded in Messages using the same cache as the
+	// Messages themselves, so that signatures are only verified once
+	verifier := newVerificationCache(options.Verifier, options.MessageCacheSize)
+	p.UseVerifier(verifier)
+	p.WaitForProposeTrigger(options.ProposeOnTrigger || proposer != nil)
+	p.EnableTransitionLog(options.TransitionLogSize, options.OnTransitionEvicted)
 	pStorage.RestoreProcess(p, shard)
 
-	return Replica{
-		options:      options,
-		shard:        shard,
-		p:            p,
-		pStorage:     pStorage,
-		blockStorage: blockStorage,
+	// Track participation after restoring the Process, so that signatories
+	// are not considered to be offline before a full window has been observed
+	if options.SkipOfflineProposers || (options.SkipOfflineInRotation && options.Liveness == nil) {
+		participation = newParticipationTracker(options.OfflineWindow, p.CurrentHeight())
+	}
+	if options.SkipOfflineProposers {
+		p.SkipOfflineProposers(participation)
+	}
+
+	replica := Replica{
+		options:       options,
+		shard:         shard,
+		p:             p,
+		pStorage:      pStorage,
+		saves:         new(sync.Mutex),
+		blockStorage:  blockStorage,
+		blockIterator: blockIterator,
 
-		scheduler: scheduler,
-		rebaser:   shardRebaser,
-		cache:     newBaseBlockCache(latestBase),
+		scheduler:     scheduler,
+		handovers:     handovers,
+		rebaser:       shardRebaser,
+		broadcaster:   signer,
+		verifier:      verifier,
+		votes:         votes,
+		cache:         newBaseBlockCache(latestBase),
+		seen:          newMessageCache(options.MessageCacheSize),
+		participation: participation,
+		delayer:       delayer,
+		applied:       applied,
+		commitRounds:  newCommitRounds(),
+		proposers:     proposers,
+		metrics:       metrics,
+		progress:      progress,
+		actions:       actions,
+		stalls:        stalls,
+		forks:         newForkDetector(),
+		queue:         newInboundQueue(options.MessageQueueSize),
+		future:        newFutureBuffer(options.FutureBufferSize),
+		counters:      newMessageCounters(),
+		lifecycle:     newLifecycle(),
+		catchUps:      newCatchUpLimiter(options.Clock, catchUpReplyInterval),
+		proposer:      proposer,
 
 		messagesSinceLastSave: 0,
 	}
+	if proposer != nil {
+		proposer.trigger = replica.triggerProposeAt
+	}
+	return replica, nil
 }
 
+// Start the Replica. Starting a Replica that has been closed does nothing.
 func (replica *Replica) Start() {
+	replica.lifecycle.mu.RLock()
+	defer replica.lifecycle.mu.RUnlock()
+
+	if _, forked := replica.forks.forked(); replica.lifecycle.closed || forked {
+		return
+	}
 	replica.p.Start()
+	if replica.queue != nil {
+		replica.queue.run(replica.handleQueuedMessage)
+	}
 }
 
-func (replica *Replica) HandleMessage(m Message) {
+// HandleMessage passes a Message to the underlying `process.Process` if, and
+// only if, it is valid. Otherwise, the Message is dropped and an error
+// describing the reason for the rejection is returned. Proposals and votes
+// below the current height can never contribute to progress, and are rejected
+// with ErrStaleHeight; blocks that have already been committed are synced
+// using catch-up Messages instead. After the Replica has been closed, all
+// Messages are dropped and ErrClosed is returned. After the Replica has seen
+// a fork, all Messages are dropped and ErrForked is returned. If the Replica
+// has a queue (see Options.MessageQueueSize), the Message is queued instead,
+// and ErrQueueFull is returned if the queue is full.
+//
+// HandleMessage is safe for concurrent use, for example by one goroutine per
+// peer. Messages are checked and verified concurrently, but the
+// `process.Process` handles one Message at a time, so concurrent Messages are
+// applied in some order, and their transitions never interleave.
+func (replica *Replica) HandleMessage(m Message) error {
+	if replica.queue != nil {
+		return replica.queueMessage(m)
+	}
+	return replica.handleMessage(m)
+}
+
+// queueMessage queues a Message to be handled by the worker of the Replica. It
+// does not take the lifecycle lock, because a Broadcaster can deliver Messages
+// back to the Replica while it is handling a Message, and taking the read lock
+// again would deadlock with a concurrent Close. The queue is closed by Close,
+// so Messages are still rejected with ErrClosed.
+func (replica *Replica) queueMessage(m Message) error {
+	if err := replica.queue.push(m); err != nil {
+		if err == ErrClosed {
+			return err
+		}
+		replica.metrics.didReject(err)
+		replica.counters.didReject(err)
+		return err
+	}
+	return nil
+}
+
+// handleQueuedMessage handles a Message that has been taken from the queue.
+func (replica *Replica) handleQueuedMessage(m Message) {
+	if err := replica.handleMessage(m); err != nil {
+		replica.options.Logger.Debugf("dropped queued message: %v", err)
+	}
+}
+
+// recoverPanic recovers from a panic while handling a Message, logs the panic
+// along with the Message that caused it, and replaces the error with
+// ErrPanicked. It must be deferred. It does not call into the
+// `process.Process`, in case the panic left it locked.
+func (replica *Replica) recoverPanic(m Message, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fields := logrus.Fields{}
+	if m.Message != nil {
+		fields["type"] = m.Message.Type()
+		fields["height"] = m.Message.Height()
+		fields["round"] = m.Message.Round()
+		fields["signatory"] = m.Message.Signatory()
+	}
+	replica.options.Logger.WithFields(fields).Errorf("recovered from panic: %v\n%s", r, debug.Stack())
+	replica.metrics.didRecoverPanic()
+	*err = ErrPanicked
+}
+
+func (replica *Replica) handleMessage(m Message) (err error) {
+	defer replica.recoverPanic(m, &err)
+
+	replica.lifecycle.mu.RLock()
+	defer replica.lifecycle.mu.RUnlock()
+
+	if replica.lifecycle.closed {
+		return ErrClosed
+	}
+	if _, forked := replica.forks.forked(); forked {
+		return ErrForked
+	}
+	if err := replica.checkMessage(m); err != nil {
+		replica.metrics.didReject(err)
+		replica.counters.didReject(err)
+		return err
+	}
+	held := replica.isFutureMessage(m)
+	if held {
+		if err := replica.future.push(m); err != nil {
+			replica.metrics.didReject(err)
+			replica.counters.didReject(err)
+			return err
+		}
+	}
+	replica.counters.didAccept()
+	replica.seen.insert(m.Message)
+	replica.participation.didParticipate(m.Message.Signatory(), m.Message.Height())
+
+	// Catch-up messages are handled by the Replica, because the
+	// `process.Process` does not store committed precommits
+	switch message := m.Message.(type) {
+	case *process.CatchUpRequest:
+		return replica.handleCatchUpRequest(message)
+	case *process.CommitRange:
+		defer replica.replayFutureMessages()
+		return replica.handleCommitRange(message)
+	}
+
+	// Messages above the current height are held until the height is reached
+	if held {
+		return nil
+	}
+	defer replica.replayFutureMessages()
+	return replica.handleProcessMessage(m)
+}
+
+// handleProcessMessage passes a Message that has been checked to the
+// `process.Process`.
+func (replica *Replica) handleProcessMessage(m Message) error {
+	// Proposals carry the commit of the previous height, which must not
+	// conflict with the block committed by the Replica
+	if propose, ok := m.Message.(*process.Propose); ok && replica.checkFork(propose.LatestCommit()) {
+		return ErrForked
+	}
+
+	// Handle the underlying `process.Message` and immediately save the
+	// `process.Process` afterwards to protect against unexpected crashes
+	replica.p.HandleMessage(m.Message)
+	replica.saveProcess()
+	replica.seen.evictBelow(replica.p.CurrentHeight())
+	replica.metrics.didProgress(replica.p)
+	return nil
+}
+
+func (replica *Replica) checkMessage(m Message) error {
 	// Check that Message is from our Shard
 	if !replica.shard.Equal(m.Shard) {
 		replica.options.Logger.Warnf("bad message: expected shard=%v, got shard=%v", replica.shard, m.Shard)
-		return
+		return ErrWrongShard
 	}
 
-	// Check that the Message sender is from our Shard (this can be a moderately
-	// expensive operation, so we cache the result until a new `block.Base` is
-	// detected)
-	replica.cache.fillBaseBlock(replica.blockStorage.LatestBaseBlock(replica.shard))
-	if !replica.cache.signatoryInBaseBlock(m.Message.Signatory()) {
-		return
+	// Drop Messages that have been replayed from an earlier session
+	if m.Epoch < replica.options.Epoch {
+		replica.options.Logger.Debugf("stale message: expected epoch>=%v, got epoch=%v", replica.options.Epoch, m.Epoch)
+		return ErrStaleEpoch
+	}
+
+	// Drop Messages that have already been seen, before doing any expensive
+	// verification
+	if replica.seen.contains(m.Message) {
+		return ErrDuplicate
+	}
+
+	// Check that the Message sender is a member of the ValidatorSet of our
+	// Shard at the height of the Message (the ValidatorSet is cached until a
+	// new `block.Base` is detected)
+	if !replica.validatorsAt(m.Message.Height()).Contains(m.Message.Signatory()) {
+		return ErrInvalidSignatory
 	}
 
 	// Verify that the Message is actually signed by the claimed `id.Signatory`
-	if err := process.Verify(m.Message); err != nil {
+	// (successful verifications are cached until the height advances, so that
+	// gossiped duplicates are only verified once)
+	replica.verifier.didAdvance(replica.p.CurrentHeight())
+	if err := process.VerifyWithHasher(m.Message, replica.verifier, replica.options.Hasher); err != nil {
 		replica.options.Logger.Warnf("bad message: unverified: %v", err)
-		return
+		return ErrInvalidSignature
 	}
 
-	// Handle the underlying `process.Message` and immediately save the
-	// `process.Process` afterwards to protect against unexpected crashes
-	replica.p.HandleMessage(m.Message)
+	// Catch-up messages concern blocks that have already been committed, so
+	// they are never stale
+	switch m.Message.(type) {
+	case *process.CatchUpRequest, *process.CommitRange:
+		return nil
+	}
+	return replica.checkProgress(m)
+}
+
+// checkProgress returns an error if the Message cannot affect the
+// `process.Process`.
+func (replica *Replica) checkProgress(m Message) error {
+	// Check that the Message can still affect the `process.Process` (Messages
+	// that have already been seen are dropped earlier, by checkMessage)
+	if m.Message.Height() < replica.p.CurrentHeight() {
+		return ErrStaleHeight
+	}
+	// Check that the Message is not too far ahead of the current round, so
+	// that a malicious peer cannot force the `process.Process` to buffer
+	// Messages for rounds that will never be reached
+	if m.Message.Height() == replica.p.CurrentHeight() && m.Message.Round() > replica.p.CurrentRound()+replica.options.MaxFutureRounds {
+		return ErrFutureRound
+	}
+	if replica.p.HasReceived(m.Message) {
+		switch message := m.Message.(type) {
+		case *process.Propose:
+			replica.checkProposerEquivocation(message)
+		case *process.Prevote, *process.Precommit:
+			replica.checkVoteEquivocation(message)
+		}
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// saveProcess saves the `process.Process` to the ProcessStorage. Saves are
+// serialized, so that the State that is saved last is always the latest
+// State, even when Messages are handled concurrently.
+func (replica *Replica) saveProcess() {
+	replica.saves.Lock()
+	defer replica.saves.Unlock()
+
 	replica.pStorage.SaveProcess(replica.p, replica.shard)
 }
 
+// Shard returns the Shard that is maintained by the Replica.
+func (replica *Replica) Shard() Shard {
+	return replica.shard
+}
+
+// CurrentHeight returns the height at which the Replica is currently trying to
+// reach consensus.
+func (replica *Replica) CurrentHeight() block.Height {
+	return replica.p.CurrentHeight()
+}
+
+// CurrentRound returns the round in which the Replica is currently trying to
+// reach consensus.
+func (replica *Replica) CurrentRound() block.Round {
+	return replica.p.CurrentRound()
+}
+
+// Subscribe returns a channel that receives a Progress event whenever the
+// Replica advances to a new height or round, so that applications do not need
+// to poll CurrentHeight and CurrentRound. Events are buffered, and are dropped
+// if the buffer is full, so slow subscribers can miss events but never stall
+// consensus. The channel is closed when the Replica is closed.
+func (replica *Replica) Subscribe() <-chan Progress {
+	return replica.progress.subscribe()
+}
+
+// SubscribeActions returns a channel that receives every proposal, prevote,
+// and precommit sent by the Replica, and every block that it commits, in the
+// order in which they happen. This is useful for recording traces of
+// consensus. Like Subscribe, Actions are buffered, and are dropped if the
+// buffer is full. The channel is closed when the Replica is closed.
+func (replica *Replica) SubscribeActions() <-chan Action {
+	return replica.actions.subscribe()
+}
+
+// ProcessState returns a read-only copy of the State of the underlying
+// `process.Process`, including the number of messages that have been received
+// at each height and round, so that consensus progress can be monitored. It
+// is a deep copy, so it is safe to inspect while the Replica handles messages.
+func (replica *Replica) ProcessState() process.StateSnapshot {
+	return replica.p.Snapshot()
+}
+
+// Validators returns the ValidatorSet of the Shard at the current height, as
+// defined by the signatories of the latest base block, or by the latest
+// ValidatorSet scheduled at, or below, the current height. Messages from
+// signatories that are not members of the ValidatorSet are rejected with
+// ErrInvalidSignatory.
+func (replica *Replica) Validators() ValidatorSet {
+	if handover, ok := replica.handovers.at(replica.p.CurrentHeight()); ok {
+		return handover.validators
+	}
+	return NewValidatorSet(replica.blockStorage.LatestBaseBlock(replica.shard).Header().Signatories())
+}
+
+// Proposer returns the signatory that is scheduled to propose at the height
+// and round in which the Replica is currently trying to reach consensus. It
+// changes as the Replica advances through heights and rounds.
+func (replica *Replica) Proposer() id.Signatory {
+	return replica.p.CurrentProposer()
+}
+
+// IsProposing returns true if the Replica is scheduled to propose at its
+// current height and round. It can be used to only do expensive work when it
+// is needed for building a block.
+func (replica *Replica) IsProposing() bool {
+	return replica.Proposer().Equal(replica.p.Signatory())
+}
+
+// TriggerPropose makes the Replica propose immediately, if it is the proposer
+// of its current height and round, and is waiting for a trigger to propose
+// (see Options.ProposeOnTrigger). It returns true if a proposal was broadcast.
+// Otherwise, it does nothing and returns false. If the Replica has a
+// CommitTimeout, it first waits for the previous block to be applied. It is
+// safe to call concurrently with HandleMessage.
+func (replica *Replica) TriggerPropose() bool {
+	if replica.proposer != nil {
+		replica.proposer.await(replica.p.CurrentHeight())
+	}
+
+	replica.lifecycle.mu.RLock()
+	defer replica.lifecycle.mu.RUnlock()
+
+	if _, forked := replica.forks.forked(); replica.lifecycle.closed || forked {
+		return false
+	}
+	return replica.p.TriggerPropose()
+}
+
+// triggerProposeAt triggers the proposal of the Replica, if it is still at the
+// height and round.
+func (replica *Replica) triggerProposeAt(height block.Height, round block.Round) bool {
+	replica.lifecycle.mu.RLock()
+	defer replica.lifecycle.mu.RUnlock()
+
+	if _, forked := replica.forks.forked(); replica.lifecycle.closed || forked {
+		return false
+	}
+	if replica.p.CurrentHeight() != height || replica.p.CurrentRound() != round {
+		return false
+	}
+	return replica.p.TriggerPropose()
+}
+
+// NextTimeout returns the duration after which the current step of the Replica
+// is expected to time out, or zero if the current step is not waiting for a
+// timeout. Waiting for a proposal times out after the ProposeTimeoutBase,
+// instead of the BackOffBase (see Options).
+func (replica *Replica) NextTimeout() time.Duration {
+	return replica.p.NextTimeout()
+}
+
+// TransitionLog returns the ordered transitions that were processed by the
+// Replica at a height, if the transition log is enabled. Transitions at a
+// height are dropped once the Replica has committed the next height.
+func (replica *Replica) TransitionLog(height block.Height) []process.Transition {
+	return replica.p.TransitionLog(height)
+}
+
+// AppliedHeights returns the lowest and highest heights of the blocks that have
+// been delivered to the OnCommit callback since the Replica was created. If the
+// highest applied height is lower than the height of the latest committed
+// block, then there are committed blocks that have not been applied. If no
+// blocks have been applied, both heights are `block.InvalidHeight`.
+func (replica *Replica) AppliedHeights() (first, last block.Height) {
+	return replica.applied.heights()
+}
+
+// LastCommit returns the most recent block committed by the Replica, together
+// with the 2f+1 precommits that prove it was committed, so that the proof can
+// be served to light clients. The precommits can be checked by calling
+// `process.LatestCommit.Verify`. It returns false if the Replica has not
+// committed a block since it was created.
+func (replica *Replica) LastCommit() (process.LatestCommit, bool) {
+	return replica.p.LastCommit()
+}
+
+// CommittedPower returns the total stake of the signatories whose precommits
+// are in the proof that the block at the height was committed, as returned by
+// the CommitIterator. Each signatory is counted at most once, and only
+// precommits for the committed block by members of the ValidatorSet at the
+// height are counted, so ValidatorSets that were scheduled by
+// ScheduleValidatorSet are taken into account. If no Stake is configured, every signatory has a stake of one. If the
+// BlockIterator is not a CommitIterator, or if the block at the height is not
+// known to be committed, zero is returned.
+func (replica *Replica) CommittedPower(height block.Height) uint64 {
+	commitIterator, ok := replica.blockIterator.(CommitIterator)
+	if !ok {
+		return 0
+	}
+	latestCommit, ok := commitIterator.CommitAtHeight(height, replica.shard)
+	if !ok {
+		return 0
+	}
+
+	validators := replica.validatorsAt(latestCommit.Block.Header().Height())
+	counted := map[id.Signatory]bool{}
+	power := uint64(0)
+	for _, precommit := range latestCommit.Precommits {
+		sig := precommit.Signatory()
+		if !validators.Contains(sig) || counted[sig] || !precommit.BlockHash().Equal(latestCommit.Block.Hash()) {
+			continue
+		}
+		// Count each signatory at most once
+		counted[sig] = true
+		if replica.options.Stake == nil {
+			power++
+			continue
+		}
+		power += replica.options.Stake(sig)
+	}
+	return power
+}
+
+// CommitRoundDistribution returns the number of heights that were committed at
+// each round, as proven by the precommits returned by the CommitIterator. It
+// counts the commits from the first height until the first height for which
+// there is no commit, and each commit is only counted once, so the
+// distribution is extended as more heights are committed. A heavy tail
+// towards higher rounds indicates that the timeouts are too short. If the
+// BlockIterator is not a CommitIterator, an empty distribution is returned.
+func (replica *Replica) CommitRoundDistribution() map[block.Round]int {
+	commitIterator, ok := replica.blockIterator.(CommitIterator)
+	if !ok {
+		return map[block.Round]int{}
+	}
+	return replica.commitRounds.count(commitIterator, replica.shard)
+}
+
+// Prune deletes the proposals and prevotes at heights below the given height
+// from the `process.Process`, and saves it to the ProcessStorage, so that the
+// blocks proposed at the previous height are not kept in storage after they
+// have been committed. Committed blocks are never deleted, because they are
+// needed to sync other Replicas. Messages that are needed to make progress are
+// kept (see `process.Process.Prune`). Prune waits for Messages that are being
+// handled, and is safe to call concurrently with HandleMessage. Pruning a
+// Replica that has been closed does nothing.
+func (replica *Replica) Prune(belowHeight block.Height) {
+	replica.lifecycle.mu.Lock()
+	defer replica.lifecycle.mu.Unlock()
+
+	if replica.lifecycle.closed {
+		return
+	}
+	replica.p.Prune(belowHeight)
+	replica.saveProcess()
+}
+
+// ForceRound starts a higher round at the current height of the
+// `process.Process`, and saves it to the ProcessStorage (see
+// `process.Process.ForceRound`). It returns an error if the round is not
+// higher than the current round, and ErrClosed if the Replica has been closed.
+// ForceRound waits for Messages that are being handled, and is safe to call
+// concurrently with HandleMessage.
+func (replica *Replica) ForceRound(round block.Round) error {
+	replica.lifecycle.mu.Lock()
+	defer replica.lifecycle.mu.Unlock()
+
+	if replica.lifecycle.closed {
+		return ErrClosed
+	}
+	if err := replica.p.ForceRound(round); err != nil {
+		return err
+	}
+	replica.saveProcess()
+	return nil
+}
+
+// Rebase the Replica onto a new set of Signatories. It is safe to call
+// concurrently with HandleMessage.
 func (replica *Replica) Rebase(sigs id.Signatories) {
 	replica.scheduler.rebase(sigs)
 	replica.rebaser.rebase(sigs)
 }
 
+// ProposerFairness audits the proposer schedule over the most recent window of
+// committed blocks. For each signatory, it returns the ratio of the share of
+// blocks that it proposed to the share of blocks that it was expected to
+// propose. The expected share is the same for all signatories, unless
+// proposers are selected by stake, and a fair schedule will result in ratios
+// close to 1. Blocks are attributed to the signatory that actually proposed
+// them, so only blocks that have been committed by the Replica since it was
+// started (and at most the last 1024 of them) are audited. Blocks that have
+// been synced are skipped.
+func (replica *Replica) ProposerFairness(window block.Height) map[id.Signatory]float64 {
+	expectedShares := replica.scheduler.expectedShares()
+	fairness := make(map[id.Signatory]float64, len(expectedShares))
+	if len(expectedShares) == 0 {
+		return fairness
+	}
+
+	// Count the blocks proposed by each signatory (the genesis block is not
+	// proposed, so it is excluded)
+	latestHeight := replica.blockStorage.LatestBlock(replica.shard).Header().Height()
+	proposed := map[id.Signatory]int{}
+	total := 0
+	for height := latestHeight; height > 0 && height > latestHeight-window; height-- {
+		proposer, ok := replica.proposers.at(height)
+		if !ok {
+			continue
+		}
+		proposed[proposer]++
+		total++
+	}
+
+	for sig, expectedShare := range expectedShares {
+		if total == 0 || expectedShare == 0 {
+			fairness[sig] = 0
+			continue
+		}
+		fairness[sig] = float64(proposed[sig]) / float64(total) / expectedShare
+	}
+	return fairness
+}
+
+// newScheduler returns the scheduler of proposers from the signatories that is
+// configured by the Options.
+func newScheduler(options Options, sigs id.Signatories) scheduler {
+	var scheduler scheduler = newRoundRobinScheduler(sigs)
+	if options.Stake != nil {
+		scheduler = newStakeWeightedScheduler(sigs, options.Stake)
+	}
+	if options.ProposerOverride != nil {
+		scheduler = newOverriddenScheduler(scheduler, options.ProposerOverride)
+	}
+	return scheduler
+}
+
+// baseBlockCache caches the ValidatorSet of the latest base block, so that it
+// is only rebuilt when a new base block is detected. It is safe for concurrent
+// use, because Messages are handled concurrently.
 type baseBlockCache struct {
+	mu                  *sync.Mutex
 	lastBaseBlockHeight block.Height
 	lastBaseBlockHash   id.Hash
-	lastBaseBlockSigs   id.Signatories
-	sigsCache           map[id.Signatory]bool
+	validators          ValidatorSet
 }
 
-func newBaseBlockCache(baseBlock block.Block) baseBlockCache {
-	cache := baseBlockCache{
+func newBaseBlockCache(baseBlock block.Block) *baseBlockCache {
+	cache := &baseBlockCache{
+		mu:                  new(sync.Mutex),
 		lastBaseBlockHeight: -1,
-		sigsCache:           map[id.Signatory]bool{},
+		validators:          NewValidatorSet(nil),
 	}
 	cache.fillBaseBlock(baseBlock)
 	return cache
 }
 
+// validatorsOf returns the ValidatorSet of the base block, filling the cache
+// if the base block is newer than the cached one.
+func (cache *baseBlockCache) validatorsOf(baseBlock block.Block) ValidatorSet {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.fillBaseBlock(baseBlock)
+	return cache.validators
+}
+
+// fillBaseBlock must only be called while holding the mutex, or before the
+// cache is shared.
 func (cache *baseBlockCache) fillBaseBlock(baseBlock block.Block) {
 	if baseBlock.Header().Height() <= cache.lastBaseBlockHeight {
 		return
@@ -192,21 +1207,5 @@ func (cache *baseBlockCache) fillBaseBlock(baseBlock block.Block) {
 	}
 	cache.lastBaseBlockHeight = baseBlock.Header().Height()
 	cache.lastBaseBlockHash = baseBlock.Hash()
-	cache.lastBaseBlockSigs = baseBlock.Header().Signatories()
-	cache.sigsCache = map[id.Signatory]bool{}
-}
-
-func (cache *baseBlockCache) signatoryInBaseBlock(sig id.Signatory) bool {
-	inBaseBlock, ok := cache.sigsCache[sig]
-	if ok {
-		return inBaseBlock
-	}
-	for _, baseBlockSig := range cache.lastBaseBlockSigs {
-		if baseBlockSig.Equal(sig) {
-			cache.sigsCache[sig] = true
-			return true
-		}
-	}
-	cache.sigsCache[sig] = false
-	return false
+	cache.validators = NewValidatorSet(baseBlock.Header().Signatories())
 }
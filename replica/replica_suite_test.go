@@ -75,6 +75,15 @@ func (m mockBlockIterator) NextBlock(kind block.Kind, height block.Height, shard
 	return RandomBytesSlice(), RandomBytesSlice(), RandomBytesSlice()
 }
 
+// emptyBlockIterator has no pending transactions, so it only produces empty
+// blocks.
+type emptyBlockIterator struct {
+}
+
+func (m emptyBlockIterator) NextBlock(kind block.Kind, height block.Height, shard Shard) (block.Txs, block.Plan, block.State) {
+	return nil, RandomBytesSlice(), RandomBytesSlice()
+}
+
 type mockValidator struct {
 	valid error
 }
@@ -87,6 +96,19 @@ func newMockValidator(valid error) Validator {
 	return mockValidator{valid: valid}
 }
 
+// panickingValidator panics while validating blocks, as if it had been given a
+// block that it cannot handle.
+type panickingValidator struct {
+	panicking bool
+}
+
+func (m *panickingValidator) IsBlockValid(block.Block, bool, Shard) (process.NilReasons, error) {
+	if m.panicking {
+		panic("malformed block")
+	}
+	return nil, nil
+}
+
 type mockObserver struct {
 }
 
@@ -101,6 +123,10 @@ func (m mockObserver) IsSignatory(Shard) bool {
 }
 func (m mockObserver) DidReceiveSufficientNilPrevotes(process.Messages, int) {
 }
+func (m mockObserver) DidBecomeProposer(block.Height, block.Round, Shard) {
+}
+func (m mockObserver) DidFailToCommitBlock(block.Height, Shard, error) {
+}
 
 type mockProcessStorage struct {
 }
@@ -110,3 +136,18 @@ func (m mockProcessStorage) SaveProcess(p *process.Process, shard Shard) {
 
 func (m mockProcessStorage) RestoreProcess(p *process.Process, shard Shard) {
 }
+
+// impersonatingSigner signs with an underlying `process.Signer`, but claims to
+// be a different `id.Signatory`.
+type impersonatingSigner struct {
+	signatory id.Signatory
+	signer    process.Signer
+}
+
+func (signer impersonatingSigner) Signatory() id.Signatory {
+	return signer.signatory
+}
+
+func (signer impersonatingSigner) Sign(hash []byte) ([]byte, error) {
+	return signer.signer.Sign(hash)
+}
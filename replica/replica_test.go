@@ -8,15 +8,21 @@ import (
 	"io/ioutil"
 	"reflect"
 	"testing/quick"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/renproject/hyperdrive/testutil"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/hyperdrive/testutil"
+	"github.com/renproject/id"
 	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
 )
 
 var _ = Describe("Replica", func() {
@@ -88,7 +94,8 @@ var _ = Describe("Replica", func() {
 					store, _, keys := initStorage(shard)
 					pstore := mockProcessStorage{}
 					broadcaster, _ := newMockBroadcaster()
-					replica := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
 
 					pMessage := RandomMessage(process.ProposeMessageType)
 					key := keys[0]
@@ -97,7 +104,7 @@ var _ = Describe("Replica", func() {
 						Shard:   shard,
 						Message: pMessage,
 					}
-					replica.HandleMessage(message)
+					Expect(replica.HandleMessage(message)).Should(Succeed())
 
 					// Expect the message not been inserted into the specific inbox,
 					// which indicating the message not passed to the process.
@@ -116,7 +123,8 @@ var _ = Describe("Replica", func() {
 					store, _, _ := initStorage(shard)
 					pstore := mockProcessStorage{}
 					broadcaster, _ := newMockBroadcaster()
-					replica := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
 					logger := logrus.StandardLogger()
 					logger.SetOutput(ioutil.Discard)
 					replica.options.Logger = logger
@@ -126,7 +134,7 @@ var _ = Describe("Replica", func() {
 						Shard:   wrongShard,
 						Message: pMessage,
 					}
-					replica.HandleMessage(message)
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrWrongShard))
 
 					// Expect the message not been inserted into the specific inbox,
 					// which indicating the message not passed to the process.
@@ -145,14 +153,15 @@ var _ = Describe("Replica", func() {
 					store, _, _ := initStorage(shard)
 					pstore := mockProcessStorage{}
 					broadcaster, _ := newMockBroadcaster()
-					replica := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
 
 					pMessage := RandomSignedMessage(process.ProposeMessageType)
 					message := Message{
 						Shard:   shard,
 						Message: pMessage,
 					}
-					replica.HandleMessage(message)
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrInvalidSignatory))
 
 					// Expect the message not been inserted into the specific inbox,
 					// which indicating the message not passed to the process.
@@ -165,10 +174,1313 @@ var _ = Describe("Replica", func() {
 
 				Expect(quick.Check(test, nil)).Should(Succeed())
 			})
+
+			It("should reject message whose signature is not valid", func() {
+				test := func(shard Shard) bool {
+					store, _, keys := initStorage(shard)
+					pstore := mockProcessStorage{}
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+					logger := logrus.StandardLogger()
+					logger.SetOutput(ioutil.Discard)
+					replica.options.Logger = logger
+
+					// Sign the message with one key, but claim to be another
+					// signatory of the shard
+					pMessage := RandomMessage(process.ProposeMessageType)
+					signer := impersonatingSigner{
+						signatory: id.NewSignatory(keys[1].PublicKey),
+						signer:    process.NewECDSASigner(*keys[0]),
+					}
+					Expect(process.SignWith(pMessage, signer)).Should(Succeed())
+					message := Message{
+						Shard:   shard,
+						Message: pMessage,
+					}
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrInvalidSignature))
+
+					state := testutil.GetStateFromProcess(replica.p, 2)
+					stored := state.Proposals.QueryByHeightRoundSignatory(pMessage.Height(), pMessage.Round(), pMessage.Signatory())
+					Expect(stored).Should(BeNil())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should reject message from a height lower than the current height", func() {
+				test := func(shard Shard) bool {
+					store, _, keys := initStorage(shard)
+					pstore := mockProcessStorage{}
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+
+					pMessage := RandomMessageWithHeightAndRound(0, RandomRound(), process.PrevoteMessageType)
+					Expect(process.Sign(pMessage, *keys[0])).Should(Succeed())
+					message := Message{
+						Shard:   shard,
+						Message: pMessage,
+					}
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrStaleHeight))
+
+					state := testutil.GetStateFromProcess(replica.p, 2)
+					stored := state.Prevotes.QueryByHeightRoundSignatory(pMessage.Height(), pMessage.Round(), pMessage.Signatory())
+					Expect(stored).Should(BeNil())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should reject a prevote from two heights below the current height, but accept one at the current height", func() {
+				test := func(shard Shard) bool {
+					store, keys := initGenesisStorage(shard)
+					iter := newMockCommitIterator(store, shard, keys, 3, 5)
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+					_, err = replica.Sync(0, 3)
+					Expect(err).ToNot(HaveOccurred())
+					height := replica.CurrentHeight()
+					Expect(height).Should(Equal(block.Height(4)))
+
+					stale := process.NewPrevote(height-2, 0, RandomHash(), nil)
+					Expect(process.Sign(stale, *keys[0])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: stale})).Should(Equal(ErrStaleHeight))
+
+					current := process.NewPrevote(height, 0, RandomHash(), nil)
+					Expect(process.Sign(current, *keys[0])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: current})).Should(Succeed())
+
+					state := testutil.GetStateFromProcess(replica.p, 2)
+					Expect(state.Prevotes.QueryByHeightRoundSignatory(height-2, 0, stale.Signatory())).Should(BeNil())
+					Expect(state.Prevotes.QueryByHeightRoundSignatory(height, 0, current.Signatory())).ShouldNot(BeNil())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should reject message from a round too far ahead of the current round", func() {
+				test := func(shard Shard) bool {
+					store, _, keys := initStorage(shard)
+					pstore := mockProcessStorage{}
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+					height, round := replica.p.CurrentHeight(), replica.p.CurrentRound()
+
+					// Expect a message 100 rounds ahead to be rejected
+					pMessage := RandomMessageWithHeightAndRound(height, round+100, process.PrevoteMessageType)
+					Expect(process.Sign(pMessage, *keys[0])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: pMessage})).Should(Equal(ErrFutureRound))
+
+					state := testutil.GetStateFromProcess(replica.p, 2)
+					stored := state.Prevotes.QueryByHeightRoundSignatory(pMessage.Height(), pMessage.Round(), pMessage.Signatory())
+					Expect(stored).Should(BeNil())
+
+					// Expect a message 1 round ahead to be buffered
+					pMessage = RandomMessageWithHeightAndRound(height, round+1, process.PrevoteMessageType)
+					Expect(process.Sign(pMessage, *keys[0])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: pMessage})).Should(Succeed())
+
+					state = testutil.GetStateFromProcess(replica.p, 2)
+					stored = state.Prevotes.QueryByHeightRoundSignatory(pMessage.Height(), pMessage.Round(), pMessage.Signatory())
+					Expect(stored).ShouldNot(BeNil())
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+
+			It("should reject message replayed from an earlier epoch", func() {
+				test := func(shard Shard) bool {
+					store, _, keys := initStorage(shard)
+					pstore := mockProcessStorage{}
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{Epoch: 2}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+					height, round := replica.p.CurrentHeight(), replica.p.CurrentRound()
+
+					// Expect a message from the previous epoch to be rejected,
+					// after being replayed over the wire
+					pMessage := RandomMessageWithHeightAndRound(height, round, process.PrevoteMessageType)
+					Expect(process.Sign(pMessage, *keys[0])).Should(Succeed())
+					data, err := Message{Shard: shard, Message: pMessage, Epoch: 1}.MarshalBinary()
+					Expect(err).NotTo(HaveOccurred())
+					replayed := Message{}
+					Expect(replayed.UnmarshalBinary(data)).Should(Succeed())
+					Expect(replica.HandleMessage(replayed)).Should(Equal(ErrStaleEpoch))
+
+					state := testutil.GetStateFromProcess(replica.p, 2)
+					stored := state.Prevotes.QueryByHeightRoundSignatory(pMessage.Height(), pMessage.Round(), pMessage.Signatory())
+					Expect(stored).Should(BeNil())
+
+					// Expect messages from the current and later epochs to be
+					// accepted
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: pMessage, Epoch: 2})).Should(Succeed())
+					pMessage = RandomMessageWithHeightAndRound(height, round, process.PrevoteMessageType)
+					Expect(process.Sign(pMessage, *keys[1])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: pMessage, Epoch: 3})).Should(Succeed())
+
+					return true
+				}
+
+				Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+			})
+
+			It("should reject message that has already been received", func() {
+				test := func(shard Shard) bool {
+					store, _, keys := initStorage(shard)
+					pstore := mockProcessStorage{}
+					broadcaster, _ := newMockBroadcaster()
+					replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+
+					messageType := RandomMessageType()
+					pMessage := RandomMessage(messageType)
+					Expect(process.Sign(pMessage, *keys[0])).Should(Succeed())
+					message := Message{
+						Shard:   shard,
+						Message: pMessage,
+					}
+					Expect(replica.HandleMessage(message)).Should(Succeed())
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrDuplicate))
+
+					// Expect a different message from the same signatory at the
+					// same height and round to be rejected too
+					duplicate := RandomMessageWithHeightAndRound(pMessage.Height(), pMessage.Round(), messageType)
+					Expect(process.Sign(duplicate, *keys[0])).Should(Succeed())
+					message = Message{
+						Shard:   shard,
+						Message: duplicate,
+					}
+					Expect(replica.HandleMessage(message)).Should(Equal(ErrDuplicate))
+
+					return true
+				}
+
+				Expect(quick.Check(test, nil)).Should(Succeed())
+			})
+		})
+	})
+
+	Context("when metrics are enabled", func() {
+		It("should advance the height gauge as blocks are committed", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				registry := prometheus.NewRegistry()
+				replica, err := New(Options{Registerer: registry}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				numCommits := 3
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					// Propose a block on behalf of the scheduled proposer
+					proposer := keys[int(height)%len(keys)]
+					proposedBlock := replica.rebaser.BlockProposal(height, 0)
+					propose := process.NewPropose(height, 0, proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					// Precommit the block on behalf of 2f+1 signatories
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+				}
+
+				Expect(promtestutil.ToFloat64(replica.metrics.Height)).Should(Equal(float64(numCommits + 1)))
+				Expect(promtestutil.ToFloat64(replica.metrics.CommittedBlocks)).Should(Equal(float64(numCommits)))
+
+				// Expect rejected messages to be counted by reason
+				pMessage := RandomSignedMessage(process.PrevoteMessageType)
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: pMessage})).Should(Equal(ErrInvalidSignatory))
+				Expect(promtestutil.ToFloat64(replica.metrics.RejectedMessages.WithLabelValues("invalid_signatory"))).Should(Equal(1.0))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should observe the time between commits using the clock", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			clock := NewMockClock(time.Now().Add(-time.Hour))
+			registry := prometheus.NewRegistry()
+			replica, err := New(Options{Registerer: registry, Clock: clock}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			for height := block.Height(1); height <= 3; height++ {
+				commitAt(&replica, height, keys[int(height)%len(keys)], keys[1:6])
+				clock.Advance(10 * time.Second)
+			}
+
+			families, err := registry.Gather()
+			Expect(err).ShouldNot(HaveOccurred())
+			found := false
+			for _, family := range families {
+				if family.GetName() != "hyperdrive_time_between_commits_seconds" {
+					continue
+				}
+				histogram := family.GetMetric()[0].GetHistogram()
+				Expect(histogram.GetSampleCount()).Should(Equal(uint64(2)))
+				Expect(histogram.GetSampleSum()).Should(Equal(20.0))
+				found = true
+			}
+			Expect(found).Should(BeTrue())
+		})
+	})
+
+	Context("when handling a message panics", func() {
+		It("should recover, log the panic, and keep handling messages", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			logger, hook := logrustest.NewNullLogger()
+			registry := prometheus.NewRegistry()
+			validator := &panickingValidator{panicking: true}
+			replica, err := New(Options{Logger: logger, Registerer: registry}, mockProcessStorage{}, store, mockBlockIterator{}, validator, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			// Panic while validating the proposed block
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			hook.Reset()
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Equal(ErrPanicked))
+
+			entry := hook.LastEntry()
+			Expect(entry).ShouldNot(BeNil())
+			Expect(entry.Level).Should(Equal(logrus.ErrorLevel))
+			Expect(entry.Message).Should(ContainSubstring("recovered from panic: malformed block"))
+			Expect(entry.Data).Should(HaveKeyWithValue("type", process.MessageType(process.ProposeMessageType)))
+			Expect(entry.Data).Should(HaveKeyWithValue("height", block.Height(1)))
+			Expect(entry.Data).Should(HaveKeyWithValue("round", block.Round(0)))
+			Expect(promtestutil.ToFloat64(replica.metrics.RecoveredPanics)).Should(Equal(1.0))
+
+			// Expect the replica to keep handling messages, and commit the
+			// proposed block
+			validator.panicking = false
+			for _, key := range keys[1:6] {
+				precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+		})
+	})
+
+	Context("when inspecting the process state", func() {
+		It("should count the messages that have been handled", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			for _, key := range keys[1:4] {
+				prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+
+			state := replica.ProcessState()
+			Expect(state.CurrentHeight).Should(Equal(block.Height(1)))
+			Expect(state.CurrentStep).Should(Equal(process.StepPrevote))
+			Expect(state.Proposals[1][0]).Should(Equal(1))
+			Expect(state.Prevotes[1][0]).Should(Equal(3))
+			Expect(state.Precommits).Should(BeEmpty())
+		})
+	})
+
+	Context("when asking for the current proposer", func() {
+		It("should rotate the proposer as rounds advance", func() {
+			test := func(shard Shard, index uint8) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+				key := keys[int(index)%len(keys)]
+				replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *key)
+				Expect(err).NotTo(HaveOccurred())
+
+				sigs := store.LatestBaseBlock(shard).Header().Signatories()
+				for round := block.Round(0); round < block.Round(2*len(sigs)); round++ {
+					replica.p.StartRound(round)
+					expected := sigs[(1+int(round))%len(sigs)]
+					Expect(replica.Proposer().Equal(expected)).Should(BeTrue())
+					Expect(replica.IsProposing()).Should(Equal(expected.Equal(id.NewSignatory(key.PublicKey))))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should update the proposer when skipping rounds", func() {
+			test := func(shard Shard, skip uint8) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+				// Allow skipping to any round that fits in a uint8
+				options := Options{MaxFutureRounds: 256}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+				sigs := store.LatestBaseBlock(shard).Header().Signatories()
+				Expect(replica.Proposer().Equal(sigs[1])).Should(BeTrue())
+
+				// Skip to a future round by sending f+1 prevotes
+				round := block.Round(skip) + 1
+				for _, key := range keys[:3] {
+					prevote := process.NewPrevote(1, round, block.InvalidHash, nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				}
+				Expect(replica.p.CurrentRound()).Should(Equal(round))
+				Expect(replica.Proposer().Equal(sigs[(1+int(round))%len(sigs)])).Should(BeTrue())
+				Expect(replica.IsProposing()).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when proposing on trigger", func() {
+		It("should only propose when the proposer is triggered", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				options := Options{ProposeOnTrigger: true}
+
+				// The proposer at the first height and round is the second key
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+				replica.Start()
+				Expect(replica.TriggerPropose()).Should(BeFalse())
+				Consistently(messages, 100*time.Millisecond).ShouldNot(Receive())
+
+				broadcaster, messages = newMockBroadcaster()
+				replica, err = New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[1])
+				Expect(err).NotTo(HaveOccurred())
+				replica.Start()
+				Consistently(messages, 100*time.Millisecond).ShouldNot(Receive())
+				Expect(replica.TriggerPropose()).Should(BeTrue())
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				propose, ok := message.Message.(*process.Propose)
+				Expect(ok).Should(BeTrue())
+				Expect(propose.Height()).Should(Equal(block.Height(1)))
+				Expect(propose.Round()).Should(Equal(block.Round(0)))
+				Expect(propose.Signatory()).Should(Equal(id.NewSignatory(keys[1].PublicKey)))
+
+				// Expect the proposer to only propose once per round
+				Expect(replica.TriggerPropose()).Should(BeFalse())
+				Consistently(messages, 100*time.Millisecond).ShouldNot(Receive())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should propose as soon as the round starts unless the option is set", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[1])
+				Expect(err).NotTo(HaveOccurred())
+				replica.Start()
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				_, ok := message.Message.(*process.Propose)
+				Expect(ok).Should(BeTrue())
+				Expect(replica.TriggerPropose()).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the maximum number of txs per block is set", func() {
+		It("should prevote nil for proposals with too many txs", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				options := Options{
+					TxCounter:      mockTxCounter{},
+					MaxTxsPerBlock: 10,
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				// Propose a block with one too many txs on behalf of the
+				// scheduled proposer
+				genesis := store.LatestBaseBlock(shard)
+				txs := make(block.Txs, 11)
+				header := block.NewHeader(block.Standard, genesis.Hash(), genesis.Hash(), txs.Hash(), block.Plan{}.Hash(), block.State{}.Hash(), 1, 0, block.Timestamp(time.Now().Unix()), nil)
+				proposedBlock := block.New(header, txs, nil, nil)
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				prevote, ok := message.Message.(*process.Prevote)
+				Expect(ok).Should(BeTrue())
+				Expect(prevote.Height()).Should(Equal(block.Height(1)))
+				Expect(prevote.BlockHash().Equal(block.InvalidHash)).Should(BeTrue())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should commit proposals with too many txs if they are precommitted by the shard", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, _ := newMockBroadcaster()
+				options := Options{
+					TxCounter:      mockTxCounter{},
+					MaxTxsPerBlock: 10,
+				}
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				genesis := store.LatestBaseBlock(shard)
+				txs := make(block.Txs, 11)
+				header := block.NewHeader(block.Standard, genesis.Hash(), genesis.Hash(), txs.Hash(), block.Plan{}.Hash(), block.State{}.Hash(), 1, 0, block.Timestamp(time.Now().Unix()), nil)
+				proposedBlock := block.New(header, txs, nil, nil)
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				// Expect the block to be committed once 2f+1 precommits have
+				// been received for it, even though the Replica prevoted nil
+				for _, key := range keys[1:6] {
+					precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+				}
+				Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+				Expect(store.LatestBlock(shard).Hash()).Should(Equal(proposedBlock.Hash()))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the maximum block size is set", func() {
+		It("should prevote nil for proposals that are too large, and prevote for others", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				genesis := store.LatestBaseBlock(shard)
+				newBlock := func(numTxBytes int) block.Block {
+					txs := make(block.Txs, numTxBytes)
+					header := block.NewHeader(block.Standard, genesis.Hash(), genesis.Hash(), txs.Hash(), block.Plan{}.Hash(), block.State{}.Hash(), 1, 0, block.Timestamp(time.Now().Unix()), nil)
+					return block.New(header, txs, nil, nil)
+				}
+
+				// Allow blocks that are exactly as large as the compliant block
+				compliantBlock := newBlock(100)
+				data, err := compliantBlock.MarshalBinary()
+				Expect(err).NotTo(HaveOccurred())
+				options := Options{MaxBlockSize: len(data)}
+
+				oversizedBlock := newBlock(101)
+
+				// Expect the limit to be ignored if it is zero
+				cases := []struct {
+					options       Options
+					proposedBlock block.Block
+					valid         bool
+				}{
+					{options, oversizedBlock, false},
+					{options, compliantBlock, true},
+					{Options{}, oversizedBlock, true},
+				}
+				for _, c := range cases {
+					broadcaster, messages := newMockBroadcaster()
+					replica, err := New(c.options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+
+					propose := process.NewPropose(1, 0, c.proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					var message Message
+					Eventually(messages).Should(Receive(&message))
+					prevote, ok := message.Message.(*process.Prevote)
+					Expect(ok).Should(BeTrue())
+					if c.valid {
+						Expect(prevote.BlockHash()).Should(Equal(c.proposedBlock.Hash()))
+					} else {
+						Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+					}
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should sync committed blocks that are too large", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 5, 5)
+				broadcaster, _ := newMockBroadcaster()
+				replica, err := New(Options{MaxBlockSize: 1}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				synced, err := replica.Sync(0, 5)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(synced).Should(Equal(block.Height(5)))
+				Expect(replica.CurrentHeight()).Should(Equal(block.Height(6)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a proposed block does not match its txs ref", func() {
+		It("should prevote nil for the mismatched block, and prevote for others", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				genesis := store.LatestBaseBlock(shard)
+				newBlock := func(txsRef id.Hash, txs block.Txs) block.Block {
+					header := block.NewHeader(block.Standard, genesis.Hash(), genesis.Hash(), txsRef, block.Plan{}.Hash(), block.State{}.Hash(), 1, 0, block.Timestamp(time.Now().Unix()), nil)
+					return block.New(header, txs, nil, nil)
+				}
+
+				// Declare the txs ref of one set of txs, but include another
+				txs := block.Txs(RandomBytesSlice())
+				cases := []struct {
+					proposedBlock block.Block
+					valid         bool
+				}{
+					{newBlock(txs.Hash(), txs), true},
+					{newBlock(txs.Hash(), append(block.Txs{0}, txs...)), false},
+				}
+				for _, c := range cases {
+					broadcaster, messages := newMockBroadcaster()
+					replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+					Expect(err).NotTo(HaveOccurred())
+
+					propose := process.NewPropose(1, 0, c.proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					var message Message
+					Eventually(messages).Should(Receive(&message))
+					prevote, ok := message.Message.(*process.Prevote)
+					Expect(ok).Should(BeTrue())
+					if c.valid {
+						Expect(prevote.BlockHash()).Should(Equal(c.proposedBlock.Hash()))
+					} else {
+						Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+					}
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when there are no pending transactions", func() {
+		It("should commit an empty block through the prevote and precommit steps", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(Options{}, pstore, store, emptyBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				// Propose an empty block on behalf of the scheduled proposer
+				proposedBlock := replica.rebaser.BlockProposal(1, 0)
+				Expect(proposedBlock.IsEmpty()).Should(BeTrue())
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				// Expect the empty block to be prevoted, instead of nil
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				prevote, ok := message.Message.(*process.Prevote)
+				Expect(ok).Should(BeTrue())
+				Expect(prevote.BlockHash()).Should(Equal(proposedBlock.Hash()))
+
+				// Expect the empty block to be precommitted after a polka
+				for _, key := range keys[1:6] {
+					prevote := process.NewPrevote(1, 0, proposedBlock.Hash(), nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				}
+				Eventually(messages).Should(Receive(&message))
+				precommit, ok := message.Message.(*process.Precommit)
+				Expect(ok).Should(BeTrue())
+				Expect(precommit.BlockHash()).Should(Equal(proposedBlock.Hash()))
+
+				// Expect the empty block to be committed after 2f+1 precommits
+				for _, key := range keys[1:6] {
+					precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+				}
+				committedBlock, ok := store.Blockchain(shard).BlockAtHeight(1)
+				Expect(ok).Should(BeTrue())
+				Expect(committedBlock.Hash()).Should(Equal(proposedBlock.Hash()))
+				Expect(committedBlock.IsEmpty()).Should(BeTrue())
+				Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when skipping offline proposers", func() {
+		// commitFirstHeight commits a block at the first height, with
+		// precommits from every signatory except the proposer of the second
+		// height, and returns a channel of the nil prevotes broadcast at the
+		// second height.
+		commitFirstHeight := func(options Options, shard Shard) <-chan *process.Prevote {
+			store, keys := initGenesisStorage(shard)
+			pstore := mockProcessStorage{}
+			broadcaster, messages := newMockBroadcaster()
+			nilPrevotes := make(chan *process.Prevote, 1)
+			go func() {
+				for message := range messages {
+					if prevote, ok := message.Message.(*process.Prevote); ok && prevote.Height() == 2 && prevote.BlockHash().Equal(block.InvalidHash) {
+						nilPrevotes <- prevote
+					}
+				}
+			}()
+			replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+			for i, key := range keys[:6] {
+				if i == 2 {
+					continue
+				}
+				precommit := process.NewPrecommit(1, 0, proposedBlock.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+			}
+			Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(2)))
+			Expect(replica.Proposer().Equal(id.NewSignatory(keys[2].PublicKey))).Should(BeTrue())
+			return nilPrevotes
+		}
+
+		It("should prevote nil without waiting for the propose timeout", func() {
+			test := func(shard Shard) bool {
+				options := Options{
+					SkipOfflineProposers: true,
+					OfflineWindow:        1,
+				}
+				nilPrevotes := commitFirstHeight(options, shard)
+
+				var prevote *process.Prevote
+				Eventually(nilPrevotes, time.Second).Should(Receive(&prevote))
+				Expect(prevote.Round()).Should(Equal(block.Round(0)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should wait for the propose timeout unless the option is set", func() {
+			test := func(shard Shard) bool {
+				nilPrevotes := commitFirstHeight(Options{}, shard)
+				Consistently(nilPrevotes, 100*time.Millisecond).ShouldNot(Receive())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when a commit callback is set", func() {
+		It("should call it once per committed height, in order", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				committed := []block.Block{}
+				options := Options{
+					OnCommit: func(committedBlock block.Block) {
+						committed = append(committed, committedBlock)
+					},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				numCommits := 3
+				for height := block.Height(1); height <= block.Height(numCommits); height++ {
+					// Skip the first round by precommitting nil on behalf of
+					// 2f+1 signatories
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 0, block.InvalidHash)
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+					Expect(committed).Should(HaveLen(int(height) - 1))
+
+					// Propose a block on behalf of the scheduled proposer
+					proposer := keys[(int(height)+1)%len(keys)]
+					proposedBlock := replica.rebaser.BlockProposal(height, 1)
+					propose := process.NewPropose(height, 1, proposedBlock, block.InvalidRound)
+					Expect(process.Sign(propose, *proposer)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+					// Precommit the block on behalf of 2f+1 signatories
+					for _, key := range keys[:5] {
+						precommit := process.NewPrecommit(height, 1, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						Expect(replica.HandleMessage(Message{Shard: shard, Message: precommit})).Should(Succeed())
+					}
+
+					// Redundant precommits must not commit the block again
+					for _, key := range keys[5:] {
+						precommit := process.NewPrecommit(height, 1, proposedBlock.Hash())
+						Expect(process.Sign(precommit, *key)).Should(Succeed())
+						replica.HandleMessage(Message{Shard: shard, Message: precommit})
+					}
+					replica.rebaser.DidCommitBlock(height)
+				}
+
+				Expect(committed).Should(HaveLen(numCommits))
+				for i, committedBlock := range committed {
+					Expect(committedBlock.Header().Height()).Should(Equal(block.Height(i + 1)))
+					storedBlock, ok := store.Blockchain(shard).BlockAtHeight(block.Height(i + 1))
+					Expect(ok).Should(BeTrue())
+					Expect(committedBlock.Equal(storedBlock)).Should(BeTrue())
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when logging at the debug level", func() {
+		It("should log the fields of every transition", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+
+				logger, hook := logrustest.NewNullLogger()
+				logger.SetLevel(logrus.DebugLevel)
+				replica, err := New(Options{Logger: logger}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				// Propose a block on behalf of the scheduled proposer, and
+				// expect the replica to prevote for it
+				proposedBlock := replica.rebaser.BlockProposal(1, 0)
+				propose := process.NewPropose(1, 0, proposedBlock, block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				hook.Reset()
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: propose})).Should(Succeed())
+
+				entry := hook.LastEntry()
+				Expect(entry).ShouldNot(BeNil())
+				Expect(entry.Level).Should(Equal(logrus.DebugLevel))
+				Expect(entry.Data).Should(HaveKeyWithValue("shard", shard))
+				Expect(entry.Data).Should(HaveKeyWithValue("height", block.Height(1)))
+				Expect(entry.Data).Should(HaveKeyWithValue("round", block.Round(0)))
+				Expect(entry.Data).Should(HaveKeyWithValue("from", process.StepPropose))
+				Expect(entry.Data).Should(HaveKeyWithValue("to", process.StepPrevote))
+				Expect(entry.Data).Should(HaveKeyWithValue("transition", process.ProposedTransitionType))
+				Expect(entry.Data).Should(HaveKeyWithValue("action", process.MessageType(process.PrevoteMessageType)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should not log transitions above the debug level", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+
+			logger, hook := logrustest.NewNullLogger()
+			logger.SetLevel(logrus.InfoLevel)
+			replica, err := New(Options{Logger: logger}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			prevote := process.NewPrevote(1, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[0])).Should(Succeed())
+			hook.Reset()
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			for _, entry := range hook.AllEntries() {
+				Expect(entry.Data).ShouldNot(HaveKey("transition"))
+			}
+		})
+	})
+
+	Context("when auditing the proposer fairness", func() {
+		It("should return ratios of 1 for a round robin schedule", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			// Commit two blocks per signatory at round 0
+			window := block.Height(2 * len(keys))
+			for height := block.Height(1); height <= window; height++ {
+				commitAt(&replica, height, keys[int(height)%len(keys)], keys[1:6])
+			}
+
+			fairness := replica.ProposerFairness(window)
+			Expect(fairness).Should(HaveLen(len(keys)))
+			for _, ratio := range fairness {
+				Expect(ratio).Should(BeNumerically("~", 1.0, 1e-9))
+			}
+		})
+
+		It("should attribute blocks to the signatories that proposed them, even after a rebase", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			// Commit blocks proposed by the first three scheduled signatories,
+			// and then rebase onto a schedule that would have selected other
+			// signatories at the same heights
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			window := block.Height(3)
+			for height := block.Height(1); height <= window; height++ {
+				commitAt(&replica, height, keys[height], keys[1:6])
+			}
+			reversed := make(id.Signatories, len(sigs))
+			for i, sig := range sigs {
+				reversed[len(sigs)-1-i] = sig
+			}
+			replica.Rebase(reversed)
+
+			// Expect the signatories that proposed to have proposed more than
+			// their share, and the others to have proposed nothing
+			fairness := replica.ProposerFairness(window)
+			Expect(fairness).Should(HaveLen(len(sigs)))
+			for i, sig := range sigs {
+				if i >= 1 && i <= int(window) {
+					Expect(fairness[sig]).Should(BeNumerically("~", float64(len(sigs))/float64(window), 1e-9))
+				} else {
+					Expect(fairness[sig]).Should(BeZero())
+				}
+			}
+		})
+
+		It("should expect signatories to propose in proportion to their stake", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+
+			// Give all of the stake to one signatory
+			staker := store.LatestBaseBlock(Shard{}).Header().Signatories()[0]
+			options := Options{
+				Stake: func(sig id.Signatory) uint64 {
+					if sig.Equal(staker) {
+						return 1
+					}
+					return 0
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			window := block.Height(10)
+			for height := block.Height(1); height <= window; height++ {
+				Expect(replica.scheduler.Schedule(height, 0).Equal(staker)).Should(BeTrue())
+				commitAt(&replica, height, keys[0], keys[1:6])
+			}
+
+			fairness := replica.ProposerFairness(window)
+			for sig, ratio := range fairness {
+				if sig.Equal(staker) {
+					Expect(ratio).Should(BeNumerically("~", 1.0, 1e-9))
+				} else {
+					Expect(ratio).Should(BeZero())
+				}
+			}
+		})
+
+		It("should skip blocks that were not committed by the replica", func() {
+			store, initHeight, _ := initStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			fairness := replica.ProposerFairness(initHeight)
+			for _, ratio := range fairness {
+				Expect(ratio).Should(BeZero())
+			}
+		})
+	})
+
+	Context("when validating options", func() {
+		It("should accept a consensus threshold that is consistent with the signatories", func() {
+			for _, n := range []int{1, 4, 7, 10} {
+				sigs := make(id.Signatories, 0, n)
+				for i := 0; i < n; i++ {
+					sigs = append(sigs, RandomSignatory())
+				}
+				Expect(Options{}.Validate(sigs)).Should(Succeed())
+				Expect(Options{ConsensusThreshold: block.ConsensusThreshold(n)}.Validate(sigs)).Should(Succeed())
+			}
+		})
+
+		It("should reject a consensus threshold that is inconsistent with the signatories", func() {
+			store, keys := initGenesisStorage(Shard{})
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			Expect(Options{ConsensusThreshold: 4}.Validate(sigs)).ShouldNot(Succeed())
+			Expect(Options{ConsensusThreshold: 8}.Validate(sigs)).ShouldNot(Succeed())
+
+			broadcaster, _ := newMockBroadcaster()
+			_, err := New(Options{ConsensusThreshold: 4}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept a consensus threshold above 2f+1, up to the number of signatories", func() {
+			store, keys := initGenesisStorage(Shard{})
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			for threshold := 5; threshold <= 7; threshold++ {
+				Expect(Options{ConsensusThreshold: threshold}.Validate(sigs)).Should(Succeed())
+			}
+
+			broadcaster, _ := newMockBroadcaster()
+			_, err := New(Options{ConsensusThreshold: 7}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reject a maximum number of txs per block without a tx counter", func() {
+			store, keys := initGenesisStorage(Shard{})
+			sigs := store.LatestBaseBlock(Shard{}).Header().Signatories()
+			Expect(Options{MaxTxsPerBlock: 10}.Validate(sigs)).ShouldNot(Succeed())
+			Expect(Options{MaxTxsPerBlock: 10, TxCounter: newBlockTxCounter()}.Validate(sigs)).ShouldNot(Succeed())
+			Expect(Options{MaxTxsPerBlock: 10, TxCounter: mockTxCounter{}}.Validate(sigs)).Should(Succeed())
+
+			broadcaster, _ := newMockBroadcaster()
+			_, err := New(Options{MaxTxsPerBlock: 10}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept signatories that are not 3f+1", func() {
+			for _, n := range []int{5, 6, 8, 9} {
+				sigs := make(id.Signatories, 0, n)
+				for i := 0; i < n; i++ {
+					sigs = append(sigs, RandomSignatory())
+				}
+				Expect(Options{}.Validate(sigs)).Should(Succeed())
+				Expect(Options{ConsensusThreshold: block.ConsensusThreshold(n)}.Validate(sigs)).Should(Succeed())
+				Expect(Options{ConsensusThreshold: block.ConsensusThreshold(n) - 1}.Validate(sigs)).ShouldNot(Succeed())
+			}
+		})
+	})
+
+	Context("when committing many heights", func() {
+		It("should only store the messages at the current and previous heights", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			pstore := newMemoryProcessStorage()
+			replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			numHeights := 5
+			for height := block.Height(1); height <= block.Height(numHeights); height++ {
+				commitAt(&replica, height, keys[int(height)%len(keys)], keys[1:6])
+			}
+			replica.Close()
+
+			stored := pstore.states[Shard{}]
+			Expect(stored.CurrentHeight).Should(Equal(block.Height(numHeights + 1)))
+			for height := block.Height(1); height <= block.Height(numHeights); height++ {
+				if height < block.Height(numHeights) {
+					Expect(stored.Proposals.QueryByHeightRound(height, 0)).Should(Equal(0))
+					Expect(stored.Prevotes.QueryByHeightRound(height, 0)).Should(Equal(0))
+					Expect(stored.Precommits.QueryByHeightRound(height, 0)).Should(Equal(0))
+					continue
+				}
+				// The precommits at the previous height justify the latest
+				// commit that is embedded in proposals
+				Expect(stored.Precommits.QueryByHeightRound(height, 0)).Should(Equal(5))
+			}
+		})
+	})
+
+	Context("when forcing a round", func() {
+		It("should only start higher rounds, and save the process", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			pstore := newMemoryProcessStorage()
+			replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replica.ForceRound(3)).Should(Succeed())
+			Expect(replica.CurrentRound()).Should(Equal(block.Round(3)))
+			Expect(pstore.states[Shard{}].CurrentRound).Should(Equal(block.Round(3)))
+			Expect(replica.ForceRound(3)).ShouldNot(Succeed())
+			Expect(replica.ForceRound(2)).ShouldNot(Succeed())
+			Expect(replica.CurrentRound()).Should(Equal(block.Round(3)))
+
+			replica.Close()
+			Expect(replica.ForceRound(4)).Should(Equal(ErrClosed))
+		})
+	})
+
+	Context("when pruning old messages", func() {
+		It("should only delete the proposals and prevotes of committed heights from storage", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			pstore := newMemoryProcessStorage()
+			replica, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Commit two blocks, and receive a prevote at the current height
+			commitAt(&replica, 1, keys[1], keys[1:6])
+			commitAt(&replica, 2, keys[2], keys[1:6])
+			prevote := process.NewPrevote(3, 0, RandomHash(), nil)
+			Expect(process.Sign(prevote, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+
+			// Expect the messages at the previous height to be stored after
+			// the commit
+			state := pstore.states[Shard{}]
+			Expect(state.Proposals.QueryByHeightRound(2, 0)).Should(Equal(1))
+			Expect(state.Prevotes.QueryByHeightRound(2, 0)).Should(Equal(5))
+			Expect(state.Precommits.QueryByHeightRound(2, 0)).Should(Equal(5))
+
+			// Expect only the proposals and prevotes below the height to be
+			// deleted, and the precommits that justify the latest commit to be
+			// kept
+			replica.Prune(3)
+			pruned := pstore.states[Shard{}]
+			Expect(pruned.Proposals.QueryByHeightRound(2, 0)).Should(Equal(0))
+			Expect(pruned.Prevotes.QueryByHeightRound(2, 0)).Should(Equal(0))
+			Expect(pruned.Precommits.QueryByHeightRound(2, 0)).Should(Equal(5))
+			Expect(pruned.Prevotes.QueryByHeightRound(3, 0)).Should(Equal(1))
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(3)))
+
+			// Expect the committed blocks to be kept
+			for height := block.Height(0); height <= 2; height++ {
+				_, ok := store.Blockchain(Shard{}).BlockAtHeight(height)
+				Expect(ok).Should(BeTrue())
+			}
+
+			// Expect the messages at the current height to be kept, however
+			// high the height is
+			replica.Prune(10)
+			pruned = pstore.states[Shard{}]
+			Expect(pruned.Precommits.QueryByHeightRound(2, 0)).Should(Equal(5))
+			Expect(pruned.Prevotes.QueryByHeightRound(3, 0)).Should(Equal(1))
+		})
+
+		It("should be safe to prune while handling messages", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, newMemoryProcessStorage(), store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				for height := block.Height(1); height <= 3; height++ {
+					commitAt(&replica, height, keys[height%7], keys[1:6])
+				}
+			}()
+			for i := 0; i < 10; i++ {
+				replica.Prune(block.Height(i))
+			}
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("when receiving competing proposals from the proposer", func() {
+		It("should keep the first proposal, report the second, and only unlock in a later round", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			evidence := []ProposerEquivocation{}
+			options := Options{
+				OnProposerEquivocation: func(equivocation ProposerEquivocation) {
+					evidence = append(evidence, equivocation)
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Lock on a block at round 0
+			locked := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(locked, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: locked})).Should(Succeed())
+			Eventually(messages).Should(Receive())
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, locked.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Eventually(messages).Should(Receive())
+			Expect(replica.Status().LockedBlockHash).Should(Equal(locked.BlockHash()))
+
+			// Receive a polka for another block at round 1, and then move on
+			// to round 2
+			unlocking := replica.rebaser.BlockProposal(1, 1)
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 1, unlocking.Hash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Expect(replica.ForceRound(2)).Should(Succeed())
+
+			// Receive a proposal that is not justified by any polka, and then
+			// a competing proposal that is justified by the polka at round 1
+			first := process.NewPropose(1, 2, replica.rebaser.BlockProposal(1, 2), 0)
+			Expect(process.Sign(first, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: first})).Should(Succeed())
+			second := process.NewPropose(1, 2, unlocking, 1)
+			Expect(process.Sign(second, *keys[3])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: second})).Should(Equal(ErrDuplicate))
+
+			// Expect the first proposal to be kept, the second to be reported,
+			// and the replica to stay locked
+			Consistently(messages).ShouldNot(Receive())
+			kept, ok := replica.p.Proposal(1, 2, first.Signatory())
+			Expect(ok).Should(BeTrue())
+			Expect(kept.BlockHash()).Should(Equal(first.BlockHash()))
+			Expect(evidence).Should(HaveLen(1))
+			Expect(evidence[0].First.BlockHash()).Should(Equal(first.BlockHash()))
+			Expect(evidence[0].Second.BlockHash()).Should(Equal(second.BlockHash()))
+			Expect(replica.Status().LockedBlockHash).Should(Equal(locked.BlockHash()))
+
+			// Expect the proposal of the next round, justified by the polka at
+			// round 1, to unlock the replica
+			Expect(replica.ForceRound(3)).Should(Succeed())
+			next := process.NewPropose(1, 3, unlocking, 1)
+			Expect(process.Sign(next, *keys[4])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: next})).Should(Succeed())
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			prevote, ok := message.Message.(*process.Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Round()).Should(Equal(block.Round(3)))
+			Expect(prevote.BlockHash()).Should(Equal(unlocking.Hash()))
 		})
 	})
 })
 
+// memoryProcessStorage stores the State of every `process.Process` that is
+// saved, by marshaling and unmarshaling it.
+type memoryProcessStorage struct {
+	states map[Shard]process.State
+}
+
+func newMemoryProcessStorage() memoryProcessStorage {
+	return memoryProcessStorage{
+		states: map[Shard]process.State{},
+	}
+}
+
+func (storage memoryProcessStorage) SaveProcess(p *process.Process, shard Shard) {
+	data, err := p.MarshalBinary()
+	Expect(err).NotTo(HaveOccurred())
+	state := process.DefaultState(1)
+	Expect(state.UnmarshalBinary(data)).Should(Succeed())
+	storage.states[shard] = state
+}
+
+func (storage memoryProcessStorage) RestoreProcess(p *process.Process, shard Shard) {
+	state, ok := storage.states[shard]
+	if !ok {
+		return
+	}
+	data, err := state.MarshalBinary()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(p.UnmarshalBinary(data)).Should(Succeed())
+}
+
 func parseType(s string) reflect.Type {
 	switch s {
 	case "propose":
@@ -1,11 +1,29 @@
 package replica
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
 	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/id"
 )
 
+// A scheduler is a `process.Scheduler` that can be rebased onto a new set of
+// signatories, and that knows the share of blocks that each signatory is
+// expected to propose. Schedulers are rebased concurrently with scheduling, so
+// they must be safe for concurrent use.
+type scheduler interface {
+	process.Scheduler
+
+	rebase(sigs id.Signatories)
+	expectedShares() map[id.Signatory]float64
+}
+
 type roundRobinScheduler struct {
+	mu          *sync.RWMutex
 	signatories id.Signatories
 }
 
@@ -13,11 +31,15 @@ type roundRobinScheduler struct {
 // robin schedule that weights the `block.Height` and the `block.Round` equally.
 func newRoundRobinScheduler(signatories id.Signatories) *roundRobinScheduler {
 	return &roundRobinScheduler{
+		mu:          new(sync.RWMutex),
 		signatories: signatories,
 	}
 }
 
 func (scheduler *roundRobinScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	scheduler.mu.RLock()
+	defer scheduler.mu.RUnlock()
+
 	if len(scheduler.signatories) == 0 {
 		return block.InvalidSignatory
 	}
@@ -25,5 +47,161 @@ func (scheduler *roundRobinScheduler) Schedule(height block.Height, round block.
 }
 
 func (scheduler *roundRobinScheduler) rebase(sigs id.Signatories) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
 	scheduler.signatories = sigs
 }
+
+func (scheduler *roundRobinScheduler) expectedShares() map[id.Signatory]float64 {
+	scheduler.mu.RLock()
+	defer scheduler.mu.RUnlock()
+
+	shares := make(map[id.Signatory]float64, len(scheduler.signatories))
+	for _, sig := range scheduler.signatories {
+		shares[sig] = 1 / float64(len(scheduler.signatories))
+	}
+	return shares
+}
+
+// A StakeFunc returns the stake of a signatory.
+type StakeFunc func(id.Signatory) uint64
+
+type stakeWeightedScheduler struct {
+	mu          *sync.RWMutex
+	stake       StakeFunc
+	signatories id.Signatories
+	cumulative  []uint64
+	total       uint64
+}
+
+// newStakeWeightedScheduler returns a `process.Scheduler` that selects
+// proposers in proportion to their stake. The selection is pseudo-random, but
+// it is seeded by the `block.Height` and the `block.Round`, so all processes
+// with the same signatories and stakes agree on the schedule. Signatories
+// without stake are never selected.
+func newStakeWeightedScheduler(signatories id.Signatories, stake StakeFunc) *stakeWeightedScheduler {
+	scheduler := &stakeWeightedScheduler{
+		mu:    new(sync.RWMutex),
+		stake: stake,
+	}
+	scheduler.rebase(signatories)
+	return scheduler
+}
+
+func (scheduler *stakeWeightedScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	scheduler.mu.RLock()
+	defer scheduler.mu.RUnlock()
+
+	if scheduler.total == 0 {
+		return block.InvalidSignatory
+	}
+
+	// Seed the RNG deterministically from the height and round
+	seedData := [16]byte{}
+	binary.LittleEndian.PutUint64(seedData[:8], uint64(height))
+	binary.LittleEndian.PutUint64(seedData[8:], uint64(round))
+	seed := sha256.Sum256(seedData[:])
+	rng := rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:8]))))
+
+	// Select the first signatory whose cumulative stake exceeds a random
+	// amount of stake
+	r := rng.Uint64() % scheduler.total
+	for i, cumulative := range scheduler.cumulative {
+		if r < cumulative {
+			return scheduler.signatories[i]
+		}
+	}
+	panic("invariant violation: random stake exceeds total stake")
+}
+
+func (scheduler *stakeWeightedScheduler) rebase(sigs id.Signatories) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.signatories = sigs
+	scheduler.cumulative = make([]uint64, len(sigs))
+	scheduler.total = 0
+	for i, sig := range sigs {
+		scheduler.total += scheduler.stake(sig)
+		scheduler.cumulative[i] = scheduler.total
+	}
+}
+
+func (scheduler *stakeWeightedScheduler) expectedShares() map[id.Signatory]float64 {
+	scheduler.mu.RLock()
+	defer scheduler.mu.RUnlock()
+
+	shares := make(map[id.Signatory]float64, len(scheduler.signatories))
+	for _, sig := range scheduler.signatories {
+		if scheduler.total == 0 {
+			shares[sig] = 0
+			continue
+		}
+		shares[sig] = float64(scheduler.stake(sig)) / float64(scheduler.total)
+	}
+	return shares
+}
+
+// A ProposerOverride returns the signatory that must propose at a height and
+// round, instead of the signatory selected by the schedule.
+type ProposerOverride func(block.Height, block.Round) id.Signatory
+
+type overriddenScheduler struct {
+	scheduler
+	override ProposerOverride
+}
+
+// newOverriddenScheduler returns a scheduler that selects proposers using the
+// ProposerOverride. Rebasing, and the expected shares, are delegated to the
+// underlying scheduler, so they do not reflect the override.
+func newOverriddenScheduler(scheduler scheduler, override ProposerOverride) *overriddenScheduler {
+	return &overriddenScheduler{
+		scheduler: scheduler,
+		override:  override,
+	}
+}
+
+func (scheduler *overriddenScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	return scheduler.override(height, round)
+}
+
+// A LivenessFunc returns false if a signatory is known to be offline at a
+// height.
+type LivenessFunc func(id.Signatory, block.Height) bool
+
+type livenessScheduler struct {
+	scheduler
+	self   id.Signatory
+	isLive LivenessFunc
+}
+
+// newLivenessScheduler returns a scheduler that skips the signatories selected
+// by the underlying scheduler while they are offline. The signatory of the
+// Replica is never considered to be offline. Rebasing, and the expected shares,
+// are delegated to the underlying scheduler, so they do not reflect skipped
+// signatories.
+func newLivenessScheduler(scheduler scheduler, self id.Signatory, isLive LivenessFunc) *livenessScheduler {
+	return &livenessScheduler{
+		scheduler: scheduler,
+		self:      self,
+		isLive:    isLive,
+	}
+}
+
+// Schedule implements the `process.Scheduler` interface. If the signatory
+// selected for the round is offline, the signatories selected for the
+// following rounds are tried in turn, so that Replicas that agree on which
+// signatories are offline also agree on the proposer. If every signatory is
+// offline, the signatory selected for the round is returned.
+func (scheduler *livenessScheduler) Schedule(height block.Height, round block.Round) id.Signatory {
+	proposer := scheduler.scheduler.Schedule(height, round)
+	n := len(scheduler.scheduler.expectedShares())
+	for i := 0; i < n; i++ {
+		candidate := scheduler.scheduler.Schedule(height, round+block.Round(i))
+		if candidate.Equal(scheduler.self) || scheduler.isLive(candidate, height) {
+			return candidate
+		}
+	}
+	return proposer
+}
@@ -3,11 +3,14 @@ package replica
 import (
 	"math/rand"
 	"testing/quick"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
 
 	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/id"
 )
 
@@ -70,3 +73,221 @@ var _ = Describe("roundRobinScheduler", func() {
 		})
 	})
 })
+
+var _ = Describe("stakeWeightedScheduler", func() {
+	// randomStakes returns a StakeFunc that assigns a random stake, in the
+	// range [0, 100), to each signatory.
+	randomStakes := func(signatories id.Signatories) (map[id.Signatory]uint64, StakeFunc) {
+		stakes := make(map[id.Signatory]uint64, len(signatories))
+		for _, sig := range signatories {
+			stakes[sig] = uint64(rand.Intn(100))
+		}
+		return stakes, func(sig id.Signatory) uint64 {
+			return stakes[sig]
+		}
+	}
+
+	Context("when asking the proposer of given height and round", func() {
+		It("should select proposers in proportion to their stake", func() {
+			test := func(signatories id.Signatories) bool {
+				stakes, stake := randomStakes(signatories)
+				scheduler := newStakeWeightedScheduler(signatories, stake)
+				total := uint64(0)
+				for _, sig := range signatories {
+					total += stakes[sig]
+				}
+
+				numRounds := 10000
+				proposed := map[id.Signatory]int{}
+				for round := 0; round < numRounds; round++ {
+					proposed[scheduler.Schedule(block.Height(rand.Int()), block.Round(round))]++
+				}
+				if total == 0 {
+					Expect(proposed[block.InvalidSignatory]).Should(Equal(numRounds))
+					return true
+				}
+				for _, sig := range signatories {
+					expected := float64(stakes[sig]) / float64(total)
+					if expected == 0 {
+						Expect(proposed[sig]).Should(BeZero())
+						continue
+					}
+					Expect(float64(proposed[sig]) / float64(numRounds)).Should(BeNumerically("~", expected, 0.05))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 20})).Should(Succeed())
+		})
+
+		It("should select the same proposer for the same height and round", func() {
+			test := func(signatories id.Signatories) bool {
+				_, stake := randomStakes(signatories)
+				scheduler := newStakeWeightedScheduler(signatories, stake)
+				otherScheduler := newStakeWeightedScheduler(signatories, stake)
+
+				for i := 0; i < 100; i++ {
+					height := block.Height(rand.Int())
+					round := block.Round(rand.Int())
+					Expect(scheduler.Schedule(height, round)).Should(Equal(otherScheduler.Schedule(height, round)))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when rebasing", func() {
+		It("should only select signatories in the rebase block", func() {
+			test := func(signatories, rebaseSigs id.Signatories) bool {
+				stakes, stake := randomStakes(append(signatories, rebaseSigs...))
+				scheduler := newStakeWeightedScheduler(signatories, stake)
+
+				scheduler.rebase(rebaseSigs)
+				total := uint64(0)
+				for _, sig := range rebaseSigs {
+					total += stakes[sig]
+				}
+
+				for i := 0; i < 100; i++ {
+					sig := scheduler.Schedule(block.Height(rand.Int()), block.Round(rand.Int()))
+					if total == 0 {
+						Expect(sig.Equal(block.InvalidSignatory)).Should(BeTrue())
+					} else {
+						Expect(stakes[sig]).Should(BeNumerically(">", 0))
+						Expect(rebaseSigs).Should(ContainElement(sig))
+					}
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("overriddenScheduler", func() {
+
+	Context("when a replica has a proposer override", func() {
+		It("should make the chosen replica propose", func() {
+			test := func(shard Shard, index uint8) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				key := keys[int(index)%len(keys)]
+				proposer := id.NewSignatory(key.PublicKey)
+				options := Options{
+					ProposerOverride: func(block.Height, block.Round) id.Signatory {
+						return proposer
+					},
+				}
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *key)
+				Expect(err).NotTo(HaveOccurred())
+
+				for round := block.Round(0); round < 10; round++ {
+					Expect(replica.scheduler.Schedule(1, round)).Should(Equal(proposer))
+				}
+				Expect(replica.Proposer()).Should(Equal(proposer))
+				Expect(replica.IsProposing()).Should(BeTrue())
+
+				replica.Start()
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.ProposeMessageType)))
+				Expect(message.Message.Signatory()).Should(Equal(proposer))
+				replica.Close()
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
+
+// networkBroadcaster delivers every Message to all of the Replicas in a
+// network, including the sender. The Replicas must have queues, so that
+// Messages are not handled while the sender is broadcasting.
+type networkBroadcaster struct {
+	replicas *[]Replica
+}
+
+func (broadcaster networkBroadcaster) Broadcast(m Message) {
+	for _, replica := range *broadcaster.replicas {
+		replica.HandleMessage(m)
+	}
+}
+
+var _ = Describe("livenessScheduler", func() {
+
+	Context("when the scheduled proposer is offline", func() {
+		It("should select the next proposer that is online", func() {
+			sigs := id.Signatories{RandomSignatory(), RandomSignatory(), RandomSignatory(), RandomSignatory()}
+			offline := map[id.Signatory]bool{sigs[1]: true, sigs[2]: true}
+			isLive := func(sig id.Signatory, height block.Height) bool {
+				return !offline[sig]
+			}
+			scheduler := newLivenessScheduler(newRoundRobinScheduler(sigs), sigs[0], isLive)
+			Expect(scheduler.Schedule(0, 0)).Should(Equal(sigs[0]))
+			Expect(scheduler.Schedule(1, 0)).Should(Equal(sigs[3]))
+			Expect(scheduler.Schedule(0, 2)).Should(Equal(sigs[3]))
+			Expect(scheduler.Schedule(3, 0)).Should(Equal(sigs[3]))
+
+			// Expect the replica to never skip itself
+			offline[sigs[0]] = true
+			Expect(scheduler.Schedule(0, 0)).Should(Equal(sigs[0]))
+
+			// Expect the scheduled proposer to be returned if every signatory
+			// is offline
+			scheduler = newLivenessScheduler(newRoundRobinScheduler(sigs), RandomSignatory(), func(id.Signatory, block.Height) bool { return false })
+			Expect(scheduler.Schedule(1, 0)).Should(Equal(sigs[1]))
+		})
+	})
+
+	Context("when a validator is offline", func() {
+		It("should skip the validator as proposer, and the other validators should still reach consensus", func() {
+			_, keys := initGenesisStorage(Shard{})
+			sigs := make(id.Signatories, len(keys))
+			for i, key := range keys {
+				sigs[i] = id.NewSignatory(key.PublicKey)
+			}
+
+			// The offline validator is the proposer at the first height, and
+			// would cost a propose timeout of 20 seconds if it was not skipped
+			offline := sigs[1]
+			options := Options{
+				MessageQueueSize:      1000,
+				SkipOfflineInRotation: true,
+				Liveness: func(sig id.Signatory, height block.Height) bool {
+					return !sig.Equal(offline)
+				},
+			}
+			replicas := make([]Replica, 0, len(keys)-1)
+			broadcaster := networkBroadcaster{replicas: &replicas}
+			for i, key := range keys {
+				if sigs[i].Equal(offline) {
+					continue
+				}
+				store := newMockBlockStorage(sigs)
+				store.Blockchain(Shard{})
+				replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *key)
+				Expect(err).NotTo(HaveOccurred())
+				replicas = append(replicas, replica)
+			}
+			for i := range replicas {
+				replicas[i].Start()
+				defer replicas[i].Close()
+			}
+
+			for _, replica := range replicas {
+				Eventually(replica.CurrentHeight, 10*time.Second).Should(BeNumerically(">", block.Height(len(sigs))))
+			}
+			for _, replica := range replicas {
+				for height := block.Height(1); height <= block.Height(len(sigs)); height++ {
+					committed, ok := replica.blockStorage.Blockchain(Shard{}).BlockAtHeight(height)
+					Expect(ok).Should(BeTrue())
+					Expect(committed.Header().Round()).Should(Equal(block.Round(0)))
+				}
+			}
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/id"
+	"github.com/renproject/phi"
+)
+
+// A ReplicaSet owns the Replicas of multiple Shards, and routes each Message
+// to the Replica of its Shard. It is safe for concurrent use, and Messages for
+// different Shards can be handled concurrently.
+type ReplicaSet struct {
+	mu       *sync.RWMutex
+	replicas map[Shard]*Replica
+}
+
+// NewReplicaSet returns a ReplicaSet that owns the Replicas. It panics if more
+// than one of the Replicas maintains the same Shard.
+func NewReplicaSet(replicas ...Replica) *ReplicaSet {
+	set := &ReplicaSet{
+		mu:       new(sync.RWMutex),
+		replicas: make(map[Shard]*Replica, len(replicas)),
+	}
+	for i := range replicas {
+		if err := set.Add(replicas[i]); err != nil {
+			panic(fmt.Errorf("invariant violation: %v", err))
+		}
+	}
+	return set
+}
+
+// Add a Replica to the set. An error is returned if the set already has a
+// Replica that maintains the same Shard. The Replica is not started.
+func (set *ReplicaSet) Add(replica Replica) error {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if _, ok := set.replicas[replica.shard]; ok {
+		return fmt.Errorf("replica already exists for shard=%v", replica.shard)
+	}
+	set.replicas[replica.shard] = &replica
+	return nil
+}
+
+// Remove the Replica that maintains the Shard from the set, and close it.
+// Removing a Shard that is not in the set does nothing.
+func (set *ReplicaSet) Remove(shard Shard) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	replica, ok := set.replicas[shard]
+	if !ok {
+		return
+	}
+	delete(set.replicas, shard)
+	replica.Close()
+}
+
+// Shards returns the Shards that are maintained by the Replicas in the set, in
+// no particular order.
+func (set *ReplicaSet) Shards() Shards {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	shards := make(Shards, 0, len(set.replicas))
+	for shard := range set.replicas {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// Start all Replicas in the set, in parallel.
+func (set *ReplicaSet) Start() {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	phi.ParForAll(set.replicas, func(shard Shard) {
+		set.replicas[shard].Start()
+	})
+}
+
+// Close all Replicas in the set. Replicas are closed, but not removed, so
+// Messages for their Shards return ErrClosed.
+func (set *ReplicaSet) Close() {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	for _, replica := range set.replicas {
+		replica.Close()
+	}
+}
+
+// Rebase the Replicas of all Shards in the set onto the signatories.
+func (set *ReplicaSet) Rebase(sigs id.Signatories) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	for _, replica := range set.replicas {
+		replica.Rebase(sigs)
+	}
+}
+
+// HandleMessage passes the Message to the Replica that maintains the Shard of
+// the Message. If there is no such Replica, the Message is dropped and
+// ErrWrongShard is returned. Otherwise, the error returned by the Replica is
+// returned.
+func (set *ReplicaSet) HandleMessage(m Message) error {
+	replica, ok := set.replica(m.Shard)
+	if !ok {
+		return ErrWrongShard
+	}
+	return replica.HandleMessage(m)
+}
+
+// CurrentHeight returns the height at which the Replica of the Shard is
+// currently trying to reach consensus. It returns false if there is no
+// Replica for the Shard in the set.
+func (set *ReplicaSet) CurrentHeight(shard Shard) (block.Height, bool) {
+	replica, ok := set.replica(shard)
+	if !ok {
+		return block.InvalidHeight, false
+	}
+	return replica.CurrentHeight(), true
+}
+
+// CurrentRound returns the round in which the Replica of the Shard is
+// currently trying to reach consensus. It returns false if there is no
+// Replica for the Shard in the set.
+func (set *ReplicaSet) CurrentRound(shard Shard) (block.Round, bool) {
+	replica, ok := set.replica(shard)
+	if !ok {
+		return block.InvalidRound, false
+	}
+	return replica.CurrentRound(), true
+}
+
+func (set *ReplicaSet) replica(shard Shard) (*Replica, bool) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	replica, ok := set.replicas[shard]
+	return replica, ok
+}
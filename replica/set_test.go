@@ -0,0 +1,123 @@
+package replica
+
+import (
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("replica set", func() {
+
+	Context("when handling messages for multiple shards", func() {
+		It("should dispatch each message to the replica of its shard", func() {
+			test := func(shard1, shard2 Shard) bool {
+				if shard1.Equal(shard2) {
+					return true
+				}
+				store1, keys1 := initGenesisStorage(shard1)
+				store2, _ := initGenesisStorage(shard2)
+				broadcaster, messages := newMockBroadcaster()
+				first, err := New(Options{}, mockProcessStorage{}, store1, mockBlockIterator{}, nil, nil, broadcaster, shard1, *keys1[0])
+				Expect(err).NotTo(HaveOccurred())
+				second, err := New(Options{}, mockProcessStorage{}, store2, mockBlockIterator{}, nil, nil, broadcaster, shard2, *keys1[0])
+				Expect(err).NotTo(HaveOccurred())
+				set := NewReplicaSet(first, second)
+				Expect(set.Shards()).Should(ConsistOf(shard1, shard2))
+
+				// Commit a block on the first shard
+				replica1 := set.replicas[shard1]
+				propose := process.NewPropose(1, 0, replica1.rebaser.BlockProposal(1, 0), block.InvalidRound)
+				Expect(process.Sign(propose, *keys1[1])).Should(Succeed())
+				Expect(set.HandleMessage(Message{Shard: shard1, Message: propose})).Should(Succeed())
+
+				var message Message
+				Eventually(messages).Should(Receive(&message))
+				Expect(message.Shard).Should(Equal(shard1))
+				Expect(message.Message.Type()).Should(Equal(process.MessageType(process.PrevoteMessageType)))
+
+				for _, key := range keys1[1:6] {
+					precommit := process.NewPrecommit(1, 0, propose.BlockHash())
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(set.HandleMessage(Message{Shard: shard1, Message: precommit})).Should(Succeed())
+				}
+
+				// Expect only the first shard to have made progress
+				height, ok := set.CurrentHeight(shard1)
+				Expect(ok).Should(BeTrue())
+				Expect(height).Should(Equal(block.Height(2)))
+				height, ok = set.CurrentHeight(shard2)
+				Expect(ok).Should(BeTrue())
+				Expect(height).Should(Equal(block.Height(1)))
+				round, ok := set.CurrentRound(shard2)
+				Expect(ok).Should(BeTrue())
+				Expect(round).Should(Equal(block.Round(0)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when handling a message for an unregistered shard", func() {
+		It("should drop the message", func() {
+			test := func(shard, unregistered Shard) bool {
+				if shard.Equal(unregistered) {
+					return true
+				}
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+				set := NewReplicaSet(replica)
+
+				propose := process.NewPropose(1, 0, set.replicas[shard].rebaser.BlockProposal(1, 0), block.InvalidRound)
+				Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+				Expect(set.HandleMessage(Message{Shard: unregistered, Message: propose})).Should(Equal(ErrWrongShard))
+				Expect(messages).ShouldNot(Receive())
+
+				_, ok := set.CurrentHeight(unregistered)
+				Expect(ok).Should(BeFalse())
+				_, ok = set.CurrentRound(unregistered)
+				Expect(ok).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when adding a replica for a shard that is already registered", func() {
+		It("should return an error", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			set := NewReplicaSet(replica)
+
+			other, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Add(other)).ShouldNot(Succeed())
+			Expect(set.Shards()).Should(HaveLen(1))
+		})
+	})
+
+	Context("when removing a shard", func() {
+		It("should drop messages for the shard", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			set := NewReplicaSet(replica)
+			set.Remove(Shard{})
+
+			prevote := process.NewPrevote(1, 0, block.InvalidHash, nil)
+			Expect(process.Sign(prevote, *keys[1])).Should(Succeed())
+			Expect(set.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Equal(ErrWrongShard))
+			Expect(set.Shards()).Should(BeEmpty())
+		})
+	})
+})
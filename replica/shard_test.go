@@ -0,0 +1,112 @@
+package replica
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("shard isolation", func() {
+	shardA, shardB := Shard{}, Shard{1}
+
+	Context("when replicas of different shards share storage", func() {
+		It("should never restore the votes of another shard", func() {
+			store, keys := initGenesisStorage(shardA)
+			store.Blockchain(shardB)
+			pstore := newMemoryProcessStorage()
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replicaA, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardA, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replicaB, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardB, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			// Prevote on the first shard, and try to leak the prevotes into
+			// the second shard
+			blockHash := RandomHash()
+			voters := keys[1:4]
+			for _, key := range voters {
+				prevote := process.NewPrevote(1, 0, blockHash, nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replicaA.HandleMessage(Message{Shard: shardA, Message: prevote})).Should(Succeed())
+				Expect(replicaB.HandleMessage(Message{Shard: shardA, Message: prevote})).Should(Equal(ErrWrongShard))
+			}
+
+			// Expect the prevotes to be restored on the first shard, and only
+			// on the first shard
+			restoredA, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardA, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			restoredB, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardB, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			for _, key := range voters {
+				signatory := id.NewSignatory(key.PublicKey)
+				_, ok := restoredA.p.Vote(process.PrevoteMessageType, 1, 0, signatory)
+				Expect(ok).Should(BeTrue())
+				_, ok = replicaB.p.Vote(process.PrevoteMessageType, 1, 0, signatory)
+				Expect(ok).Should(BeFalse())
+				_, ok = restoredB.p.Vote(process.PrevoteMessageType, 1, 0, signatory)
+				Expect(ok).Should(BeFalse())
+			}
+		})
+
+		It("should never commit blocks to another shard", func() {
+			store, keys := initGenesisStorage(shardA)
+			store.Blockchain(shardB)
+			pstore := newMemoryProcessStorage()
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replicaA, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardA, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			commitAt(&replicaA, 1, keys[1], keys[1:6])
+
+			Expect(store.LatestBlock(shardA).Header().Height()).Should(Equal(block.Height(1)))
+			Expect(store.LatestBlock(shardB).Header().Height()).Should(Equal(block.Height(0)))
+			replicaB, err := New(Options{}, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shardB, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicaB.CurrentHeight()).Should(Equal(block.Height(1)))
+		})
+	})
+
+	Context("when replicas of different shards share a block builder", func() {
+		It("should build the blocks of each replica for its own shard", func() {
+			store, keys := initGenesisStorage(shardA)
+			store.Blockchain(shardB)
+			shards := make(chan Shard, 2)
+			options := Options{
+				BlockBuilder: func(height block.Height, parent id.Hash, shard Shard) (block.Txs, block.Plan, block.State, error) {
+					Expect(parent).Should(Equal(store.LatestBlock(shard).Hash()))
+					shards <- shard
+					return block.Txs(RandomBytesSlice()), nil, nil, nil
+				},
+			}
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replicaA, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shardA, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			replicaB, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shardB, *keys[1])
+			Expect(err).NotTo(HaveOccurred())
+			replicaA.Start()
+			defer replicaA.Close()
+			replicaB.Start()
+			defer replicaB.Close()
+
+			var first, second Shard
+			Eventually(shards).Should(Receive(&first))
+			Eventually(shards).Should(Receive(&second))
+			Expect([]Shard{first, second}).Should(ConsistOf(shardA, shardB))
+		})
+	})
+})
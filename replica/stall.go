@@ -0,0 +1,73 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// A StallFunc is called with the height and round of a Replica, and the number
+// of consecutive timeouts that have happened at that height, whenever the
+// number of consecutive timeouts reaches, or exceeds, the stall threshold.
+type StallFunc func(height block.Height, round block.Round, consecutiveTimeouts int)
+
+// A stallDetector counts the consecutive timeouts of a Replica that happen
+// without a new height being reached. Rounds that start at the same height do
+// not reset the count, because rounds keep advancing (by timeouts, and by nil
+// precommits) while consensus is stalled. It is shared by all copies of a
+// Replica.
+type stallDetector struct {
+	mu        *sync.Mutex
+	threshold int
+	onStall   StallFunc
+	height    block.Height
+	timeouts  int
+}
+
+func newStallDetector(threshold int, onStall StallFunc) *stallDetector {
+	return &stallDetector{
+		mu:        new(sync.Mutex),
+		threshold: threshold,
+		onStall:   onStall,
+		height:    block.InvalidHeight,
+		timeouts:  0,
+	}
+}
+
+// didStartRound resets the count if the round is at a new height.
+func (detector *stallDetector) didStartRound(height block.Height, round block.Round) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	if height != detector.height {
+		detector.height = height
+		detector.timeouts = 0
+	}
+}
+
+// didTimeout counts the timeout, and calls the StallFunc if the count has
+// reached the threshold. The StallFunc is never called if the threshold is
+// zero.
+func (detector *stallDetector) didTimeout(transition process.Transition) {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	if transition.Height != detector.height {
+		detector.height = transition.Height
+		detector.timeouts = 0
+	}
+	detector.timeouts++
+	if detector.threshold > 0 && detector.timeouts >= detector.threshold && detector.onStall != nil {
+		detector.onStall(transition.Height, transition.Round, detector.timeouts)
+	}
+}
+
+// consecutiveTimeouts returns the number of consecutive timeouts at the
+// current height.
+func (detector *stallDetector) consecutiveTimeouts() int {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	return detector.timeouts
+}
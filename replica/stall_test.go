@@ -0,0 +1,106 @@
+package replica
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("stall detection", func() {
+	// stall records a call to the StallFunc.
+	type stall struct {
+		height   block.Height
+		round    block.Round
+		timeouts int
+	}
+
+	Context("when the proposers of consecutive rounds are offline", func() {
+		It("should call the stall callback once the threshold is reached, and reset after a commit", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			stalls := make(chan stall, 100)
+			options := Options{
+				BackOffBase:    10 * time.Millisecond,
+				BackOffMax:     10 * time.Millisecond,
+				StallThreshold: 3,
+				OnStall: func(height block.Height, round block.Round, timeouts int) {
+					stalls <- stall{height: height, round: round, timeouts: timeouts}
+				},
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+
+			// The proposers of the first three rounds are never heard from,
+			// so every round times out waiting for a proposal
+			for round := block.Round(0); round < 3; round++ {
+				Consistently(stalls, 5*time.Millisecond).ShouldNot(Receive())
+				Eventually(func() block.Round {
+					var message Message
+					Eventually(messages).Should(Receive(&message))
+					if prevote, ok := message.Message.(*process.Prevote); ok && prevote.BlockHash().Equal(block.InvalidHash) {
+						return prevote.Round()
+					}
+					return block.InvalidRound
+				}).Should(Equal(round))
+
+				for _, key := range keys[1:6] {
+					prevote := process.NewPrevote(1, round, block.InvalidHash, nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+				}
+				for _, key := range keys[1:6] {
+					precommit := process.NewPrecommit(1, round, block.InvalidHash)
+					Expect(process.Sign(precommit, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+				}
+			}
+
+			var s stall
+			Eventually(stalls).Should(Receive(&s))
+			Expect(s).Should(Equal(stall{height: 1, round: 2, timeouts: 3}))
+			Expect(replica.Status().ConsecutiveTimeouts).Should(BeNumerically(">=", 3))
+
+			// Commit the proposal at the next round
+			go func() {
+				defer GinkgoRecover()
+				for range messages {
+				}
+			}()
+			proposedBlock := replica.rebaser.BlockProposal(1, 3)
+			propose := process.NewPropose(1, 3, proposedBlock, block.InvalidRound)
+			Expect(process.Sign(propose, *keys[4])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			for _, key := range keys[1:6] {
+				precommit := process.NewPrecommit(1, 3, proposedBlock.Hash())
+				Expect(process.Sign(precommit, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: precommit})).Should(Succeed())
+			}
+			Expect(replica.CurrentHeight()).Should(Equal(block.Height(2)))
+			Expect(replica.Status().ConsecutiveTimeouts).Should(Equal(0))
+		})
+	})
+
+	Context("when the stall threshold is zero", func() {
+		It("should count timeouts without calling the stall callback", func() {
+			detector := newStallDetector(0, func(block.Height, block.Round, int) {
+				defer GinkgoRecover()
+				Fail("unexpected stall")
+			})
+			detector.didStartRound(1, 0)
+			for round := block.Round(0); round < 10; round++ {
+				detector.didTimeout(process.Transition{Type: process.TimedOutProposeTransitionType, Height: 1, Round: round})
+			}
+			Expect(detector.consecutiveTimeouts()).Should(Equal(10))
+
+			detector.didStartRound(1, 10)
+			Expect(detector.consecutiveTimeouts()).Should(Equal(10))
+			detector.didStartRound(2, 0)
+			Expect(detector.consecutiveTimeouts()).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+package replica
+
+import (
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A Status is a consistent view of the progress of a Replica, intended for
+// admin endpoints. The consensus fields are read from the `process.Process` at
+// the same instant, so they are mutually consistent. The message counters are
+// read separately, and include every Message handled before the Status was
+// returned.
+type Status struct {
+	Shard  Shard
+	Height block.Height
+	Round  block.Round
+	// State is the step of the current round. Its String method returns the
+	// name of the step.
+	State process.Step
+
+	// Locked is true if the Replica is locked on a block, in which case
+	// LockedRound is the round in which it locked, and LockedBlockHash is the
+	// hash of the block. Otherwise, LockedRound is invalid.
+	Locked          bool
+	LockedRound     block.Round
+	LockedBlockHash id.Hash
+
+	// LastCommitHeight is the height of the latest committed block (zero for
+	// the genesis block).
+	LastCommitHeight block.Height
+
+	// ConsecutiveTimeouts is the number of timeouts that have happened at the
+	// current height. It is reset whenever a new height is reached.
+	ConsecutiveTimeouts int
+
+	// MessagesAccepted counts the Messages from peers that have been passed
+	// to the `process.Process`, and MessagesRejected counts the Messages that
+	// have been rejected, by the reason for their rejection.
+	MessagesAccepted uint64
+	MessagesRejected map[string]uint64
+}
+
+// messageCounters count the Messages accepted and rejected by a Replica. They
+// are always counted, even if the Replica has no Metrics.
+type messageCounters struct {
+	mu       *sync.Mutex
+	accepted uint64
+	rejected map[string]uint64
+}
+
+func newMessageCounters() *messageCounters {
+	return &messageCounters{
+		mu:       new(sync.Mutex),
+		accepted: 0,
+		rejected: map[string]uint64{},
+	}
+}
+
+func (counters *messageCounters) didAccept() {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	counters.accepted++
+}
+
+func (counters *messageCounters) didReject(err error) {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	counters.rejected[rejectionReason(err)]++
+}
+
+// counts returns the counters. The result does not share memory with the
+// messageCounters.
+func (counters *messageCounters) counts() (uint64, map[string]uint64) {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	rejected := make(map[string]uint64, len(counters.rejected))
+	for reason, n := range counters.rejected {
+		rejected[reason] = n
+	}
+	return counters.accepted, rejected
+}
+
+// Status returns the current Status of the Replica. It is safe for concurrent
+// use.
+func (replica *Replica) Status() Status {
+	snapshot := replica.p.Snapshot()
+	accepted, rejected := replica.counters.counts()
+
+	lastCommitHeight := snapshot.CurrentHeight - 1
+	if lastCommitHeight < 0 {
+		lastCommitHeight = 0
+	}
+	return Status{
+		Shard:  replica.shard,
+		Height: snapshot.CurrentHeight,
+		Round:  snapshot.CurrentRound,
+		State:  snapshot.CurrentStep,
+
+		Locked:          snapshot.LockedRound != block.InvalidRound,
+		LockedRound:     snapshot.LockedRound,
+		LockedBlockHash: snapshot.LockedBlockHash,
+
+		LastCommitHeight:    lastCommitHeight,
+		ConsecutiveTimeouts: replica.stalls.consecutiveTimeouts(),
+
+		MessagesAccepted: accepted,
+		MessagesRejected: rejected,
+	}
+}
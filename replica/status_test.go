@@ -0,0 +1,65 @@
+package replica
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+var _ = Describe("status", func() {
+	Context("when the replica has just started", func() {
+		It("should not be locked", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			status := replica.Status()
+			Expect(status.Shard).Should(Equal(Shard{}))
+			Expect(status.Height).Should(Equal(block.Height(1)))
+			Expect(status.Round).Should(Equal(block.Round(0)))
+			Expect(status.Locked).Should(BeFalse())
+			Expect(status.LockedRound).Should(Equal(block.InvalidRound))
+			Expect(status.LastCommitHeight).Should(Equal(block.Height(0)))
+			Expect(status.MessagesAccepted).Should(BeZero())
+			Expect(status.MessagesRejected).Should(BeEmpty())
+		})
+	})
+
+	Context("when the replica is locked", func() {
+		It("should report the locked round and the precommit step", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, propose.BlockHash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: prevote})).Should(Succeed())
+			}
+			Expect(replica.HandleMessage(Message{Shard: Shard{1}, Message: propose})).Should(Equal(ErrWrongShard))
+
+			status := replica.Status()
+			Expect(status.Height).Should(Equal(block.Height(1)))
+			Expect(status.Round).Should(Equal(block.Round(0)))
+			Expect(status.State).Should(Equal(process.StepPrecommit))
+			Expect(status.State.String()).Should(Equal("Precommit"))
+			Expect(status.Locked).Should(BeTrue())
+			Expect(status.LockedRound).Should(Equal(block.Round(0)))
+			Expect(status.LockedBlockHash).Should(Equal(propose.BlockHash()))
+			Expect(status.LastCommitHeight).Should(Equal(block.Height(0)))
+			Expect(status.MessagesAccepted).Should(Equal(uint64(6)))
+			Expect(status.MessagesRejected).Should(Equal(map[string]uint64{"wrong_shard": 1}))
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package replica
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+// ErrSyncUnsupported is returned when syncing a Replica whose BlockIterator
+// does not implement the CommitIterator interface.
+var ErrSyncUnsupported = errors.New("sync unsupported: block iterator is not a commit iterator")
+
+// Sync the committed blocks in the range [from, to] from the CommitIterator,
+// so that a Replica that has fallen behind can fast-forward its height. Each
+// block must be backed by 2F+1 valid precommits, and blocks are synced in
+// order until `to` is reached, or until no more committed blocks are known.
+// Blocks below the current height of the Replica are skipped, but blocks above
+// it cannot be skipped. Sync returns the height of the latest block committed
+// by the Replica after syncing. Sync waits for Messages that are being
+// handled, and is safe to call concurrently with HandleMessage. It returns
+// ErrClosed if the Replica has been closed.
+func (replica *Replica) Sync(from, to block.Height) (block.Height, error) {
+	replica.lifecycle.mu.Lock()
+	defer replica.lifecycle.mu.Unlock()
+
+	if replica.lifecycle.closed {
+		return block.InvalidHeight, ErrClosed
+	}
+	if from > to {
+		return block.InvalidHeight, fmt.Errorf("bad range: expected from=%v to be less than or equal to to=%v", from, to)
+	}
+	commitIterator, ok := replica.blockIterator.(CommitIterator)
+	if !ok {
+		return block.InvalidHeight, ErrSyncUnsupported
+	}
+
+	synced := replica.p.CurrentHeight() - 1
+	if from > synced+1 {
+		return synced, fmt.Errorf("bad range: expected from=%v to be less than or equal to height=%v", from, synced+1)
+	}
+	from = synced + 1
+	for height := from; height <= to; height++ {
+		latestCommit, ok := commitIterator.CommitAtHeight(height, replica.shard)
+		if !ok {
+			// The range extends beyond the highest known committed block
+			break
+		}
+		if err := replica.syncCommit(latestCommit); err != nil {
+			replica.saveProcess()
+			return synced, err
+		}
+		synced = height
+	}
+	replica.saveProcess()
+	return synced, nil
+}
+
+// syncCommit fast-forwards the Replica through the next committed block, which
+// must be at the current height of the Replica.
+func (replica *Replica) syncCommit(latestCommit process.LatestCommit) error {
+	height := replica.p.CurrentHeight()
+	if latestCommit.Block.Header().Height() != height {
+		return fmt.Errorf("bad commit: expected block at height=%v, got block at height=%v", height, latestCommit.Block.Header().Height())
+	}
+	if err := replica.p.SyncCommit(latestCommit); err != nil {
+		return fmt.Errorf("bad commit at height=%v: %v", height, err)
+	}
+	replica.rebaser.DidCommitBlock(height)
+	return nil
+}
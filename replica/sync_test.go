@@ -0,0 +1,192 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+type mockCommitIterator struct {
+	mockBlockIterator
+	commits map[block.Height]process.LatestCommit
+}
+
+func (m mockCommitIterator) CommitAtHeight(height block.Height, shard Shard) (process.LatestCommit, bool) {
+	latestCommit, ok := m.commits[height]
+	return latestCommit, ok
+}
+
+// newMockCommitIterator returns a CommitIterator with a chain of blocks
+// committed on top of the genesis block. Each block is precommitted by the
+// first numPrecommits keys.
+func newMockCommitIterator(store BlockStorage, shard Shard, keys []*ecdsa.PrivateKey, numBlocks, numPrecommits int) mockCommitIterator {
+	genesis := store.LatestBaseBlock(shard)
+	parent := genesis
+	commits := map[block.Height]process.LatestCommit{}
+	for height := block.Height(1); height <= block.Height(numBlocks); height++ {
+		txs, plan, prevState := block.Txs(RandomBytesSlice()), block.Plan(RandomBytesSlice()), block.State(RandomBytesSlice())
+		header := block.NewHeader(
+			block.Standard,
+			parent.Hash(),
+			genesis.Hash(),
+			txs.Hash(),
+			plan.Hash(),
+			prevState.Hash(),
+			height,
+			0,
+			block.Timestamp(time.Now().Unix()),
+			nil,
+		)
+		committedBlock := block.New(header, txs, plan, prevState)
+
+		precommits := make([]process.Precommit, 0, numPrecommits)
+		for _, key := range keys[:numPrecommits] {
+			precommit := process.NewPrecommit(height, 0, committedBlock.Hash())
+			if err := process.Sign(precommit, *key); err != nil {
+				panic(err)
+			}
+			precommits = append(precommits, *precommit)
+		}
+		commits[height] = process.LatestCommit{
+			Block:      committedBlock,
+			Precommits: precommits,
+		}
+		parent = committedBlock
+	}
+	return mockCommitIterator{commits: commits}
+}
+
+var _ = Describe("sync", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when a replica is behind", func() {
+		It("should fast-forward to the latest synced block", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 10, 5)
+				broadcaster, _ := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				synced, err := replica.Sync(0, 10)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(synced).Should(Equal(block.Height(10)))
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(11)))
+				for height := block.Height(1); height <= 10; height++ {
+					committedBlock, ok := store.Blockchain(shard).BlockAtHeight(height)
+					Expect(ok).Should(BeTrue())
+					Expect(committedBlock.Equal(iter.commits[height].Block)).Should(BeTrue())
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should stop at the highest known block when syncing beyond it", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 5, 5)
+				broadcaster, _ := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				synced, err := replica.Sync(1, 10)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(synced).Should(Equal(block.Height(5)))
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(6)))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+
+		It("should reject blocks without 2F+1 precommits", func() {
+			test := func(shard Shard) bool {
+				store, keys := initGenesisStorage(shard)
+				iter := newMockCommitIterator(store, shard, keys, 10, 4)
+				broadcaster, _ := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				synced, err := replica.Sync(0, 10)
+				Expect(err).To(HaveOccurred())
+				Expect(synced).Should(Equal(block.Height(0)))
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(1)))
+				Expect(store.Blockchain(shard).BlockExistsAtHeight(1)).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the commits are signed by non-validators", func() {
+		It("should not fast-forward", func() {
+			test := func(shard Shard) bool {
+				store, _ := initGenesisStorage(shard)
+				forgers := make([]*ecdsa.PrivateKey, 7)
+				for i := range forgers {
+					forgers[i] = newEcdsaKey()
+				}
+				iter := newMockCommitIterator(store, shard, forgers, 10, 7)
+				broadcaster, _ := newMockBroadcaster()
+				replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+				Expect(err).NotTo(HaveOccurred())
+
+				synced, err := replica.Sync(0, 10)
+				Expect(err).To(HaveOccurred())
+				Expect(synced).Should(Equal(block.Height(0)))
+				Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(1)))
+				Expect(store.Blockchain(shard).BlockExistsAtHeight(1)).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+
+	Context("when the block iterator cannot iterate over commits", func() {
+		It("should return an error", func() {
+			store, _ := initGenesisStorage(Shard{})
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = replica.Sync(0, 10)
+			Expect(err).Should(Equal(ErrSyncUnsupported))
+		})
+	})
+
+	Context("when a replica has been closed", func() {
+		It("should not fast-forward", func() {
+			shard := Shard{}
+			store, keys := initGenesisStorage(shard)
+			iter := newMockCommitIterator(store, shard, keys, 10, 5)
+			broadcaster, _ := newMockBroadcaster()
+			replica, err := New(Options{}, mockProcessStorage{}, store, iter, nil, nil, broadcaster, shard, *newEcdsaKey())
+			Expect(err).NotTo(HaveOccurred())
+			replica.Close()
+
+			synced, err := replica.Sync(0, 10)
+			Expect(err).Should(Equal(ErrClosed))
+			Expect(synced).Should(Equal(block.InvalidHeight))
+			Expect(replica.p.CurrentHeight()).Should(Equal(block.Height(1)))
+			Expect(store.Blockchain(shard).BlockExistsAtHeight(1)).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package replica
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+// A TxCounter counts the application-specific transactions in `block.Txs`. No
+// assumptions are made about the format of `block.Txs`, so applications that
+// want transactions to be counted must provide their own TxCounter.
+type TxCounter interface {
+	CountTxs(txs block.Txs) int
+}
+
+type blockTxCounter struct{}
+
+// newBlockTxCounter returns a TxCounter that counts non-empty `block.Txs` as
+// one transaction.
+func newBlockTxCounter() TxCounter {
+	return blockTxCounter{}
+}
+
+func (blockTxCounter) CountTxs(txs block.Txs) int {
+	if len(txs) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// ThroughputStat reports the number of blocks, and transactions, that were
+// committed during a window of time.
+type ThroughputStat struct {
+	Window time.Duration
+	Blocks int
+	Txs    int
+
+	BlocksPerSecond float64
+	TxsPerSecond    float64
+}
+
+// Throughput returns the number of blocks, and transactions, that have been
+// committed per second during the most recent window of time. Blocks are
+// attributed to the window using their `block.Timestamp`, and the end of the
+// window is the current time of the Clock.
+func (replica *Replica) Throughput(window time.Duration) ThroughputStat {
+	stat := ThroughputStat{Window: window}
+	if window <= 0 {
+		return stat
+	}
+
+	// Walk backwards from the latest block until a block is found that was
+	// committed before the window (the genesis block is not committed, so it
+	// is excluded)
+	now := replica.options.Clock.Now()
+	since := now.Add(-window)
+	blockchain := replica.blockStorage.Blockchain(replica.shard)
+	latestHeight := replica.blockStorage.LatestBlock(replica.shard).Header().Height()
+	for height := latestHeight; height > 0; height-- {
+		committedBlock, ok := blockchain.BlockAtHeight(height)
+		if !ok {
+			break
+		}
+		timestamp := time.Unix(int64(committedBlock.Header().Timestamp()), 0)
+		if timestamp.Before(since) {
+			break
+		}
+		if timestamp.After(now) {
+			continue
+		}
+		stat.Blocks++
+		stat.Txs += replica.options.TxCounter.CountTxs(committedBlock.Txs())
+	}
+
+	stat.BlocksPerSecond = float64(stat.Blocks) / window.Seconds()
+	stat.TxsPerSecond = float64(stat.Txs) / window.Seconds()
+	return stat
+}
@@ -0,0 +1,94 @@
+package replica
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+)
+
+// mockTxCounter counts every byte in the `block.Txs` as one transaction.
+type mockTxCounter struct{}
+
+func (mockTxCounter) CountTxs(txs block.Txs) int {
+	return len(txs)
+}
+
+var _ = Describe("throughput", func() {
+	Context("when blocks have been committed during the window", func() {
+		It("should return the number of blocks and txs committed per second", func() {
+			test := func(shard Shard) bool {
+				store, _, keys := initStorage(shard)
+				now := time.Unix(time.Now().Unix(), 0)
+
+				// Commit one block per second, for 20 seconds, with an
+				// increasing number of txs
+				initHeight := store.LatestBlock(shard).Header().Height()
+				numBlocks := 20
+				for i := 0; i < numBlocks; i++ {
+					header := RandomBlockHeaderJSON(block.Standard)
+					header.Height = initHeight + block.Height(i+1)
+					header.Timestamp = block.Timestamp(now.Add(-time.Duration(numBlocks-i-1) * time.Second).Unix())
+					txs := make(block.Txs, i)
+					store.Blockchain(shard).InsertBlockAtHeight(header.Height, block.New(header.ToBlockHeader(), txs, nil, nil))
+				}
+
+				pstore := mockProcessStorage{}
+				broadcaster, _ := newMockBroadcaster()
+				options := Options{
+					Clock:     NewMockClock(now),
+					TxCounter: mockTxCounter{},
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				// The window covers the most recent blocks, which have the most
+				// txs
+				window := rand.Intn(numBlocks-1) + 1
+				stat := replica.Throughput(time.Duration(window) * time.Second)
+				expectedBlocks := window + 1
+				expectedTxs := 0
+				for i := numBlocks - expectedBlocks; i < numBlocks; i++ {
+					expectedTxs += i
+				}
+				Expect(stat.Window).Should(Equal(time.Duration(window) * time.Second))
+				Expect(stat.Blocks).Should(Equal(expectedBlocks))
+				Expect(stat.Txs).Should(Equal(expectedTxs))
+				Expect(stat.BlocksPerSecond).Should(BeNumerically("~", float64(expectedBlocks)/float64(window)))
+				Expect(stat.TxsPerSecond).Should(BeNumerically("~", float64(expectedTxs)/float64(window)))
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when no blocks have been committed during the window", func() {
+		It("should return zero throughput", func() {
+			test := func(shard Shard) bool {
+				store, _, keys := initStorage(shard)
+				pstore := mockProcessStorage{}
+				broadcaster, _ := newMockBroadcaster()
+				options := Options{
+					Clock: NewMockClock(time.Now().Add(365 * 24 * time.Hour)),
+				}
+				replica, err := New(options, pstore, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				stat := replica.Throughput(time.Minute)
+				Expect(stat.Blocks).Should(BeZero())
+				Expect(stat.Txs).Should(BeZero())
+				Expect(stat.BlocksPerSecond).Should(BeZero())
+				Expect(stat.TxsPerSecond).Should(BeZero())
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+})
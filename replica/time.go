@@ -8,29 +8,39 @@ import (
 	"github.com/renproject/hyperdrive/process"
 )
 
+// A backOffTimer returns timeouts that grow exponentially with the round, up
+// to a maximum. Waiting for a proposal has its own base timeout, so that
+// silent proposers can be skipped sooner than the prevote and precommit
+// timeouts would allow.
 type backOffTimer struct {
-	exp  float64
-	base time.Duration
-	max  time.Duration
+	exp         float64
+	proposeBase time.Duration
+	base        time.Duration
+	max         time.Duration
 }
 
-func newBackOffTimer(exp float64, base time.Duration, max time.Duration) process.Timer {
+func newBackOffTimer(exp float64, proposeBase time.Duration, base time.Duration, max time.Duration) process.Timer {
 	return &backOffTimer{
-		exp:  exp,
-		base: base,
-		max:  max,
+		exp:         exp,
+		proposeBase: proposeBase,
+		base:        base,
+		max:         max,
 	}
 }
 
 func (timer *backOffTimer) Timeout(step process.Step, round block.Round) time.Duration {
+	base := timer.base
+	if step == process.StepPropose {
+		base = timer.proposeBase
+	}
 	if round == 0 {
-		return timer.base
+		return base
 	}
 	multiplier := math.Pow(timer.exp, float64(round))
 	var duration time.Duration
 
 	// Make sure it doesn't overflow
-	durationFloat := float64(timer.base) * multiplier
+	durationFloat := float64(base) * multiplier
 	if durationFloat > math.MaxInt64 {
 		duration = time.Duration(math.MaxInt64)
 	} else {
@@ -42,3 +52,8 @@ func (timer *backOffTimer) Timeout(step process.Step, round block.Round) time.Du
 	}
 	return duration
 }
+
+// A Clock tells the current time, and waits for durations of time to pass. It
+// is used by the Replica, and its Process, so that time dependent behaviour can
+// be tested deterministically.
+type Clock = process.Clock
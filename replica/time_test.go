@@ -7,6 +7,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
 
 	"github.com/renproject/hyperdrive/block"
 	"github.com/renproject/hyperdrive/process"
@@ -23,7 +24,7 @@ var _ = Describe("timer", func() {
 				max := time.Duration(rand.Int())
 				base := time.Duration(rand.Intn(int(max)))
 				exp := rand.Float64() + 1
-				timer := newBackOffTimer(exp, base, max)
+				timer := newBackOffTimer(exp, base, base, max)
 
 				Expect(timer.Timeout(randomStep(), 0)).Should(Equal(base))
 
@@ -39,5 +40,46 @@ var _ = Describe("timer", func() {
 
 			Expect(quick.Check(test, nil)).Should(Succeed())
 		})
+
+		It("should use the propose base when waiting for a proposal", func() {
+			timer := newBackOffTimer(2, time.Second, time.Minute, time.Hour)
+
+			Expect(timer.Timeout(process.StepPropose, 0)).Should(Equal(time.Second))
+			Expect(timer.Timeout(process.StepPropose, 3)).Should(Equal(8 * time.Second))
+			Expect(timer.Timeout(process.StepPrevote, 0)).Should(Equal(time.Minute))
+			Expect(timer.Timeout(process.StepPrecommit, 3)).Should(Equal(8 * time.Minute))
+			Expect(timer.Timeout(process.StepPropose, 20)).Should(Equal(time.Hour))
+		})
+	})
+
+	Context("when the proposer is silent", func() {
+		It("should prevote nil after the propose timeout", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			clock := NewMockClock(time.Now())
+			options := Options{
+				BackOffBase:        time.Hour,
+				ProposeTimeoutBase: time.Second,
+				Clock:              clock,
+			}
+			replica, err := New(options, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+			replica.Start()
+			defer replica.Close()
+			Expect(replica.NextTimeout()).Should(Equal(time.Second))
+
+			clock.Advance(time.Second - time.Millisecond)
+			Consistently(messages, 10*time.Millisecond).ShouldNot(Receive())
+
+			clock.Advance(time.Millisecond)
+			var message Message
+			Eventually(messages).Should(Receive(&message))
+			prevote, ok := message.Message.(*process.Prevote)
+			Expect(ok).Should(BeTrue())
+			Expect(prevote.Height()).Should(Equal(block.Height(1)))
+			Expect(prevote.Round()).Should(Equal(block.Round(0)))
+			Expect(prevote.BlockHash()).Should(Equal(block.InvalidHash))
+			Expect(replica.Status().State).Should(Equal(process.StepPrevote))
+		})
 	})
 })
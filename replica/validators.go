@@ -0,0 +1,74 @@
+package replica
+
+import (
+	"crypto/sha256"
+
+	"github.com/renproject/id"
+)
+
+// A ValidatorSet is the ordered set of signatories that are allowed to
+// participate in consensus for a Shard. The order is the order of the
+// signatories in the latest base block, and is used for proposer rotation, so
+// all Replicas of the Shard agree on the index of every signatory. A
+// ValidatorSet is never modified after it is created, so it is safe for
+// concurrent use.
+type ValidatorSet struct {
+	signatories id.Signatories
+	indices     map[id.Signatory]int
+}
+
+// NewValidatorSet returns a ValidatorSet of the signatories, in order. If a
+// signatory appears more than once, its index is the index of its first
+// appearance.
+func NewValidatorSet(signatories id.Signatories) ValidatorSet {
+	validators := ValidatorSet{
+		signatories: make(id.Signatories, len(signatories)),
+		indices:     make(map[id.Signatory]int, len(signatories)),
+	}
+	copy(validators.signatories, signatories)
+	for i, sig := range signatories {
+		if _, ok := validators.indices[sig]; !ok {
+			validators.indices[sig] = i
+		}
+	}
+	return validators
+}
+
+// Contains returns true if the signatory is a member of the ValidatorSet.
+func (validators ValidatorSet) Contains(sig id.Signatory) bool {
+	_, ok := validators.indices[sig]
+	return ok
+}
+
+// Index returns the index of the signatory in the ValidatorSet, or -1 if it is
+// not a member.
+func (validators ValidatorSet) Index(sig id.Signatory) int {
+	i, ok := validators.indices[sig]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// Len returns the number of signatories in the ValidatorSet.
+func (validators ValidatorSet) Len() int {
+	return len(validators.signatories)
+}
+
+// Signatories returns a copy of the signatories in the ValidatorSet, in order.
+func (validators ValidatorSet) Signatories() id.Signatories {
+	signatories := make(id.Signatories, len(validators.signatories))
+	copy(signatories, validators.signatories)
+	return signatories
+}
+
+// Hash returns the SHA256 hash of the signatories in the ValidatorSet, in
+// order. It commits to the ValidatorSet in a LightCommitProof, so that
+// verifiers only need to trust the hash, and not the signatories themselves.
+func (validators ValidatorSet) Hash() id.Hash {
+	data := make([]byte, 0, len(validators.signatories)*len(id.Signatory{}))
+	for _, sig := range validators.signatories {
+		data = append(data, sig[:]...)
+	}
+	return sha256.Sum256(data)
+}
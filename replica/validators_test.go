@@ -0,0 +1,96 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing/quick"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+var _ = Describe("validator set", func() {
+
+	newEcdsaKey := func() *ecdsa.PrivateKey {
+		privateKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return privateKey
+	}
+
+	Context("when checking membership", func() {
+		It("should contain, and index, every member in order", func() {
+			test := func() bool {
+				sigs := make(id.Signatories, 7)
+				for i := range sigs {
+					sigs[i] = RandomSignatory()
+				}
+				validators := NewValidatorSet(sigs)
+
+				Expect(validators.Len()).Should(Equal(len(sigs)))
+				Expect(validators.Signatories()).Should(Equal(sigs))
+				for i, sig := range sigs {
+					Expect(validators.Contains(sig)).Should(BeTrue())
+					Expect(validators.Index(sig)).Should(Equal(i))
+				}
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+
+		It("should not contain, or index, a non-member", func() {
+			test := func() bool {
+				validators := NewValidatorSet(id.Signatories{RandomSignatory(), RandomSignatory()})
+				nonMember := RandomSignatory()
+
+				Expect(validators.Contains(nonMember)).Should(BeFalse())
+				Expect(validators.Index(nonMember)).Should(Equal(-1))
+				Expect(NewValidatorSet(nil).Contains(nonMember)).Should(BeFalse())
+				return true
+			}
+
+			Expect(quick.Check(test, nil)).Should(Succeed())
+		})
+	})
+
+	Context("when rotating proposers", func() {
+		It("should schedule proposers by their index in the validator set", func() {
+			test := func(shard Shard, skip uint8) bool {
+				store, keys := initGenesisStorage(shard)
+				broadcaster, messages := newMockBroadcaster()
+				go func() {
+					for range messages {
+					}
+				}()
+				replica, err := New(Options{MaxFutureRounds: 256}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, shard, *keys[0])
+				Expect(err).NotTo(HaveOccurred())
+				validators := replica.Validators()
+				Expect(validators.Signatories()).Should(Equal(store.LatestBaseBlock(shard).Header().Signatories()))
+
+				// Skip to a future round by sending f+1 prevotes
+				round := block.Round(skip) + 1
+				for _, key := range keys[:3] {
+					prevote := process.NewPrevote(1, round, block.InvalidHash, nil)
+					Expect(process.Sign(prevote, *key)).Should(Succeed())
+					Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Succeed())
+				}
+				Expect(validators.Index(replica.Proposer())).Should(Equal((1 + int(round)) % validators.Len()))
+
+				// Expect messages from non-members to be rejected
+				prevote := process.NewPrevote(1, round, block.InvalidHash, nil)
+				Expect(process.Sign(prevote, *newEcdsaKey())).Should(Succeed())
+				Expect(validators.Contains(prevote.Signatory())).Should(BeFalse())
+				Expect(replica.HandleMessage(Message{Shard: shard, Message: prevote})).Should(Equal(ErrInvalidSignatory))
+				return true
+			}
+
+			Expect(quick.Check(test, &quick.Config{MaxCount: 10})).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package replica
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A verificationCache is a `process.Verifier` that remembers successful
+// verifications by an underlying `process.Verifier`, so that Messages that are
+// gossiped more than once are only verified once. It is keyed by the sighash,
+// signature, and signatory, so a Message that has been tampered with is always
+// verified again. Failed verifications are never remembered.
+//
+// Verifications are remembered for the current height and the previous height,
+// so that late Messages from the previous height are also covered. The cache
+// is cleared of all older verifications whenever the height advances, and it
+// never holds more than twice its capacity.
+type verificationCache struct {
+	verifier process.Verifier
+
+	mu       *sync.Mutex
+	capacity int
+	height   block.Height
+	current  map[id.Hash]struct{}
+	previous map[id.Hash]struct{}
+}
+
+func newVerificationCache(verifier process.Verifier, capacity int) *verificationCache {
+	return &verificationCache{
+		verifier: verifier,
+
+		mu:       new(sync.Mutex),
+		capacity: capacity,
+		height:   0,
+		current:  map[id.Hash]struct{}{},
+		previous: map[id.Hash]struct{}{},
+	}
+}
+
+// Verify implements the `process.Verifier` interface.
+func (cache *verificationCache) Verify(hash, sig []byte, signatory id.Signatory) error {
+	key := verificationKey(hash, sig, signatory)
+
+	cache.mu.Lock()
+	_, inCurrent := cache.current[key]
+	_, inPrevious := cache.previous[key]
+	cache.mu.Unlock()
+	if inCurrent || inPrevious {
+		return nil
+	}
+
+	// Verify without holding the lock, because verification is expensive
+	if err := cache.verifier.Verify(hash, sig, signatory); err != nil {
+		return err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if len(cache.current) >= cache.capacity {
+		cache.previous = cache.current
+		cache.current = map[id.Hash]struct{}{}
+	}
+	cache.current[key] = struct{}{}
+	return nil
+}
+
+// didAdvance clears the cache of verifications from before the previous
+// height, if the height has advanced.
+func (cache *verificationCache) didAdvance(height block.Height) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if height <= cache.height {
+		return
+	}
+	if height == cache.height+1 {
+		cache.previous = cache.current
+	} else {
+		cache.previous = map[id.Hash]struct{}{}
+	}
+	cache.current = map[id.Hash]struct{}{}
+	cache.height = height
+}
+
+// verificationKey returns the key of a verification in the cache, covering
+// everything that the result of the verification depends on.
+func verificationKey(hash, sig []byte, signatory id.Signatory) id.Hash {
+	data := make([]byte, 0, len(hash)+len(sig)+len(signatory))
+	data = append(data, hash...)
+	data = append(data, sig...)
+	data = append(data, signatory[:]...)
+	return sha256.Sum256(data)
+}
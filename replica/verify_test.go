@@ -0,0 +1,185 @@
+package replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/process"
+)
+
+func newCountingVerifier() (countingVerifier, *int64) {
+	count := new(int64)
+	return countingVerifier{count: count, verifier: process.NewECDSAVerifier()}, count
+}
+
+func newSignedPrevote(key *ecdsa.PrivateKey) *process.Prevote {
+	prevote := process.NewPrevote(1, 0, RandomHash(), nil)
+	if err := process.Sign(prevote, *key); err != nil {
+		panic(err)
+	}
+	return prevote
+}
+
+func newVerificationKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+var _ = Describe("verification cache", func() {
+	Context("when verifying the same message more than once", func() {
+		It("should only verify it once", func() {
+			verifier, count := newCountingVerifier()
+			cache := newVerificationCache(verifier, 100)
+			prevote := newSignedPrevote(newVerificationKey())
+
+			for i := 0; i < 10; i++ {
+				Expect(process.VerifyWith(prevote, cache)).Should(Succeed())
+			}
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(1)))
+		})
+	})
+
+	Context("when verifying a message that has been tampered with", func() {
+		It("should never be served from the cache", func() {
+			verifier, count := newCountingVerifier()
+			cache := newVerificationCache(verifier, 100)
+			key := newVerificationKey()
+			prevote := newSignedPrevote(key)
+			Expect(process.VerifyWith(prevote, cache)).Should(Succeed())
+
+			// Reuse the signature for a prevote for a different block
+			tampered := process.NewPrevote(prevote.Height(), prevote.Round(), RandomHash(), nil)
+			Expect(process.SignWith(tampered, impersonatingSigner{
+				signatory: prevote.Signatory(),
+				signer:    process.NewECDSASigner(*newVerificationKey()),
+			})).Should(Succeed())
+			for i := 0; i < 3; i++ {
+				Expect(process.VerifyWith(tampered, cache)).ShouldNot(Succeed())
+			}
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(4)))
+		})
+
+		It("should never cache a failed verification", func() {
+			verifier, count := newCountingVerifier()
+			cache := newVerificationCache(verifier, 100)
+			prevote := newSignedPrevote(newVerificationKey())
+			sigHash, sig := prevote.SigHash(), prevote.Sig()
+			other := RandomSignatory()
+
+			for i := 0; i < 3; i++ {
+				Expect(cache.Verify(sigHash[:], sig[:], other)).ShouldNot(Succeed())
+			}
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(3)))
+			Expect(cache.Verify(sigHash[:], sig[:], prevote.Signatory())).Should(Succeed())
+			Expect(cache.Verify(sigHash[:], sig[:], other)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when the height advances", func() {
+		It("should remember verifications for the current and previous heights", func() {
+			verifier, count := newCountingVerifier()
+			cache := newVerificationCache(verifier, 100)
+			prevote := newSignedPrevote(newVerificationKey())
+
+			cache.didAdvance(1)
+			Expect(process.VerifyWith(prevote, cache)).Should(Succeed())
+			cache.didAdvance(2)
+			Expect(process.VerifyWith(prevote, cache)).Should(Succeed())
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(1)))
+
+			// Expect the verification to be forgotten after two heights
+			cache.didAdvance(4)
+			Expect(process.VerifyWith(prevote, cache)).Should(Succeed())
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(2)))
+		})
+	})
+
+	Context("when a proposal embeds prevotes that have already been received", func() {
+		It("should only verify the proposal and the other prevotes", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			go func() {
+				for range messages {
+				}
+			}()
+			verifier, count := newCountingVerifier()
+			replica, err := New(Options{Verifier: verifier}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			proposedBlock := replica.rebaser.BlockProposal(1, 0)
+			polka := []process.Prevote{}
+			for _, key := range keys[1:6] {
+				prevote := process.NewPrevote(1, 0, proposedBlock.Hash(), nil)
+				Expect(process.Sign(prevote, *key)).Should(Succeed())
+				polka = append(polka, *prevote)
+			}
+			for i := range polka[:4] {
+				Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: &polka[i]})).Should(Succeed())
+			}
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(4)))
+
+			// Expect the prevotes that have already been received to be served
+			// from the cache, and the other prevote to be verified by the
+			// verifier of the replica
+			Expect(replica.ForceRound(1)).Should(Succeed())
+			propose := ProposeWithPolka(process.NewPropose(1, 1, proposedBlock, 0), polka)
+			Expect(process.Sign(propose, *keys[2])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			Expect(replica.p.HasReceived(propose)).Should(BeTrue())
+			Expect(atomic.LoadInt64(count)).Should(Equal(int64(6)))
+		})
+	})
+
+	Context("when the cache is full", func() {
+		It("should never hold more than twice its capacity", func() {
+			cache := newVerificationCache(process.NewECDSAVerifier(), 2)
+			key := newVerificationKey()
+			for i := 0; i < 10; i++ {
+				Expect(process.VerifyWith(newSignedPrevote(key), cache)).Should(Succeed())
+				Expect(len(cache.current) + len(cache.previous)).Should(BeNumerically("<=", 4))
+			}
+		})
+	})
+})
+
+// BenchmarkVerificationCache verifies duplicate traffic, where every message is
+// received 10 times, with and without a verification cache.
+func BenchmarkVerificationCache(b *testing.B) {
+	key := newVerificationKey()
+	prevotes := make([]*process.Prevote, 10)
+	for i := range prevotes {
+		prevotes[i] = newSignedPrevote(key)
+	}
+
+	run := func(b *testing.B, verifier process.Verifier, count *int64) {
+		for i := 0; i < b.N; i++ {
+			for _, prevote := range prevotes {
+				for j := 0; j < 10; j++ {
+					if err := process.VerifyWith(prevote, verifier); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		}
+		b.Logf("%v verifications for %v messages", atomic.LoadInt64(count), b.N*len(prevotes)*10)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		verifier, count := newCountingVerifier()
+		run(b, verifier, count)
+	})
+	b.Run("cached", func(b *testing.B) {
+		verifier, count := newCountingVerifier()
+		run(b, newVerificationCache(verifier, 100), count)
+	})
+}
@@ -0,0 +1,128 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A Watermark is the height, round, and step of the latest vote signed by a
+// Replica, and the sighash of that vote. The step is the `process.MessageType`
+// of the vote, so that a Precommit is later than the Prevote at the same height
+// and round. The zero Watermark is earlier than every vote.
+type Watermark struct {
+	Height  block.Height
+	Round   block.Round
+	Step    process.MessageType
+	SigHash id.Hash
+}
+
+// before returns true if the Watermark is strictly earlier than the other
+// Watermark. The sighashes are ignored.
+func (watermark Watermark) before(other Watermark) bool {
+	if watermark.Height != other.Height {
+		return watermark.Height < other.Height
+	}
+	if watermark.Round != other.Round {
+		return watermark.Round < other.Round
+	}
+	return watermark.Step < other.Step
+}
+
+// A WatermarkStorage durably stores the Watermark of the votes signed by the
+// Replica of a Shard. SaveWatermark must not return until the Watermark is
+// durable, because the signature of a vote is only released after its
+// Watermark has been saved. LoadWatermark returns the zero Watermark if no
+// Watermark has been saved for the Shard.
+type WatermarkStorage interface {
+	SaveWatermark(shard Shard, watermark Watermark) error
+	LoadWatermark(shard Shard) Watermark
+}
+
+type memoryWatermarkStorage struct {
+	mu         *sync.Mutex
+	watermarks map[Shard]Watermark
+}
+
+// newMemoryWatermarkStorage returns a WatermarkStorage that is not durable. It
+// protects against a buggy `process.Process` signing conflicting votes, but not
+// against double signing after a restart.
+func newMemoryWatermarkStorage() WatermarkStorage {
+	return &memoryWatermarkStorage{
+		mu:         new(sync.Mutex),
+		watermarks: map[Shard]Watermark{},
+	}
+}
+
+// SaveWatermark implements the `WatermarkStorage` interface.
+func (storage *memoryWatermarkStorage) SaveWatermark(shard Shard, watermark Watermark) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.watermarks[shard] = watermark
+	return nil
+}
+
+// LoadWatermark implements the `WatermarkStorage` interface.
+func (storage *memoryWatermarkStorage) LoadWatermark(shard Shard) Watermark {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	return storage.watermarks[shard]
+}
+
+// A doubleSignGuard refuses to sign a Prevote or Precommit unless it is
+// strictly later than the latest vote that has been signed, so that no bug in
+// the `process.Process`, and no restart from stale state, can cause the Replica
+// to sign two conflicting votes. Re-signing the latest vote is allowed, because
+// it cannot conflict with itself and is needed to rebroadcast lost votes.
+type doubleSignGuard struct {
+	mu        *sync.Mutex
+	shard     Shard
+	storage   WatermarkStorage
+	watermark Watermark
+}
+
+func newDoubleSignGuard(storage WatermarkStorage, shard Shard) *doubleSignGuard {
+	return &doubleSignGuard{
+		mu:        new(sync.Mutex),
+		shard:     shard,
+		storage:   storage,
+		watermark: storage.LoadWatermark(shard),
+	}
+}
+
+// allow returns nil if the Message can be signed, after raising the Watermark
+// to the Message and saving it. Messages that are not votes are always
+// allowed.
+func (guard *doubleSignGuard) allow(m process.Message) error {
+	switch m.(type) {
+	case *process.Prevote, *process.Precommit:
+	default:
+		return nil
+	}
+
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+
+	watermark := Watermark{
+		Height:  m.Height(),
+		Round:   m.Round(),
+		Step:    m.Type(),
+		SigHash: m.SigHash(),
+	}
+	if !guard.watermark.before(watermark) {
+		if watermark == guard.watermark {
+			return nil
+		}
+		return fmt.Errorf("refusing to sign %T at height=%v, round=%v: already signed height=%v, round=%v, step=%v", m, watermark.Height, watermark.Round, guard.watermark.Height, guard.watermark.Round, guard.watermark.Step)
+	}
+	if err := guard.storage.SaveWatermark(guard.shard, watermark); err != nil {
+		return fmt.Errorf("error saving watermark: %v", err)
+	}
+	guard.watermark = watermark
+	return nil
+}
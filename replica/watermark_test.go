@@ -0,0 +1,121 @@
+package replica
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/hyperdrive/testutil"
+
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+)
+
+type failingWatermarkStorage struct {
+	WatermarkStorage
+}
+
+func (failingWatermarkStorage) SaveWatermark(Shard, Watermark) error {
+	return errors.New("disk full")
+}
+
+var _ = Describe("double sign protection", func() {
+	Context("when signing votes in order", func() {
+		It("should allow every vote, and save the watermark before allowing it", func() {
+			storage := newMemoryWatermarkStorage()
+			guard := newDoubleSignGuard(storage, Shard{})
+
+			votes := []process.Message{
+				process.NewPrevote(1, 0, RandomHash(), nil),
+				process.NewPrecommit(1, 0, RandomHash()),
+				process.NewPrevote(1, 1, RandomHash(), nil),
+				process.NewPrecommit(1, 1, RandomHash()),
+				process.NewPrevote(2, 0, RandomHash(), nil),
+			}
+			for _, vote := range votes {
+				Expect(guard.allow(vote)).Should(Succeed())
+				Expect(storage.LoadWatermark(Shard{})).Should(Equal(Watermark{
+					Height:  vote.Height(),
+					Round:   vote.Round(),
+					Step:    vote.Type(),
+					SigHash: vote.SigHash(),
+				}))
+			}
+		})
+
+		It("should allow the latest vote to be signed again", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			prevote := process.NewPrevote(1, 0, RandomHash(), nil)
+			Expect(guard.allow(prevote)).Should(Succeed())
+			Expect(guard.allow(prevote)).Should(Succeed())
+		})
+
+		It("should always allow messages that are not votes", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			Expect(guard.allow(process.NewPrevote(10, 10, RandomHash(), nil))).Should(Succeed())
+			Expect(guard.allow(process.NewPropose(1, 0, RandomBlock(block.Standard), block.InvalidRound))).Should(Succeed())
+			Expect(guard.allow(process.NewResign(1, 0))).Should(Succeed())
+		})
+	})
+
+	Context("when signing a regressed vote", func() {
+		It("should refuse to sign a vote at a lower height", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			Expect(guard.allow(process.NewPrevote(2, 0, RandomHash(), nil))).Should(Succeed())
+			Expect(guard.allow(process.NewPrevote(1, 5, RandomHash(), nil))).ShouldNot(Succeed())
+			Expect(guard.allow(process.NewPrecommit(1, 5, RandomHash()))).ShouldNot(Succeed())
+		})
+
+		It("should refuse to sign a vote at a lower round", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			Expect(guard.allow(process.NewPrevote(1, 3, RandomHash(), nil))).Should(Succeed())
+			Expect(guard.allow(process.NewPrevote(1, 2, RandomHash(), nil))).ShouldNot(Succeed())
+			Expect(guard.allow(process.NewPrecommit(1, 2, RandomHash()))).ShouldNot(Succeed())
+		})
+
+		It("should refuse to sign a prevote after a precommit at the same round", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			Expect(guard.allow(process.NewPrecommit(1, 0, RandomHash()))).Should(Succeed())
+			Expect(guard.allow(process.NewPrevote(1, 0, RandomHash(), nil))).ShouldNot(Succeed())
+		})
+
+		It("should refuse to sign a different vote at the same step", func() {
+			guard := newDoubleSignGuard(newMemoryWatermarkStorage(), Shard{})
+			Expect(guard.allow(process.NewPrevote(1, 0, RandomHash(), nil))).Should(Succeed())
+			Expect(guard.allow(process.NewPrevote(1, 0, RandomHash(), nil))).ShouldNot(Succeed())
+		})
+
+		It("should refuse to sign a regressed vote after a restart", func() {
+			storage := newMemoryWatermarkStorage()
+			guard := newDoubleSignGuard(storage, Shard{})
+			Expect(guard.allow(process.NewPrecommit(5, 1, RandomHash()))).Should(Succeed())
+
+			restarted := newDoubleSignGuard(storage, Shard{})
+			Expect(restarted.allow(process.NewPrevote(5, 1, RandomHash(), nil))).ShouldNot(Succeed())
+			Expect(restarted.allow(process.NewPrevote(5, 2, RandomHash(), nil))).Should(Succeed())
+		})
+	})
+
+	Context("when the watermark cannot be saved", func() {
+		It("should refuse to sign", func() {
+			guard := newDoubleSignGuard(failingWatermarkStorage{newMemoryWatermarkStorage()}, Shard{})
+			Expect(guard.allow(process.NewPrevote(1, 0, RandomHash(), nil))).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when a replica restarts behind its watermark", func() {
+		It("should not broadcast votes below the watermark", func() {
+			store, keys := initGenesisStorage(Shard{})
+			broadcaster, messages := newMockBroadcaster()
+			storage := newMemoryWatermarkStorage()
+			Expect(storage.SaveWatermark(Shard{}, Watermark{Height: 1, Round: 0, Step: process.PrecommitMessageType})).Should(Succeed())
+			replica, err := New(Options{Watermarks: storage}, mockProcessStorage{}, store, mockBlockIterator{}, nil, nil, broadcaster, Shard{}, *keys[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			propose := process.NewPropose(1, 0, replica.rebaser.BlockProposal(1, 0), block.InvalidRound)
+			Expect(process.Sign(propose, *keys[1])).Should(Succeed())
+			Expect(replica.HandleMessage(Message{Shard: Shard{}, Message: propose})).Should(Succeed())
+			Consistently(messages).ShouldNot(Receive())
+		})
+	})
+})
@@ -3,6 +3,7 @@ package testutil
 import (
 	"crypto/ecdsa"
 	cRand "crypto/rand"
+	"encoding/json"
 	"math/rand"
 	"sync"
 	"time"
@@ -60,6 +61,12 @@ func RandomMessage(t process.MessageType) process.Message {
 		return RandomPrevote()
 	case process.PrecommitMessageType:
 		return RandomPrecommit()
+	case process.ResignMessageType:
+		return RandomResign()
+	case process.CatchUpRequestMessageType:
+		return RandomCatchUpRequest()
+	case process.CommitRangeMessageType:
+		return RandomCommitRange()
 	default:
 		panic("unknown message type")
 	}
@@ -78,6 +85,8 @@ func RandomMessageWithHeightAndRound(height block.Height, round block.Round, t p
 	case process.PrecommitMessageType:
 		hash := RandomHash()
 		msg = process.NewPrecommit(height, round, hash)
+	case process.ResignMessageType:
+		msg = process.NewResign(height, round)
 	default:
 		panic("unknown message type")
 	}
@@ -103,6 +112,54 @@ func RandomPropose() *process.Propose {
 	return process.NewPropose(height, round, block, validRound)
 }
 
+// ProposeWithLatestCommit returns a copy of the propose with the latest commit
+// attached. The returned propose is not signed.
+func ProposeWithLatestCommit(propose *process.Propose, latestCommit process.LatestCommit) *process.Propose {
+	data, err := json.Marshal(propose)
+	if err != nil {
+		panic(err)
+	}
+	tmp := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		panic(err)
+	}
+	if tmp["latestCommit"], err = json.Marshal(latestCommit); err != nil {
+		panic(err)
+	}
+	if data, err = json.Marshal(tmp); err != nil {
+		panic(err)
+	}
+	newPropose := new(process.Propose)
+	if err := json.Unmarshal(data, newPropose); err != nil {
+		panic(err)
+	}
+	return newPropose
+}
+
+// ProposeWithPolka returns a copy of the propose with the polka attached. The
+// returned propose is not signed.
+func ProposeWithPolka(propose *process.Propose, polka []process.Prevote) *process.Propose {
+	data, err := json.Marshal(propose)
+	if err != nil {
+		panic(err)
+	}
+	tmp := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		panic(err)
+	}
+	if tmp["polka"], err = json.Marshal(polka); err != nil {
+		panic(err)
+	}
+	if data, err = json.Marshal(tmp); err != nil {
+		panic(err)
+	}
+	newPropose := new(process.Propose)
+	if err := json.Unmarshal(data, newPropose); err != nil {
+		panic(err)
+	}
+	return newPropose
+}
+
 func RandomPrevote() *process.Prevote {
 	height := block.Height(rand.Int63())
 	round := block.Round(rand.Int63())
@@ -111,14 +168,42 @@ func RandomPrevote() *process.Prevote {
 	nilReasons["key1"] = []byte("val1")
 	nilReasons["key2"] = []byte("val2")
 	nilReasons["key3"] = []byte("val3")
-	return process.NewPrevote(height, round, hash, nilReasons)
+	return process.NewPrevoteWithExtension(height, round, hash, nilReasons, RandomBytesSlice())
 }
 
 func RandomPrecommit() *process.Precommit {
 	height := block.Height(rand.Int63())
 	round := block.Round(rand.Int63())
 	hash := RandomHash()
-	return process.NewPrecommit(height, round, hash)
+	return process.NewPrecommitWithExtension(height, round, hash, RandomBytesSlice())
+}
+
+func RandomResign() *process.Resign {
+	height := block.Height(rand.Int63())
+	round := block.Round(rand.Int63())
+	return process.NewResign(height, round)
+}
+
+func RandomCatchUpRequest() *process.CatchUpRequest {
+	height := block.Height(rand.Int63())
+	return process.NewCatchUpRequest(height)
+}
+
+func RandomCommitRange() *process.CommitRange {
+	numCommits := rand.Intn(10)
+	commits := make([]process.LatestCommit, numCommits)
+	for i := range commits {
+		numPrecommits := rand.Intn(10)
+		precommits := make([]process.Precommit, numPrecommits)
+		for j := range precommits {
+			precommits[j] = *RandomPrecommit()
+		}
+		commits[i] = process.LatestCommit{
+			Block:      RandomBlock(RandomBlockKind()),
+			Precommits: precommits,
+		}
+	}
+	return process.NewCommitRange(commits)
 }
 
 func RandomMessageType() process.MessageType {
@@ -156,6 +241,7 @@ type ProcessOrigin struct {
 	Scheduler   process.Scheduler
 	Broadcaster process.Broadcaster
 	Timer       process.Timer
+	Clock       process.Clock
 	Observer    process.Observer
 }
 
@@ -188,12 +274,13 @@ func NewProcessOrigin(f int) ProcessOrigin {
 		Scheduler:   NewMockScheduler(sig),
 		Broadcaster: NewMockBroadcaster(messages),
 		Timer:       NewMockTimer(1 * time.Second),
+		Clock:       process.NewSystemClock(),
 		Observer:    MockObserver{},
 	}
 }
 
 func (p ProcessOrigin) ToProcess() *process.Process {
-	return process.New(
+	proc := process.New(
 		logrus.StandardLogger(),
 		p.Signatory,
 		p.Blockchain,
@@ -205,6 +292,8 @@ func (p ProcessOrigin) ToProcess() *process.Process {
 		p.Scheduler,
 		p.Timer,
 	)
+	proc.UseClock(p.Clock)
+	return proc
 }
 
 type MockBlockchain struct {
@@ -229,11 +318,12 @@ func NewMockBlockchain(signatories id.Signatories) *MockBlockchain {
 	}
 }
 
-func (bc *MockBlockchain) InsertBlockAtHeight(height block.Height, block block.Block) {
+func (bc *MockBlockchain) InsertBlockAtHeight(height block.Height, block block.Block) error {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
 	bc.blocks[height] = block
+	return nil
 }
 
 func (bc *MockBlockchain) InsertBlockStatAtHeight(height block.Height, state block.State) {
@@ -320,6 +410,12 @@ func (m MockObserver) DidCommitBlock(block.Height) {
 func (m MockObserver) DidReceiveSufficientNilPrevotes(process.Messages, int) {
 }
 
+func (m MockObserver) DidBecomeProposer(block.Height, block.Round) {
+}
+
+func (m MockObserver) DidFailToCommitBlock(block.Height, error) {
+}
+
 type MockBroadcaster struct {
 	messages chan<- process.Message
 }
@@ -360,6 +456,66 @@ func (timer *MockTimer) Timeout(step process.Step, round block.Round) time.Durat
 	return timer.timeout
 }
 
+// MockClock is a `process.Clock` that only moves forward in time when it is
+// advanced.
+type MockClock struct {
+	mu      *sync.Mutex
+	now     time.Time
+	waiters []mockClockWaiter
+}
+
+type mockClockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{
+		mu:      new(sync.Mutex),
+		now:     now,
+		waiters: []mockClockWaiter{},
+	}
+}
+
+func (clock *MockClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	return clock.now
+}
+
+func (clock *MockClock) After(duration time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	at := clock.now.Add(duration)
+	if !at.After(clock.now) {
+		ch <- clock.now
+		return ch
+	}
+	clock.waiters = append(clock.waiters, mockClockWaiter{at: at, ch: ch})
+	return ch
+}
+
+// Advance the MockClock by a duration, and fire all of the channels returned
+// by After that are due.
+func (clock *MockClock) Advance(duration time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	clock.now = clock.now.Add(duration)
+	waiters := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.at.After(clock.now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.ch <- clock.now
+	}
+	clock.waiters = waiters
+}
+
 func GetStateFromProcess(p *process.Process, f int) process.State {
 	data, err := p.MarshalBinary()
 	if err != nil {
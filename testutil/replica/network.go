@@ -0,0 +1,288 @@
+package testutil_replica
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/hyperdrive/block"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/testutil"
+	"github.com/renproject/id"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxTimeoutsPerStep is the maximum number of timeouts that can expire while
+// stepping a ReplicaNetwork, before the height is considered to be stuck.
+const MaxTimeoutsPerStep = 20
+
+// A Behavior determines how a Replica in a ReplicaNetwork behaves when it
+// broadcasts prevotes. Byzantine behaviors are injected by the network, so the
+// Replica itself is unaware of them.
+type Behavior uint8
+
+const (
+	// Honest Replicas broadcast their prevotes unmodified.
+	Honest Behavior = iota
+	// Equivocate broadcasts every prevote, followed by a conflicting prevote
+	// for a random block at the same height and round.
+	Equivocate
+	// Silent Replicas never broadcast prevotes.
+	Silent
+	// NilPrevote replaces every prevote with a nil prevote.
+	NilPrevote
+)
+
+// A ReplicaNetwork is a network of Replicas that share a Shard, and that are
+// connected by an in-memory broadcaster. Messages are queued when they are
+// broadcast, and are only delivered when the network is stepped, so tests can
+// drive the Replicas to consensus deterministically.
+type ReplicaNetwork struct {
+	Shard    replica.Shard
+	Replicas []replica.Replica
+	Stores   []*MockPersistentStorage
+
+	clock   *testutil.MockClock
+	timeout time.Duration
+	keys    []*ecdsa.PrivateKey
+	indices map[id.Signatory]int
+
+	mu                *sync.Mutex
+	queue             []replica.Message
+	behaviors         []Behavior
+	voteEquivocations [][]replica.VoteEquivocation
+}
+
+// NewReplicaNetwork returns a ReplicaNetwork of n Replicas, of which the first
+// 3f+1 are the signatories of the genesis block. The Replicas are not started.
+func NewReplicaNetwork(n, f int) *ReplicaNetwork {
+	if n < 3*f+1 {
+		panic(fmt.Sprintf("pre-condition violation: expected at least %v replicas, got %v", 3*f+1, n))
+	}
+
+	keys := make([]*ecdsa.PrivateKey, n)
+	sigs := make(id.Signatories, n)
+	for i := range keys {
+		key, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		if err != nil {
+			panic(fmt.Sprintf("cannot generate private key, err = %v", err))
+		}
+		keys[i], sigs[i] = key, id.NewSignatory(key.PublicKey)
+	}
+	genesisBlock := testutil.GenesisBlock(sigs[:3*f+1])
+
+	network := &ReplicaNetwork{
+		Shard:    RandomShard(),
+		Replicas: make([]replica.Replica, n),
+		Stores:   make([]*MockPersistentStorage, n),
+
+		clock:   testutil.NewMockClock(time.Now()),
+		timeout: time.Second,
+		keys:    keys,
+		indices: make(map[id.Signatory]int, n),
+
+		mu:                new(sync.Mutex),
+		queue:             []replica.Message{},
+		behaviors:         make([]Behavior, n),
+		voteEquivocations: make([][]replica.VoteEquivocation, n),
+	}
+	for i := range network.Replicas {
+		i := i
+		network.indices[sigs[i]] = i
+		logger := logrus.New()
+		logger.SetOutput(ioutil.Discard)
+		options := replica.Options{
+			Logger:      logger.WithField("replica", i),
+			BackOffExp:  1,
+			BackOffBase: network.timeout,
+			BackOffMax:  network.timeout,
+			Clock:       network.clock,
+			OnVoteEquivocation: func(equivocation replica.VoteEquivocation) {
+				network.mu.Lock()
+				defer network.mu.Unlock()
+				network.voteEquivocations[i] = append(network.voteEquivocations[i], equivocation)
+			},
+		}
+		store := NewMockPersistentStorage(replica.Shards{network.Shard})
+		store.Init(genesisBlock)
+		network.Stores[i] = store
+		r, err := replica.New(options, store, store, NewMockBlockIterator(store), NewMockValidator(store), NewMockObserver(store, i < 3*f+1), network, network.Shard, *keys[i])
+		if err != nil {
+			panic(fmt.Sprintf("cannot create replica, err = %v", err))
+		}
+		network.Replicas[i] = r
+	}
+	return network
+}
+
+// Signatory of the ith Replica.
+func (network *ReplicaNetwork) Signatory(i int) id.Signatory {
+	return id.NewSignatory(network.keys[i].PublicKey)
+}
+
+// SetBehavior of the ith Replica. All Replicas are Honest by default. Byzantine
+// Replicas are expected to be a minority of the signatories.
+func (network *ReplicaNetwork) SetBehavior(i int, behavior Behavior) {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	network.behaviors[i] = behavior
+}
+
+// VoteEquivocations returns the evidence of vote equivocation that has been
+// reported by the ith Replica.
+func (network *ReplicaNetwork) VoteEquivocations(i int) []replica.VoteEquivocation {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	equivocations := make([]replica.VoteEquivocation, len(network.voteEquivocations[i]))
+	copy(equivocations, network.voteEquivocations[i])
+	return equivocations
+}
+
+// Broadcast implements the `replica.Broadcaster` interface by queueing the
+// Message until the network is stepped. Prevotes from Byzantine Replicas are
+// modified according to their Behavior.
+func (network *ReplicaNetwork) Broadcast(m replica.Message) {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	prevote, ok := m.Message.(*process.Prevote)
+	if !ok {
+		network.queue = append(network.queue, m)
+		return
+	}
+	i := network.indices[prevote.Signatory()]
+	switch network.behaviors[i] {
+	case Honest:
+		network.queue = append(network.queue, m)
+	case Equivocate:
+		network.queue = append(network.queue, m)
+		network.queue = append(network.queue, network.prevote(i, prevote.Height(), prevote.Round(), testutil.RandomHash()))
+	case Silent:
+	case NilPrevote:
+		network.queue = append(network.queue, network.prevote(i, prevote.Height(), prevote.Round(), block.InvalidHash))
+	default:
+		panic(fmt.Sprintf("unknown behavior=%v", network.behaviors[i]))
+	}
+}
+
+// prevote returns a Message with a prevote for the block hash, signed by the
+// ith Replica.
+func (network *ReplicaNetwork) prevote(i int, height block.Height, round block.Round, blockHash id.Hash) replica.Message {
+	prevote := process.NewPrevote(height, round, blockHash, nil)
+	if err := process.Sign(prevote, *network.keys[i]); err != nil {
+		panic(fmt.Sprintf("cannot sign prevote, err = %v", err))
+	}
+	return replica.Message{Shard: network.Shard, Message: prevote}
+}
+
+// Start all Replicas in the network.
+func (network *ReplicaNetwork) Start() {
+	for i := range network.Replicas {
+		network.Replicas[i].Start()
+	}
+}
+
+// Close all Replicas in the network.
+func (network *ReplicaNetwork) Close() {
+	for i := range network.Replicas {
+		network.Replicas[i].Close()
+	}
+}
+
+// Step delivers queued Messages to every Replica, and expires timeouts
+// whenever there are no queued Messages, until every Honest Replica has
+// committed the lowest height at which any Honest Replica is trying to reach
+// consensus. It returns
+// the committed height, or an error if the height is not committed within
+// MaxTimeoutsPerStep timeouts.
+func (network *ReplicaNetwork) Step() (block.Height, error) {
+	height := network.lowestHeight()
+	for timeouts := 0; ; timeouts++ {
+		// Stop delivering as soon as the height is committed, so that the
+		// Replicas do not race ahead to later heights
+		for network.deliver() {
+			if network.lowestHeight() > height {
+				return height, nil
+			}
+		}
+		if timeouts >= MaxTimeoutsPerStep {
+			return height, fmt.Errorf("cannot commit height=%v after %v timeouts", height, timeouts)
+		}
+
+		// Timeouts are handled asynchronously by the Replicas, so wait for
+		// them to broadcast their next Messages
+		network.clock.Advance(network.timeout)
+		for i := 0; i < 100 && network.isQueueEmpty(); i++ {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// CommittedBlocks returns the block committed by every Replica at the height.
+// Replicas that have not committed the height return an invalid block.
+func (network *ReplicaNetwork) CommittedBlocks(height block.Height) []block.Block {
+	blocks := make([]block.Block, len(network.Stores))
+	for i, store := range network.Stores {
+		b, ok := store.Blockchain(network.Shard).BlockAtHeight(height)
+		if !ok {
+			b = block.InvalidBlock
+		}
+		blocks[i] = b
+	}
+	return blocks
+}
+
+// deliver the oldest queued Message to every Replica. It returns false if
+// there are no queued Messages.
+func (network *ReplicaNetwork) deliver() bool {
+	network.mu.Lock()
+	if len(network.queue) == 0 {
+		network.mu.Unlock()
+		return false
+	}
+	m := network.queue[0]
+	network.queue = network.queue[1:]
+	network.mu.Unlock()
+
+	// Replicas broadcast while handling Messages, so the queue must not be
+	// locked during delivery
+	for i := range network.Replicas {
+		network.Replicas[i].HandleMessage(m)
+	}
+	return true
+}
+
+func (network *ReplicaNetwork) isQueueEmpty() bool {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	return len(network.queue) == 0
+}
+
+// lowestHeight returns the lowest current height of the Honest Replicas.
+func (network *ReplicaNetwork) lowestHeight() block.Height {
+	// Replicas broadcast while holding their own locks, so the network must
+	// not be locked while querying them
+	network.mu.Lock()
+	behaviors := make([]Behavior, len(network.behaviors))
+	copy(behaviors, network.behaviors)
+	network.mu.Unlock()
+
+	height := block.InvalidHeight
+	for i := range network.Replicas {
+		if behaviors[i] != Honest {
+			continue
+		}
+		if h := network.Replicas[i].CurrentHeight(); height == block.InvalidHeight || h < height {
+			height = h
+		}
+	}
+	return height
+}
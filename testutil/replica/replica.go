@@ -135,6 +135,12 @@ func (observer *MockObserver) IsSignatory(replica.Shard) bool {
 func (observer *MockObserver) DidReceiveSufficientNilPrevotes(process.Messages, int) {
 }
 
+func (observer *MockObserver) DidBecomeProposer(block.Height, block.Round, replica.Shard) {
+}
+
+func (observer *MockObserver) DidFailToCommitBlock(block.Height, replica.Shard, error) {
+}
+
 type latestMessages struct {
 	Mu        *sync.RWMutex
 	Height    block.Height